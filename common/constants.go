@@ -129,6 +129,9 @@ const (
 	RequestIdKey = "X-Oneapi-Request-Id"
 )
 
+// SessionKeyMFA 会话是否经过两步验证或通行密钥登录
+const SessionKeyMFA = "mfa"
+
 const (
 	RoleGuestUser  = 0
 	RoleCommonUser = 1
@@ -193,10 +196,21 @@ const (
 	ChannelStatusEnabled          = 1 // don't use 0, 0 is the default value!
 	ChannelStatusManuallyDisabled = 2 // also don't use 0
 	ChannelStatusAutoDisabled     = 3
+	ChannelStatusDraining         = 4 // 排空中：不再分配新请求，进行中的请求正常完成
 )
 
 const (
 	TopUpStatusPending = "pending"
 	TopUpStatusSuccess = "success"
 	TopUpStatusExpired = "expired"
+	// 以下状态由支付订单状态机使用
+	TopUpStatusCancelled = "cancelled"
+	TopUpStatusFailed    = "failed"
+	TopUpStatusRefunded  = "refunded"
+)
+
+const (
+	RefundStatusPending  = "pending"
+	RefundStatusApproved = "approved"
+	RefundStatusRejected = "rejected"
 )
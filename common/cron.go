@@ -0,0 +1,130 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule 标准 5 段 cron 表达式：分 时 日 月 周，支持 *、列表、范围与步长，周日可写作 0 或 7
+type CronSchedule struct {
+	minute [60]bool
+	hour   [24]bool
+	dom    [32]bool
+	month  [13]bool
+	dow    [7]bool
+	// 日与周都有限制时按任一匹配计算，与标准 cron 一致
+	domAny bool
+	dowAny bool
+}
+
+func ParseCronExpression(expr string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron 表达式应为 5 段（分 时 日 月 周）：%s", expr)
+	}
+	schedule := &CronSchedule{
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}
+	if err := parseCronField(fields[0], 0, 59, schedule.minute[:]); err != nil {
+		return nil, err
+	}
+	if err := parseCronField(fields[1], 0, 23, schedule.hour[:]); err != nil {
+		return nil, err
+	}
+	if err := parseCronField(fields[2], 1, 31, schedule.dom[:]); err != nil {
+		return nil, err
+	}
+	if err := parseCronField(fields[3], 1, 12, schedule.month[:]); err != nil {
+		return nil, err
+	}
+	var dow [8]bool
+	if err := parseCronField(fields[4], 0, 7, dow[:]); err != nil {
+		return nil, err
+	}
+	copy(schedule.dow[:], dow[:7])
+	if dow[7] {
+		schedule.dow[0] = true
+	}
+	return schedule, nil
+}
+
+func parseCronField(field string, min int, max int, values []bool) error {
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			rangePart = part[:idx]
+			s, err := strconv.Atoi(part[idx+1:])
+			if err != nil || s <= 0 {
+				return fmt.Errorf("cron 步长无效：%s", part)
+			}
+			step = s
+		}
+		start, end := min, max
+		if rangePart != "*" {
+			if idx := strings.Index(rangePart, "-"); idx >= 0 {
+				var err1, err2 error
+				start, err1 = strconv.Atoi(rangePart[:idx])
+				end, err2 = strconv.Atoi(rangePart[idx+1:])
+				if err1 != nil || err2 != nil {
+					return fmt.Errorf("cron 范围无效：%s", part)
+				}
+			} else {
+				v, err := strconv.Atoi(rangePart)
+				if err != nil {
+					return fmt.Errorf("cron 取值无效：%s", part)
+				}
+				start = v
+				if step == 1 {
+					end = v
+				}
+			}
+		}
+		if start < min || end > max || start > end {
+			return fmt.Errorf("cron 取值超出范围 %d-%d：%s", min, max, part)
+		}
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+	return nil
+}
+
+// Matches 判断时间所在的分钟是否匹配
+func (s *CronSchedule) Matches(t time.Time) bool {
+	if !s.minute[t.Minute()] || !s.hour[t.Hour()] || !s.month[int(t.Month())] {
+		return false
+	}
+	domMatch := s.dom[t.Day()]
+	dowMatch := s.dow[int(t.Weekday())]
+	if s.domAny || s.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Prev 返回 (t-within, t] 内最近一次触发的时间，没有时返回零值
+func (s *CronSchedule) Prev(t time.Time, within time.Duration) time.Time {
+	earliest := t.Add(-within)
+	for current := t.Truncate(time.Minute); current.After(earliest); current = current.Add(-time.Minute) {
+		if s.Matches(current) {
+			return current
+		}
+	}
+	return time.Time{}
+}
+
+// Next 返回 t 之后一年内下一次触发的时间，没有时返回零值
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	current := t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(1, 0, 0)
+	for current.Before(end) {
+		if s.Matches(current) {
+			return current
+		}
+		current = current.Add(time.Minute)
+	}
+	return time.Time{}
+}
@@ -16,13 +16,17 @@ var (
 	PrintVersion = flag.Bool("version", false, "print version and exit")
 	PrintHelp    = flag.Bool("help", false, "print help and exit")
 	LogDir       = flag.String("log-dir", "./logs", "specify the log directory")
+	// RotateChannelKeys 使用当前主密钥重新加密所有渠道密钥后退出
+	RotateChannelKeys = flag.Bool("rotate-channel-keys", false, "re-encrypt channel keys with the current master key and exit")
+	// ConfigBundle 启动时应用的配置包文件（YAML 或 JSON）
+	ConfigBundle = flag.String("config-bundle", "", "apply the config bundle (YAML or JSON) at startup")
 )
 
 func printHelp() {
 	fmt.Println("MIX API " + Version + " - All in one API service for OpenAI API.")
 	fmt.Println("Copyright (C) 2023 JustSong. All rights reserved.")
 	fmt.Println("GitHub: https://github.com/songquanpeng/one-api")
-	fmt.Println("Usage: one-api [--port <port>] [--log-dir <log directory>] [--version] [--help]")
+	fmt.Println("Usage: one-api [--port <port>] [--log-dir <log directory>] [--rotate-channel-keys] [--config-bundle <file>] [--version] [--help]")
 }
 
 func InitEnv() {
@@ -53,6 +57,9 @@ func InitEnv() {
 	} else {
 		CryptoSecret = SessionSecret
 	}
+	if err := InitSecretMasterKey(); err != nil {
+		log.Fatal(err)
+	}
 	if os.Getenv("SQLITE_PATH") != "" {
 		SQLitePath = os.Getenv("SQLITE_PATH")
 	}
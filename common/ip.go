@@ -0,0 +1,68 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// SplitIpRules 拆分以逗号、空白或换行分隔的 IP 与 CIDR
+func SplitIpRules(rules string) []string {
+	return strings.FieldsFunc(rules, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+}
+
+func parseIpRule(rule string) (*net.IPNet, error) {
+	if strings.Contains(rule, "/") {
+		_, ipNet, err := net.ParseCIDR(rule)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 CIDR：%s", rule)
+		}
+		return ipNet, nil
+	}
+	ip := net.ParseIP(rule)
+	if ip == nil {
+		return nil, fmt.Errorf("无效的 IP：%s", rule)
+	}
+	// 单个 IP 视为 /32 或 /128 网段
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// ValidateIpRules 校验 IP 与 CIDR 列表，返回以换行分隔的规范化结果
+func ValidateIpRules(rules string) (string, error) {
+	items := SplitIpRules(rules)
+	for _, item := range items {
+		if _, err := parseIpRule(item); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(items, "\n"), nil
+}
+
+// ParseIpRules 解析 IP 与 CIDR 列表，忽略无效项
+func ParseIpRules(rules string) []*net.IPNet {
+	items := SplitIpRules(rules)
+	ipNets := make([]*net.IPNet, 0, len(items))
+	for _, item := range items {
+		if ipNet, err := parseIpRule(item); err == nil {
+			ipNets = append(ipNets, ipNet)
+		}
+	}
+	return ipNets
+}
+
+func IpMatchRules(ip net.IP, rules []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range rules {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package common
+
+// 管理后台的细粒度权限
+const (
+	PermissionChannelRead      = "channel.read"
+	PermissionChannelWrite     = "channel.write"
+	PermissionChannelKeyReveal = "channel.key.reveal"
+	PermissionUserRead         = "user.read"
+	PermissionUserWrite        = "user.write"
+	PermissionUserQuotaAdjust  = "user.quota.adjust"
+	PermissionLogReadAll       = "log.read.all"
+	PermissionLogDelete        = "log.delete"
+	PermissionStatsRead        = "stats.read"
+	PermissionRedemptionRead   = "redemption.read"
+	PermissionRedemptionWrite  = "redemption.write"
+	PermissionBillingRead      = "billing.read"
+	PermissionBillingWrite     = "billing.write"
+	PermissionOptionRead       = "option.read"
+	PermissionOptionWrite      = "option.write"
+	PermissionRoleManage       = "role.manage"
+	PermissionAuditRead        = "audit.read"
+)
+
+var AllPermissions = []string{
+	PermissionChannelRead,
+	PermissionChannelWrite,
+	PermissionChannelKeyReveal,
+	PermissionUserRead,
+	PermissionUserWrite,
+	PermissionUserQuotaAdjust,
+	PermissionLogReadAll,
+	PermissionLogDelete,
+	PermissionStatsRead,
+	PermissionRedemptionRead,
+	PermissionRedemptionWrite,
+	PermissionBillingRead,
+	PermissionBillingWrite,
+	PermissionOptionRead,
+	PermissionOptionWrite,
+	PermissionRoleManage,
+	PermissionAuditRead,
+}
+
+// 未分配自定义角色时，管理员拥有除密钥查看、系统设置、角色管理与审计日志以外的全部权限
+var adminDefaultPermissions = []string{
+	PermissionChannelRead,
+	PermissionChannelWrite,
+	PermissionUserRead,
+	PermissionUserWrite,
+	PermissionUserQuotaAdjust,
+	PermissionLogReadAll,
+	PermissionLogDelete,
+	PermissionStatsRead,
+	PermissionRedemptionRead,
+	PermissionRedemptionWrite,
+	PermissionBillingRead,
+	PermissionBillingWrite,
+}
+
+func IsValidPermission(permission string) bool {
+	for _, p := range AllPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDefaultPermissions 返回数字权限等级对应的默认权限
+func GetDefaultPermissions(role int) []string {
+	switch {
+	case role >= RoleRootUser:
+		return AllPermissions
+	case role >= RoleAdminUser:
+		return adminDefaultPermissions
+	default:
+		return []string{}
+	}
+}
+
+// 管理令牌的权限范围：除管理权限外，还可授予以下个人接口权限
+const (
+	ScopeAll         = "*"            // 拥有用户的全部权限
+	ScopeSelfRead    = "self.read"    // 个人资料、日志、统计等只读接口
+	ScopeSelfWrite   = "self.write"   // 修改个人资料、充值等个人写操作
+	ScopeTokenManage = "token.manage" // API 令牌的查询与增删改
+)
+
+var selfScopes = []string{
+	ScopeSelfRead,
+	ScopeSelfWrite,
+	ScopeTokenManage,
+}
+
+// GetAvailableScopes 返回指定权限可授予管理令牌的全部范围
+func GetAvailableScopes(permissions []string) []string {
+	scopes := make([]string, 0, len(selfScopes)+len(permissions))
+	scopes = append(scopes, selfScopes...)
+	return append(scopes, permissions...)
+}
+
+func IsValidScope(scope string) bool {
+	return scope == ScopeAll || StringsContains(selfScopes, scope) || IsValidPermission(scope)
+}
@@ -69,6 +69,33 @@ func RedisSet(key string, value string, expiration time.Duration) error {
 	return RDB.Set(ctx, key, value, expiration).Err()
 }
 
+// RedisSetNX 仅在 key 不存在时写入，返回是否写入成功，用于跨节点租约
+func RedisSetNX(key string, value string, expiration time.Duration) (bool, error) {
+	if DebugEnabled {
+		SysLog(fmt.Sprintf("Redis SETNX: key=%s, value=%s, expiration=%v", key, value, expiration))
+	}
+	ctx := context.Background()
+	return RDB.SetNX(ctx, key, value, expiration).Result()
+}
+
+// redisCompareAndDelScript 仅在 key 的值等于 ARGV[1] 时删除，避免释放其他节点持有的租约
+var redisCompareAndDelScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisCompareAndDel 仅在 key 的值等于 value 时删除，返回是否删除
+func RedisCompareAndDel(key string, value string) (bool, error) {
+	if DebugEnabled {
+		SysLog(fmt.Sprintf("Redis COMPARE AND DEL: key=%s, value=%s", key, value))
+	}
+	ctx := context.Background()
+	deleted, err := redisCompareAndDelScript.Run(ctx, RDB, []string{key}, value).Int()
+	return deleted > 0, err
+}
+
 func RedisGet(key string) (string, error) {
 	if DebugEnabled {
 		SysLog(fmt.Sprintf("Redis GET: key=%s", key))
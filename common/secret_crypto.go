@@ -0,0 +1,239 @@
+package common
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 加密后的密钥格式：enc:v1:<主密钥编号>:<被主密钥加密的数据密钥>:<被数据密钥加密的内容>
+const encryptedSecretPrefix = "enc:v1:"
+
+const maskedSecretMarker = "****"
+
+var (
+	secretMasterKey   []byte
+	secretMasterKeyId string
+	// 轮换前的主密钥，仅用于解密
+	previousSecretMasterKeys = map[string][]byte{}
+)
+
+// parseSecretMasterKey 支持 32 字节的 base64 / hex 密钥，其他字符串通过 SHA-256 派生
+func parseSecretMasterKey(raw string) []byte {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(raw); err == nil && len(key) == 32 {
+		return key
+	}
+	if key, err := hex.DecodeString(raw); err == nil && len(key) == 32 {
+		return key
+	}
+	sum := sha256.Sum256([]byte(raw))
+	return sum[:]
+}
+
+func getSecretMasterKeyId(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// InitSecretMasterKey 从环境变量 CHANNEL_KEY_MASTER_KEY 或 CHANNEL_KEY_MASTER_KEY_FILE 指定的文件读取主密钥，
+// CHANNEL_KEY_PREVIOUS_MASTER_KEYS 以逗号分隔轮换前的主密钥
+func InitSecretMasterKey() error {
+	raw := os.Getenv("CHANNEL_KEY_MASTER_KEY")
+	if raw == "" {
+		if path := os.Getenv("CHANNEL_KEY_MASTER_KEY_FILE"); path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read master key file: %w", err)
+			}
+			raw = string(data)
+		}
+	}
+	secretMasterKey = parseSecretMasterKey(raw)
+	if secretMasterKey != nil {
+		secretMasterKeyId = getSecretMasterKeyId(secretMasterKey)
+	}
+	for _, previous := range strings.Split(os.Getenv("CHANNEL_KEY_PREVIOUS_MASTER_KEYS"), ",") {
+		if key := parseSecretMasterKey(previous); key != nil {
+			previousSecretMasterKeys[getSecretMasterKeyId(key)] = key
+		}
+	}
+	if secretMasterKey == nil {
+		SysLog("CHANNEL_KEY_MASTER_KEY not set, channel keys will be stored in plaintext")
+	}
+	return nil
+}
+
+func SecretEncryptionEnabled() bool {
+	return secretMasterKey != nil
+}
+
+func IsEncryptedSecret(s string) bool {
+	return strings.HasPrefix(s, encryptedSecretPrefix)
+}
+
+func aesGCMSeal(key []byte, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func aesGCMOpen(key []byte, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+// EncryptSecret 使用随机数据密钥加密内容，并以主密钥加密数据密钥；未配置主密钥时原样返回
+func EncryptSecret(plaintext string) (string, error) {
+	if !SecretEncryptionEnabled() || plaintext == "" || IsEncryptedSecret(plaintext) {
+		return plaintext, nil
+	}
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	payload, err := aesGCMSeal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := aesGCMSeal(secretMasterKey, dataKey)
+	if err != nil {
+		return "", err
+	}
+	return encryptedSecretPrefix + secretMasterKeyId + ":" +
+		base64.StdEncoding.EncodeToString(wrapped) + ":" +
+		base64.StdEncoding.EncodeToString(payload), nil
+}
+
+func parseEncryptedSecret(s string) (keyId string, wrapped []byte, payload []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(s, encryptedSecretPrefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, errors.New("invalid encrypted secret format")
+	}
+	if wrapped, err = base64.StdEncoding.DecodeString(parts[1]); err != nil {
+		return "", nil, nil, err
+	}
+	if payload, err = base64.StdEncoding.DecodeString(parts[2]); err != nil {
+		return "", nil, nil, err
+	}
+	return parts[0], wrapped, payload, nil
+}
+
+func getMasterKeyById(keyId string) ([]byte, error) {
+	if secretMasterKey != nil && keyId == secretMasterKeyId {
+		return secretMasterKey, nil
+	}
+	if key, ok := previousSecretMasterKeys[keyId]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("master key %s not found", keyId)
+}
+
+func unwrapDataKey(keyId string, wrapped []byte) ([]byte, error) {
+	masterKey, err := getMasterKeyById(keyId)
+	if err != nil {
+		return nil, err
+	}
+	return aesGCMOpen(masterKey, wrapped)
+}
+
+// DecryptSecret 解密 EncryptSecret 的结果，未加密的内容原样返回
+func DecryptSecret(s string) (string, error) {
+	if !IsEncryptedSecret(s) {
+		return s, nil
+	}
+	keyId, wrapped, payload, err := parseEncryptedSecret(s)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := unwrapDataKey(keyId, wrapped)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := aesGCMOpen(dataKey, payload)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// RewrapSecret 用当前主密钥重新加密数据密钥（内容密文不变），明文内容会被加密，返回是否发生变化
+func RewrapSecret(s string) (string, bool, error) {
+	if !SecretEncryptionEnabled() || s == "" {
+		return s, false, nil
+	}
+	if !IsEncryptedSecret(s) {
+		encrypted, err := EncryptSecret(s)
+		return encrypted, err == nil, err
+	}
+	keyId, wrapped, payload, err := parseEncryptedSecret(s)
+	if err != nil {
+		return "", false, err
+	}
+	if keyId == secretMasterKeyId {
+		return s, false, nil
+	}
+	dataKey, err := unwrapDataKey(keyId, wrapped)
+	if err != nil {
+		return "", false, err
+	}
+	rewrapped, err := aesGCMSeal(secretMasterKey, dataKey)
+	if err != nil {
+		return "", false, err
+	}
+	return encryptedSecretPrefix + secretMasterKeyId + ":" +
+		base64.StdEncoding.EncodeToString(rewrapped) + ":" +
+		base64.StdEncoding.EncodeToString(payload), true, nil
+}
+
+// MaskSecret 对每一行密钥保留首尾少量字符用于辨认
+func MaskSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if len(line) <= 8 {
+			lines[i] = maskedSecretMarker
+			continue
+		}
+		lines[i] = line[:3] + maskedSecretMarker + line[len(line)-4:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// IsMaskedSecret 判断是否为 MaskSecret 的输出，用于防止脱敏值被回写
+func IsMaskedSecret(s string) bool {
+	return strings.Contains(s, maskedSecretMarker)
+}
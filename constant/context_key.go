@@ -9,13 +9,17 @@ const (
 	/* token related keys */
 	ContextKeyTokenUnlimited         ContextKey = "token_unlimited_quota"
 	ContextKeyTokenKey               ContextKey = "token_key"
+	ContextKeyTokenKeyHash           ContextKey = "token_key_hash" // 轮换宽限期内为新令牌的哈希
 	ContextKeyTokenId                ContextKey = "token_id"
 	ContextKeyTokenGroup             ContextKey = "token_group"
 	ContextKeyTokenAllowIps          ContextKey = "allow_ips"
+	ContextKeyTokenDenyIps           ContextKey = "deny_ips"
+	ContextKeyTokenCallbackUrl       ContextKey = "token_callback_url"
 	ContextKeyTokenSpecificChannelId ContextKey = "specific_channel_id"
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
 	ContextKeyTokenChannelTag        ContextKey = "token_channel_tag" // 添加渠道标签上下文键
+	ContextKeyTokenOrganizationId    ContextKey = "token_organization_id"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -42,4 +46,8 @@ const (
 	ContextKeyUserGroup   ContextKey = "user_group"
 	ContextKeyUsingGroup  ContextKey = "group"
 	ContextKeyUserName    ContextKey = "username"
+
+	/* admin related keys */
+	ContextKeyAuditRecorded   ContextKey = "audit_recorded"
+	ContextKeyManagementToken ContextKey = "management_token"
 )
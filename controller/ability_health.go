@@ -0,0 +1,228 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+// abilityHealthCell 健康矩阵中单个渠道×模型的状态
+type abilityHealthCell struct {
+	// 与渠道状态取值一致：1 启用，2 手动禁用，3 自动禁用
+	Status        int    `json:"status"`
+	StatusReason  string `json:"status_reason,omitempty"`
+	StatusTime    int64  `json:"status_time,omitempty"`
+	FailCount     int    `json:"fail_count"`
+	LastError     string `json:"last_error,omitempty"`
+	LastErrorTime int64  `json:"last_error_time,omitempty"`
+	// 最近一次测试结果，未测试过时为空
+	TestSuccess  *bool  `json:"test_success,omitempty"`
+	ResponseTime int    `json:"response_time,omitempty"`
+	TestTime     int64  `json:"test_time,omitempty"`
+	TestMessage  string `json:"test_message,omitempty"`
+}
+
+type abilityHealthRow struct {
+	ChannelId     int                           `json:"channel_id"`
+	ChannelName   string                        `json:"channel_name"`
+	ChannelType   int                           `json:"channel_type"`
+	ChannelStatus int                           `json:"channel_status"`
+	Tag           *string                       `json:"tag"`
+	Models        map[string]*abilityHealthCell `json:"models"`
+}
+
+// GetAbilityHealthMatrix 返回渠道×模型的健康矩阵，可按分组、模型、标签与渠道过滤
+func GetAbilityHealthMatrix(c *gin.Context) {
+	group := c.Query("group")
+	modelFilter := c.Query("model")
+	tag := c.Query("tag")
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+
+	channels, err := model.GetAllChannels(0, 0, true, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channels = lo.Filter(channels, func(channel *model.Channel, _ int) bool {
+		if channelId != 0 && channel.Id != channelId {
+			return false
+		}
+		if tag != "" && (channel.Tag == nil || *channel.Tag != tag) {
+			return false
+		}
+		if group != "" && !lo.Contains(channel.GetGroups(), group) {
+			return false
+		}
+		if modelFilter != "" && !lo.Contains(channel.GetModels(), modelFilter) {
+			return false
+		}
+		return true
+	})
+	channelIds := lo.Map(channels, func(channel *model.Channel, _ int) int {
+		return channel.Id
+	})
+	if len(channelIds) == 0 {
+		common.ApiSuccess(c, gin.H{
+			"models":   []string{},
+			"channels": []*abilityHealthRow{},
+		})
+		return
+	}
+	healths, err := model.GetAbilityHealths(channelIds)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	testResults, err := model.GetChannelTestResultsByChannelIds(channelIds)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	healthMap := make(map[string]*model.AbilityHealth, len(healths))
+	for _, health := range healths {
+		healthMap[fmt.Sprintf("%d|%s", health.ChannelId, health.Model)] = health
+	}
+	testResultMap := make(map[string]*model.ChannelTestResult, len(testResults))
+	for _, result := range testResults {
+		testResultMap[fmt.Sprintf("%d|%s", result.ChannelId, result.Model)] = result
+	}
+
+	modelSet := make(map[string]bool)
+	rows := make([]*abilityHealthRow, 0, len(channels))
+	for _, channel := range channels {
+		row := &abilityHealthRow{
+			ChannelId:     channel.Id,
+			ChannelName:   channel.Name,
+			ChannelType:   channel.Type,
+			ChannelStatus: channel.Status,
+			Tag:           channel.Tag,
+			Models:        make(map[string]*abilityHealthCell),
+		}
+		for _, modelName := range channel.GetModels() {
+			if modelFilter != "" && modelName != modelFilter {
+				continue
+			}
+			modelSet[modelName] = true
+			cell := &abilityHealthCell{Status: common.ChannelStatusEnabled}
+			key := fmt.Sprintf("%d|%s", channel.Id, modelName)
+			if health, ok := healthMap[key]; ok {
+				cell.Status = health.Status
+				cell.StatusReason = health.StatusReason
+				cell.StatusTime = health.StatusTime
+				cell.FailCount = health.FailCount
+				cell.LastError = health.LastError
+				cell.LastErrorTime = health.LastErrorTime
+			}
+			if result, ok := testResultMap[key]; ok {
+				success := result.Success
+				cell.TestSuccess = &success
+				cell.ResponseTime = result.ResponseTime
+				cell.TestTime = result.TestTime
+				cell.TestMessage = result.Message
+			}
+			row.Models[modelName] = cell
+		}
+		rows = append(rows, row)
+	}
+	models := lo.Keys(modelSet)
+	sort.Strings(models)
+	common.ApiSuccess(c, gin.H{
+		"models":   models,
+		"channels": rows,
+	})
+}
+
+type updateAbilityHealthRequest struct {
+	ChannelId int    `json:"channel_id"`
+	Model     string `json:"model"`
+	Enabled   bool   `json:"enabled"`
+}
+
+// UpdateAbilityHealth 手动启用或禁用渠道的单个模型，手动禁用的模型不会被自动启用
+func UpdateAbilityHealth(c *gin.Context) {
+	var req updateAbilityHealthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channel, err := model.GetChannelById(req.ChannelId, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !lo.Contains(channel.GetModels(), req.Model) {
+		common.ApiErrorMsg(c, "渠道不包含该模型")
+		return
+	}
+	origin, _ := model.GetAbilityHealth(req.ChannelId, req.Model)
+	status := common.ChannelStatusManuallyDisabled
+	if req.Enabled {
+		status = common.ChannelStatusEnabled
+	}
+	if _, err = model.UpdateAbilityHealthStatus(req.ChannelId, req.Model, status, ""); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	updated, _ := model.GetAbilityHealth(req.ChannelId, req.Model)
+	service.RecordAudit(c, "channel.ability.update", "channel", strconv.Itoa(req.ChannelId), origin, updated)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// AutomaticallyRetestDisabledAbilities 定时重新测试自动禁用的模型，测试通过后重新启用
+func AutomaticallyRetestDisabledAbilities() {
+	for {
+		time.Sleep(time.Minute)
+		healthSetting := operation_setting.GetModelHealthSetting()
+		if !healthSetting.Enabled || healthSetting.RetestIntervalMinutes <= 0 || !common.AutomaticEnableChannelEnabled {
+			continue
+		}
+		before := common.GetTimestamp() - int64(healthSetting.RetestIntervalMinutes)*60
+		healths, err := model.GetAbilityHealthsToRetest(before, 50)
+		if err != nil {
+			common.SysError("failed to get disabled abilities: " + err.Error())
+			continue
+		}
+		for _, health := range healths {
+			retestAbility(health)
+			time.Sleep(common.RequestInterval)
+		}
+	}
+}
+
+func retestAbility(health *model.AbilityHealth) {
+	channel, err := model.GetChannelById(health.ChannelId, true)
+	if err != nil {
+		return
+	}
+	// 渠道已不再包含该模型
+	if !lo.Contains(channel.GetModels(), health.Model) {
+		_ = model.DeleteAbilityHealth(health.ChannelId, health.Model)
+		return
+	}
+	model.UpdateAbilityRetestTime(health.ChannelId, health.Model)
+	result, _, _ := runChannelTest(channel, health.Model, "")
+	if result.localErr == nil && result.newAPIError == nil {
+		model.RecordAbilitySuccess(channel.Id, health.Model)
+		service.EnableAbility(channel.Id, channel.Name, health.Model)
+		return
+	}
+	message := ""
+	if result.newAPIError != nil {
+		message = result.newAPIError.Error()
+	} else {
+		message = result.localErr.Error()
+	}
+	model.RecordAbilityError(channel.Id, health.Model, message)
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"bytes"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+// 单次导出的最大条数
+const auditLogExportLimit = 10000
+
+func getAuditLogFilter(c *gin.Context) model.AuditLogFilter {
+	actorId, _ := strconv.Atoi(c.Query("actor_id"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	return model.AuditLogFilter{
+		ActorId:        actorId,
+		Action:         c.Query("action"),
+		TargetType:     c.Query("target_type"),
+		TargetId:       c.Query("target_id"),
+		RequestId:      c.Query("request_id"),
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+	}
+}
+
+func GetAuditLogs(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	logs, total, err := model.GetAuditLogs(getAuditLogFilter(c), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(logs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// ExportAuditLogs 按筛选条件导出审计日志为 Excel 文件
+func ExportAuditLogs(c *gin.Context) {
+	logs, _, err := model.GetAuditLogs(getAuditLogFilter(c), 0, auditLogExportLimit)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	f := excelize.NewFile()
+	defer func() {
+		if err := f.Close(); err != nil {
+			common.SysError("Error closing Excel file: " + err.Error())
+		}
+	}()
+	sheetName := "AuditLogs"
+	f.SetSheetName("Sheet1", sheetName)
+
+	headers := []string{
+		"ID", "时间", "操作人ID", "操作人", "操作人权限", "操作", "对象类型", "对象ID",
+		"变更前", "变更后", "IP", "User-Agent", "请求ID", "状态码",
+	}
+	for i, header := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(sheetName, cell, header)
+	}
+	for i, log := range logs {
+		data := []interface{}{
+			log.Id,
+			time.Unix(log.CreatedAt, 0).Format("2006-01-02 15:04:05"),
+			log.ActorId,
+			log.ActorName,
+			log.ActorRole,
+			log.Action,
+			log.TargetType,
+			log.TargetId,
+			log.Before,
+			log.After,
+			log.Ip,
+			log.UserAgent,
+			log.RequestId,
+			log.StatusCode,
+		}
+		for j, value := range data {
+			cell, _ := excelize.CoordinatesToCellName(j+1, i+2)
+			f.SetCellValue(sheetName, cell, value)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename=audit_logs.xlsx")
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", buf.Bytes())
+}
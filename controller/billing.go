@@ -3,6 +3,7 @@ package controller
 import (
 	"github.com/gin-gonic/gin"
 	"one-api/common"
+	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
 )
@@ -19,6 +20,9 @@ func GetSubscription(c *gin.Context) {
 		expiredTime = token.ExpiredTime
 		remainQuota = token.RemainQuota
 		usedQuota = token.UsedQuota
+	} else if orgId := common.GetContextKeyInt(c, constant.ContextKeyTokenOrganizationId); orgId != 0 {
+		// 组织令牌按组织额度池与成员用量计算
+		remainQuota, usedQuota, err = getOrganizationBillingQuota(orgId, c.GetInt("id"))
 	} else {
 		userId := c.GetInt("id")
 		remainQuota, err = model.GetUserQuota(userId, false)
@@ -65,6 +69,8 @@ func GetUsage(c *gin.Context) {
 		tokenId := c.GetInt("token_id")
 		token, err = model.GetTokenById(tokenId)
 		quota = token.UsedQuota
+	} else if orgId := common.GetContextKeyInt(c, constant.ContextKeyTokenOrganizationId); orgId != 0 {
+		_, quota, err = getOrganizationBillingQuota(orgId, c.GetInt("id"))
 	} else {
 		userId := c.GetInt("id")
 		quota, err = model.GetUserUsedQuota(userId)
@@ -90,3 +96,16 @@ func GetUsage(c *gin.Context) {
 	c.JSON(200, usage)
 	return
 }
+
+// getOrganizationBillingQuota 返回成员通过组织令牌的剩余可用额度与已用额度
+func getOrganizationBillingQuota(orgId int, userId int) (remainQuota int, usedQuota int, err error) {
+	remainQuota, err = model.GetBillingQuota(userId, orgId)
+	if err != nil {
+		return 0, 0, err
+	}
+	member, err := model.GetOrganizationMember(orgId, userId)
+	if err != nil {
+		return 0, 0, err
+	}
+	return remainQuota, member.UsedQuota, nil
+}
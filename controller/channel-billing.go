@@ -11,7 +11,7 @@ import (
 	"one-api/model"
 	"one-api/service"
 	"one-api/setting"
-	"one-api/types"
+	"sort"
 	"strconv"
 	"time"
 
@@ -165,7 +165,6 @@ func updateChannelCloseAIBalance(channel *model.Channel) (float64, error) {
 	if err != nil {
 		return 0, err
 	}
-	channel.UpdateBalance(response.TotalAvailable)
 	return response.TotalAvailable, nil
 }
 
@@ -187,7 +186,6 @@ func updateChannelOpenAISBBalance(channel *model.Channel) (float64, error) {
 	if err != nil {
 		return 0, err
 	}
-	channel.UpdateBalance(balance)
 	return balance, nil
 }
 
@@ -207,7 +205,6 @@ func updateChannelAIProxyBalance(channel *model.Channel) (float64, error) {
 	if !response.Success {
 		return 0, fmt.Errorf("code: %d, message: %s", response.ErrorCode, response.Message)
 	}
-	channel.UpdateBalance(response.Data.TotalPoints)
 	return response.Data.TotalPoints, nil
 }
 
@@ -223,7 +220,6 @@ func updateChannelAPI2GPTBalance(channel *model.Channel) (float64, error) {
 	if err != nil {
 		return 0, err
 	}
-	channel.UpdateBalance(response.TotalRemaining)
 	return response.TotalRemaining, nil
 }
 
@@ -245,7 +241,6 @@ func updateChannelSiliconFlowBalance(channel *model.Channel) (float64, error) {
 	if err != nil {
 		return 0, err
 	}
-	channel.UpdateBalance(balance)
 	return balance, nil
 }
 
@@ -274,7 +269,6 @@ func updateChannelDeepSeekBalance(channel *model.Channel) (float64, error) {
 	if err != nil {
 		return 0, err
 	}
-	channel.UpdateBalance(balance)
 	return balance, nil
 }
 
@@ -289,7 +283,6 @@ func updateChannelAIGC2DBalance(channel *model.Channel) (float64, error) {
 	if err != nil {
 		return 0, err
 	}
-	channel.UpdateBalance(response.TotalAvailable)
 	return response.TotalAvailable, nil
 }
 
@@ -305,7 +298,6 @@ func updateChannelOpenRouterBalance(channel *model.Channel) (float64, error) {
 		return 0, err
 	}
 	balance := response.Data.TotalCredits - response.Data.TotalUsage
-	channel.UpdateBalance(balance)
 	return balance, nil
 }
 
@@ -339,43 +331,12 @@ func updateChannelMoonshotBalance(channel *model.Channel) (float64, error) {
 	}
 	availableBalanceCny := response.Data.AvailableBalance
 	availableBalanceUsd := decimal.NewFromFloat(availableBalanceCny).Div(decimal.NewFromFloat(setting.Price)).InexactFloat64()
-	channel.UpdateBalance(availableBalanceUsd)
 	return availableBalanceUsd, nil
 }
 
-func updateChannelBalance(channel *model.Channel) (float64, error) {
-	baseURL := constant.ChannelBaseURLs[channel.Type]
-	if channel.GetBaseURL() == "" {
-		channel.BaseURL = &baseURL
-	}
-	switch channel.Type {
-	case constant.ChannelTypeOpenAI:
-		if channel.GetBaseURL() != "" {
-			baseURL = channel.GetBaseURL()
-		}
-	case constant.ChannelTypeAzure:
-		return 0, errors.New("尚未实现")
-	case constant.ChannelTypeCustom:
-		baseURL = channel.GetBaseURL()
-	//case common.ChannelTypeOpenAISB:
-	//	return updateChannelOpenAISBBalance(channel)
-	case constant.ChannelTypeAIProxy:
-		return updateChannelAIProxyBalance(channel)
-	case constant.ChannelTypeAPI2GPT:
-		return updateChannelAPI2GPTBalance(channel)
-	case constant.ChannelTypeAIGC2D:
-		return updateChannelAIGC2DBalance(channel)
-	case constant.ChannelTypeSiliconFlow:
-		return updateChannelSiliconFlowBalance(channel)
-	case constant.ChannelTypeDeepSeek:
-		return updateChannelDeepSeekBalance(channel)
-	case constant.ChannelTypeOpenRouter:
-		return updateChannelOpenRouterBalance(channel)
-	case constant.ChannelTypeMoonshot:
-		return updateChannelMoonshotBalance(channel)
-	default:
-		return 0, errors.New("尚未实现")
-	}
+// updateChannelOpenAIBalance 通过 OpenAI 兼容的账单接口查询余额
+func updateChannelOpenAIBalance(channel *model.Channel) (float64, error) {
+	baseURL := channel.GetBaseURL()
 	url := fmt.Sprintf("%s/v1/dashboard/billing/subscription", baseURL)
 
 	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
@@ -404,7 +365,38 @@ func updateChannelBalance(channel *model.Channel) (float64, error) {
 		return 0, err
 	}
 	balance := subscription.HardLimitUSD - usage.TotalUsage/100
-	channel.UpdateBalance(balance)
+	return balance, nil
+}
+
+func init() {
+	// 新增余额查询时在此注册渠道类型，无需修改 updateChannelBalance
+	service.RegisterBalanceFetcher(constant.ChannelTypeOpenAI, service.BalanceFetcherFunc(updateChannelOpenAIBalance))
+	service.RegisterBalanceFetcher(constant.ChannelTypeCustom, service.BalanceFetcherFunc(updateChannelOpenAIBalance))
+	//service.RegisterBalanceFetcher(constant.ChannelTypeOpenAISB, service.BalanceFetcherFunc(updateChannelOpenAISBBalance))
+	service.RegisterBalanceFetcher(constant.ChannelTypeAIProxy, service.BalanceFetcherFunc(updateChannelAIProxyBalance))
+	service.RegisterBalanceFetcher(constant.ChannelTypeAPI2GPT, service.BalanceFetcherFunc(updateChannelAPI2GPTBalance))
+	service.RegisterBalanceFetcher(constant.ChannelTypeAIGC2D, service.BalanceFetcherFunc(updateChannelAIGC2DBalance))
+	service.RegisterBalanceFetcher(constant.ChannelTypeSiliconFlow, service.BalanceFetcherFunc(updateChannelSiliconFlowBalance))
+	service.RegisterBalanceFetcher(constant.ChannelTypeDeepSeek, service.BalanceFetcherFunc(updateChannelDeepSeekBalance))
+	service.RegisterBalanceFetcher(constant.ChannelTypeOpenRouter, service.BalanceFetcherFunc(updateChannelOpenRouterBalance))
+	service.RegisterBalanceFetcher(constant.ChannelTypeMoonshot, service.BalanceFetcherFunc(updateChannelMoonshotBalance))
+}
+
+// updateChannelBalance 查询渠道余额，保存余额历史并按阈值提醒或禁用渠道
+func updateChannelBalance(channel *model.Channel) (float64, error) {
+	baseURL := constant.ChannelBaseURLs[channel.Type]
+	if channel.GetBaseURL() == "" {
+		channel.BaseURL = &baseURL
+	}
+	fetcher := service.GetBalanceFetcher(channel.Type)
+	if fetcher == nil {
+		return 0, errors.New("尚未实现")
+	}
+	balance, err := fetcher.FetchBalance(channel)
+	if err != nil {
+		return 0, err
+	}
+	service.ApplyChannelBalance(channel, balance)
 	return balance, nil
 }
 
@@ -454,17 +446,14 @@ func updateAllChannelsBalance() error {
 		//if channel.Type != common.ChannelTypeOpenAI && channel.Type != common.ChannelTypeCustom {
 		//	continue
 		//}
-		balance, err := updateChannelBalance(channel)
+		// 余额不足时由 updateChannelBalance 按阈值禁用渠道
+		_, err := updateChannelBalance(channel)
 		if err != nil {
 			continue
-		} else {
-			// err is nil & balance <= 0 means quota is used up
-			if balance <= 0 {
-				service.DisableChannel(*types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, "", channel.GetAutoBan()), "余额不足")
-			}
 		}
 		time.Sleep(common.RequestInterval)
 	}
+	service.CleanChannelBalanceHistory()
 	return nil
 }
 
@@ -490,3 +479,91 @@ func AutomaticallyUpdateChannels(frequency int) {
 		common.SysLog("channels update done")
 	}
 }
+
+// GetChannelBalanceHistory 返回渠道最近 days 天（默认 7 天）的余额记录与消耗预测
+func GetChannelBalanceHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channel, err := model.GetChannelById(id, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	days, _ := strconv.Atoi(c.Query("days"))
+	if days <= 0 {
+		days = 7
+	}
+	histories, err := model.GetChannelBalanceHistory(channel.Id, common.GetTimestamp()-int64(days)*24*3600)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	forecast, err := service.GetChannelBalanceForecast(channel.Id, channel.Balance)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"balance":              channel.Balance,
+		"balance_updated_time": channel.BalanceUpdatedTime,
+		"histories":            histories,
+		"forecast":             forecast,
+	})
+}
+
+type channelBalanceForecastItem struct {
+	Id                 int     `json:"id"`
+	Name               string  `json:"name"`
+	Type               int     `json:"type"`
+	Status             int     `json:"status"`
+	Balance            float64 `json:"balance"`
+	BalanceUpdatedTime int64   `json:"balance_updated_time"`
+	WarnThreshold      float64 `json:"warn_threshold"`
+	DisableThreshold   float64 `json:"disable_threshold"`
+	BalanceAlert       any     `json:"balance_alert"`
+	service.ChannelBalanceForecast
+}
+
+// GetChannelBalanceForecasts 返回支持余额查询的渠道的余额与消耗预测，按预计剩余天数升序
+func GetChannelBalanceForecasts(c *gin.Context) {
+	channels, err := model.GetAllChannels(0, 0, true, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	items := make([]channelBalanceForecastItem, 0)
+	for _, channel := range channels {
+		if channel.BalanceUpdatedTime == 0 || service.GetBalanceFetcher(channel.Type) == nil {
+			continue
+		}
+		forecast, err := service.GetChannelBalanceForecast(channel.Id, channel.Balance)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		warnThreshold, disableThreshold := service.GetChannelBalanceThresholds(channel)
+		items = append(items, channelBalanceForecastItem{
+			Id:                     channel.Id,
+			Name:                   channel.Name,
+			Type:                   channel.Type,
+			Status:                 channel.Status,
+			Balance:                channel.Balance,
+			BalanceUpdatedTime:     channel.BalanceUpdatedTime,
+			WarnThreshold:          warnThreshold,
+			DisableThreshold:       disableThreshold,
+			BalanceAlert:           channel.GetOtherInfo()["balance_alert"],
+			ChannelBalanceForecast: forecast,
+		})
+	}
+	// 无法预测的渠道排在最后
+	sort.SliceStable(items, func(i, j int) bool {
+		if (items[i].DaysLeft < 0) != (items[j].DaysLeft < 0) {
+			return items[j].DaysLeft < 0
+		}
+		return items[i].DaysLeft < items[j].DaysLeft
+	})
+	common.ApiSuccess(c, items)
+}
@@ -2,11 +2,13 @@ package controller
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"math"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -16,10 +18,12 @@ import (
 	"one-api/middleware"
 	"one-api/model"
 	"one-api/relay"
+	relaychannel "one-api/relay/channel"
 	relaycommon "one-api/relay/common"
-	relayconstant "one-api/relay/constant"
 	"one-api/relay/helper"
 	"one-api/service"
+	"one-api/setting/model_setting"
+	"one-api/setting/operation_setting"
 	"one-api/types"
 	"strconv"
 	"strings"
@@ -35,54 +39,115 @@ type testResult struct {
 	context     *gin.Context
 	localErr    error
 	newAPIError *types.NewAPIError
+	// 实际测试的模型与方式，任务平台渠道只探测接口，模型为空
+	model string
+	mode  string
 }
 
-func testChannel(channel *model.Channel, testModel string) testResult {
-	tik := time.Now()
-	if channel.Type == constant.ChannelTypeMidjourney {
-		return testResult{
-			localErr:    errors.New("midjourney channel test is not supported"),
-			newAPIError: nil,
-		}
+const channelTestModeProbe = "probe"
+
+// 各测试方式对应的请求路径，用于确定 RelayMode
+var testModeRequestPaths = map[string]string{
+	model_setting.ModelTestModeChat:      "/v1/chat/completions",
+	model_setting.ModelTestModeEmbedding: "/v1/embeddings",
+	model_setting.ModelTestModeRerank:    "/v1/rerank",
+	model_setting.ModelTestModeImage:     "/v1/images/generations",
+	model_setting.ModelTestModeTTS:       "/v1/audio/speech",
+	model_setting.ModelTestModeSTT:       "/v1/audio/transcriptions",
+}
+
+// getChannelTestModel 未指定模型时优先使用渠道的测试模型，其次为渠道的第一个模型
+func getChannelTestModel(channel *model.Channel) string {
+	if channel.TestModel != nil && *channel.TestModel != "" {
+		return *channel.TestModel
 	}
-	if channel.Type == constant.ChannelTypeMidjourneyPlus {
-		return testResult{
-			localErr:    errors.New("midjourney plus channel test is not supported"),
-			newAPIError: nil,
-		}
+	if len(channel.GetModels()) > 0 {
+		return channel.GetModels()[0]
 	}
-	if channel.Type == constant.ChannelTypeSunoAPI {
-		return testResult{
-			localErr:    errors.New("suno channel test is not supported"),
-			newAPIError: nil,
+	return "gpt-4o-mini"
+}
+
+// getModelTestMode 优先使用模型配置的测试方式，未配置时按模型支持的端点类型确定，
+// 最后按模型名称识别 Embedding 模型
+func getModelTestMode(channel *model.Channel, testModel string) string {
+	if mode := model_setting.GetModelTestMode(testModel); mode != "" {
+		return mode
+	}
+	if channel.Type == constant.ChannelTypeMokaAI {
+		return model_setting.ModelTestModeEmbedding
+	}
+	for _, endpointType := range common.GetEndpointTypesByChannelType(channel.Type, testModel) {
+		switch endpointType {
+		case constant.EndpointTypeJinaRerank:
+			return model_setting.ModelTestModeRerank
+		case constant.EndpointTypeImageGeneration:
+			return model_setting.ModelTestModeImage
 		}
 	}
-	if channel.Type == constant.ChannelTypeKling {
-		return testResult{
-			localErr:    errors.New("kling channel test is not supported"),
-			newAPIError: nil,
-		}
+	if strings.Contains(strings.ToLower(testModel), "embed") ||
+		strings.HasPrefix(testModel, "m3e") || // m3e 系列模型
+		strings.Contains(testModel, "bge-") { // bge 系列模型
+		return model_setting.ModelTestModeEmbedding
 	}
-	if channel.Type == constant.ChannelTypeJimeng {
+	return model_setting.ModelTestModeChat
+}
+
+// probeTaskChannel 任务平台渠道调用平台注册的轻量接口测试，不提交任务
+func probeTaskChannel(channel *model.Channel, provider *relaychannel.TaskProvider) testResult {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = &http.Request{Header: make(http.Header)}
+	result := testResult{context: c, mode: channelTestModeProbe}
+	if provider.Probe == nil {
+		result.localErr = fmt.Errorf("%s channel test is not supported", provider.Platform)
+		return result
+	}
+	key, _, newAPIError := channel.GetNextEnabledKey()
+	if newAPIError != nil {
+		result.localErr = newAPIError
+		result.newAPIError = newAPIError
+		return result
+	}
+	baseURL := constant.ChannelBaseURLs[channel.Type]
+	if channel.GetBaseURL() != "" {
+		baseURL = channel.GetBaseURL()
+	}
+	common.SetContextKey(c, constant.ContextKeyChannelKey, key)
+	if err := provider.Probe(baseURL, key); err != nil {
+		result.localErr = err
+		result.newAPIError = types.NewError(err, types.ErrorCodeBadResponse)
+	}
+	return result
+}
+
+func testChannel(channel *model.Channel, testModel string, testMode string) testResult {
+	tik := time.Now()
+	if provider := relaychannel.GetTaskProviderByChannelType(channel.Type); provider != nil {
+		return probeTaskChannel(channel, provider)
+	}
+	if testModel == "" {
+		testModel = getChannelTestModel(channel)
+	}
+	if testMode == "" {
+		testMode = getModelTestMode(channel, testModel)
+	}
+	requestPath, ok := testModeRequestPaths[testMode]
+	if !ok {
 		return testResult{
-			localErr:    errors.New("jimeng channel test is not supported"),
-			newAPIError: nil,
+			localErr: fmt.Errorf("invalid test mode: %s", testMode),
+			model:    testModel,
+			mode:     testMode,
 		}
 	}
+	result := testChannelModel(channel, testModel, testMode, requestPath, tik)
+	result.model = testModel
+	result.mode = testMode
+	return result
+}
+
+func testChannelModel(channel *model.Channel, testModel string, testMode string, requestPath string, tik time.Time) testResult {
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 
-	requestPath := "/v1/chat/completions"
-
-	// 先判断是否为 Embedding 模型
-	if strings.Contains(strings.ToLower(testModel), "embedding") ||
-		strings.HasPrefix(testModel, "m3e") || // m3e 系列模型
-		strings.Contains(testModel, "bge-") || // bge 系列模型
-		strings.Contains(testModel, "embed") ||
-		channel.Type == constant.ChannelTypeMokaAI { // 其他 embedding 模型
-		requestPath = "/v1/embeddings" // 修改请求路径
-	}
-
 	c.Request = &http.Request{
 		Method: "POST",
 		URL:    &url.URL{Path: requestPath}, // 使用动态路径
@@ -90,21 +155,10 @@ func testChannel(channel *model.Channel, testModel string) testResult {
 		Header: make(http.Header),
 	}
 
-	if testModel == "" {
-		if channel.TestModel != nil && *channel.TestModel != "" {
-			testModel = *channel.TestModel
-		} else {
-			if len(channel.GetModels()) > 0 {
-				testModel = channel.GetModels()[0]
-			} else {
-				testModel = "gpt-4o-mini"
-			}
-		}
-	}
-
 	cache, err := model.GetUserCache(1)
 	if err != nil {
 		return testResult{
+			context:     c,
 			localErr:    err,
 			newAPIError: nil,
 		}
@@ -127,7 +181,24 @@ func testChannel(channel *model.Channel, testModel string) testResult {
 		}
 	}
 
-	info := relaycommon.GenRelayInfo(c)
+	rerankRequest := dto.RerankRequest{
+		Query:     "hello",
+		Documents: []any{"hello world"},
+		TopN:      1,
+	}
+	var info *relaycommon.RelayInfo
+	switch testMode {
+	case model_setting.ModelTestModeEmbedding:
+		info = relaycommon.GenRelayInfoEmbedding(c)
+	case model_setting.ModelTestModeRerank:
+		info = relaycommon.GenRelayInfoRerank(c, &rerankRequest)
+	case model_setting.ModelTestModeImage:
+		info = relaycommon.GenRelayInfoImage(c)
+	case model_setting.ModelTestModeTTS, model_setting.ModelTestModeSTT:
+		info = relaycommon.GenRelayInfoOpenAIAudio(c)
+	default:
+		info = relaycommon.GenRelayInfo(c)
+	}
 
 	err = helper.ModelMappedHelper(c, info, nil)
 	if err != nil {
@@ -153,7 +224,7 @@ func testChannel(channel *model.Channel, testModel string) testResult {
 	// 创建一个用于日志的 info 副本，移除 ApiKey
 	logInfo := *info
 	logInfo.ApiKey = ""
-	common.SysLog(fmt.Sprintf("testing channel %d with model %s , info %+v ", channel.Id, testModel, logInfo))
+	common.SysLog(fmt.Sprintf("testing channel %d with model %s (%s), info %+v ", channel.Id, testModel, testMode, logInfo))
 
 	priceData, err := helper.ModelPriceHelper(c, info, 0, int(request.MaxTokens))
 	if err != nil {
@@ -166,37 +237,70 @@ func testChannel(channel *model.Channel, testModel string) testResult {
 
 	adaptor.Init(info)
 
-	var convertedRequest any
-	// 根据 RelayMode 选择正确的转换函数
-	if info.RelayMode == relayconstant.RelayModeEmbeddings {
-		// 创建一个 EmbeddingRequest
-		embeddingRequest := dto.EmbeddingRequest{
-			Input: request.Input,
-			Model: request.Model,
+	var requestBody io.Reader
+	// 根据测试方式选择对应的转换函数
+	switch testMode {
+	case model_setting.ModelTestModeTTS, model_setting.ModelTestModeSTT:
+		audioRequest := dto.AudioRequest{
+			Model:          testModel,
+			Input:          "hi",
+			Voice:          "alloy",
+			ResponseFormat: "json",
 		}
-		// 调用专门用于 Embedding 的转换函数
-		convertedRequest, err = adaptor.ConvertEmbeddingRequest(c, info, embeddingRequest)
-	} else {
-		// 对其他所有请求类型（如 Chat），保持原有逻辑
-		convertedRequest, err = adaptor.ConvertOpenAIRequest(c, info, request)
-	}
-
-	if err != nil {
-		return testResult{
-			context:     c,
-			localErr:    err,
-			newAPIError: types.NewError(err, types.ErrorCodeConvertRequestFailed),
+		if testMode == model_setting.ModelTestModeTTS {
+			audioRequest.ResponseFormat = ""
+		} else if err = setupTestAudioForm(c, testModel); err != nil {
+			return testResult{
+				context:     c,
+				localErr:    err,
+				newAPIError: types.NewError(err, types.ErrorCodeInvalidRequest),
+			}
 		}
-	}
-	jsonData, err := json.Marshal(convertedRequest)
-	if err != nil {
-		return testResult{
-			context:     c,
-			localErr:    err,
-			newAPIError: types.NewError(err, types.ErrorCodeJsonMarshalFailed),
+		requestBody, err = adaptor.ConvertAudioRequest(c, info, audioRequest)
+		if err != nil {
+			return testResult{
+				context:     c,
+				localErr:    err,
+				newAPIError: types.NewError(err, types.ErrorCodeConvertRequestFailed),
+			}
+		}
+	default:
+		var convertedRequest any
+		switch testMode {
+		case model_setting.ModelTestModeEmbedding:
+			convertedRequest, err = adaptor.ConvertEmbeddingRequest(c, info, dto.EmbeddingRequest{
+				Input: []any{"hello world"},
+				Model: testModel,
+			})
+		case model_setting.ModelTestModeRerank:
+			rerankRequest.Model = testModel
+			convertedRequest, err = adaptor.ConvertRerankRequest(c, info.RelayMode, rerankRequest)
+		case model_setting.ModelTestModeImage:
+			convertedRequest, err = adaptor.ConvertImageRequest(c, info, dto.ImageRequest{
+				Model:  testModel,
+				Prompt: "a white cat",
+				N:      1,
+			})
+		default:
+			convertedRequest, err = adaptor.ConvertOpenAIRequest(c, info, request)
+		}
+		if err != nil {
+			return testResult{
+				context:     c,
+				localErr:    err,
+				newAPIError: types.NewError(err, types.ErrorCodeConvertRequestFailed),
+			}
+		}
+		jsonData, err := json.Marshal(convertedRequest)
+		if err != nil {
+			return testResult{
+				context:     c,
+				localErr:    err,
+				newAPIError: types.NewError(err, types.ErrorCodeJsonMarshalFailed),
+			}
 		}
+		requestBody = bytes.NewBuffer(jsonData)
 	}
-	requestBody := bytes.NewBuffer(jsonData)
 	c.Request.Body = io.NopCloser(requestBody)
 	resp, err := adaptor.DoRequest(c, info, requestBody)
 	if err != nil {
@@ -226,14 +330,14 @@ func testChannel(channel *model.Channel, testModel string) testResult {
 			newAPIError: respErr,
 		}
 	}
-	if usageA == nil {
+	usage, ok := usageA.(*dto.Usage)
+	if !ok || usage == nil {
 		return testResult{
 			context:     c,
 			localErr:    errors.New("usage is nil"),
 			newAPIError: types.NewError(errors.New("usage is nil"), types.ErrorCodeBadResponseBody),
 		}
 	}
-	usage := usageA.(*dto.Usage)
 	result := w.Result()
 	respBody, err := io.ReadAll(result.Body)
 	if err != nil {
@@ -274,7 +378,10 @@ func testChannel(channel *model.Channel, testModel string) testResult {
 		Group:            info.UsingGroup,
 		Other:            other,
 	})
-	common.SysLog(fmt.Sprintf("testing channel #%d, response: \n%s", channel.Id, string(respBody)))
+	// 语音与图片响应体较大，不写入日志
+	if testMode != model_setting.ModelTestModeTTS && testMode != model_setting.ModelTestModeImage {
+		common.SysLog(fmt.Sprintf("testing channel #%d, response: \n%s", channel.Id, string(respBody)))
+	}
 	return testResult{
 		context:     c,
 		localErr:    nil,
@@ -282,22 +389,72 @@ func testChannel(channel *model.Channel, testModel string) testResult {
 	}
 }
 
+// setupTestAudioForm 构造包含一秒静音 WAV 文件的表单，用于测试语音转文字模型
+func setupTestAudioForm(c *gin.Context, testModel string) error {
+	const sampleRate = 16000
+	samples := make([]byte, sampleRate*2)
+	// 16 位单声道 PCM 的 WAV 文件头
+	header := struct {
+		Riff          [4]byte
+		ChunkSize     uint32
+		Wave          [4]byte
+		Fmt           [4]byte
+		FmtSize       uint32
+		AudioFormat   uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		Data          [4]byte
+		DataSize      uint32
+	}{
+		Riff:          [4]byte{'R', 'I', 'F', 'F'},
+		ChunkSize:     uint32(36 + len(samples)),
+		Wave:          [4]byte{'W', 'A', 'V', 'E'},
+		Fmt:           [4]byte{'f', 'm', 't', ' '},
+		FmtSize:       16,
+		AudioFormat:   1,
+		Channels:      1,
+		SampleRate:    sampleRate,
+		ByteRate:      sampleRate * 2,
+		BlockAlign:    2,
+		BitsPerSample: 16,
+		Data:          [4]byte{'d', 'a', 't', 'a'},
+		DataSize:      uint32(len(samples)),
+	}
+	var wav bytes.Buffer
+	if err := binary.Write(&wav, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	wav.Write(samples)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if err := writer.WriteField("model", testModel); err != nil {
+		return err
+	}
+	part, err := writer.CreateFormFile("file", "test.wav")
+	if err != nil {
+		return err
+	}
+	if _, err = part.Write(wav.Bytes()); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	c.Request.Body = io.NopCloser(&body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	return c.Request.ParseMultipartForm(1 << 20)
+}
+
 func buildTestRequest(model string) *dto.GeneralOpenAIRequest {
 	testRequest := &dto.GeneralOpenAIRequest{
 		Model:  "", // this will be set later
 		Stream: false,
 	}
 
-	// 先判断是否为 Embedding 模型
-	if strings.Contains(strings.ToLower(model), "embedding") || // 其他 embedding 模型
-		strings.HasPrefix(model, "m3e") || // m3e 系列模型
-		strings.Contains(model, "bge-") {
-		testRequest.Model = model
-		// Embedding 请求
-		testRequest.Input = []any{"hello world"} // 修改为any，因为dto/openai_request.go 的ParseInput方法无法处理[]string类型
-		return testRequest
-	}
-	// 并非Embedding 模型
 	if strings.HasPrefix(model, "o") {
 		testRequest.MaxCompletionTokens = 10
 	} else if strings.Contains(model, "thinking") {
@@ -335,19 +492,40 @@ func TestChannel(c *gin.Context) {
 	//		go func() { _ = channel.SaveChannelInfo() }()
 	//	}
 	//}()
+	testMode := c.Query("mode")
+	if testMode != "" && !model_setting.IsValidModelTestMode(testMode) {
+		common.ApiErrorMsg(c, "无效的测试方式："+testMode)
+		return
+	}
+	// 依次测试渠道的全部模型，每个模型按各自的测试方式
+	if c.Query("all") == "true" {
+		testModels := channel.GetModels()
+		if relaychannel.GetTaskProviderByChannelType(channel.Type) != nil {
+			testModels = []string{""}
+		}
+		results := make([]*model.ChannelTestResult, 0, len(testModels))
+		for i, testModel := range testModels {
+			if i > 0 {
+				time.Sleep(common.RequestInterval)
+			}
+			_, _, testResult := runChannelTest(channel, testModel, "")
+			results = append(results, testResult)
+		}
+		common.ApiSuccess(c, results)
+		return
+	}
 	testModel := c.Query("model")
-	tik := time.Now()
-	result := testChannel(channel, testModel)
+	result, milliseconds, _ := runChannelTest(channel, testModel, testMode)
 	if result.localErr != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": result.localErr.Error(),
 			"time":    0.0,
+			"model":   result.model,
+			"mode":    result.mode,
 		})
 		return
 	}
-	tok := time.Now()
-	milliseconds := tok.Sub(tik).Milliseconds()
 	go channel.UpdateResponseTime(milliseconds)
 	consumedTime := float64(milliseconds) / 1000.0
 	if result.newAPIError != nil {
@@ -355,6 +533,8 @@ func TestChannel(c *gin.Context) {
 			"success": false,
 			"message": result.newAPIError.Error(),
 			"time":    consumedTime,
+			"model":   result.model,
+			"mode":    result.mode,
 		})
 		return
 	}
@@ -362,10 +542,54 @@ func TestChannel(c *gin.Context) {
 		"success": true,
 		"message": "",
 		"time":    consumedTime,
+		"model":   result.model,
+		"mode":    result.mode,
 	})
 	return
 }
 
+// runChannelTest 测试渠道的一个模型并保存该模型最近一次的测试结果
+func runChannelTest(channel *model.Channel, testModel string, testMode string) (testResult, int64, *model.ChannelTestResult) {
+	tik := time.Now()
+	result := testChannel(channel, testModel, testMode)
+	milliseconds := time.Since(tik).Milliseconds()
+	return result, milliseconds, saveChannelTestResult(channel.Id, result, milliseconds)
+}
+
+func saveChannelTestResult(channelId int, result testResult, milliseconds int64) *model.ChannelTestResult {
+	testResult := &model.ChannelTestResult{
+		ChannelId:    channelId,
+		Model:        result.model,
+		Mode:         result.mode,
+		Success:      result.localErr == nil && result.newAPIError == nil,
+		ResponseTime: int(milliseconds),
+	}
+	if result.newAPIError != nil {
+		testResult.Message = result.newAPIError.Error()
+	} else if result.localErr != nil {
+		testResult.Message = result.localErr.Error()
+	}
+	if err := model.SaveChannelTestResult(testResult); err != nil {
+		common.SysError("failed to save channel test result: " + err.Error())
+	}
+	return testResult
+}
+
+// GetChannelTestResults 返回渠道各模型最近一次的测试结果
+func GetChannelTestResults(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	results, err := model.GetChannelTestResults(channelId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, results)
+}
+
 var testAllChannelsLock sync.Mutex
 var testAllChannelsRunning bool = false
 
@@ -395,40 +619,17 @@ func testAllChannels(notify bool) error {
 		}()
 
 		for _, channel := range channels {
-			isChannelEnabled := channel.Status == common.ChannelStatusEnabled
-			tik := time.Now()
-			result := testChannel(channel, "")
-			tok := time.Now()
-			milliseconds := tok.Sub(tik).Milliseconds()
-
-			shouldBanChannel := false
-			newAPIError := result.newAPIError
-			// request error disables the channel
-			if newAPIError != nil {
-				shouldBanChannel = service.ShouldDisableChannel(channel.Type, result.newAPIError)
+			testModels := []string{""}
+			if operation_setting.GetModelHealthSetting().TestAllModels && relaychannel.GetTaskProviderByChannelType(channel.Type) == nil {
+				testModels = channel.GetModels()
 			}
-
-			// 当错误检查通过，才检查响应时间
-			if common.AutomaticDisableChannelEnabled && !shouldBanChannel {
-				if milliseconds > disableThreshold {
-					err := errors.New(fmt.Sprintf("响应时间 %.2fs 超过阈值 %.2fs", float64(milliseconds)/1000.0, float64(disableThreshold)/1000.0))
-					newAPIError = types.NewError(err, types.ErrorCodeChannelResponseTimeExceeded)
-					shouldBanChannel = true
+			for _, testModel := range testModels {
+				banned := testChannelAndApply(channel, testModel, disableThreshold)
+				time.Sleep(common.RequestInterval)
+				if banned {
+					break
 				}
 			}
-
-			// disable channel
-			if isChannelEnabled && shouldBanChannel && channel.GetAutoBan() {
-				go processChannelError(result.context, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(result.context, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
-			}
-
-			// enable channel
-			if !isChannelEnabled && service.ShouldEnableChannel(newAPIError, channel.Status) {
-				service.EnableChannel(channel.Id, common.GetContextKeyString(result.context, constant.ContextKeyChannelKey), channel.Name)
-			}
-
-			channel.UpdateResponseTime(milliseconds)
-			time.Sleep(common.RequestInterval)
 		}
 
 		if notify {
@@ -438,6 +639,58 @@ func testAllChannels(notify bool) error {
 	return nil
 }
 
+// testChannelAndApply 测试渠道的一个模型并按结果禁用或启用渠道与模型，返回渠道是否因本次测试被禁用
+func testChannelAndApply(channel *model.Channel, testModel string, disableThreshold int64) bool {
+	isChannelEnabled := channel.Status == common.ChannelStatusEnabled
+	result, milliseconds, _ := runChannelTest(channel, testModel, "")
+	channelKey := common.GetContextKeyString(result.context, constant.ContextKeyChannelKey)
+	newAPIError := result.newAPIError
+	defer channel.UpdateResponseTime(milliseconds)
+
+	// 按模型记录健康状态，错误只与模型有关时只禁用该模型
+	if result.model != "" {
+		if newAPIError == nil && result.localErr == nil {
+			model.RecordAbilitySuccess(channel.Id, result.model)
+			service.EnableAbility(channel.Id, channel.Name, result.model)
+		} else if newAPIError != nil {
+			reachThreshold := service.RecordAbilityFailure(channel.Id, result.model, newAPIError.Error())
+			if service.ShouldDisableAbility(newAPIError) {
+				if reachThreshold && channel.GetAutoBan() {
+					service.DisableAbility(*types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, channelKey, channel.GetAutoBan()), result.model, newAPIError.Error())
+				}
+				return false
+			}
+		}
+	}
+
+	shouldBanChannel := false
+	// request error disables the channel
+	if newAPIError != nil {
+		shouldBanChannel = service.ShouldDisableChannel(channel.Type, result.newAPIError)
+	}
+
+	// 当错误检查通过，才检查响应时间
+	if common.AutomaticDisableChannelEnabled && !shouldBanChannel {
+		if milliseconds > disableThreshold {
+			err := errors.New(fmt.Sprintf("响应时间 %.2fs 超过阈值 %.2fs", float64(milliseconds)/1000.0, float64(disableThreshold)/1000.0))
+			newAPIError = types.NewError(err, types.ErrorCodeChannelResponseTimeExceeded)
+			shouldBanChannel = true
+		}
+	}
+
+	// disable channel
+	if isChannelEnabled && shouldBanChannel && channel.GetAutoBan() {
+		go processChannelError(result.context, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, channelKey, channel.GetAutoBan()), "", newAPIError)
+		return true
+	}
+
+	// enable channel
+	if !isChannelEnabled && service.ShouldEnableChannel(newAPIError, channel.Status) {
+		service.EnableChannel(channel.Id, channelKey, channel.Name)
+	}
+	return false
+}
+
 func TestAllChannels(c *gin.Context) {
 	err := testAllChannels(true)
 	if err != nil {
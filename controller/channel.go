@@ -7,6 +7,8 @@ import (
 	"one-api/common"
 	"one-api/constant"
 	"one-api/model"
+	"one-api/service"
+	"one-api/types"
 	"strconv"
 	"strings"
 
@@ -164,6 +166,21 @@ func FetchUpstreamModels(c *gin.Context) {
 		return
 	}
 
+	ids, err := fetchChannelUpstreamModels(channel)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    ids,
+	})
+}
+
+// fetchChannelUpstreamModels 从渠道的上游 /v1/models 接口拉取模型列表
+func fetchChannelUpstreamModels(channel *model.Channel) ([]string, error) {
 	baseURL := constant.ChannelBaseURLs[channel.Type]
 	if channel.GetBaseURL() != "" {
 		baseURL = channel.GetBaseURL()
@@ -175,19 +192,22 @@ func FetchUpstreamModels(c *gin.Context) {
 	case constant.ChannelTypeAli:
 		url = fmt.Sprintf("%s/compatible-mode/v1/models", baseURL)
 	}
-	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
+	key := channel.Key
+	if channel.ChannelInfo.IsMultiKey {
+		var newAPIError *types.NewAPIError
+		key, _, newAPIError = channel.GetNextEnabledKey()
+		if newAPIError != nil {
+			return nil, newAPIError
+		}
+	}
+	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(key))
 	if err != nil {
-		common.ApiError(c, err)
-		return
+		return nil, err
 	}
 
 	var result OpenAIModelsResponse
 	if err = json.Unmarshal(body, &result); err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"message": fmt.Sprintf("解析响应失败: %s", err.Error()),
-		})
-		return
+		return nil, fmt.Errorf("解析响应失败: %s", err.Error())
 	}
 
 	var ids []string
@@ -198,12 +218,7 @@ func FetchUpstreamModels(c *gin.Context) {
 		}
 		ids = append(ids, id)
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "",
-		"data":    ids,
-	})
+	return ids, nil
 }
 
 func FixChannelsAbilities(c *gin.Context) {
@@ -337,11 +352,12 @@ func GetChannel(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
-	channel, err := model.GetChannelById(id, false)
+	channel, err := model.GetChannelById(id, true)
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	channel.MaskKey()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -350,6 +366,28 @@ func GetChannel(c *gin.Context) {
 	return
 }
 
+// RevealChannelKey 返回渠道明文密钥，需要 channel.key.reveal 权限，每次查看都会记录日志
+func RevealChannelKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channel, err := model.GetChannelById(id, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.RecordAudit(c, "channel.key.reveal", "channel", strconv.Itoa(channel.Id), nil, nil)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"key": channel.Key,
+		},
+	})
+}
+
 // validateChannel 通用的渠道校验函数
 func validateChannel(channel *model.Channel, isAdd bool) error {
 	// 校验 channel settings
@@ -362,6 +400,9 @@ func validateChannel(channel *model.Channel, isAdd bool) error {
 		if channel == nil || channel.Key == "" {
 			return fmt.Errorf("channel cannot be empty")
 		}
+		if common.IsMaskedSecret(channel.Key) {
+			return fmt.Errorf("密钥不能是脱敏后的值")
+		}
 
 		// 检查模型名称长度是否超过 255
 		for _, m := range channel.GetModels() {
@@ -513,6 +554,11 @@ func AddChannel(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	channelIds := make([]string, 0, len(channels))
+	for _, channel := range channels {
+		channelIds = append(channelIds, strconv.Itoa(channel.Id))
+	}
+	service.RecordAudit(c, "channel.create", "channel", strings.Join(channelIds, ","), nil, addChannelRequest.Channel)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -522,6 +568,7 @@ func AddChannel(c *gin.Context) {
 
 func DeleteChannel(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+	originChannel, _ := model.GetChannelById(id, true)
 	channel := model.Channel{Id: id}
 	err := channel.Delete()
 	if err != nil {
@@ -529,6 +576,7 @@ func DeleteChannel(c *gin.Context) {
 		return
 	}
 	model.InitChannelCache()
+	service.RecordAudit(c, "channel.delete", "channel", strconv.Itoa(id), originChannel, nil)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -688,7 +736,7 @@ func UpdateChannel(c *gin.Context) {
 		return
 	}
 	// Preserve existing ChannelInfo to ensure multi-key channels keep correct state even if the client does not send ChannelInfo in the request.
-	originChannel, err := model.GetChannelById(channel.Id, false)
+	originChannel, err := model.GetChannelById(channel.Id, true)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -710,6 +758,9 @@ func UpdateChannel(c *gin.Context) {
 		return
 	}
 	model.InitChannelCache()
+	if updatedChannel, err := model.GetChannelById(channel.Id, true); err == nil {
+		service.RecordAudit(c, "channel.update", "channel", strconv.Itoa(channel.Id), originChannel, updatedChannel)
+	}
 	channel.Key = ""
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -5,18 +5,32 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/model"
+	"one-api/service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/xuri/excelize/v2"
 )
 
+// ExportChannels 默认导出脱敏密钥，拥有 channel.key.reveal 权限的用户可通过 reveal_keys=true 导出明文密钥（会记录日志）
 func ExportChannels(c *gin.Context) {
+	revealKeys := c.Query("reveal_keys") == "true"
+	if revealKeys && !model.UserHasPermission(c.GetInt("id"), c.GetInt("role"), common.PermissionChannelKeyReveal) {
+		common.ApiErrorMsg(c, "无权导出明文密钥")
+		return
+	}
 	// 获取所有渠道数据
 	channels, err := model.GetAllChannels(0, 0, true, false)
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	if revealKeys {
+		service.RecordAudit(c, "channel.export.reveal_keys", "channel", "", nil, gin.H{"count": len(channels)})
+	} else {
+		for i := range channels {
+			channels[i].MaskKey()
+		}
+	}
 
 	// 创建Excel文件
 	f := excelize.NewFile()
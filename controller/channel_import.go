@@ -70,6 +70,7 @@ func ImportChannels(c *gin.Context) {
 
 	// 解析数据行
 	channels := make([]model.Channel, 0, len(rows)-1)
+	skipped := 0
 	for i := 1; i < len(rows); i++ {
 		row := rows[i]
 		if len(row) == 0 {
@@ -185,6 +186,12 @@ func ImportChannels(c *gin.Context) {
 			}
 		}
 
+		// 脱敏导出的密钥无法使用，跳过
+		if channel.Key == "" || common.IsMaskedSecret(channel.Key) {
+			skipped++
+			continue
+		}
+
 		// 设置默认值
 		if channel.CreatedTime == 0 {
 			channel.CreatedTime = common.GetTimestamp()
@@ -198,6 +205,11 @@ func ImportChannels(c *gin.Context) {
 		channels = append(channels, channel)
 	}
 
+	if len(channels) == 0 {
+		common.ApiError(c, fmt.Errorf("no channel with a valid key found in Excel file"))
+		return
+	}
+
 	// 批量插入渠道
 	err = model.BatchInsertChannels(channels)
 	if err != nil {
@@ -211,6 +223,6 @@ func ImportChannels(c *gin.Context) {
 	// 返回成功响应
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": fmt.Sprintf("成功导入 %d 个渠道", len(channels)),
+		"message": fmt.Sprintf("成功导入 %d 个渠道，跳过 %d 个缺少有效密钥的渠道", len(channels), skipped),
 	})
 }
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportConfigBundle 导出配置包，format=json 时导出 JSON，默认 YAML
+func ExportConfigBundle(c *gin.Context) {
+	bundle, err := service.ExportConfigBundle()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	format := c.DefaultQuery("format", "yaml")
+	data, err := service.EncodeConfigBundle(bundle, format)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	contentType := "application/yaml"
+	if format == "json" {
+		contentType = "application/json"
+	} else {
+		format = "yaml"
+	}
+	c.Header("Content-Disposition", "attachment; filename=config."+format)
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// planConfigBundle 解析请求体中的配置包（YAML 或 JSON）并与当前配置对比，接口中不读取 key_ref
+func planConfigBundle(c *gin.Context) (*service.ConfigPlan, bool) {
+	data, err := c.GetRawData()
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	bundle, err := service.ParseConfigBundle(data)
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	prune, _ := strconv.ParseBool(c.Query("prune"))
+	plan, err := service.PlanConfigBundle(bundle, prune, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	return plan, true
+}
+
+// PlanConfigBundle 预览应用配置包会产生的变化，不修改任何配置
+func PlanConfigBundle(c *gin.Context) {
+	plan, ok := planConfigBundle(c)
+	if !ok {
+		return
+	}
+	common.ApiSuccess(c, plan)
+}
+
+// ApplyConfigBundle 应用配置包，prune=true 时删除未出现在配置包中的渠道
+func ApplyConfigBundle(c *gin.Context) {
+	plan, ok := planConfigBundle(c)
+	if !ok {
+		return
+	}
+	if err := service.ApplyConfigPlan(plan); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if len(plan.Changes) > 0 {
+		service.RecordAudit(c, "config_bundle.apply", "config_bundle", "", nil, plan.Changes)
+	}
+	common.ApiSuccess(c, plan)
+}
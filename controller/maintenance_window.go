@@ -0,0 +1,238 @@
+package controller
+
+import (
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type channelDrainRequest struct {
+	ChannelIds []int  `json:"channel_ids"`
+	Tag        string `json:"tag"`
+	Reason     string `json:"reason"`
+}
+
+type drainingChannelItem struct {
+	Id           int     `json:"id"`
+	Name         string  `json:"name"`
+	Tag          *string `json:"tag"`
+	Inflight     int64   `json:"inflight"`
+	StatusReason any     `json:"status_reason"`
+	StatusTime   any     `json:"status_time"`
+	DrainedTime  any     `json:"drained_time"`
+}
+
+// GetDrainingChannels 返回排空中的渠道及其进行中的请求数
+func GetDrainingChannels(c *gin.Context) {
+	channels, err := model.GetDrainingChannels()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	inflight := service.GetChannelInflight()
+	items := make([]drainingChannelItem, 0, len(channels))
+	for _, channel := range channels {
+		info := channel.GetOtherInfo()
+		items = append(items, drainingChannelItem{
+			Id:           channel.Id,
+			Name:         channel.Name,
+			Tag:          channel.Tag,
+			Inflight:     inflight[channel.Id],
+			StatusReason: info["status_reason"],
+			StatusTime:   info["status_time"],
+			DrainedTime:  info["drained_time"],
+		})
+	}
+	common.ApiSuccess(c, items)
+}
+
+func bindChannelDrainRequest(c *gin.Context) ([]int, string, bool) {
+	var req channelDrainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return nil, "", false
+	}
+	channelIds, err := service.ResolveChannelIds(req.ChannelIds, strings.TrimSpace(req.Tag))
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, "", false
+	}
+	if len(channelIds) == 0 {
+		common.ApiErrorMsg(c, "请指定渠道或标签")
+		return nil, "", false
+	}
+	return channelIds, req.Reason, true
+}
+
+// DrainChannels 手动排空渠道，不再分配新请求，进行中的请求正常完成
+func DrainChannels(c *gin.Context) {
+	channelIds, reason, ok := bindChannelDrainRequest(c)
+	if !ok {
+		return
+	}
+	if reason == "" {
+		reason = "手动排空"
+	}
+	drained, err := service.DrainChannels(channelIds, reason)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.RecordAudit(c, "channel.drain", "channel", service.FormatChannelIds(drained), nil, gin.H{"reason": reason})
+	common.ApiSuccess(c, drained)
+}
+
+// RestoreDrainingChannels 将排空中的渠道恢复为启用
+func RestoreDrainingChannels(c *gin.Context) {
+	channelIds, _, ok := bindChannelDrainRequest(c)
+	if !ok {
+		return
+	}
+	restored, err := service.RestoreDrainingChannels(channelIds, "手动恢复")
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.RecordAudit(c, "channel.restore", "channel", service.FormatChannelIds(restored), nil, nil)
+	common.ApiSuccess(c, restored)
+}
+
+type maintenanceWindowRequest struct {
+	Id              int    `json:"id"`
+	Name            string `json:"name"`
+	ChannelIds      string `json:"channel_ids"` // 以逗号分隔
+	Tag             string `json:"tag"`
+	StartTime       int64  `json:"start_time"`
+	EndTime         int64  `json:"end_time"`
+	Cron            string `json:"cron"`
+	DurationMinutes int    `json:"duration_minutes"`
+	Enabled         bool   `json:"enabled"`
+	Remark          string `json:"remark"`
+}
+
+type maintenanceWindowItem struct {
+	*model.MaintenanceWindow
+	// 当前或下一次维护时段
+	NextStartTime int64 `json:"next_start_time"`
+	NextEndTime   int64 `json:"next_end_time"`
+}
+
+func GetMaintenanceWindows(c *gin.Context) {
+	windows, err := model.GetAllMaintenanceWindows()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	now := time.Now()
+	items := make([]maintenanceWindowItem, 0, len(windows))
+	for _, window := range windows {
+		item := maintenanceWindowItem{MaintenanceWindow: window}
+		start, end, _ := service.GetMaintenanceWindowRange(window, now)
+		if end > now.Unix() {
+			item.NextStartTime = start
+			item.NextEndTime = end
+		}
+		items = append(items, item)
+	}
+	common.ApiSuccess(c, items)
+}
+
+func bindMaintenanceWindow(c *gin.Context) (*model.MaintenanceWindow, bool) {
+	var req maintenanceWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return nil, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 64 {
+		common.ApiErrorMsg(c, "名称不能为空且长度不能超过 64")
+		return nil, false
+	}
+	window := &model.MaintenanceWindow{
+		Id:              req.Id,
+		Name:            req.Name,
+		ChannelIds:      req.ChannelIds,
+		Tag:             strings.TrimSpace(req.Tag),
+		StartTime:       req.StartTime,
+		EndTime:         req.EndTime,
+		Cron:            strings.TrimSpace(req.Cron),
+		DurationMinutes: req.DurationMinutes,
+		Enabled:         req.Enabled,
+		Remark:          req.Remark,
+	}
+	window.SetChannelIds(window.GetChannelIds())
+	if err := service.ValidateMaintenanceWindow(window); err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	return window, true
+}
+
+func AddMaintenanceWindow(c *gin.Context) {
+	window, ok := bindMaintenanceWindow(c)
+	if !ok {
+		return
+	}
+	window.Id = 0
+	if err := window.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.RecordAudit(c, "maintenance_window.create", "maintenance_window", strconv.Itoa(window.Id), nil, window)
+	common.ApiSuccess(c, window)
+}
+
+// UpdateMaintenanceWindow 修改窗口配置，维护中的窗口在下一次检查时按新配置结束或继续
+func UpdateMaintenanceWindow(c *gin.Context) {
+	window, ok := bindMaintenanceWindow(c)
+	if !ok {
+		return
+	}
+	originWindow, err := model.GetMaintenanceWindowById(window.Id)
+	if err != nil {
+		common.ApiErrorMsg(c, "维护窗口不存在")
+		return
+	}
+	window.CreatedTime = originWindow.CreatedTime
+	window.Active = originWindow.Active
+	window.ActiveStartTime = originWindow.ActiveStartTime
+	window.ActiveEndTime = originWindow.ActiveEndTime
+	window.DrainedChannels = originWindow.DrainedChannels
+	if err = window.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.RecordAudit(c, "maintenance_window.update", "maintenance_window", strconv.Itoa(window.Id), originWindow, window)
+	common.ApiSuccess(c, window)
+}
+
+// DeleteMaintenanceWindow 删除窗口，维护中的窗口先恢复本次排空的渠道
+func DeleteMaintenanceWindow(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	originWindow, err := model.GetMaintenanceWindowById(id)
+	if err != nil {
+		common.ApiErrorMsg(c, "维护窗口不存在")
+		return
+	}
+	if originWindow.Active {
+		if err = service.FinishMaintenanceWindow(originWindow); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	if err = model.DeleteMaintenanceWindowById(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.RecordAudit(c, "maintenance_window.delete", "maintenance_window", strconv.Itoa(id), originWindow, nil)
+	common.ApiSuccess(c, nil)
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 通过 /api/user/token 生成的默认令牌名称
+const defaultManagementTokenName = "default"
+
+type managementTokenRequest struct {
+	Name        string   `json:"name"`
+	Scopes      []string `json:"scopes"`
+	AllowIps    string   `json:"allow_ips"`
+	ExpiredTime int64    `json:"expired_time"`
+}
+
+// bindManagementToken 校验请求并填充令牌的名称、权限范围、IP 白名单与过期时间
+func bindManagementToken(c *gin.Context, token *model.ManagementToken) bool {
+	var req managementTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 64 {
+		common.ApiErrorMsg(c, "令牌名称长度必须在1-64之间")
+		return false
+	}
+	if req.ExpiredTime != 0 && req.ExpiredTime < common.GetTimestamp() {
+		common.ApiErrorMsg(c, "过期时间不能早于当前时间")
+		return false
+	}
+	allowIps, err := model.ValidateAllowIps(req.AllowIps)
+	if err != nil {
+		common.ApiError(c, err)
+		return false
+	}
+	permissions, err := model.GetUserPermissions(c.GetInt("id"), c.GetInt("role"))
+	if err != nil {
+		common.ApiError(c, err)
+		return false
+	}
+	if err = token.SetScopes(req.Scopes, permissions); err != nil {
+		common.ApiError(c, err)
+		return false
+	}
+	token.Name = req.Name
+	token.AllowIps = allowIps
+	token.ExpiredTime = req.ExpiredTime
+	return true
+}
+
+func getManagementTokenParam(c *gin.Context) (*model.ManagementToken, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	token, err := model.GetUserManagementTokenById(id, c.GetInt("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "令牌不存在")
+		return nil, false
+	}
+	return token, true
+}
+
+func GetSelfManagementTokens(c *gin.Context) {
+	tokens, err := model.GetUserManagementTokens(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, tokens)
+}
+
+// GetManagementTokenScopes 返回当前用户可授予管理令牌的权限范围
+func GetManagementTokenScopes(c *gin.Context) {
+	permissions, err := model.GetUserPermissions(c.GetInt("id"), c.GetInt("role"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, common.GetAvailableScopes(permissions))
+}
+
+// AddManagementToken 创建管理令牌，令牌明文仅在创建时返回一次
+func AddManagementToken(c *gin.Context) {
+	token := &model.ManagementToken{UserId: c.GetInt("id")}
+	if !bindManagementToken(c, token) {
+		return
+	}
+	key, err := model.GenerateManagementTokenKey()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = token.Insert(key); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"token": token,
+		"key":   key,
+	})
+}
+
+func UpdateManagementToken(c *gin.Context) {
+	token, ok := getManagementTokenParam(c)
+	if !ok {
+		return
+	}
+	if token.Status != model.ManagementTokenStatusActive {
+		common.ApiErrorMsg(c, "令牌已被吊销")
+		return
+	}
+	if !bindManagementToken(c, token) {
+		return
+	}
+	if err := token.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, token)
+}
+
+func RevokeManagementToken(c *gin.Context) {
+	token, ok := getManagementTokenParam(c)
+	if !ok {
+		return
+	}
+	if err := token.Revoke(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, token)
+}
+
+func DeleteManagementToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = model.DeleteUserManagementToken(id, c.GetInt("id")); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
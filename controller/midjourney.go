@@ -17,158 +17,139 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func UpdateMidjourneyTaskBulk() {
-	//imageModel := "midjourney"
-	ctx := context.TODO()
-	for {
-		time.Sleep(time.Duration(15) * time.Second)
-
-		tasks := model.GetAllUnFinishTasks()
-		if len(tasks) == 0 {
+// UpdateMidjourneyTaskAll 按渠道批量查询 Midjourney 任务进度，由统一任务轮询调用
+func UpdateMidjourneyTaskAll(ctx context.Context, taskChannelM map[int][]string, tasks map[string]*model.Task) error {
+	taskM := make(map[string]*model.Midjourney, len(tasks))
+	for taskId, task := range tasks {
+		taskM[taskId] = model.MidjourneyFromTask(task)
+	}
+	for channelId, taskIds := range taskChannelM {
+		common.LogInfo(ctx, fmt.Sprintf("渠道 #%d 未完成的任务有: %d", channelId, len(taskIds)))
+		if len(taskIds) == 0 {
 			continue
 		}
-
-		common.LogInfo(ctx, fmt.Sprintf("检测到未完成的任务数有: %v", len(tasks)))
-		taskChannelM := make(map[int][]string)
-		taskM := make(map[string]*model.Midjourney)
-		nullTaskIds := make([]int, 0)
-		for _, task := range tasks {
-			if task.MjId == "" {
-				// 统计失败的未完成任务
-				nullTaskIds = append(nullTaskIds, task.Id)
-				continue
-			}
-			taskM[task.MjId] = task
-			taskChannelM[task.ChannelId] = append(taskChannelM[task.ChannelId], task.MjId)
-		}
-		if len(nullTaskIds) > 0 {
-			err := model.MjBulkUpdateByTaskIds(nullTaskIds, map[string]any{
-				"status":   "FAILURE",
-				"progress": "100%",
+		midjourneyChannel, err := model.CacheGetChannel(channelId)
+		if err != nil {
+			common.LogError(ctx, fmt.Sprintf("CacheGetChannel: %v", err))
+			failReason := fmt.Sprintf("获取渠道信息失败，请联系管理员，渠道ID：%d", channelId)
+			err := model.MjBulkUpdate(taskIds, map[string]any{
+				"fail_reason": failReason,
+				"status":      "FAILURE",
+				"progress":    "100%",
 			})
 			if err != nil {
-				common.LogError(ctx, fmt.Sprintf("Fix null mj_id task error: %v", err))
+				common.LogInfo(ctx, fmt.Sprintf("UpdateMidjourneyTask error: %v", err))
 			} else {
-				common.LogInfo(ctx, fmt.Sprintf("Fix null mj_id task success: %v", nullTaskIds))
+				for _, taskId := range taskIds {
+					if task := taskM[taskId]; task != nil && task.Status != "FAILURE" {
+						task.Status = "FAILURE"
+						task.Progress = "100%"
+						task.FailReason = failReason
+						settleMidjourneyTask(ctx, task)
+						service.NotifyMidjourneyStatusChange(task)
+					}
+				}
 			}
+			continue
 		}
-		if len(taskChannelM) == 0 {
+		responseItems, err := fetchMidjourneyTasks(midjourneyChannel, taskIds)
+		if err != nil {
+			common.LogError(ctx, fmt.Sprintf("Get Task error: %v", err))
 			continue
 		}
 
-		for channelId, taskIds := range taskChannelM {
-			common.LogInfo(ctx, fmt.Sprintf("渠道 #%d 未完成的任务有: %d", channelId, len(taskIds)))
-			if len(taskIds) == 0 {
+		for _, responseItem := range responseItems {
+			task := taskM[responseItem.MjId]
+
+			if task == nil {
 				continue
 			}
-			midjourneyChannel, err := model.CacheGetChannel(channelId)
-			if err != nil {
-				common.LogError(ctx, fmt.Sprintf("CacheGetChannel: %v", err))
-				err := model.MjBulkUpdate(taskIds, map[string]any{
-					"fail_reason": fmt.Sprintf("获取渠道信息失败，请联系管理员，渠道ID：%d", channelId),
-					"status":      "FAILURE",
-					"progress":    "100%",
-				})
-				if err != nil {
-					common.LogInfo(ctx, fmt.Sprintf("UpdateMidjourneyTask error: %v", err))
-				}
+			if !checkMjTaskNeedUpdate(task, responseItem) {
 				continue
 			}
-			requestUrl := fmt.Sprintf("%s/mj/task/list-by-condition", *midjourneyChannel.BaseURL)
-
-			body, _ := json.Marshal(map[string]any{
-				"ids": taskIds,
-			})
-			req, err := http.NewRequest("POST", requestUrl, bytes.NewBuffer(body))
-			if err != nil {
-				common.LogError(ctx, fmt.Sprintf("Get Task error: %v", err))
-				continue
+			oldStatus := task.Status
+			task.Code = 1
+			task.Progress = responseItem.Progress
+			task.PromptEn = responseItem.PromptEn
+			task.State = responseItem.State
+			task.SubmitTime = responseItem.SubmitTime
+			task.StartTime = responseItem.StartTime
+			task.FinishTime = responseItem.FinishTime
+			task.ImageUrl = responseItem.ImageUrl
+			task.Status = responseItem.Status
+			task.FailReason = responseItem.FailReason
+			if responseItem.Properties != nil {
+				propertiesStr, _ := json.Marshal(responseItem.Properties)
+				task.Properties = string(propertiesStr)
 			}
-			// 设置超时时间
-			timeout := time.Second * 15
-			ctx, cancel := context.WithTimeout(context.Background(), timeout)
-			// 使用带有超时的 context 创建新的请求
-			req = req.WithContext(ctx)
-			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("mj-api-secret", midjourneyChannel.Key)
-			resp, err := service.GetHttpClient().Do(req)
-			if err != nil {
-				common.LogError(ctx, fmt.Sprintf("Get Task Do req error: %v", err))
-				continue
+			if responseItem.Buttons != nil {
+				buttonStr, _ := json.Marshal(responseItem.Buttons)
+				task.Buttons = string(buttonStr)
 			}
-			if resp.StatusCode != http.StatusOK {
-				common.LogError(ctx, fmt.Sprintf("Get Task status code: %d", resp.StatusCode))
-				continue
+			if (task.Progress != "100%" && responseItem.FailReason != "") || (task.Progress == "100%" && task.Status == "FAILURE") {
+				common.LogInfo(ctx, task.MjId+" 构建失败，"+task.FailReason)
+				task.Status = "FAILURE"
+				task.Progress = "100%"
 			}
-			responseBody, err := io.ReadAll(resp.Body)
+			err = task.Update()
 			if err != nil {
-				common.LogError(ctx, fmt.Sprintf("Get Task parse body error: %v", err))
+				common.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
 				continue
 			}
-			var responseItems []dto.MidjourneyDto
-			err = json.Unmarshal(responseBody, &responseItems)
-			if err != nil {
-				common.LogError(ctx, fmt.Sprintf("Get Task parse body error2: %v, body: %s", err, string(responseBody)))
-				continue
+			if task.Status == "FAILURE" {
+				settleMidjourneyTask(ctx, task)
 			}
-			resp.Body.Close()
-			req.Body.Close()
-			cancel()
-
-			for _, responseItem := range responseItems {
-				task := taskM[responseItem.MjId]
-
-				useTime := (time.Now().UnixNano() / int64(time.Millisecond)) - task.SubmitTime
-				// 如果时间超过一小时，且进度不是100%，则认为任务失败
-				if useTime > 3600000 && task.Progress != "100%" {
-					responseItem.FailReason = "上游任务超时（超过1小时）"
-					responseItem.Status = "FAILURE"
-				}
-				if !checkMjTaskNeedUpdate(task, responseItem) {
-					continue
-				}
-				task.Code = 1
-				task.Progress = responseItem.Progress
-				task.PromptEn = responseItem.PromptEn
-				task.State = responseItem.State
-				task.SubmitTime = responseItem.SubmitTime
-				task.StartTime = responseItem.StartTime
-				task.FinishTime = responseItem.FinishTime
-				task.ImageUrl = responseItem.ImageUrl
-				task.Status = responseItem.Status
-				task.FailReason = responseItem.FailReason
-				if responseItem.Properties != nil {
-					propertiesStr, _ := json.Marshal(responseItem.Properties)
-					task.Properties = string(propertiesStr)
-				}
-				if responseItem.Buttons != nil {
-					buttonStr, _ := json.Marshal(responseItem.Buttons)
-					task.Buttons = string(buttonStr)
-				}
-				shouldReturnQuota := false
-				if (task.Progress != "100%" && responseItem.FailReason != "") || (task.Progress == "100%" && task.Status == "FAILURE") {
-					common.LogInfo(ctx, task.MjId+" 构建失败，"+task.FailReason)
-					task.Progress = "100%"
-					if task.Quota != 0 {
-						shouldReturnQuota = true
-					}
-				}
-				err = task.Update()
-				if err != nil {
-					common.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
-				} else {
-					if shouldReturnQuota {
-						err = model.IncreaseUserQuota(task.UserId, task.Quota, false)
-						if err != nil {
-							common.LogError(ctx, "fail to increase user quota: "+err.Error())
-						}
-						logContent := fmt.Sprintf("构图失败 %s，补偿 %s", task.MjId, common.LogQuota(task.Quota))
-						model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
-					}
-				}
+			if task.Status != oldStatus {
+				service.ArchiveMidjourneyTaskAssets(task)
+				service.NotifyMidjourneyStatusChange(task)
 			}
 		}
 	}
+	return nil
+}
+
+// fetchMidjourneyTasks 通过 list-by-condition 批量查询渠道上的任务
+func fetchMidjourneyTasks(midjourneyChannel *model.Channel, taskIds []string) ([]dto.MidjourneyDto, error) {
+	requestUrl := fmt.Sprintf("%s/mj/task/list-by-condition", *midjourneyChannel.BaseURL)
+	body, _ := json.Marshal(map[string]any{
+		"ids": taskIds,
+	})
+	// 设置超时时间
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", requestUrl, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("mj-api-secret", midjourneyChannel.Key)
+	resp, err := service.GetHttpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var responseItems []dto.MidjourneyDto
+	if err := json.Unmarshal(responseBody, &responseItems); err != nil {
+		return nil, fmt.Errorf("parse body error: %v, body: %s", err, string(responseBody))
+	}
+	return responseItems, nil
+}
+
+// settleMidjourneyTask 构图失败时退还额度，已退还的任务不会重复退款
+func settleMidjourneyTask(ctx context.Context, task *model.Midjourney) {
+	if task.Quota == 0 {
+		return
+	}
+	if _, err := service.SettleTaskRefund(ctx, int64(task.Id), task.Quota, task.FailReason); err != nil {
+		common.LogError(ctx, fmt.Sprintf("failed to settle midjourney task %s: %s", task.MjId, err.Error()))
+	}
 }
 
 func checkMjTaskNeedUpdate(oldTask *model.Midjourney, newTask dto.MidjourneyDto) bool {
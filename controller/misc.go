@@ -58,6 +58,8 @@ func GetStatus(c *gin.Context) {
 		"server_address":           setting.ServerAddress,
 		"price":                    setting.Price,
 		"stripe_unit_price":        setting.StripeUnitPrice,
+		"default_currency":         operation_setting.ResolveCurrency(""),
+		"currencies":               operation_setting.GetSupportedCurrencies(),
 		"min_topup":                setting.MinTopUp,
 		"stripe_min_topup":         setting.StripeMinTopUp,
 		"turnstile_check":          common.TurnstileCheckEnabled,
@@ -74,6 +76,7 @@ func GetStatus(c *gin.Context) {
 		"default_collapse_sidebar": common.DefaultCollapseSidebar,
 		"enable_online_topup":      setting.PayAddress != "" && setting.EpayId != "" && setting.EpayKey != "",
 		"enable_stripe_topup":      setting.StripeApiSecret != "" && setting.StripeWebhookSecret != "" && setting.StripePriceId != "",
+		"payment_providers":        getEnabledPaymentProviderNames(),
 		"mj_notify_enabled":        setting.MjNotifyEnabled,
 		"chats":                    setting.Chats,
 		"demo_site_enabled":        operation_setting.DemoSiteEnabled,
@@ -92,6 +95,7 @@ func GetStatus(c *gin.Context) {
 		"oidc_client_id":              system_setting.GetOIDCSettings().ClientId,
 		"oidc_authorization_endpoint": system_setting.GetOIDCSettings().AuthorizationEndpoint,
 		"setup":                       constant.Setup,
+		"passkey_login":               system_setting.GetPasskeySettings().Enabled,
 	}
 
 	// 根据启用状态注入可选内容
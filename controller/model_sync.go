@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	relaychannel "one-api/relay/channel"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// syncChannelModels 拉取渠道的上游模型并按同步规则对比，apply 为 true 时写入渠道模型与同步状态。
+// 拉取上游或规则出错时返回带错误信息的报告
+func syncChannelModels(channel *model.Channel, apply bool) (*model.ModelSyncReport, error) {
+	report, result, err := diffChannelUpstreamModels(channel)
+	report.Applied = apply
+	if !apply {
+		return report, nil
+	}
+	// 只修改同步相关的键，其他流程写入的 other_info 保持不变
+	patch := map[string]interface{}{}
+	if err == nil {
+		if result.Changed() {
+			if err = model.UpdateChannelModels(channel, result.Models); err != nil {
+				return nil, err
+			}
+		}
+		patch["model_sync_misses"] = result.Misses
+	}
+	// 出错时同样记录同步时间，等到下一个同步周期再重试
+	patch["model_sync_time"] = common.GetTimestamp()
+	if err = model.PatchChannelOtherInfo(channel, patch); err != nil {
+		common.SysError(fmt.Sprintf("failed to save model sync state of channel #%d: %s", channel.Id, err.Error()))
+	}
+	return report, nil
+}
+
+func diffChannelUpstreamModels(channel *model.Channel) (*model.ModelSyncReport, *service.ModelSyncResult, error) {
+	upstreamModels, err := fetchChannelUpstreamModels(channel)
+	if err == nil {
+		var result *service.ModelSyncResult
+		result, err = service.DiffChannelModels(channel, upstreamModels, service.GetChannelModelSyncMisses(channel))
+		if err == nil {
+			return service.NewModelSyncReport(channel, result, false), result, nil
+		}
+	}
+	return &model.ModelSyncReport{
+		ChannelId:   channel.Id,
+		ChannelName: channel.Name,
+		Error:       err.Error(),
+	}, nil, err
+}
+
+// SyncChannelModels 手动同步单个渠道的上游模型，apply=true 时写入渠道，否则只预览变化
+func SyncChannelModels(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channel, err := model.GetChannelById(id, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	apply, _ := strconv.ParseBool(c.Query("apply"))
+	origin := channel.Models
+	report, err := syncChannelModels(channel, apply)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if apply {
+		if err = report.Insert(); err != nil {
+			common.SysError("failed to save model sync report: " + err.Error())
+		}
+		if origin != channel.Models {
+			service.RecordAudit(c, "channel.model_sync", "channel", strconv.Itoa(channel.Id), gin.H{"models": origin}, gin.H{"models": channel.Models})
+		}
+	}
+	common.ApiSuccess(c, report)
+}
+
+func GetModelSyncReports(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	reports, total, err := model.GetModelSyncReports(channelId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(reports)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func isChannelModelSyncDue(channel *model.Channel, intervalSeconds int64) bool {
+	if channel.Status == common.ChannelStatusManuallyDisabled || !channel.GetSetting().ModelSyncEnabled {
+		return false
+	}
+	if relaychannel.GetTaskProviderByChannelType(channel.Type) != nil {
+		return false
+	}
+	lastSyncTime, _ := channel.GetOtherInfo()["model_sync_time"].(float64)
+	return common.GetTimestamp()-int64(lastSyncTime) >= intervalSeconds
+}
+
+// AutomaticallySyncChannelModels 定时同步开启了模型同步的渠道，有变化或同步失败时保存报告并通知超级管理员
+func AutomaticallySyncChannelModels() {
+	for {
+		time.Sleep(time.Minute)
+		syncSetting := operation_setting.GetModelSyncSetting()
+		if !syncSetting.Enabled || syncSetting.IntervalMinutes <= 0 {
+			continue
+		}
+		channels, err := model.GetAllChannels(0, 0, true, false)
+		if err != nil {
+			common.SysError("failed to get channels: " + err.Error())
+			continue
+		}
+		for _, channel := range channels {
+			if !isChannelModelSyncDue(channel, int64(syncSetting.IntervalMinutes)*60) {
+				continue
+			}
+			report, err := syncChannelModels(channel, true)
+			if err != nil {
+				common.SysError(fmt.Sprintf("failed to sync models of channel #%d: %s", channel.Id, err.Error()))
+				continue
+			}
+			if report.Error != "" || report.HasChanges() {
+				if err := report.Insert(); err != nil {
+					common.SysError("failed to save model sync report: " + err.Error())
+				}
+				service.NotifyModelSyncReport(report)
+			}
+			time.Sleep(common.RequestInterval)
+		}
+		if syncSetting.ReportRetentionDays > 0 {
+			before := common.GetTimestamp() - int64(syncSetting.ReportRetentionDays)*24*3600
+			if _, err := model.DeleteModelSyncReportsBefore(before); err != nil {
+				common.SysError("failed to clean model sync reports: " + err.Error())
+			}
+		}
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"one-api/common"
 	"one-api/model"
+	"one-api/service"
 	"one-api/setting"
 	"one-api/setting/system_setting"
 	"strconv"
@@ -16,6 +18,7 @@ import (
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type OidcResponse struct {
@@ -28,11 +31,12 @@ type OidcResponse struct {
 }
 
 type OidcUser struct {
-	OpenID            string `json:"sub"`
-	Email             string `json:"email"`
-	Name              string `json:"name"`
-	PreferredUsername string `json:"preferred_username"`
-	Picture           string `json:"picture"`
+	OpenID            string   `json:"sub"`
+	Email             string   `json:"email"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+	Picture           string   `json:"picture"`
+	Groups            []string `json:"-"` // 从 oidc.group_claim 指定的字段中读取
 }
 
 func getOidcUserInfoByCode(code string) (*OidcUser, error) {
@@ -89,11 +93,21 @@ func getOidcUserInfoByCode(code string) (*OidcUser, error) {
 		return nil, errors.New("OIDC 获取用户信息失败！请检查设置！")
 	}
 
+	body, err := io.ReadAll(res2.Body)
+	if err != nil {
+		return nil, err
+	}
 	var oidcUser OidcUser
-	err = json.NewDecoder(res2.Body).Decode(&oidcUser)
+	err = json.Unmarshal(body, &oidcUser)
 	if err != nil {
 		return nil, err
 	}
+	if groupClaim := system_setting.GetOIDCSettings().GroupClaim; groupClaim != "" {
+		var claims map[string]interface{}
+		if err = json.Unmarshal(body, &claims); err == nil {
+			oidcUser.Groups = getOidcGroupClaim(claims, groupClaim)
+		}
+	}
 	if oidcUser.OpenID == "" || oidcUser.Email == "" {
 		common.SysError("OIDC 获取用户信息为空！请检查设置！")
 		return nil, errors.New("OIDC 获取用户信息为空！请检查设置！")
@@ -101,6 +115,51 @@ func getOidcUserInfoByCode(code string) (*OidcUser, error) {
 	return &oidcUser, nil
 }
 
+// getOidcGroupClaim 读取用户组字段，支持以 . 分隔的嵌套字段，值可以是字符串数组或以逗号分隔的字符串
+func getOidcGroupClaim(claims map[string]interface{}, claim string) []string {
+	var value interface{} = claims
+	for _, key := range strings.Split(claim, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	groups := make([]string, 0)
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if group, ok := item.(string); ok && group != "" {
+				groups = append(groups, group)
+			}
+		}
+	case string:
+		for _, group := range strings.Split(v, ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				groups = append(groups, group)
+			}
+		}
+	}
+	return groups
+}
+
+// linkScimUserForOidc OIDC 首次登录时关联由 SCIM 预先创建的用户，避免重复注册
+func linkScimUserForOidc(oidcUser *OidcUser) (*model.User, bool) {
+	user, err := model.FindScimUserForOidc(oidcUser.OpenID, oidcUser.Email)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			common.SysError("failed to find scim user for oidc: " + err.Error())
+		}
+		return nil, false
+	}
+	if err = model.UpdateScimUserFields(user.Id, map[string]interface{}{"oidc_id": oidcUser.OpenID}); err != nil {
+		common.SysError("failed to link oidc id: " + err.Error())
+		return nil, false
+	}
+	user.OidcId = oidcUser.OpenID
+	return user, true
+}
+
 func OidcAuth(c *gin.Context) {
 	session := sessions.Default(c)
 	state := c.Query("state")
@@ -141,6 +200,8 @@ func OidcAuth(c *gin.Context) {
 			})
 			return
 		}
+	} else if scimUser, ok := linkScimUserForOidc(oidcUser); ok {
+		user = *scimUser
 	} else {
 		if common.RegisterEnabled {
 			user.Email = oidcUser.Email
@@ -178,6 +239,13 @@ func OidcAuth(c *gin.Context) {
 		})
 		return
 	}
+	if system_setting.GetOIDCSettings().GroupClaim != "" {
+		if err := service.ApplyIdpGroups(user.Id, oidcUser.Groups); err != nil {
+			common.SysError("failed to apply oidc groups: " + err.Error())
+		} else if group, err := model.GetUserGroup(user.Id, true); err == nil {
+			user.Group = group
+		}
+	}
 	setupLogin(&user, c)
 }
 
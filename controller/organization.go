@@ -0,0 +1,460 @@
+package controller
+
+import (
+	"fmt"
+	"html"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 邀请链接有效期
+const organizationInvitationValidSeconds = 7 * 24 * 3600
+
+type organizationRequest struct {
+	Name string `json:"name"`
+}
+
+type organizationMemberRequest struct {
+	Role       string `json:"role"`
+	SpendLimit *int   `json:"spend_limit"`
+	ResetUsed  bool   `json:"reset_used"`
+}
+
+type organizationInvitationRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type organizationQuotaRequest struct {
+	Quota  *int `json:"quota"`
+	Status *int `json:"status"`
+}
+
+// getOrganizationMembership 校验当前用户是否为组织成员，requireManager 为 true 时要求所有者或管理员
+func getOrganizationMembership(c *gin.Context, requireManager bool) (*model.Organization, *model.OrganizationMember, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, nil, false
+	}
+	org, err := model.GetOrganizationById(id)
+	if err != nil {
+		common.ApiErrorMsg(c, "组织不存在")
+		return nil, nil, false
+	}
+	member, err := model.GetOrganizationMember(org.Id, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, nil, false
+	}
+	if requireManager && !member.IsOrganizationManager() {
+		common.ApiErrorMsg(c, "仅组织所有者或管理员可以进行此操作")
+		return nil, nil, false
+	}
+	return org, member, true
+}
+
+func GetSelfOrganizations(c *gin.Context) {
+	orgs, err := model.GetUserOrganizations(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, orgs)
+}
+
+func CreateOrganization(c *gin.Context) {
+	var req organizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 64 {
+		common.ApiErrorMsg(c, "组织名称不能为空且长度不能超过 64")
+		return
+	}
+	org, err := model.CreateOrganization(req.Name, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+func GetOrganization(c *gin.Context) {
+	org, member, ok := getOrganizationMembership(c, false)
+	if !ok {
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"organization": org,
+		"membership":   member,
+	})
+}
+
+func UpdateOrganization(c *gin.Context) {
+	org, _, ok := getOrganizationMembership(c, true)
+	if !ok {
+		return
+	}
+	var req organizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 64 {
+		common.ApiErrorMsg(c, "组织名称不能为空且长度不能超过 64")
+		return
+	}
+	if err := org.UpdateName(req.Name); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+// DeleteOrganization 仅所有者可删除，剩余额度按转入比例退回成员
+func DeleteOrganization(c *gin.Context) {
+	org, member, ok := getOrganizationMembership(c, true)
+	if !ok {
+		return
+	}
+	if member.Role != model.OrganizationRoleOwner {
+		common.ApiErrorMsg(c, "仅组织所有者可以删除组织")
+		return
+	}
+	if err := model.DeleteOrganization(org.Id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func GetOrganizationMembers(c *gin.Context) {
+	org, _, ok := getOrganizationMembership(c, false)
+	if !ok {
+		return
+	}
+	members, err := model.GetOrganizationMembers(org.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, members)
+}
+
+// UpdateOrganizationMember 调整成员角色与额度上限，只有所有者可以任免管理员
+func UpdateOrganizationMember(c *gin.Context) {
+	org, me, ok := getOrganizationMembership(c, true)
+	if !ok {
+		return
+	}
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req organizationMemberRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return
+	}
+	target, err := model.GetOrganizationMember(org.Id, userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Role != "" && req.Role != target.Role {
+		if !model.IsValidOrganizationRole(req.Role) || req.Role == model.OrganizationRoleOwner {
+			common.ApiErrorMsg(c, "无效的成员角色")
+			return
+		}
+		if target.Role == model.OrganizationRoleOwner {
+			common.ApiErrorMsg(c, "无法修改所有者的角色")
+			return
+		}
+		if me.Role != model.OrganizationRoleOwner {
+			common.ApiErrorMsg(c, "仅组织所有者可以任免管理员")
+			return
+		}
+		target.Role = req.Role
+	}
+	if target.Role == model.OrganizationRoleAdmin && me.Role != model.OrganizationRoleOwner && target.UserId != me.UserId {
+		common.ApiErrorMsg(c, "仅组织所有者可以调整管理员的额度")
+		return
+	}
+	if req.SpendLimit != nil {
+		if *req.SpendLimit < 0 {
+			common.ApiErrorMsg(c, "额度上限不能为负数")
+			return
+		}
+		target.SpendLimit = *req.SpendLimit
+	}
+	if err = model.UpdateOrganizationMember(target, req.ResetUsed); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, target)
+}
+
+// RemoveOrganizationMember 移除成员，成员也可以通过移除自己退出组织
+func RemoveOrganizationMember(c *gin.Context) {
+	org, me, ok := getOrganizationMembership(c, false)
+	if !ok {
+		return
+	}
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	target, err := model.GetOrganizationMember(org.Id, userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if target.Role == model.OrganizationRoleOwner {
+		common.ApiErrorMsg(c, "所有者无法退出组织，请直接删除组织")
+		return
+	}
+	if target.UserId != me.UserId {
+		if !me.IsOrganizationManager() {
+			common.ApiErrorMsg(c, "仅组织所有者或管理员可以进行此操作")
+			return
+		}
+		if target.Role == model.OrganizationRoleAdmin && me.Role != model.OrganizationRoleOwner {
+			common.ApiErrorMsg(c, "仅组织所有者可以移除管理员")
+			return
+		}
+	}
+	if err = model.RemoveOrganizationMember(org.Id, userId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func GetOrganizationInvitations(c *gin.Context) {
+	org, _, ok := getOrganizationMembership(c, true)
+	if !ok {
+		return
+	}
+	invitations, err := model.GetOrganizationInvitations(org.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, invitations)
+}
+
+// InviteOrganizationMember 通过邮件发送邀请链接
+func InviteOrganizationMember(c *gin.Context) {
+	org, me, ok := getOrganizationMembership(c, true)
+	if !ok {
+		return
+	}
+	var req organizationInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return
+	}
+	if req.Role == "" {
+		req.Role = model.OrganizationRoleMember
+	}
+	if !model.IsValidOrganizationRole(req.Role) || req.Role == model.OrganizationRoleOwner {
+		common.ApiErrorMsg(c, "无效的成员角色")
+		return
+	}
+	if req.Role == model.OrganizationRoleAdmin && me.Role != model.OrganizationRoleOwner {
+		common.ApiErrorMsg(c, "仅组织所有者可以邀请管理员")
+		return
+	}
+	if err := common.Validate.Var(req.Email, "required,email"); err != nil {
+		common.ApiErrorMsg(c, "无效的邮箱地址")
+		return
+	}
+	if common.SMTPServer == "" {
+		common.ApiErrorMsg(c, "未配置 SMTP 服务器，无法发送邀请邮件")
+		return
+	}
+	invitation := &model.OrganizationInvitation{
+		OrganizationId: org.Id,
+		Email:          req.Email,
+		Role:           req.Role,
+		InviterId:      me.UserId,
+		ExpiredTime:    common.GetTimestamp() + organizationInvitationValidSeconds,
+	}
+	if err := model.CreateOrganizationInvitation(invitation); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	link := fmt.Sprintf("%s/organization/invitation?code=%s", setting.ServerAddress, invitation.Code)
+	subject := fmt.Sprintf("%s组织邀请", common.SystemName)
+	content := fmt.Sprintf("<p>您好，您被邀请加入%s上的组织「%s」。</p>"+
+		"<p>点击 <a href='%s'>此处</a> 登录并接受邀请。</p>"+
+		"<p>如果链接无法点击，请尝试点击下面的链接或将其复制到浏览器中打开：<br> %s </p>"+
+		"<p>邀请 7 天内有效，接受邀请的账号需绑定邮箱 %s。</p>", common.SystemName, html.EscapeString(org.Name), link, link, html.EscapeString(invitation.Email))
+	if err := common.SendEmail(subject, invitation.Email, content); err != nil {
+		_ = model.RevokeOrganizationInvitation(org.Id, invitation.Id)
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, invitation)
+}
+
+func RevokeOrganizationInvitation(c *gin.Context) {
+	org, _, ok := getOrganizationMembership(c, true)
+	if !ok {
+		return
+	}
+	invitationId, err := strconv.Atoi(c.Param("invitation_id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = model.RevokeOrganizationInvitation(org.Id, invitationId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func AcceptOrganizationInvitation(c *gin.Context) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		common.ApiErrorMsg(c, "无效的参数")
+		return
+	}
+	user, err := model.GetUserById(c.GetInt("id"), false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	member, err := model.AcceptOrganizationInvitation(req.Code, user)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, member)
+}
+
+// DepositOrganizationQuota 成员将个人额度转入组织额度池
+func DepositOrganizationQuota(c *gin.Context) {
+	org, _, ok := getOrganizationMembership(c, false)
+	if !ok {
+		return
+	}
+	var req struct {
+		Quota int `json:"quota"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return
+	}
+	userId := c.GetInt("id")
+	if err := model.TransferQuotaToOrganization(org.Id, userId, req.Quota); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(userId, model.LogTypeManage, fmt.Sprintf("向组织 %s 转入额度 %s", org.Name, common.LogQuota(req.Quota)))
+	common.ApiSuccess(c, nil)
+}
+
+// GetOrganizationLogs 所有者与管理员可查看全部成员的日志，普通成员仅能查看自己的日志
+func GetOrganizationLogs(c *gin.Context) {
+	org, me, ok := getOrganizationMembership(c, false)
+	if !ok {
+		return
+	}
+	userId := me.UserId
+	if me.IsOrganizationManager() {
+		userId, _ = strconv.Atoi(c.Query("user_id"))
+	}
+	pageInfo := common.GetPageQuery(c)
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	logs, total, err := model.GetOrganizationLogs(org.Id, userId, startTimestamp, endTimestamp, c.Query("model_name"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(logs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetOrganizationUsage(c *gin.Context) {
+	org, _, ok := getOrganizationMembership(c, true)
+	if !ok {
+		return
+	}
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	byMember, byModel, err := model.GetOrganizationUsage(org.Id, startTimestamp, endTimestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"quota":      org.Quota,
+		"used_quota": org.UsedQuota,
+		"members":    byMember,
+		"models":     byModel,
+	})
+}
+
+func GetAllOrganizations(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	orgs, total, err := model.GetAllOrganizations(c.Query("keyword"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(orgs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// AdminUpdateOrganization 管理员调整组织额度池或启用状态
+func AdminUpdateOrganization(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req organizationQuotaRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return
+	}
+	org, err := model.GetOrganizationById(id)
+	if err != nil {
+		common.ApiErrorMsg(c, "组织不存在")
+		return
+	}
+	if req.Quota != nil && *req.Quota != org.Quota {
+		if err = model.SetOrganizationQuota(org.Id, *req.Quota); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		model.RecordLog(org.OwnerId, model.LogTypeManage, fmt.Sprintf("管理员将组织 %s 的额度从 %s修改为 %s", org.Name, common.LogQuota(org.Quota), common.LogQuota(*req.Quota)))
+		org.Quota = *req.Quota
+	}
+	if req.Status != nil {
+		if err = model.SetOrganizationStatus(org.Id, *req.Status); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		org.Status = *req.Status
+	}
+	common.ApiSuccess(c, org)
+}
@@ -0,0 +1,242 @@
+package controller
+
+import (
+	"errors"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+	"strings"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const (
+	sessionKeyPasskeyRegistration = "passkey_registration"
+	sessionKeyPasskeyLogin        = "passkey_login"
+)
+
+// savePasskeySession 将 WebAuthn 挑战保存到会话中，完成时取出校验
+func savePasskeySession(c *gin.Context, key string, data *webauthn.SessionData) error {
+	encoded, err := common.Marshal(data)
+	if err != nil {
+		return err
+	}
+	session := sessions.Default(c)
+	session.Set(key, string(encoded))
+	return session.Save()
+}
+
+func loadPasskeySession(c *gin.Context, key string) (*webauthn.SessionData, error) {
+	session := sessions.Default(c)
+	encoded, ok := session.Get(key).(string)
+	if !ok || encoded == "" {
+		return nil, errors.New("通行密钥会话已过期，请重试")
+	}
+	session.Delete(key)
+	if err := session.Save(); err != nil {
+		return nil, err
+	}
+	var data webauthn.SessionData
+	if err := common.UnmarshalJsonStr(encoded, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func GetSelfPasskeys(c *gin.Context) {
+	passkeys, err := model.GetUserPasskeys(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, passkeys)
+}
+
+func PasskeyRegisterBegin(c *gin.Context) {
+	wa, err := service.GetWebAuthn()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if _, ok := checkExistingSecondFactor(c); !ok {
+		return
+	}
+	user, err := model.GetUserById(c.GetInt("id"), false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	passkeyUser, err := service.NewPasskeyUser(user)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if len(passkeyUser.Passkeys) >= service.MaxPasskeysPerUser {
+		common.ApiErrorMsg(c, "通行密钥数量已达上限")
+		return
+	}
+	options, sessionData, err := wa.BeginRegistration(passkeyUser,
+		webauthn.WithExclusions(passkeyUser.ExclusionDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementRequired,
+			UserVerification: service.GetPasskeyUserVerification(),
+		}),
+	)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = savePasskeySession(c, sessionKeyPasskeyRegistration, sessionData); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, options)
+}
+
+// PasskeyRegisterFinish 请求体为浏览器返回的凭据，名称通过 name 查询参数传入
+func PasskeyRegisterFinish(c *gin.Context) {
+	wa, err := service.GetWebAuthn()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	sessionData, err := loadPasskeySession(c, sessionKeyPasskeyRegistration)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	hadSecondFactor, ok := checkExistingSecondFactor(c)
+	if !ok {
+		return
+	}
+	user, err := model.GetUserById(c.GetInt("id"), false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	passkeyUser, err := service.NewPasskeyUser(user)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	credential, err := wa.FinishRegistration(passkeyUser, *sessionData, c.Request)
+	if err != nil {
+		common.ApiErrorMsg(c, "通行密钥注册失败："+err.Error())
+		return
+	}
+	encoded, err := common.Marshal(credential)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	name := strings.TrimSpace(c.Query("name"))
+	if name == "" {
+		name = "Passkey"
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	passkey := &model.PasskeyCredential{
+		UserId:       user.Id,
+		Name:         name,
+		CredentialId: service.EncodePasskeyCredentialId(credential.ID),
+		Credential:   string(encoded),
+	}
+	if err = passkey.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 首次添加验证方式时视为当前会话已完成两步验证
+	if !hadSecondFactor {
+		markSessionMFA(c)
+	}
+	common.ApiSuccess(c, passkey)
+}
+
+func DeleteSelfPasskey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	userId := c.GetInt("id")
+	if service.IsTwoFactorEnforced(c.GetInt("role")) && !model.IsTwoFAEnabled(userId) {
+		passkeys, err := model.GetUserPasskeys(userId)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if len(passkeys) <= 1 {
+			common.ApiErrorMsg(c, "管理员要求启用两步验证，请先启用 TOTP 或添加其他通行密钥")
+			return
+		}
+	}
+	if err = model.DeleteUserPasskey(id, userId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// PasskeyLoginBegin 无用户名登录，由浏览器选择已保存的通行密钥
+func PasskeyLoginBegin(c *gin.Context) {
+	wa, err := service.GetWebAuthn()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	options, sessionData, err := wa.BeginDiscoverableLogin(webauthn.WithUserVerification(service.GetPasskeyUserVerification()))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = savePasskeySession(c, sessionKeyPasskeyLogin, sessionData); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, options)
+}
+
+// PasskeyLoginFinish 通行密钥本身即为强认证，登录后无需再输入 TOTP 验证码
+func PasskeyLoginFinish(c *gin.Context) {
+	wa, err := service.GetWebAuthn()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	sessionData, err := loadPasskeySession(c, sessionKeyPasskeyLogin)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var passkeyUser *service.PasskeyUser
+	_, credential, err := wa.FinishPasskeyLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		user, loadErr := service.LoadPasskeyUserByHandle(userHandle)
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		passkeyUser = user
+		return user, nil
+	}, *sessionData, c.Request)
+	if err != nil || passkeyUser == nil {
+		common.ApiErrorMsg(c, "通行密钥验证失败")
+		return
+	}
+	if passkeyUser.User.Status != common.UserStatusEnabled {
+		common.ApiErrorMsg(c, "用户已被封禁")
+		return
+	}
+	if passkey := passkeyUser.FindPasskey(credential.ID); passkey != nil {
+		if encoded, err := common.Marshal(credential); err == nil {
+			if err = passkey.UpdateCredential(string(encoded)); err != nil {
+				common.SysError("failed to update passkey credential: " + err.Error())
+			}
+		}
+	}
+	completeLogin(passkeyUser.User, c, true)
+}
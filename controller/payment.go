@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/payment"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type paymentRequest struct {
+	Amount        int64  `json:"amount"`
+	PaymentMethod string `json:"payment_method"`
+}
+
+type topUpReviewRequest struct {
+	Remark string `json:"remark"`
+	// 退款时用户余额不足以扣回到账额度仍强制退款，余额将扣为负数
+	Clawback bool `json:"clawback"`
+}
+
+func getPaymentQuote(c *gin.Context, providerName string, amount int64) (float64, error) {
+	provider, err := payment.GetEnabledProvider(providerName)
+	if err != nil {
+		return 0, err
+	}
+	if amount < provider.GetMinTopUp() {
+		return 0, fmt.Errorf("充值数量不能小于 %d", provider.GetMinTopUp())
+	}
+	group, err := model.GetUserGroup(c.GetInt("id"), true)
+	if err != nil {
+		return 0, errors.New("获取用户分组失败")
+	}
+	quote, err := provider.Quote(amount, group)
+	if err != nil {
+		return 0, err
+	}
+	if quote.Money <= 0.01 {
+		return 0, errors.New("充值金额过低")
+	}
+	return quote.Money, nil
+}
+
+func createPaymentOrder(c *gin.Context, providerName string, amount int64, paymentMethod string) (*payment.OrderResult, error) {
+	provider, err := payment.GetEnabledProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	user, err := model.GetUserById(c.GetInt("id"), false)
+	if err != nil {
+		return nil, errors.New("获取用户信息失败")
+	}
+	return payment.CreateOrder(c, provider, &payment.OrderRequest{
+		UserId:        user.Id,
+		Amount:        amount,
+		PaymentMethod: paymentMethod,
+		Group:         user.Group,
+		Email:         user.Email,
+		CustomerId:    user.StripeCustomer,
+	})
+}
+
+func getEnabledPaymentProviderNames() []string {
+	names := make([]string, 0)
+	for _, provider := range payment.GetEnabledProviders() {
+		names = append(names, provider.Name())
+	}
+	return names
+}
+
+// GetPaymentProviders 返回已启用的支付渠道
+func GetPaymentProviders(c *gin.Context) {
+	providers := make([]gin.H, 0)
+	for _, provider := range payment.GetEnabledProviders() {
+		providers = append(providers, gin.H{
+			"name":      provider.Name(),
+			"min_topup": provider.GetMinTopUp(),
+		})
+	}
+	common.ApiSuccess(c, providers)
+}
+
+func RequestPaymentAmount(c *gin.Context) {
+	var req paymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	payMoney, err := getPaymentQuote(c, c.Param("provider"), req.Amount)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, payMoney)
+}
+
+func RequestPayment(c *gin.Context) {
+	var req paymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	result, err := createPaymentOrder(c, c.Param("provider"), req.Amount, req.PaymentMethod)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, result)
+}
+
+// PaymentNotify 各支付渠道的统一回调入口
+func PaymentNotify(c *gin.Context) {
+	provider, err := payment.GetProvider(c.Param("provider"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	payment.HandleCallback(c, provider)
+}
+
+func GetSelfTopUps(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	topUps, total, err := model.GetAllTopUps(c.GetInt("id"), c.Query("status"), "", "", pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(topUps)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetSelfTopUp 查询自己的订单，待支付订单会向支付平台同步状态
+func GetSelfTopUp(c *gin.Context) {
+	topUp := model.GetTopUpByTradeNo(c.Param("trade_no"))
+	if topUp == nil || topUp.UserId != c.GetInt("id") {
+		common.ApiErrorMsg(c, "充值订单不存在")
+		return
+	}
+	synced, err := payment.SyncOrder(topUp)
+	if err != nil {
+		common.SysError("failed to sync top up order: " + err.Error())
+	} else if synced != nil {
+		topUp = synced
+	}
+	common.ApiSuccess(c, topUp)
+}
+
+func GetAllTopUps(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	topUps, total, err := model.GetAllTopUps(userId, c.Query("status"), c.Query("provider"), c.Query("trade_no"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(topUps)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func getTopUpParam(c *gin.Context) (*model.TopUp, *topUpReviewRequest, bool) {
+	var req topUpReviewRequest
+	_ = c.ShouldBindJSON(&req)
+	topUp := model.GetTopUpByTradeNo(c.Param("trade_no"))
+	if topUp == nil {
+		common.ApiErrorMsg(c, "充值订单不存在")
+		return nil, nil, false
+	}
+	return topUp, &req, true
+}
+
+// ConfirmTopUp 管理员确认到账，用于银行转账等线下支付
+func ConfirmTopUp(c *gin.Context) {
+	topUp, _, ok := getTopUpParam(c)
+	if !ok {
+		return
+	}
+	topUp, err := payment.ApplyEvent(&payment.Event{TradeNo: topUp.TradeNo, Status: common.TopUpStatusSuccess})
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, topUp)
+}
+
+func CancelTopUp(c *gin.Context) {
+	topUp, req, ok := getTopUpParam(c)
+	if !ok {
+		return
+	}
+	topUp, err := model.TransitTopUpStatus(topUp.TradeNo, common.TopUpStatusCancelled, req.Remark)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, topUp)
+}
+
+// RefundTopUp 在支付平台发起退款并扣回到账额度
+func RefundTopUp(c *gin.Context) {
+	topUp, req, ok := getTopUpParam(c)
+	if !ok {
+		return
+	}
+	topUp, err := payment.RefundOrder(topUp, req.Remark, req.Clawback)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, topUp)
+}
+
+// SyncTopUp 向支付平台查询并同步订单状态
+func SyncTopUp(c *gin.Context) {
+	topUp, _, ok := getTopUpParam(c)
+	if !ok {
+		return
+	}
+	topUp, err := payment.SyncOrder(topUp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, topUp)
+}
@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SimulatePricing 使用拟调整的倍率/价格回放历史消费日志，在通过 UpdateOption 生效前评估收入变化
+func SimulatePricing(c *gin.Context) {
+	var req dto.PriceSimulationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	result, err := service.SimulatePricing(&req)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, result)
+}
@@ -3,14 +3,43 @@ package controller
 import (
 	"one-api/model"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
 	"one-api/setting/ratio_setting"
 
 	"github.com/gin-gonic/gin"
 )
 
+// 模型倍率为 1 时每百万 tokens 的美元价格
+const usdPerMillionTokensPerRatio = 2.0
+
+type pricingDisplay struct {
+	model.Pricing
+	// 按次计费时的单次价格（展示币种）
+	Price float64 `json:"price,omitempty"`
+	// 按量计费时每百万 tokens 的输入、输出价格（展示币种）
+	InputPrice  float64 `json:"input_price,omitempty"`
+	OutputPrice float64 `json:"output_price,omitempty"`
+}
+
+func buildPricingDisplay(pricing []model.Pricing, currency operation_setting.CurrencyInfo) []pricingDisplay {
+	result := make([]pricingDisplay, 0, len(pricing))
+	for _, p := range pricing {
+		item := pricingDisplay{Pricing: p}
+		if p.QuotaType == 1 {
+			item.Price = p.ModelPrice * currency.Rate
+		} else {
+			item.InputPrice = p.ModelRatio * usdPerMillionTokensPerRatio * currency.Rate
+			item.OutputPrice = item.InputPrice * p.CompletionRatio
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
 func GetPricing(c *gin.Context) {
 	pricing := model.GetPricing()
 	userId, exists := c.Get("id")
+	preferredCurrency := c.Query("currency")
 	usableGroup := map[string]string{}
 	groupRatio := map[string]float64{}
 	for s, f := range ratio_setting.GetGroupRatioCopy() {
@@ -21,6 +50,9 @@ func GetPricing(c *gin.Context) {
 		user, err := model.GetUserCache(userId.(int))
 		if err == nil {
 			group = user.Group
+			if preferredCurrency == "" {
+				preferredCurrency = user.GetSetting().Currency
+			}
 			for g := range groupRatio {
 				ratio, ok := ratio_setting.GetGroupGroupRatio(group, g)
 				if ok {
@@ -38,11 +70,14 @@ func GetPricing(c *gin.Context) {
 		}
 	}
 
+	currency := operation_setting.GetCurrencyInfo(preferredCurrency)
+
 	c.JSON(200, gin.H{
 		"success":      true,
-		"data":         pricing,
+		"data":         buildPricingDisplay(pricing, currency),
 		"group_ratio":  groupRatio,
 		"usable_group": usableGroup,
+		"currency":     currency,
 	})
 }
 
@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type refundRequest struct {
+	LogId     int    `json:"log_id"`
+	CreatedAt int64  `json:"created_at"`
+	Reason    string `json:"reason"`
+}
+
+type refundReviewRequest struct {
+	Quota  int    `json:"quota"`
+	Remark string `json:"remark"`
+}
+
+func GetAllRefunds(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	refunds, total, err := model.GetAllRefunds(c.Query("status"), c.Query("username"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(refunds)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetUserRefunds(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	refunds, total, err := model.GetUserRefunds(c.GetInt("id"), c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(refunds)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// RequestRefund 用户对自己的消费日志发起退款申请，log_id 为日志列表中展示的编号
+func RequestRefund(c *gin.Context) {
+	refundSetting := operation_setting.GetRefundSetting()
+	if !refundSetting.UserRequestEnabled {
+		common.ApiErrorMsg(c, "管理员未开启退款申请")
+		return
+	}
+	var req refundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		common.ApiErrorMsg(c, "请填写退款原因")
+		return
+	}
+	if len(req.Reason) > 255 {
+		common.ApiErrorMsg(c, "退款原因过长")
+		return
+	}
+	userId := c.GetInt("id")
+	log, err := model.GetUserConsumeLog(userId, req.LogId, req.CreatedAt)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if log.Quota <= 0 {
+		common.ApiErrorMsg(c, "该日志未产生扣费，无需退款")
+		return
+	}
+	if refundSetting.RequestWindowHours > 0 &&
+		common.GetTimestamp()-log.CreatedAt > int64(refundSetting.RequestWindowHours)*3600 {
+		common.ApiErrorMsg(c, "已超过可申请退款的时间")
+		return
+	}
+	refund := model.NewRefundFromLog(log, req.Reason)
+	if err = refund.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	refund.LogId = refund.LogId % 1024
+	common.ApiSuccess(c, refund)
+}
+
+func ApproveRefund(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req refundReviewRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Quota < 0 {
+		common.ApiError(c, errors.New("退款额度不能为负数"))
+		return
+	}
+	refund, err := model.ApproveRefund(id, c.GetInt("id"), req.Quota, req.Remark)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, refund)
+}
+
+func RejectRefund(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req refundReviewRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if strings.TrimSpace(req.Remark) == "" {
+		common.ApiErrorMsg(c, "请填写拒绝原因")
+		return
+	}
+	refund, err := model.RejectRefund(id, c.GetInt("id"), req.Remark)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, refund)
+}
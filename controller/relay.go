@@ -88,10 +88,11 @@ func Relay(c *gin.Context) {
 		newAPIError = relayRequest(c, relayMode, channel)
 
 		if newAPIError == nil {
+			service.RecordAbilitySuccess(channel.Id, originalModel)
 			return // 成功处理请求，直接返回
 		}
 
-		go processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
+		go processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), originalModel, newAPIError)
 
 		if !shouldRetry(c, newAPIError, common.RetryTimes-i) {
 			break
@@ -151,10 +152,11 @@ func WssRelay(c *gin.Context) {
 		newAPIError = wssRequest(c, ws, relayMode, channel)
 
 		if newAPIError == nil {
+			service.RecordAbilitySuccess(channel.Id, originalModel)
 			return // 成功处理请求，直接返回
 		}
 
-		go processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
+		go processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), originalModel, newAPIError)
 
 		if !shouldRetry(c, newAPIError, common.RetryTimes-i) {
 			break
@@ -194,10 +196,11 @@ func RelayClaude(c *gin.Context) {
 		newAPIError = claudeRequest(c, channel)
 
 		if newAPIError == nil {
+			service.RecordAbilitySuccess(channel.Id, originalModel)
 			return // 成功处理请求，直接返回
 		}
 
-		go processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
+		go processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), originalModel, newAPIError)
 
 		if !shouldRetry(c, newAPIError, common.RetryTimes-i) {
 			break
@@ -220,6 +223,7 @@ func RelayClaude(c *gin.Context) {
 
 func relayRequest(c *gin.Context, relayMode int, channel *model.Channel) *types.NewAPIError {
 	addUsedChannel(c, channel.Id)
+	defer service.BeginChannelRequest(channel.Id)()
 	requestBody, _ := common.GetRequestBody(c)
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 	return relayHandler(c, relayMode)
@@ -227,6 +231,7 @@ func relayRequest(c *gin.Context, relayMode int, channel *model.Channel) *types.
 
 func wssRequest(c *gin.Context, ws *websocket.Conn, relayMode int, channel *model.Channel) *types.NewAPIError {
 	addUsedChannel(c, channel.Id)
+	defer service.BeginChannelRequest(channel.Id)()
 	requestBody, _ := common.GetRequestBody(c)
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 	return relay.WssHelper(c, ws)
@@ -234,6 +239,7 @@ func wssRequest(c *gin.Context, ws *websocket.Conn, relayMode int, channel *mode
 
 func claudeRequest(c *gin.Context, channel *model.Channel) *types.NewAPIError {
 	addUsedChannel(c, channel.Id)
+	defer service.BeginChannelRequest(channel.Id)()
 	requestBody, _ := common.GetRequestBody(c)
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 	return relay.ClaudeHelper(c)
@@ -319,10 +325,17 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	return true
 }
 
-func processChannelError(c *gin.Context, channelError types.ChannelError, err *types.NewAPIError) {
+func processChannelError(c *gin.Context, channelError types.ChannelError, modelName string, err *types.NewAPIError) {
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
 	// do not use context to get channel info, there may be inconsistent channel info when processing asynchronously
 	common.LogError(c, fmt.Sprintf("relay error (channel #%d, status code: %d): %s", channelError.ChannelId, err.StatusCode, err.Error()))
+	// 错误只与模型有关时只禁用渠道的该模型，不影响其他模型；连续失败达到阈值才禁用
+	if modelName != "" && service.ShouldDisableAbility(err) {
+		if service.RecordAbilityFailure(channelError.ChannelId, modelName, err.Error()) && channelError.AutoBan {
+			service.DisableAbility(channelError, modelName, err.Error())
+		}
+		return
+	}
 	if service.ShouldDisableChannel(channelError.ChannelId, err) && channelError.AutoBan {
 		service.DisableChannel(channelError, err.Error())
 	}
@@ -339,9 +352,13 @@ func RelayMidjourney(c *gin.Context) {
 	case relayconstant.RelayModeMidjourneyTaskImageSeed:
 		err = relay.RelayMidjourneyTaskImageSeed(c)
 	case relayconstant.RelayModeSwapFace:
-		err = relay.RelaySwapFace(c)
+		err = relayMidjourneySubmit(c, func() *dto.MidjourneyResponse {
+			return relay.RelaySwapFace(c)
+		})
 	default:
-		err = relay.RelayMidjourneySubmit(c, relayMode)
+		err = relayMidjourneySubmit(c, func() *dto.MidjourneyResponse {
+			return relay.RelayMidjourneySubmit(c, relayMode)
+		})
 	}
 	//err = relayMidjourneySubmit(c, relayMode)
 	log.Println(err)
@@ -361,6 +378,12 @@ func RelayMidjourney(c *gin.Context) {
 	}
 }
 
+// relayMidjourneySubmit 提交任务期间计入渠道进行中的请求，排空时等待提交完成
+func relayMidjourneySubmit(c *gin.Context, submit func() *dto.MidjourneyResponse) *dto.MidjourneyResponse {
+	defer service.BeginChannelRequest(c.GetInt("channel_id"))()
+	return submit()
+}
+
 func RelayNotImplemented(c *gin.Context) {
 	err := dto.OpenAIError{
 		Message: "API not implemented",
@@ -428,14 +451,12 @@ func RelayTask(c *gin.Context) {
 }
 
 func taskRelayHandler(c *gin.Context, relayMode int) *dto.TaskError {
-	var err *dto.TaskError
-	switch relayMode {
-	case relayconstant.RelayModeSunoFetch, relayconstant.RelayModeSunoFetchByID, relayconstant.RelayModeKlingFetchByID:
-		err = relay.RelayTaskFetch(c, relayMode)
-	default:
-		err = relay.RelayTaskSubmit(c, relayMode)
+	if relay.IsTaskFetchRelayMode(relayMode) {
+		return relay.RelayTaskFetch(c, relayMode)
 	}
-	return err
+	// 重试时 channel_id 已切换为新选择的渠道
+	defer service.BeginChannelRequest(c.GetInt("channel_id"))()
+	return relay.RelayTaskSubmit(c, relayMode)
 }
 
 func shouldRetryTaskRelay(c *gin.Context, channelId int, taskErr *dto.TaskError, retryTimes int) bool {
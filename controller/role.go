@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type roleRequest struct {
+	Id          int      `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+func GetAllRoles(c *gin.Context) {
+	roles, err := model.GetAllRoles()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, roles)
+}
+
+// GetAllPermissions 返回全部可分配的权限及各权限等级的默认权限
+func GetAllPermissions(c *gin.Context) {
+	common.ApiSuccess(c, gin.H{
+		"permissions": common.AllPermissions,
+		"defaults": gin.H{
+			"admin": common.GetDefaultPermissions(common.RoleAdminUser),
+			"root":  common.GetDefaultPermissions(common.RoleRootUser),
+		},
+	})
+}
+
+func bindRole(c *gin.Context) (*model.Role, bool) {
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return nil, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 64 {
+		common.ApiErrorMsg(c, "角色名称不能为空且长度不能超过 64")
+		return nil, false
+	}
+	role := &model.Role{
+		Id:          req.Id,
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	if err := role.SetPermissions(req.Permissions); err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	return role, true
+}
+
+func AddRole(c *gin.Context) {
+	role, ok := bindRole(c)
+	if !ok {
+		return
+	}
+	role.Id = 0
+	if err := role.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.RecordAudit(c, "role.create", "role", strconv.Itoa(role.Id), nil, role)
+	common.ApiSuccess(c, role)
+}
+
+func UpdateRole(c *gin.Context) {
+	role, ok := bindRole(c)
+	if !ok {
+		return
+	}
+	originRole, err := model.GetRoleById(role.Id)
+	if err != nil {
+		common.ApiErrorMsg(c, "角色不存在")
+		return
+	}
+	role.CreatedTime = originRole.CreatedTime
+	if err := role.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.RecordAudit(c, "role.update", "role", strconv.Itoa(role.Id), originRole, role)
+	common.ApiSuccess(c, role)
+}
+
+// DeleteRole 删除角色，已分配该角色的用户恢复为默认权限
+func DeleteRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	originRole, err := model.GetRoleById(id)
+	if err != nil {
+		common.ApiErrorMsg(c, "角色不存在")
+		return
+	}
+	if err = model.DeleteRoleById(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.RecordAudit(c, "role.delete", "role", strconv.Itoa(id), originRole, nil)
+	common.ApiSuccess(c, nil)
+}
@@ -0,0 +1,864 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	scimDefaultCount = 100
+	scimMaxCount     = 200
+)
+
+// 仅支持 attribute eq "value" 形式的过滤条件，足以满足主流身份提供商的查重请求
+var scimFilterPattern = regexp.MustCompile(`(?i)^\s*([a-z.]+)\s+eq\s+"((?:[^"\\]|\\.)*)"\s*$`)
+
+var scimMemberPathPattern = regexp.MustCompile(`(?i)^members\[value\s+eq\s+"([^"]*)"\]$`)
+
+// scimUserChanges POST、PUT 与 PATCH 请求中需要写入的用户属性，nil 表示不修改
+type scimUserChanges struct {
+	UserName    *string
+	ExternalId  *string
+	DisplayName *string
+	Email       *string
+	Active      *bool
+}
+
+func scimJSON(c *gin.Context, status int, obj any) {
+	c.Header("Content-Type", "application/scim+json")
+	c.JSON(status, obj)
+}
+
+func scimError(c *gin.Context, status int, scimType string, detail string) {
+	scimJSON(c, status, dto.ScimError{
+		Schemas:  []string{dto.ScimSchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+func scimTime(timestamp int64) string {
+	if timestamp == 0 {
+		return ""
+	}
+	return time.Unix(timestamp, 0).UTC().Format(time.RFC3339)
+}
+
+func scimLocation(resource string, id int) string {
+	return fmt.Sprintf("%s/scim/v2/%s/%d", strings.TrimSuffix(setting.ServerAddress, "/"), resource, id)
+}
+
+// getScimPagination 解析从 1 开始的 startIndex 与 count
+func getScimPagination(c *gin.Context) (int, int) {
+	startIndex, _ := strconv.Atoi(c.Query("startIndex"))
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.Query("count"))
+	if err != nil || count < 0 {
+		count = scimDefaultCount
+	}
+	if count > scimMaxCount {
+		count = scimMaxCount
+	}
+	return startIndex, count
+}
+
+func parseScimFilter(filter string) (string, string, error) {
+	if strings.TrimSpace(filter) == "" {
+		return "", "", nil
+	}
+	matches := scimFilterPattern.FindStringSubmatch(filter)
+	if matches == nil {
+		return "", "", errors.New("only 'attribute eq \"value\"' filters are supported")
+	}
+	value := strings.ReplaceAll(matches[2], `\"`, `"`)
+	return strings.ToLower(matches[1]), value, nil
+}
+
+func scimListResponse(c *gin.Context, resources []any, total int64, startIndex int) {
+	scimJSON(c, http.StatusOK, dto.ScimListResponse{
+		Schemas:      []string{dto.ScimSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) > max {
+		return string(runes[:max])
+	}
+	return s
+}
+
+func parseScimBool(raw json.RawMessage) (bool, error) {
+	var value bool
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return value, nil
+	}
+	var str string
+	if err := json.Unmarshal(raw, &str); err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(strings.ToLower(str))
+}
+
+func parseScimString(raw json.RawMessage) (string, error) {
+	var value string
+	err := json.Unmarshal(raw, &value)
+	return strings.TrimSpace(value), err
+}
+
+// getPrimaryScimEmail 优先返回 primary 邮箱，其次返回第一个邮箱
+func getPrimaryScimEmail(emails []dto.ScimMultiValue) (string, bool) {
+	if len(emails) == 0 {
+		return "", false
+	}
+	for _, email := range emails {
+		if email.Primary {
+			return strings.TrimSpace(email.Value), true
+		}
+	}
+	return strings.TrimSpace(emails[0].Value), true
+}
+
+func getScimDisplayName(user *dto.ScimUser) string {
+	if user.DisplayName != "" {
+		return user.DisplayName
+	}
+	if user.Name != nil {
+		if user.Name.Formatted != "" {
+			return user.Name.Formatted
+		}
+		if name := strings.TrimSpace(user.Name.GivenName + " " + user.Name.FamilyName); name != "" {
+			return name
+		}
+	}
+	return user.UserName
+}
+
+func scimUserChangesFromResource(user *dto.ScimUser) scimUserChanges {
+	userName := strings.TrimSpace(user.UserName)
+	externalId := strings.TrimSpace(user.ExternalId)
+	displayName := getScimDisplayName(user)
+	changes := scimUserChanges{
+		UserName:    &userName,
+		ExternalId:  &externalId,
+		DisplayName: &displayName,
+		Active:      user.Active,
+	}
+	if email, ok := getPrimaryScimEmail(user.Emails); ok {
+		changes.Email = &email
+	}
+	return changes
+}
+
+// applyScimUserPatchValue 处理 PATCH 中的单个属性，未知属性会被忽略
+func applyScimUserPatchValue(changes *scimUserChanges, op string, path string, raw json.RawMessage) error {
+	path = strings.ToLower(path)
+	if op == "remove" {
+		if path == "externalid" {
+			empty := ""
+			changes.ExternalId = &empty
+		}
+		return nil
+	}
+	switch {
+	case path == "active":
+		active, err := parseScimBool(raw)
+		if err != nil {
+			return err
+		}
+		changes.Active = &active
+	case path == "username":
+		userName, err := parseScimString(raw)
+		if err != nil {
+			return err
+		}
+		changes.UserName = &userName
+	case path == "externalid":
+		externalId, err := parseScimString(raw)
+		if err != nil {
+			return err
+		}
+		changes.ExternalId = &externalId
+	case path == "displayname" || path == "name.formatted":
+		displayName, err := parseScimString(raw)
+		if err != nil {
+			return err
+		}
+		changes.DisplayName = &displayName
+	case path == "emails":
+		var emails []dto.ScimMultiValue
+		if err := json.Unmarshal(raw, &emails); err != nil {
+			return err
+		}
+		if email, ok := getPrimaryScimEmail(emails); ok {
+			changes.Email = &email
+		}
+	case strings.HasPrefix(path, "emails[") && strings.HasSuffix(path, ".value"), path == "emails.value":
+		email, err := parseScimString(raw)
+		if err != nil {
+			return err
+		}
+		changes.Email = &email
+	}
+	return nil
+}
+
+func scimUserChangesFromPatch(req *dto.ScimPatchRequest) (scimUserChanges, error) {
+	var changes scimUserChanges
+	for _, operation := range req.Operations {
+		op := strings.ToLower(operation.Op)
+		if op != "add" && op != "replace" && op != "remove" {
+			return changes, fmt.Errorf("unsupported op %s", operation.Op)
+		}
+		if operation.Path != "" {
+			if err := applyScimUserPatchValue(&changes, op, operation.Path, operation.Value); err != nil {
+				return changes, err
+			}
+			continue
+		}
+		// 未指定 path 时 value 为属性对象
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &values); err != nil {
+			return changes, err
+		}
+		for path, raw := range values {
+			if err := applyScimUserPatchValue(&changes, op, path, raw); err != nil {
+				return changes, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+func buildScimUser(scimUser *model.ScimUser, user *model.User) (*dto.ScimUser, error) {
+	groups, err := model.GetUserScimGroups(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	active := user.Status == common.UserStatusEnabled
+	resource := &dto.ScimUser{
+		Schemas:     []string{dto.ScimSchemaUser},
+		Id:          strconv.Itoa(user.Id),
+		ExternalId:  scimUser.ExternalId,
+		UserName:    scimUser.UserName,
+		Name:        &dto.ScimName{Formatted: user.DisplayName},
+		DisplayName: user.DisplayName,
+		Active:      &active,
+		Meta: &dto.ScimMeta{
+			ResourceType: "User",
+			Created:      scimTime(scimUser.CreatedTime),
+			LastModified: scimTime(scimUser.UpdatedTime),
+			Location:     scimLocation("Users", user.Id),
+		},
+	}
+	if user.Email != "" {
+		resource.Emails = []dto.ScimMultiValue{{Value: user.Email, Type: "work", Primary: true}}
+	}
+	for _, group := range groups {
+		resource.Groups = append(resource.Groups, dto.ScimMultiValue{
+			Value:   strconv.Itoa(group.Id),
+			Display: group.DisplayName,
+			Ref:     scimLocation("Groups", group.Id),
+		})
+	}
+	return resource, nil
+}
+
+// getScimUserParam 加载路径参数对应的 SCIM 用户，失败时已写入错误响应
+func getScimUserParam(c *gin.Context) (*model.ScimUser, *model.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		scimError(c, http.StatusNotFound, "", "user not found")
+		return nil, nil, false
+	}
+	scimUser, err := model.GetScimUserByUserId(id)
+	if err != nil {
+		scimError(c, http.StatusNotFound, "", "user not found")
+		return nil, nil, false
+	}
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		scimError(c, http.StatusNotFound, "", "user not found")
+		return nil, nil, false
+	}
+	return scimUser, user, true
+}
+
+func respondScimUser(c *gin.Context, status int, scimUser *model.ScimUser, user *model.User) {
+	resource, err := buildScimUser(scimUser, user)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	scimJSON(c, status, resource)
+}
+
+// applyScimUserChanges 写入用户属性与 SCIM 关联信息，冲突时返回的错误已写入响应
+func applyScimUserChanges(c *gin.Context, scimUser *model.ScimUser, user *model.User, changes scimUserChanges) bool {
+	if changes.UserName != nil && *changes.UserName != scimUser.UserName {
+		if *changes.UserName == "" {
+			scimError(c, http.StatusBadRequest, "invalidValue", "userName is required")
+			return false
+		}
+		if existing, err := model.GetScimUserByUserName(*changes.UserName); err == nil && existing.UserId != user.Id {
+			scimError(c, http.StatusConflict, "uniqueness", "userName already exists")
+			return false
+		}
+		scimUser.UserName = *changes.UserName
+	}
+	if changes.ExternalId != nil {
+		scimUser.ExternalId = *changes.ExternalId
+	}
+	if err := scimUser.Update(); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return false
+	}
+	updates := make(map[string]interface{})
+	if changes.DisplayName != nil && *changes.DisplayName != "" {
+		user.DisplayName = truncateRunes(*changes.DisplayName, 20)
+		updates["display_name"] = user.DisplayName
+	}
+	if changes.Email != nil && len(*changes.Email) <= 50 {
+		user.Email = *changes.Email
+		updates["email"] = user.Email
+	}
+	if changes.Active != nil {
+		user.Status = common.UserStatusEnabled
+		if !*changes.Active {
+			user.Status = common.UserStatusDisabled
+		}
+		updates["status"] = user.Status
+	}
+	if err := model.UpdateScimUserFields(user.Id, updates); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return false
+	}
+	return true
+}
+
+func GetScimUsers(c *gin.Context) {
+	attribute, value, err := parseScimFilter(c.Query("filter"))
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+	var filter model.ScimUserFilter
+	switch attribute {
+	case "":
+	case "username":
+		filter.UserName = value
+	case "externalid":
+		filter.ExternalId = value
+	case "emails", "emails.value":
+		filter.Email = value
+	default:
+		scimError(c, http.StatusBadRequest, "invalidFilter", "unsupported filter attribute "+attribute)
+		return
+	}
+	startIndex, count := getScimPagination(c)
+	scimUsers, total, err := model.GetScimUsers(filter, startIndex-1, count)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	resources := make([]any, 0, len(scimUsers))
+	for _, scimUser := range scimUsers {
+		user, err := model.GetUserById(scimUser.UserId, false)
+		if err != nil {
+			continue
+		}
+		resource, err := buildScimUser(scimUser, user)
+		if err != nil {
+			scimError(c, http.StatusInternalServerError, "", err.Error())
+			return
+		}
+		resources = append(resources, resource)
+	}
+	scimListResponse(c, resources, total, startIndex)
+}
+
+func GetScimUser(c *gin.Context) {
+	scimUser, user, ok := getScimUserParam(c)
+	if !ok {
+		return
+	}
+	respondScimUser(c, http.StatusOK, scimUser, user)
+}
+
+func CreateScimUser(c *gin.Context) {
+	var req dto.ScimUser
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	changes := scimUserChangesFromResource(&req)
+	if *changes.UserName == "" {
+		scimError(c, http.StatusBadRequest, "invalidValue", "userName is required")
+		return
+	}
+	if _, err := model.GetScimUserByUserName(*changes.UserName); err == nil {
+		scimError(c, http.StatusConflict, "uniqueness", "userName already exists")
+		return
+	}
+	user := &model.User{
+		Username:    model.GenerateScimUsername(*changes.UserName),
+		DisplayName: truncateRunes(*changes.DisplayName, 20),
+		Status:      common.UserStatusEnabled,
+	}
+	if changes.Email != nil && len(*changes.Email) <= 50 {
+		user.Email = *changes.Email
+	}
+	if changes.Active != nil && !*changes.Active {
+		user.Status = common.UserStatusDisabled
+	}
+	scimUser, err := model.CreateScimUser(user, *changes.UserName, *changes.ExternalId)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	service.SyncScimUserGroup(user.Id)
+	service.RecordAudit(c, "scim.user.create", "user", strconv.Itoa(user.Id), nil, user)
+	respondScimUser(c, http.StatusCreated, scimUser, user)
+}
+
+func ReplaceScimUser(c *gin.Context) {
+	scimUser, user, ok := getScimUserParam(c)
+	if !ok {
+		return
+	}
+	var req dto.ScimUser
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	before := *user
+	if !applyScimUserChanges(c, scimUser, user, scimUserChangesFromResource(&req)) {
+		return
+	}
+	service.RecordAudit(c, "scim.user.update", "user", strconv.Itoa(user.Id), before, user)
+	respondScimUser(c, http.StatusOK, scimUser, user)
+}
+
+func PatchScimUser(c *gin.Context) {
+	scimUser, user, ok := getScimUserParam(c)
+	if !ok {
+		return
+	}
+	var req dto.ScimPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	changes, err := scimUserChangesFromPatch(&req)
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	before := *user
+	if !applyScimUserChanges(c, scimUser, user, changes) {
+		return
+	}
+	service.RecordAudit(c, "scim.user.update", "user", strconv.Itoa(user.Id), before, user)
+	respondScimUser(c, http.StatusOK, scimUser, user)
+}
+
+func DeleteScimUser(c *gin.Context) {
+	_, user, ok := getScimUserParam(c)
+	if !ok {
+		return
+	}
+	if err := model.DeleteScimUser(user.Id); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	service.RecordAudit(c, "scim.user.delete", "user", strconv.Itoa(user.Id), user, nil)
+	c.Status(http.StatusNoContent)
+}
+
+func buildScimGroup(group *model.ScimGroup, withMembers bool) (*dto.ScimGroup, error) {
+	resource := &dto.ScimGroup{
+		Schemas:     []string{dto.ScimSchemaGroup},
+		Id:          strconv.Itoa(group.Id),
+		ExternalId:  group.ExternalId,
+		DisplayName: group.DisplayName,
+		Meta: &dto.ScimMeta{
+			ResourceType: "Group",
+			Created:      scimTime(group.CreatedTime),
+			LastModified: scimTime(group.UpdatedTime),
+			Location:     scimLocation("Groups", group.Id),
+		},
+	}
+	if !withMembers {
+		return resource, nil
+	}
+	memberIds, err := model.GetScimGroupMemberIds(group.Id)
+	if err != nil {
+		return nil, err
+	}
+	for _, userId := range memberIds {
+		resource.Members = append(resource.Members, dto.ScimMultiValue{
+			Value: strconv.Itoa(userId),
+			Ref:   scimLocation("Users", userId),
+		})
+	}
+	return resource, nil
+}
+
+// scimGroupWithMembers 身份提供商可以通过 excludedAttributes=members 跳过成员列表
+func scimGroupWithMembers(c *gin.Context) bool {
+	return !strings.Contains(strings.ToLower(c.Query("excludedAttributes")), "members")
+}
+
+func respondScimGroup(c *gin.Context, status int, group *model.ScimGroup) {
+	resource, err := buildScimGroup(group, scimGroupWithMembers(c))
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	scimJSON(c, status, resource)
+}
+
+func getScimGroupParam(c *gin.Context) (*model.ScimGroup, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		scimError(c, http.StatusNotFound, "", "group not found")
+		return nil, false
+	}
+	group, err := model.GetScimGroupById(id)
+	if err != nil {
+		scimError(c, http.StatusNotFound, "", "group not found")
+		return nil, false
+	}
+	return group, true
+}
+
+func parseScimMemberIds(members []dto.ScimMultiValue) []int {
+	userIds := make([]int, 0, len(members))
+	for _, member := range members {
+		if userId, err := strconv.Atoi(member.Value); err == nil {
+			userIds = append(userIds, userId)
+		}
+	}
+	return userIds
+}
+
+// checkScimGroupName 组名唯一，冲突时返回 false 并写入错误响应
+func checkScimGroupName(c *gin.Context, displayName string, groupId int) bool {
+	if displayName == "" {
+		scimError(c, http.StatusBadRequest, "invalidValue", "displayName is required")
+		return false
+	}
+	groups, _, err := model.GetScimGroups(displayName, "", 0, 1)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return false
+	}
+	if len(groups) > 0 && groups[0].Id != groupId {
+		scimError(c, http.StatusConflict, "uniqueness", "displayName already exists")
+		return false
+	}
+	return true
+}
+
+// syncScimUsers 成员或组名变化后重新计算相关用户的网关分组
+func syncScimUsers(userIds []int) {
+	for _, userId := range userIds {
+		service.SyncScimUserGroup(userId)
+	}
+}
+
+func GetScimGroups(c *gin.Context) {
+	attribute, value, err := parseScimFilter(c.Query("filter"))
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+	var displayName, externalId string
+	switch attribute {
+	case "":
+	case "displayname":
+		displayName = value
+	case "externalid":
+		externalId = value
+	default:
+		scimError(c, http.StatusBadRequest, "invalidFilter", "unsupported filter attribute "+attribute)
+		return
+	}
+	startIndex, count := getScimPagination(c)
+	groups, total, err := model.GetScimGroups(displayName, externalId, startIndex-1, count)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	withMembers := scimGroupWithMembers(c)
+	resources := make([]any, 0, len(groups))
+	for _, group := range groups {
+		resource, err := buildScimGroup(group, withMembers)
+		if err != nil {
+			scimError(c, http.StatusInternalServerError, "", err.Error())
+			return
+		}
+		resources = append(resources, resource)
+	}
+	scimListResponse(c, resources, total, startIndex)
+}
+
+func GetScimGroup(c *gin.Context) {
+	group, ok := getScimGroupParam(c)
+	if !ok {
+		return
+	}
+	respondScimGroup(c, http.StatusOK, group)
+}
+
+func CreateScimGroup(c *gin.Context) {
+	var req dto.ScimGroup
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	group := &model.ScimGroup{
+		DisplayName: strings.TrimSpace(req.DisplayName),
+		ExternalId:  strings.TrimSpace(req.ExternalId),
+	}
+	if !checkScimGroupName(c, group.DisplayName, 0) {
+		return
+	}
+	if err := group.Insert(); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	added, err := model.AddScimGroupMembers(group.Id, parseScimMemberIds(req.Members))
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	syncScimUsers(added)
+	service.RecordAudit(c, "scim.group.create", "scim_group", strconv.Itoa(group.Id), nil, group)
+	respondScimGroup(c, http.StatusCreated, group)
+}
+
+func ReplaceScimGroup(c *gin.Context) {
+	group, ok := getScimGroupParam(c)
+	if !ok {
+		return
+	}
+	var req dto.ScimGroup
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	before := *group
+	group.DisplayName = strings.TrimSpace(req.DisplayName)
+	group.ExternalId = strings.TrimSpace(req.ExternalId)
+	if !checkScimGroupName(c, group.DisplayName, group.Id) {
+		return
+	}
+	if err := group.Update(); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	affected, err := model.ReplaceScimGroupMembers(group.Id, parseScimMemberIds(req.Members))
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	syncScimUsers(affected)
+	service.RecordAudit(c, "scim.group.update", "scim_group", strconv.Itoa(group.Id), before, group)
+	respondScimGroup(c, http.StatusOK, group)
+}
+
+// patchScimGroupMembers 处理成员的 add / remove / replace，返回成员变化涉及的用户
+func patchScimGroupMembers(groupId int, op string, path string, raw json.RawMessage) ([]int, error) {
+	var members []dto.ScimMultiValue
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &members); err != nil {
+			return nil, err
+		}
+	}
+	userIds := parseScimMemberIds(members)
+	if matches := scimMemberPathPattern.FindStringSubmatch(path); matches != nil {
+		userId, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return nil, nil
+		}
+		userIds = []int{userId}
+	}
+	switch op {
+	case "add":
+		return model.AddScimGroupMembers(groupId, userIds)
+	case "remove":
+		if strings.EqualFold(path, "members") && len(userIds) == 0 {
+			return model.ReplaceScimGroupMembers(groupId, nil)
+		}
+		return userIds, model.RemoveScimGroupMembers(groupId, userIds)
+	default:
+		return model.ReplaceScimGroupMembers(groupId, userIds)
+	}
+}
+
+func PatchScimGroup(c *gin.Context) {
+	group, ok := getScimGroupParam(c)
+	if !ok {
+		return
+	}
+	var req dto.ScimPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	before := *group
+	affected := make([]int, 0)
+	renamed := false
+	for _, operation := range req.Operations {
+		op := strings.ToLower(operation.Op)
+		if op != "add" && op != "replace" && op != "remove" {
+			scimError(c, http.StatusBadRequest, "invalidValue", "unsupported op "+operation.Op)
+			return
+		}
+		values := map[string]json.RawMessage{}
+		if operation.Path != "" {
+			values[operation.Path] = operation.Value
+		} else if err := json.Unmarshal(operation.Value, &values); err != nil {
+			scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+			return
+		}
+		for path, raw := range values {
+			lowerPath := strings.ToLower(path)
+			switch {
+			case lowerPath == "members" || strings.HasPrefix(lowerPath, "members["):
+				userIds, err := patchScimGroupMembers(group.Id, op, path, raw)
+				if err != nil {
+					scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+					return
+				}
+				affected = append(affected, userIds...)
+			case lowerPath == "displayname" && op != "remove":
+				displayName, err := parseScimString(raw)
+				if err != nil {
+					scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+					return
+				}
+				if !checkScimGroupName(c, displayName, group.Id) {
+					return
+				}
+				renamed = renamed || displayName != group.DisplayName
+				group.DisplayName = displayName
+			case lowerPath == "externalid":
+				externalId := ""
+				if op != "remove" {
+					var err error
+					if externalId, err = parseScimString(raw); err != nil {
+						scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+						return
+					}
+				}
+				group.ExternalId = externalId
+			}
+		}
+	}
+	if err := group.Update(); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	if renamed {
+		memberIds, err := model.GetScimGroupMemberIds(group.Id)
+		if err != nil {
+			scimError(c, http.StatusInternalServerError, "", err.Error())
+			return
+		}
+		affected = append(affected, memberIds...)
+	}
+	syncScimUsers(affected)
+	service.RecordAudit(c, "scim.group.update", "scim_group", strconv.Itoa(group.Id), before, group)
+	respondScimGroup(c, http.StatusOK, group)
+}
+
+func DeleteScimGroup(c *gin.Context) {
+	group, ok := getScimGroupParam(c)
+	if !ok {
+		return
+	}
+	memberIds, err := model.DeleteScimGroup(group.Id)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	syncScimUsers(memberIds)
+	service.RecordAudit(c, "scim.group.delete", "scim_group", strconv.Itoa(group.Id), group, nil)
+	c.Status(http.StatusNoContent)
+}
+
+func GetScimServiceProviderConfig(c *gin.Context) {
+	scimJSON(c, http.StatusOK, gin.H{
+		"schemas":        []string{dto.ScimSchemaServiceProviderConfig},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": scimMaxCount},
+		"changePassword": gin.H{"supported": false},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "Authentication using the SCIM token generated in the admin console",
+		}},
+	})
+}
+
+func GetScimResourceTypes(c *gin.Context) {
+	resources := []any{
+		gin.H{
+			"schemas":  []string{dto.ScimSchemaResourceType},
+			"id":       "User",
+			"name":     "User",
+			"endpoint": "/Users",
+			"schema":   dto.ScimSchemaUser,
+		},
+		gin.H{
+			"schemas":  []string{dto.ScimSchemaResourceType},
+			"id":       "Group",
+			"name":     "Group",
+			"endpoint": "/Groups",
+			"schema":   dto.ScimSchemaGroup,
+		},
+	}
+	scimListResponse(c, resources, int64(len(resources)), 1)
+}
+
+// GenerateScimToken 生成新的 SCIM Bearer Token 并保存哈希，旧 Token 立即失效，明文仅返回一次
+func GenerateScimToken(c *gin.Context) {
+	token, tokenHash, err := service.GenerateScimToken()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = model.UpdateOption("scim.token_hash", tokenHash); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.RecordAudit(c, "scim.token.generate", "option", "scim.token_hash", nil, nil)
+	common.ApiSuccess(c, gin.H{
+		"token": token,
+	})
+}
@@ -6,79 +6,182 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"one-api/common"
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
 	"one-api/relay"
+	"one-api/relay/channel"
+	"one-api/service"
+	"one-api/setting/operation_setting"
 	"sort"
 	"strconv"
 	"time"
 
+	"github.com/bytedance/gopkg/util/gopool"
 	"github.com/gin-gonic/gin"
 	"github.com/samber/lo"
 )
 
+// 轮询循环的检查间隔，每个任务实际的查询时间由 tasks.next_poll_time 决定
+const taskPollTickSeconds = 3
+
+// customTaskPollers 协议不同于 TaskAdaptor 的平台（PollMode 为 TaskPollCustom）使用的查询函数
+var customTaskPollers = map[constant.TaskPlatform]func(ctx context.Context, taskChannelM map[int][]string, taskM map[string]*model.Task) error{
+	constant.TaskPlatformMidjourney: UpdateMidjourneyTaskAll,
+}
+
+// UpdateTaskBulk 按各任务的下一次查询时间轮询上游：提交后快速查询，之后指数退避。
+// 启用 Redis 时各节点通过租约分担任务
 func UpdateTaskBulk() {
-	//revocer
-	//imageModel := "midjourney"
 	for {
-		time.Sleep(time.Duration(15) * time.Second)
-		common.SysLog("任务进度轮询开始")
-		ctx := context.TODO()
-		allTasks := model.GetAllUnFinishSyncTasks(500)
-		platformTask := make(map[constant.TaskPlatform][]*model.Task)
-		for _, t := range allTasks {
-			platformTask[t.Platform] = append(platformTask[t.Platform], t)
+		time.Sleep(time.Duration(taskPollTickSeconds) * time.Second)
+		pollDueTasks(context.TODO())
+	}
+}
+
+func pollDueTasks(ctx context.Context) {
+	pollSetting := operation_setting.GetTaskPollSetting()
+	now := time.Now().Unix()
+	allTasks := model.GetDueUnFinishTasks(now, pollSetting.BatchSize, channel.GetPolledTaskPlatforms())
+	if len(allTasks) == 0 {
+		return
+	}
+	claimed := make([]int64, 0, len(allTasks))
+	defer func() {
+		service.ReleaseTaskPollLeases(claimed)
+	}()
+	platformTask := make(map[constant.TaskPlatform][]*model.Task)
+	for _, task := range allTasks {
+		if !service.AcquireTaskPollLease(task.ID) {
+			continue
 		}
-		for platform, tasks := range platformTask {
-			if len(tasks) == 0 {
+		claimed = append(claimed, task.ID)
+		// 先写入下一次查询时间，查询失败或状态未变化时按退避间隔再次查询；上游会主动推送的任务只按最大间隔兜底
+		interval := pollSetting.GetTaskPollInterval(task.PollCount)
+		if task.NotifyToken != "" && task.PollCount > 0 {
+			interval = pollSetting.GetTaskPollInterval(math.MaxInt32)
+		}
+		task.PollCount++
+		task.NextPollTime = now + interval
+		if err := model.UpdateTaskPollSchedule(task.ID, task.NextPollTime, task.PollCount); err != nil {
+			common.LogError(ctx, fmt.Sprintf("failed to schedule task #%d: %s", task.ID, err.Error()))
+			continue
+		}
+		platformTask[task.Platform] = append(platformTask[task.Platform], task)
+	}
+	for platform, tasks := range platformTask {
+		taskChannelM := make(map[int][]string)
+		taskM := make(map[string]*model.Task)
+		nullTaskIds := make([]int64, 0)
+		for _, task := range tasks {
+			if task.TaskID == "" {
+				// 统计失败的未完成任务
+				nullTaskIds = append(nullTaskIds, task.ID)
 				continue
 			}
-			taskChannelM := make(map[int][]string)
-			taskM := make(map[string]*model.Task)
-			nullTaskIds := make([]int64, 0)
-			for _, task := range tasks {
-				if task.TaskID == "" {
-					// 统计失败的未完成任务
-					nullTaskIds = append(nullTaskIds, task.ID)
-					continue
-				}
-				taskM[task.TaskID] = task
-				taskChannelM[task.ChannelId] = append(taskChannelM[task.ChannelId], task.TaskID)
+			taskM[task.TaskID] = task
+			taskChannelM[task.ChannelId] = append(taskChannelM[task.ChannelId], task.TaskID)
+		}
+		if len(nullTaskIds) > 0 {
+			err := model.TaskBulkUpdateByID(nullTaskIds, map[string]any{
+				"status":   "FAILURE",
+				"progress": "100%",
+			})
+			if err != nil {
+				common.LogError(ctx, fmt.Sprintf("Fix null task_id task error: %v", err))
+			} else {
+				common.LogInfo(ctx, fmt.Sprintf("Fix null task_id task success: %v", nullTaskIds))
 			}
-			if len(nullTaskIds) > 0 {
-				err := model.TaskBulkUpdateByID(nullTaskIds, map[string]any{
-					"status":   "FAILURE",
-					"progress": "100%",
-				})
-				if err != nil {
-					common.LogError(ctx, fmt.Sprintf("Fix null task_id task error: %v", err))
-				} else {
-					common.LogInfo(ctx, fmt.Sprintf("Fix null task_id task success: %v", nullTaskIds))
-				}
+		}
+		if len(taskChannelM) == 0 {
+			continue
+		}
+
+		UpdateTaskByPlatform(platform, taskChannelM, taskM)
+	}
+}
+
+// 超时任务的检查间隔
+const taskSweepIntervalSeconds = 60
+
+// SweepTimeoutTasks 定期检查超过平台最长处理时间仍未完成的任务（包括不参与轮询、等待上游推送的平台），标记失败并退还额度
+func SweepTimeoutTasks() {
+	for {
+		time.Sleep(time.Duration(taskSweepIntervalSeconds) * time.Second)
+		sweepTimeoutTasks(context.TODO())
+	}
+}
+
+func sweepTimeoutTasks(ctx context.Context) {
+	pollSetting := operation_setting.GetTaskPollSetting()
+	now := time.Now().Unix()
+	for _, provider := range channel.GetTaskProviders() {
+		deadlineMinutes := pollSetting.GetDeadlineMinutes(string(provider.Platform))
+		if deadlineMinutes <= 0 {
+			continue
+		}
+		tasks := model.GetTimeoutUnFinishTasks(provider.Platform, now-int64(deadlineMinutes)*60, pollSetting.BatchSize)
+		for _, task := range tasks {
+			// 没有上游任务 ID 的任务由轮询直接修正，不在这里退款
+			if task.TaskID == "" {
+				continue
 			}
-			if len(taskChannelM) == 0 {
+			// 正在被轮询的任务留到下一次检查
+			if !service.AcquireTaskPollLease(task.ID) {
 				continue
 			}
+			failTaskByDeadline(ctx, task, deadlineMinutes)
+			service.ReleaseTaskPollLeases([]int64{task.ID})
+		}
+	}
+}
 
-			UpdateTaskByPlatform(platform, taskChannelM, taskM)
+// failTaskByDeadline 任务超过最长处理时间仍未完成，标记失败并退还额度
+func failTaskByDeadline(ctx context.Context, task *model.Task, deadlineMinutes int) {
+	failReason := fmt.Sprintf("上游任务超时（超过%d分钟）", deadlineMinutes)
+	task.Status = model.TaskStatusFailure
+	task.Progress = "100%"
+	task.FailReason = failReason
+	task.FinishTime = time.Now().Unix()
+	if task.Platform == constant.TaskPlatformMidjourney {
+		midjourneyTask := model.MidjourneyFromTask(task)
+		midjourneyTask.FinishTime = time.Now().UnixMilli()
+		if err := midjourneyTask.Update(); err != nil {
+			common.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
+			return
 		}
-		common.SysLog("任务进度轮询完成")
+		settleTask(ctx, task)
+		service.NotifyMidjourneyStatusChange(midjourneyTask)
+	} else {
+		if err := task.Update(); err != nil {
+			common.LogError(ctx, "UpdateTask task error: "+err.Error())
+			return
+		}
+		settleTask(ctx, task)
+		service.NotifyTaskStatusChange(task)
 	}
+	common.LogInfo(ctx, fmt.Sprintf("Task %s timed out after %d minutes", task.TaskID, deadlineMinutes))
 }
 
+// UpdateTaskByPlatform 按平台注册时声明的轮询方式更新任务进度
 func UpdateTaskByPlatform(platform constant.TaskPlatform, taskChannelM map[int][]string, taskM map[string]*model.Task) {
-	switch platform {
-	case constant.TaskPlatformMidjourney:
-		//_ = UpdateMidjourneyTaskAll(context.Background(), tasks)
-	case constant.TaskPlatformSuno:
+	provider := channel.GetTaskProvider(platform)
+	if provider == nil {
+		common.SysLog("未知平台: " + string(platform))
+		return
+	}
+	switch provider.PollMode {
+	case channel.TaskPollBatch:
 		_ = UpdateSunoTaskAll(context.Background(), taskChannelM, taskM)
-	case constant.TaskPlatformKling, constant.TaskPlatformJimeng:
+	case channel.TaskPollSingle:
 		_ = UpdateVideoTaskAll(context.Background(), platform, taskChannelM, taskM)
-	default:
-		common.SysLog("未知平台")
+	case channel.TaskPollCustom:
+		if poller, ok := customTaskPollers[platform]; ok {
+			_ = poller(context.Background(), taskChannelM, taskM)
+		}
 	}
 }
 
@@ -100,13 +203,16 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 	channel, err := model.CacheGetChannel(channelId)
 	if err != nil {
 		common.SysLog(fmt.Sprintf("CacheGetChannel: %v", err))
+		failReason := fmt.Sprintf("获取渠道信息失败，请联系管理员，渠道ID：%d", channelId)
 		err = model.TaskBulkUpdate(taskIds, map[string]any{
-			"fail_reason": fmt.Sprintf("获取渠道信息失败，请联系管理员，渠道ID：%d", channelId),
+			"fail_reason": failReason,
 			"status":      "FAILURE",
 			"progress":    "100%",
 		})
 		if err != nil {
 			common.SysError(fmt.Sprintf("UpdateMidjourneyTask error2: %v", err))
+		} else {
+			notifyTasksFailed(taskIds, taskM, failReason)
 		}
 		return err
 	}
@@ -147,6 +253,7 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 		if !checkTaskNeedUpdate(task, responseItem) {
 			continue
 		}
+		oldStatus := task.Status
 
 		task.Status = lo.If(model.TaskStatus(responseItem.Status) != "", model.TaskStatus(responseItem.Status)).Else(task.Status)
 		task.FailReason = lo.If(responseItem.FailReason != "", responseItem.FailReason).Else(task.FailReason)
@@ -155,21 +262,8 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 		task.FinishTime = lo.If(responseItem.FinishTime != 0, responseItem.FinishTime).Else(task.FinishTime)
 		if responseItem.FailReason != "" || task.Status == model.TaskStatusFailure {
 			common.LogInfo(ctx, task.TaskID+" 构建失败，"+task.FailReason)
+			task.Status = model.TaskStatusFailure
 			task.Progress = "100%"
-			//err = model.CacheUpdateUserQuota(task.UserId) ?
-			if err != nil {
-				common.LogError(ctx, "error update user quota cache: "+err.Error())
-			} else {
-				quota := task.Quota
-				if quota != 0 {
-					err = model.IncreaseUserQuota(task.UserId, quota, false)
-					if err != nil {
-						common.LogError(ctx, "fail to increase user quota: "+err.Error())
-					}
-					logContent := fmt.Sprintf("异步任务执行失败 %s，补偿 %s", task.TaskID, common.LogQuota(quota))
-					model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
-				}
-			}
 		}
 		if responseItem.Status == model.TaskStatusSuccess {
 			task.Progress = "100%"
@@ -179,11 +273,81 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 		err = task.Update()
 		if err != nil {
 			common.SysError("UpdateMidjourneyTask task error: " + err.Error())
+			continue
+		}
+		if task.Progress == "100%" {
+			settleTask(ctx, task)
+			archiveTaskResult(task)
+		}
+		if task.Status != oldStatus {
+			service.NotifyTaskStatusChange(task)
 		}
 	}
 	return nil
 }
 
+// notifyTasksFailed 批量标记失败后退还额度并推送状态变化回调
+func notifyTasksFailed(taskIds []string, taskM map[string]*model.Task, failReason string) {
+	for _, taskId := range taskIds {
+		task := taskM[taskId]
+		if task == nil || task.Status == model.TaskStatusFailure {
+			continue
+		}
+		task.Status = model.TaskStatusFailure
+		task.Progress = "100%"
+		task.FailReason = failReason
+		settleTask(context.Background(), task)
+		service.NotifyTaskStatusChange(task)
+	}
+}
+
+// archiveTaskResult 任务成功后在后台转存生成的文件
+func archiveTaskResult(task *model.Task) {
+	if task.Status != model.TaskStatusSuccess || !operation_setting.GetTaskAssetSetting().Enabled {
+		return
+	}
+	var urls []string
+	if adaptor, ok := relay.GetTaskAdaptor(task.Platform).(channel.TaskAssetAdaptor); ok {
+		urls = adaptor.GetTaskAssetUrls(task.Data)
+	} else if task.FailReason != "" {
+		// 视频任务成功时 FailReason 保存结果地址
+		urls = []string{task.FailReason}
+	}
+	finishedTask := *task
+	gopool.Go(func() {
+		service.ArchiveTaskAssets(&finishedTask, urls)
+	})
+}
+
+// settleTask 任务结束时结算退款：失败全额退还，成功但部分结果生成失败时按比例退还
+func settleTask(ctx context.Context, task *model.Task) {
+	if task.Quota == 0 {
+		return
+	}
+	var refundQuota int
+	reason := task.FailReason
+	switch task.Status {
+	case model.TaskStatusFailure:
+		refundQuota = task.Quota
+	case model.TaskStatusSuccess:
+		adaptor, ok := relay.GetTaskAdaptor(task.Platform).(channel.TaskSettlementAdaptor)
+		if !ok {
+			return
+		}
+		total, failed := adaptor.CountTaskItems(task.Data)
+		if total == 0 || failed == 0 {
+			return
+		}
+		refundQuota = task.Quota * failed / total
+		reason = fmt.Sprintf("%d/%d 个结果生成失败", failed, total)
+	default:
+		return
+	}
+	if _, err := service.SettleTaskRefund(ctx, task.ID, refundQuota, reason); err != nil {
+		common.LogError(ctx, fmt.Sprintf("failed to settle task %s: %s", task.TaskID, err.Error()))
+	}
+}
+
 func checkTaskNeedUpdate(oldTask *model.Task, newTask dto.SunoDataResponse) bool {
 
 	if oldTask.SubmitTime != newTask.SubmitTime {
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServeTaskAsset 通过签名地址访问转存的任务结果文件，无需登录
+func ServeTaskAsset(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if !service.VerifyTaskAssetSignature(id, expires, c.Query("signature")) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "访问地址无效或已过期",
+		})
+		return
+	}
+	asset, err := model.GetTaskAssetById(id)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	store, err := service.GetTaskAssetStore(asset.Backend)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	reader, err := store.Get(c.Request.Context(), asset.StorageKey)
+	if err != nil {
+		common.SysError("failed to read task asset: " + err.Error())
+		c.Status(http.StatusNotFound)
+		return
+	}
+	defer reader.Close()
+	contentType, inline := taskAssetContentType(asset.ContentType)
+	c.Header("Content-Type", contentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	if !inline {
+		c.Header("Content-Disposition", "attachment")
+	}
+	c.Header("Cache-Control", "private, max-age="+strconv.FormatInt(max(expires-time.Now().Unix(), 0), 10))
+	// 本地文件支持 Range 请求，便于播放音视频
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, "", time.Unix(asset.CreatedTime, 0), seeker)
+		return
+	}
+	c.Header("Content-Length", strconv.FormatInt(asset.Size, 10))
+	c.Status(http.StatusOK)
+	_, _ = io.Copy(c.Writer, reader)
+}
+
+// taskAssetContentType 返回响应使用的 Content-Type，只有图片、音频与视频可以在浏览器中直接打开，
+// 其他类型（包括可执行脚本的 SVG）按 application/octet-stream 下载，避免上游返回的 HTML 在本站域名下执行
+func taskAssetContentType(contentType string) (string, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "image/svg+xml" {
+		return "application/octet-stream", false
+	}
+	for _, prefix := range []string{"image/", "audio/", "video/"} {
+		if strings.HasPrefix(mediaType, prefix) {
+			return mediaType, true
+		}
+	}
+	return "application/octet-stream", false
+}
+
+type taskAssetItem struct {
+	*model.TaskAsset
+	Url string `json:"url"`
+}
+
+func GetUserTaskAssets(c *gin.Context) {
+	getTaskAssets(c, c.GetInt("id"))
+}
+
+func GetAllTaskAssets(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	getTaskAssets(c, userId)
+}
+
+func getTaskAssets(c *gin.Context, userId int) {
+	pageInfo := common.GetPageQuery(c)
+	assets, total, err := model.GetUserTaskAssets(userId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	items := make([]taskAssetItem, 0, len(assets))
+	for _, asset := range assets {
+		items = append(items, taskAssetItem{TaskAsset: asset, Url: service.GetTaskAssetUrl(asset)})
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(items)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetUserTaskAssetUsage 返回自己占用的转存空间与保留天数
+func GetUserTaskAssetUsage(c *gin.Context) {
+	userId := c.GetInt("id")
+	size, err := model.SumUserTaskAssetSize(userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	retentionDays := operation_setting.GetTaskAssetSetting().RetentionDays
+	if userSetting, err := model.GetUserSetting(userId, false); err == nil && userSetting.AssetRetentionDays != 0 {
+		retentionDays = userSetting.AssetRetentionDays
+	}
+	common.ApiSuccess(c, gin.H{
+		"storage_bytes":  size,
+		"retention_days": retentionDays,
+		"quota_per_mb":   operation_setting.GetTaskAssetSetting().QuotaPerMB,
+	})
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/relay"
+	"one-api/relay/channel"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TaskNotify 接收上游平台主动推送的任务状态，地址在提交任务时通过 callback_url / notifyHook 下发，路径中的 token 用于鉴权
+func TaskNotify(c *gin.Context) {
+	platform := constant.TaskPlatform(c.Param("platform"))
+	task, err := model.GetTaskByNotifyToken(platform, c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "任务不存在",
+		})
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 已完成的任务不再接受推送，避免重复退款
+	if task.Progress == "100%" {
+		common.ApiSuccess(c, nil)
+		return
+	}
+	ctx := context.Background()
+	if platform == constant.TaskPlatformMidjourney {
+		var midjRequest dto.MidjourneyDto
+		if err := json.Unmarshal(body, &midjRequest); err != nil {
+			common.ApiErrorMsg(c, "无效的请求体")
+			return
+		}
+		if midjRequest.MjId != task.TaskID {
+			common.ApiErrorMsg(c, "任务 ID 不匹配")
+			return
+		}
+		if err := relay.ApplyMidjourneyNotify(model.MidjourneyFromTask(task), midjRequest); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		common.ApiSuccess(c, nil)
+		return
+	}
+	callbackAdaptor, ok := relay.GetTaskAdaptor(platform).(channel.TaskCallbackAdaptor)
+	if !ok {
+		common.ApiErrorMsg(c, "该平台不支持任务推送")
+		return
+	}
+	taskResult, responseBody, err := callbackAdaptor.ParseTaskCallback(body)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if taskResult.TaskID != "" && taskResult.TaskID != task.TaskID {
+		common.ApiErrorMsg(c, "任务 ID 不匹配")
+		return
+	}
+	if err := applyVideoTaskResult(ctx, task, taskResult, responseBody); err != nil {
+		common.LogError(ctx, fmt.Sprintf("Failed to apply task notify %s: %s", task.TaskID, err.Error()))
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
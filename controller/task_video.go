@@ -9,6 +9,8 @@ import (
 	"one-api/model"
 	"one-api/relay"
 	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
 	"time"
 )
 
@@ -28,13 +30,16 @@ func updateVideoTaskAll(ctx context.Context, platform constant.TaskPlatform, cha
 	}
 	cacheGetChannel, err := model.CacheGetChannel(channelId)
 	if err != nil {
+		failReason := fmt.Sprintf("Failed to get channel info, channel ID: %d", channelId)
 		errUpdate := model.TaskBulkUpdate(taskIds, map[string]any{
-			"fail_reason": fmt.Sprintf("Failed to get channel info, channel ID: %d", channelId),
+			"fail_reason": failReason,
 			"status":      "FAILURE",
 			"progress":    "100%",
 		})
 		if errUpdate != nil {
 			common.SysError(fmt.Sprintf("UpdateVideoTask error: %v", errUpdate))
+		} else {
+			notifyTasksFailed(taskIds, taskM, failReason)
 		}
 		return fmt.Errorf("CacheGetChannel failed: %w", err)
 	}
@@ -85,10 +90,17 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 	//	return fmt.Errorf("video task fetch failed for task %s", taskId)
 	//}
 
+	return applyVideoTaskResult(ctx, task, taskResult, responseBody)
+}
+
+// applyVideoTaskResult 将上游查询或推送得到的任务状态写回任务，失败时退还额度
+func applyVideoTaskResult(ctx context.Context, task *model.Task, taskResult *relaycommon.TaskInfo, responseBody []byte) error {
+	taskId := task.TaskID
 	now := time.Now().Unix()
 	if taskResult.Status == "" {
 		return fmt.Errorf("task %s status is empty", taskId)
 	}
+	oldStatus := task.Status
 	task.Status = model.TaskStatus(taskResult.Status)
 	switch taskResult.Status {
 	case model.TaskStatusSubmitted:
@@ -114,14 +126,6 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 		}
 		task.FailReason = taskResult.Reason
 		common.LogInfo(ctx, fmt.Sprintf("Task %s failed: %s", task.TaskID, task.FailReason))
-		quota := task.Quota
-		if quota != 0 {
-			if err := model.IncreaseUserQuota(task.UserId, quota, false); err != nil {
-				common.LogError(ctx, "Failed to increase user quota: "+err.Error())
-			}
-			logContent := fmt.Sprintf("Video async task failed %s, refund %s", task.TaskID, common.LogQuota(quota))
-			model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
-		}
 	default:
 		return fmt.Errorf("unknown task status %s for task %s", taskResult.Status, taskId)
 	}
@@ -132,6 +136,14 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 	task.Data = responseBody
 	if err := task.Update(); err != nil {
 		common.SysError("UpdateVideoTask task error: " + err.Error())
+		return nil
+	}
+	if task.Progress == "100%" {
+		settleTask(ctx, task)
+		archiveTaskResult(task)
+	}
+	if task.Status != oldStatus {
+		service.NotifyTaskStatusChange(task)
 	}
 
 	return nil
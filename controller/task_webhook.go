@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"errors"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetUserTaskWebhookDeliveries(c *gin.Context) {
+	getTaskWebhookDeliveries(c, c.GetInt("id"))
+}
+
+func GetAllTaskWebhookDeliveries(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	getTaskWebhookDeliveries(c, userId)
+}
+
+func getTaskWebhookDeliveries(c *gin.Context, userId int) {
+	pageInfo := common.GetPageQuery(c)
+	filter := model.TaskWebhookDeliveryFilter{
+		UserId: userId,
+		TaskId: c.Query("task_id"),
+		State:  c.Query("state"),
+	}
+	deliveries, total, err := model.GetTaskWebhookDeliveries(filter, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(deliveries)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// RetryTaskWebhookDelivery 手动重新投递自己的任务回调
+func RetryTaskWebhookDelivery(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	delivery, err := model.GetTaskWebhookDeliveryById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if delivery.UserId != c.GetInt("id") {
+		common.ApiError(c, errors.New("回调记录不存在"))
+		return
+	}
+	if err = service.RetryTaskWebhookDelivery(delivery); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/model"
+	"one-api/service"
+	"one-api/setting/operation_setting"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -119,6 +121,20 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	if err = token.NormalizeIpRules(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = service.ValidateCallbackUrl(token.CallbackUrl); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if token.OrganizationId != 0 {
+		if _, err = model.GetOrganizationMember(token.OrganizationId, c.GetInt("id")); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
 	key, err := common.GenerateKey()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -131,7 +147,6 @@ func AddToken(c *gin.Context) {
 	cleanToken := model.Token{
 		UserId:             c.GetInt("id"),
 		Name:               token.Name,
-		Key:                key,
 		CreatedTime:        common.GetTimestamp(),
 		AccessedTime:       common.GetTimestamp(),
 		ExpiredTime:        token.ExpiredTime,
@@ -140,25 +155,134 @@ func AddToken(c *gin.Context) {
 		ModelLimitsEnabled: token.ModelLimitsEnabled,
 		ModelLimits:        token.ModelLimits,
 		AllowIps:           token.AllowIps,
+		DenyIps:            token.DenyIps,
+		CallbackUrl:        token.CallbackUrl,
 		Group:              token.Group,
 		RateLimitPerMinute: token.RateLimitPerMinute,
 		RateLimitPerDay:    token.RateLimitPerDay,
 		LastRateLimitReset: 0,
 		ChannelTag:         token.ChannelTag,
 		TotalUsageLimit:    token.TotalUsageLimit,
+		OrganizationId:     token.OrganizationId,
 	}
+	cleanToken.SetKey(key)
 	err = cleanToken.Insert()
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	// 令牌明文仅在创建时返回一次
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
+		"data":    cleanToken,
 	})
 	return
 }
 
+// ResetTokenKey 重新生成令牌，旧令牌立即失效，新令牌明文仅返回一次
+func ResetTokenKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	token, err := model.GetTokenByIds(id, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if token.ReplacedById != 0 {
+		common.ApiErrorMsg(c, "该令牌已被轮换，请修改新令牌")
+		return
+	}
+	key, err := common.GenerateKey()
+	if err != nil {
+		common.ApiErrorMsg(c, "生成令牌失败")
+		common.SysError("failed to generate token key: " + err.Error())
+		return
+	}
+	if err = token.ResetKey(key); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, token)
+}
+
+type RotateTokenRequest struct {
+	ExpiredTime *int64 `json:"expired_time"`
+	GraceHours  *int   `json:"grace_hours"`
+}
+
+// RotateToken 签发继承原令牌配置与剩余额度的新令牌，原令牌在宽限期内仍可使用
+func RotateToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	req := RotateTokenRequest{}
+	if c.Request.ContentLength > 0 {
+		if err = c.ShouldBindJSON(&req); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	token, err := model.GetTokenByIds(id, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if token.ReplacedById != 0 {
+		common.ApiErrorMsg(c, "该令牌已被轮换")
+		return
+	}
+	now := common.GetTimestamp()
+	// 默认沿用原令牌的有效期长度
+	expiredTime := int64(-1)
+	if token.ExpiredTime != -1 && token.ExpiredTime > token.CreatedTime {
+		expiredTime = now + token.ExpiredTime - token.CreatedTime
+	}
+	if req.ExpiredTime != nil {
+		expiredTime = *req.ExpiredTime
+	}
+	if expiredTime != -1 && expiredTime <= now {
+		common.ApiErrorMsg(c, "过期时间不能早于当前时间")
+		return
+	}
+	graceHours := operation_setting.GetTokenSetting().RotationGraceHours
+	if req.GraceHours != nil {
+		graceHours = *req.GraceHours
+	}
+	if graceHours < 0 {
+		common.ApiErrorMsg(c, "宽限期不能为负数")
+		return
+	}
+	key, err := common.GenerateKey()
+	if err != nil {
+		common.ApiErrorMsg(c, "生成令牌失败")
+		common.SysError("failed to generate token key: " + err.Error())
+		return
+	}
+	newToken, err := model.RotateToken(token, key, expiredTime, int64(graceHours)*3600)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, newToken)
+}
+
+// HashLegacyTokenKeys 将所有旧令牌的明文替换为哈希，旧令牌仍然可用，但用户无法再查看令牌
+func HashLegacyTokenKeys(c *gin.Context) {
+	count, err := model.HashLegacyTokenKeys()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.RecordAudit(c, "token.hash_legacy_keys", "token", "", nil, gin.H{"count": count})
+	common.ApiSuccess(c, count)
+}
+
 func DeleteToken(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	userId := c.GetInt("id")
@@ -195,6 +319,10 @@ func UpdateToken(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	if cleanToken.ReplacedById != 0 {
+		common.ApiErrorMsg(c, "该令牌已被轮换，请修改新令牌")
+		return
+	}
 	if token.Status == common.TokenStatusEnabled {
 		if cleanToken.Status == common.TokenStatusExpired && cleanToken.ExpiredTime <= common.GetTimestamp() && cleanToken.ExpiredTime != -1 {
 			c.JSON(http.StatusOK, gin.H{
@@ -214,19 +342,40 @@ func UpdateToken(c *gin.Context) {
 	if statusOnly != "" {
 		cleanToken.Status = token.Status
 	} else {
+		if err = token.NormalizeIpRules(); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if err = service.ValidateCallbackUrl(token.CallbackUrl); err != nil {
+			common.ApiError(c, err)
+			return
+		}
 		// If you add more fields, please also update token.Update()
 		cleanToken.Name = token.Name
+		if cleanToken.ExpiredTime != token.ExpiredTime {
+			// 过期时间变化后重新发送过期提醒
+			cleanToken.ExpiryNotifiedTime = 0
+		}
 		cleanToken.ExpiredTime = token.ExpiredTime
 		cleanToken.RemainQuota = token.RemainQuota
 		cleanToken.UnlimitedQuota = token.UnlimitedQuota
 		cleanToken.ModelLimitsEnabled = token.ModelLimitsEnabled
 		cleanToken.ModelLimits = token.ModelLimits
 		cleanToken.AllowIps = token.AllowIps
+		cleanToken.DenyIps = token.DenyIps
+		cleanToken.CallbackUrl = token.CallbackUrl
 		cleanToken.Group = token.Group
 		cleanToken.RateLimitPerMinute = token.RateLimitPerMinute
 		cleanToken.RateLimitPerDay = token.RateLimitPerDay
 		cleanToken.ChannelTag = token.ChannelTag
 		cleanToken.TotalUsageLimit = token.TotalUsageLimit
+		if token.OrganizationId != 0 && token.OrganizationId != cleanToken.OrganizationId {
+			if _, err = model.GetOrganizationMember(token.OrganizationId, userId); err != nil {
+				common.ApiError(c, err)
+				return
+			}
+		}
+		cleanToken.OrganizationId = token.OrganizationId
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -0,0 +1,261 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	sessionKeyPending2FAUserId = "pending_2fa_user_id"
+	sessionKeyPending2FATime   = "pending_2fa_time"
+	// 账号密码验证通过后提交验证码的有效期（秒）
+	pending2FAExpireSeconds = 300
+)
+
+type twoFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+func bindTwoFACode(c *gin.Context) (string, bool) {
+	var req twoFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		common.ApiErrorMsg(c, "验证码不能为空")
+		return "", false
+	}
+	return req.Code, true
+}
+
+// markSessionMFA 用户在当前会话中完成了两步验证
+func markSessionMFA(c *gin.Context) {
+	session := sessions.Default(c)
+	session.Set(common.SessionKeyMFA, true)
+	if err := session.Save(); err != nil {
+		common.SysError("failed to save session: " + err.Error())
+	}
+}
+
+func isSessionMFA(c *gin.Context) bool {
+	mfa, _ := sessions.Default(c).Get(common.SessionKeyMFA).(bool)
+	return mfa
+}
+
+// checkExistingSecondFactor 用户已有 TOTP 或通行密钥时，当前会话必须已通过两步验证才能添加新的验证方式，
+// 避免仅凭密码登录的会话通过添加验证方式绕过两步验证。返回用户此前是否已有验证方式
+func checkExistingSecondFactor(c *gin.Context) (hadSecondFactor bool, ok bool) {
+	if !model.HasSecondFactor(c.GetInt("id")) {
+		return false, true
+	}
+	if !isSessionMFA(c) {
+		common.ApiErrorMsg(c, "请先使用已有的两步验证方式登录后再添加新的验证方式")
+		return true, false
+	}
+	return true, true
+}
+
+// Login2FA 账号密码或第三方登录通过后，提交 TOTP 验证码或恢复码完成登录
+func Login2FA(c *gin.Context) {
+	code, ok := bindTwoFACode(c)
+	if !ok {
+		return
+	}
+	session := sessions.Default(c)
+	userId, ok := session.Get(sessionKeyPending2FAUserId).(int)
+	pendingTime, _ := session.Get(sessionKeyPending2FATime).(int64)
+	if !ok || common.GetTimestamp()-pendingTime > pending2FAExpireSeconds {
+		common.ApiErrorMsg(c, "登录已过期，请重新登录")
+		return
+	}
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if user.Status != common.UserStatusEnabled {
+		common.ApiErrorMsg(c, "用户已被封禁")
+		return
+	}
+	if err = service.VerifyTwoFACode(user.Id, code); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	completeLogin(user, c, true)
+}
+
+func GetTwoFAStatus(c *gin.Context) {
+	userId := c.GetInt("id")
+	passkeys, err := model.GetUserPasskeys(userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	enabled := model.IsTwoFAEnabled(userId)
+	var recoveryCodesRemaining int64
+	if enabled {
+		recoveryCodesRemaining = model.CountRemainingRecoveryCodes(userId)
+	}
+	mfa := isSessionMFA(c)
+	common.ApiSuccess(c, gin.H{
+		"enabled":                  enabled,
+		"recovery_codes_remaining": recoveryCodesRemaining,
+		"passkey_count":            len(passkeys),
+		"enforced":                 service.IsTwoFactorEnforced(c.GetInt("role")),
+		"session_verified":         mfa,
+	})
+}
+
+// SetupTwoFA 生成新的 TOTP 密钥，需调用 EnableTwoFA 校验验证码后才会生效
+func SetupTwoFA(c *gin.Context) {
+	userId := c.GetInt("id")
+	if model.IsTwoFAEnabled(userId) {
+		common.ApiErrorMsg(c, "两步验证已启用，请先关闭")
+		return
+	}
+	if _, ok := checkExistingSecondFactor(c); !ok {
+		return
+	}
+	key, err := service.GenerateTwoFASecret(c.GetString("username"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = model.SaveTwoFASecret(userId, key.Secret()); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"secret":      key.Secret(),
+		"otpauth_url": key.URL(),
+	})
+}
+
+// EnableTwoFA 校验认证器生成的验证码后启用两步验证，返回一次性恢复码（仅展示一次）
+func EnableTwoFA(c *gin.Context) {
+	code, ok := bindTwoFACode(c)
+	if !ok {
+		return
+	}
+	userId := c.GetInt("id")
+	hadSecondFactor, ok := checkExistingSecondFactor(c)
+	if !ok {
+		return
+	}
+	twoFA, err := model.GetTwoFAByUserId(userId)
+	if err != nil {
+		common.ApiErrorMsg(c, "请先生成两步验证密钥")
+		return
+	}
+	if twoFA.Enabled {
+		common.ApiErrorMsg(c, "两步验证已启用")
+		return
+	}
+	secret, err := twoFA.GetSecret()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	step, valid := service.ValidateTOTPCode(secret, code, 0)
+	if !valid {
+		common.ApiErrorMsg(c, "验证码错误")
+		return
+	}
+	recoveryCodes, err := service.GenerateRecoveryCodes()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = model.EnableTwoFA(userId, step, recoveryCodes); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 首次添加验证方式时视为当前会话已完成两步验证
+	if !hadSecondFactor {
+		markSessionMFA(c)
+	}
+	common.ApiSuccess(c, gin.H{
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+// DisableTwoFA 校验验证码或恢复码后关闭两步验证
+func DisableTwoFA(c *gin.Context) {
+	code, ok := bindTwoFACode(c)
+	if !ok {
+		return
+	}
+	userId := c.GetInt("id")
+	if err := service.VerifyTwoFACode(userId, code); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if service.IsTwoFactorEnforced(c.GetInt("role")) && !model.HasPasskey(userId) {
+		common.ApiErrorMsg(c, "管理员要求启用两步验证，请先添加通行密钥后再关闭")
+		return
+	}
+	if err := model.DisableTwoFA(userId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，旧的恢复码全部失效
+func RegenerateRecoveryCodes(c *gin.Context) {
+	code, ok := bindTwoFACode(c)
+	if !ok {
+		return
+	}
+	userId := c.GetInt("id")
+	if err := service.VerifyTwoFACode(userId, code); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recoveryCodes, err := service.GenerateRecoveryCodes()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = model.ReplaceRecoveryCodes(userId, recoveryCodes); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+// ResetUserTwoFA 管理员为丢失认证设备的用户清除两步验证与通行密钥
+func ResetUserTwoFA(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !canManageUserRole(c.GetInt("role"), user.Role) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权更新同权限等级或更高权限等级的用户信息",
+		})
+		return
+	}
+	if err = model.DisableTwoFA(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = model.DeleteAllUserPasskeys(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.RecordAudit(c, "user.2fa.reset", "user", strconv.Itoa(id), nil, nil)
+	common.ApiSuccess(c, nil)
+}
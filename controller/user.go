@@ -8,7 +8,9 @@ import (
 	"one-api/common"
 	"one-api/dto"
 	"one-api/model"
+	"one-api/service"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
 	"strconv"
 	"strings"
 	"sync"
@@ -65,9 +67,40 @@ func Login(c *gin.Context) {
 	setupLogin(&user, c)
 }
 
-// setup session & cookies and then return user info
+// setupLogin 校验通过账号密码或第三方登录后调用，已启用 TOTP 或注册通行密钥的用户需要继续完成两步验证：
+// 提交 TOTP 验证码或恢复码，或改用通行密钥登录
 func setupLogin(user *model.User, c *gin.Context) {
+	if model.HasSecondFactor(user.Id) {
+		session := sessions.Default(c)
+		session.Set(sessionKeyPending2FAUserId, user.Id)
+		session.Set(sessionKeyPending2FATime, common.GetTimestamp())
+		if err := session.Save(); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"message": "无法保存会话信息，请重试",
+				"success": false,
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": "",
+			"success": true,
+			"data": gin.H{
+				"require_2fa":     true,
+				"totp_enabled":    model.IsTwoFAEnabled(user.Id),
+				"passkey_enabled": model.HasPasskey(user.Id),
+			},
+		})
+		return
+	}
+	completeLogin(user, c, false)
+}
+
+// completeLogin setup session & cookies and then return user info，mfa 表示本次登录是否经过两步验证或通行密钥
+func completeLogin(user *model.User, c *gin.Context, mfa bool) {
 	session := sessions.Default(c)
+	session.Delete(sessionKeyPending2FAUserId)
+	session.Delete(sessionKeyPending2FATime)
+	session.Set(common.SessionKeyMFA, mfa)
 	session.Set("id", user.Id)
 	session.Set("username", user.Username)
 	session.Set("role", user.Role)
@@ -216,7 +249,6 @@ func Register(c *gin.Context) {
 		token := model.Token{
 			UserId:             insertedUser.Id, // 使用插入后的用户ID
 			Name:               cleanUser.Username + "的初始令牌",
-			Key:                key,
 			CreatedTime:        common.GetTimestamp(),
 			AccessedTime:       common.GetTimestamp(),
 			ExpiredTime:        -1,     // 永不过期
@@ -227,6 +259,7 @@ func Register(c *gin.Context) {
 		if setting.DefaultUseAutoGroup {
 			token.Group = "auto"
 		}
+		token.SetKey(key)
 		if err := token.Insert(); err != nil {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
@@ -274,6 +307,11 @@ func SearchUsers(c *gin.Context) {
 	return
 }
 
+// canManageUserRole 普通用户可由拥有相应权限的操作者管理，管理员只能由更高权限等级的用户管理
+func canManageUserRole(myRole int, targetRole int) bool {
+	return targetRole < common.RoleAdminUser || myRole > targetRole || myRole == common.RoleRootUser
+}
+
 func GetUser(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -286,7 +324,7 @@ func GetUser(c *gin.Context) {
 		return
 	}
 	myRole := c.GetInt("role")
-	if myRole <= user.Role && myRole != common.RoleRootUser {
+	if !canManageUserRole(myRole, user.Role) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权获取同级或更高等级用户的信息",
@@ -301,16 +339,10 @@ func GetUser(c *gin.Context) {
 	return
 }
 
+// GenerateAccessToken 生成拥有全部权限的默认管理令牌，之前生成的默认令牌会被吊销；需要限制权限时请使用管理令牌接口
 func GenerateAccessToken(c *gin.Context) {
 	id := c.GetInt("id")
-	user, err := model.GetUserById(id, true)
-	if err != nil {
-		common.ApiError(c, err)
-		return
-	}
-	// get rand int 28-32
-	randI := common.GetRandomInt(4)
-	key, err := common.GenerateRandomKey(29 + randI)
+	key, err := model.GenerateManagementTokenKey()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -319,17 +351,16 @@ func GenerateAccessToken(c *gin.Context) {
 		common.SysError("failed to generate key: " + err.Error())
 		return
 	}
-	user.SetAccessToken(key)
-
-	if model.DB.Where("access_token = ?", user.AccessToken).First(user).RowsAffected != 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"message": "请重试，系统生成的 UUID 竟然重复了！",
-		})
+	if err = model.RevokeUserManagementTokensByName(id, defaultManagementTokenName); err != nil {
+		common.ApiError(c, err)
 		return
 	}
-
-	if err := user.Update(false); err != nil {
+	token := &model.ManagementToken{
+		UserId: id,
+		Name:   defaultManagementTokenName,
+		Scopes: common.ScopeAll,
+	}
+	if err = token.Insert(key); err != nil {
 		common.ApiError(c, err)
 		return
 	}
@@ -337,7 +368,7 @@ func GenerateAccessToken(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
-		"data":    user.AccessToken,
+		"data":    key,
 	})
 	return
 }
@@ -415,6 +446,132 @@ func GetSelf(c *gin.Context) {
 	return
 }
 
+// GetSelfPermissions 返回当前用户的有效权限，供前端控制管理菜单
+func GetSelfPermissions(c *gin.Context) {
+	permissions, err := model.GetUserPermissions(c.GetInt("id"), c.GetInt("role"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, permissions)
+}
+
+type assignRoleRequest struct {
+	RoleId int `json:"role_id"`
+}
+
+// AssignUserRole 为用户分配自定义角色，role_id 为 0 时恢复默认权限
+func AssignUserRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req assignRoleRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return
+	}
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if user.Role == common.RoleRootUser {
+		common.ApiErrorMsg(c, "无法为超级管理员分配角色")
+		return
+	}
+	if !canManageUserRole(c.GetInt("role"), user.Role) {
+		common.ApiErrorMsg(c, "无权更新同权限等级或更高权限等级的用户信息")
+		return
+	}
+	if err = model.AssignUserRole(id, req.RoleId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.RecordAudit(c, "user.role.assign", "user", strconv.Itoa(id), gin.H{"role_id": user.RoleId}, gin.H{"role_id": req.RoleId})
+	common.ApiSuccess(c, nil)
+}
+
+type userIpRulesRequest struct {
+	AllowIps string `json:"allow_ips"`
+	DenyIps  string `json:"deny_ips"`
+}
+
+// UpdateUserIpRules 设置用户账户级 IP 白名单与黑名单，作用于该用户的全部令牌
+func UpdateUserIpRules(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req userIpRulesRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return
+	}
+	if req.AllowIps, err = common.ValidateIpRules(req.AllowIps); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.DenyIps, err = common.ValidateIpRules(req.DenyIps); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	user, err := model.GetUserById(id, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !canManageUserRole(c.GetInt("role"), user.Role) {
+		common.ApiErrorMsg(c, "无权更新同权限等级或更高权限等级的用户信息")
+		return
+	}
+	setting := user.GetSetting()
+	if err = model.UpdateUserIpRules(id, req.AllowIps, req.DenyIps); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.RecordAudit(c, "user.ip_rules.update", "user", strconv.Itoa(id),
+		gin.H{"allow_ips": setting.AllowIps, "deny_ips": setting.DenyIps}, req)
+	common.ApiSuccess(c, nil)
+}
+
+type userAssetRetentionRequest struct {
+	RetentionDays int `json:"retention_days"`
+}
+
+// UpdateUserAssetRetention 设置用户任务结果文件的保留天数，0 为使用全局配置，-1 为永久保留，只影响之后转存的文件
+func UpdateUserAssetRetention(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req userAssetRetentionRequest
+	if err = c.ShouldBindJSON(&req); err != nil || req.RetentionDays < -1 {
+		common.ApiErrorMsg(c, "无效的参数")
+		return
+	}
+	user, err := model.GetUserById(id, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !canManageUserRole(c.GetInt("role"), user.Role) {
+		common.ApiErrorMsg(c, "无权更新同权限等级或更高权限等级的用户信息")
+		return
+	}
+	oldRetentionDays := user.GetSetting().AssetRetentionDays
+	if err = model.UpdateUserAssetRetention(id, req.RetentionDays); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.RecordAudit(c, "user.asset_retention.update", "user", strconv.Itoa(id),
+		gin.H{"retention_days": oldRetentionDays}, req)
+	common.ApiSuccess(c, nil)
+}
+
 func GetUserModels(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -468,20 +625,37 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 	myRole := c.GetInt("role")
-	if myRole <= originUser.Role && myRole != common.RoleRootUser {
+	if !canManageUserRole(myRole, originUser.Role) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权更新同权限等级或更高权限等级的用户信息",
 		})
 		return
 	}
-	if myRole <= updatedUser.Role && myRole != common.RoleRootUser {
+	if !canManageUserRole(myRole, updatedUser.Role) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权将其他用户权限等级提升到大于等于自己的权限等级",
 		})
 		return
 	}
+	myId := c.GetInt("id")
+	if originUser.Quota != updatedUser.Quota && !model.UserHasPermission(myId, myRole, common.PermissionUserQuotaAdjust) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权调整用户额度",
+		})
+		return
+	}
+	if !model.UserHasPermission(myId, myRole, common.PermissionUserWrite) &&
+		(originUser.Username != updatedUser.Username || originUser.DisplayName != updatedUser.DisplayName ||
+			originUser.Group != updatedUser.Group || originUser.Remark != updatedUser.Remark || updatedUser.Password != "$I_LOVE_U") {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "仅有额度调整权限，无法修改用户其他信息",
+		})
+		return
+	}
 	if updatedUser.Password == "$I_LOVE_U" {
 		updatedUser.Password = "" // rollback to what it should be
 	}
@@ -493,6 +667,9 @@ func UpdateUser(c *gin.Context) {
 	if originUser.Quota != updatedUser.Quota {
 		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", common.LogQuota(originUser.Quota), common.LogQuota(updatedUser.Quota)))
 	}
+	if user, err := model.GetUserById(originUser.Id, false); err == nil {
+		service.RecordAudit(c, "user.update", "user", strconv.Itoa(originUser.Id), originUser, user)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -577,7 +754,7 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 	myRole := c.GetInt("role")
-	if myRole <= originUser.Role {
+	if !canManageUserRole(myRole, originUser.Role) || originUser.Role == common.RoleRootUser {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权删除同权限等级或更高权限等级的用户",
@@ -592,6 +769,7 @@ func DeleteUser(c *gin.Context) {
 		})
 		return
 	}
+	service.RecordAudit(c, "user.delete", "user", strconv.Itoa(id), originUser, nil)
 }
 
 func DeleteSelf(c *gin.Context) {
@@ -657,6 +835,7 @@ func CreateUser(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	service.RecordAudit(c, "user.create", "user", strconv.Itoa(cleanUser.Id), nil, cleanUser)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -695,13 +874,14 @@ func ManageUser(c *gin.Context) {
 		return
 	}
 	myRole := c.GetInt("role")
-	if myRole <= user.Role && myRole != common.RoleRootUser {
+	if !canManageUserRole(myRole, user.Role) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权更新同权限等级或更高权限等级的用户信息",
 		})
 		return
 	}
+	originUser := user
 	switch req.Action {
 	case "disable":
 		user.Status = common.UserStatusDisabled
@@ -767,6 +947,7 @@ func ManageUser(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	service.RecordAudit(c, "user.manage."+req.Action, "user", strconv.Itoa(user.Id), originUser, user)
 	clearUser := model.User{
 		Role:   user.Role,
 		Status: user.Status,
@@ -850,6 +1031,7 @@ type UpdateUserSettingRequest struct {
 	NotificationEmail          string  `json:"notification_email,omitempty"`
 	AcceptUnsetModelRatioModel bool    `json:"accept_unset_model_ratio_model"`
 	RecordIpLog                bool    `json:"record_ip_log"`
+	Currency                   string  `json:"currency,omitempty"`
 }
 
 func UpdateUserSetting(c *gin.Context) {
@@ -911,6 +1093,16 @@ func UpdateUserSetting(c *gin.Context) {
 		}
 	}
 
+	// 验证展示币种
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if req.Currency != "" && !operation_setting.IsSupportedCurrency(req.Currency) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "不支持的币种",
+		})
+		return
+	}
+
 	userId := c.GetInt("id")
 	user, err := model.GetUserById(userId, true)
 	if err != nil {
@@ -918,12 +1110,17 @@ func UpdateUserSetting(c *gin.Context) {
 		return
 	}
 
-	// 构建设置
+	// 构建设置，IP 规则与结果文件保留天数由管理员设置，保持不变
+	oldSettings := user.GetSetting()
 	settings := dto.UserSetting{
 		NotifyType:            req.QuotaWarningType,
 		QuotaWarningThreshold: req.QuotaWarningThreshold,
 		AcceptUnsetRatioModel: req.AcceptUnsetModelRatioModel,
 		RecordIpLog:           req.RecordIpLog,
+		Currency:              req.Currency,
+		AllowIps:              oldSettings.AllowIps,
+		DenyIps:               oldSettings.DenyIps,
+		AssetRetentionDays:    oldSettings.AssetRetentionDays,
 	}
 
 	// 如果是webhook类型,添加webhook相关设置
@@ -4,4 +4,13 @@ type ChannelSettings struct {
 	ForceFormat       bool   `json:"force_format,omitempty"`
 	ThinkingToContent bool   `json:"thinking_to_content,omitempty"`
 	Proxy             string `json:"proxy"`
+	// 参与定时上游模型同步，规则在全局规则之外追加
+	ModelSyncEnabled bool     `json:"model_sync_enabled,omitempty"`
+	ModelSyncInclude []string `json:"model_sync_include,omitempty"`
+	ModelSyncExclude []string `json:"model_sync_exclude,omitempty"`
+	// 余额提醒与自动禁用阈值，未设置时使用全局配置
+	BalanceWarnThreshold    *float64 `json:"balance_warn_threshold,omitempty"`
+	BalanceDisableThreshold *float64 `json:"balance_disable_threshold,omitempty"`
+	// 余额不足提醒期间将渠道优先级降到该值，余额恢复后还原
+	BalanceDemotePriority *int64 `json:"balance_demote_priority,omitempty"`
 }
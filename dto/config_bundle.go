@@ -0,0 +1,44 @@
+package dto
+
+// ConfigBundle 声明式配置包，可导出为 YAML 或 JSON 保存在 git 中，再通过 plan/apply 同步到其他环境。
+// 各分区只处理出现的字段，未出现的配置保持不变
+type ConfigBundle struct {
+	Version int `json:"version"`
+	// 其他系统设置，键为 options 表中的键，敏感配置（密钥、令牌等）不会导出也不能导入
+	Options map[string]any `json:"options,omitempty"`
+	// 模型倍率与价格：model_ratio、completion_ratio、model_price、cache_ratio
+	Ratios map[string]any `json:"ratios,omitempty"`
+	// 分组：group_ratio、group_group_ratio、user_usable_groups、topup_group_ratio
+	Groups map[string]any `json:"groups,omitempty"`
+	// 自动分组：groups、default_use_auto_group
+	AutoGroups map[string]any `json:"auto_groups,omitempty"`
+	// 模型请求速率限制：enabled、duration_minutes、count、success_count、group
+	RateLimits map[string]any `json:"rate_limits,omitempty"`
+	// 按名称匹配的渠道
+	Channels []ConfigBundleChannel `json:"channels,omitempty"`
+}
+
+type ConfigBundleChannel struct {
+	Name string `json:"name"`
+	Type int    `json:"type"`
+	// 为 false 时手动禁用，为 true 时启用手动禁用的渠道，不影响自动禁用与排空中的渠道
+	Enabled *bool `json:"enabled,omitempty"`
+	// 密钥引用：env:变量名 或 file:文件路径，只能在启动时通过 --config-bundle 应用；为空时保留已有渠道的密钥
+	KeyRef string `json:"key_ref,omitempty"`
+	// 多密钥模式 random 或 polling，密钥按行分隔
+	MultiKeyMode       string            `json:"multi_key_mode,omitempty"`
+	BaseURL            string            `json:"base_url,omitempty"`
+	OpenAIOrganization string            `json:"openai_organization,omitempty"`
+	TestModel          string            `json:"test_model,omitempty"`
+	Models             []string          `json:"models,omitempty"`
+	Groups             []string          `json:"groups,omitempty"`
+	Tag                string            `json:"tag,omitempty"`
+	Priority           int64             `json:"priority,omitempty"`
+	Weight             uint              `json:"weight,omitempty"`
+	AutoBan            *bool             `json:"auto_ban,omitempty"`
+	ModelMapping       map[string]string `json:"model_mapping,omitempty"`
+	StatusCodeMapping  map[string]string `json:"status_code_mapping,omitempty"`
+	Setting            map[string]any    `json:"setting,omitempty"`
+	ParamOverride      map[string]any    `json:"param_override,omitempty"`
+	Other              string            `json:"other,omitempty"`
+}
@@ -0,0 +1,39 @@
+package dto
+
+// PriceSimulationRequest 倍率/价格调整模拟请求
+// 各倍率表仅需填写需要调整的条目，未填写的模型/分组沿用日志记录时的倍率
+type PriceSimulationRequest struct {
+	StartTimestamp  int64              `json:"start_timestamp"`
+	EndTimestamp    int64              `json:"end_timestamp"`
+	ModelName       string             `json:"model_name"`
+	Username        string             `json:"username"`
+	Group           string             `json:"group"`
+	Channel         int                `json:"channel"`
+	ModelRatio      map[string]float64 `json:"model_ratio"`
+	ModelPrice      map[string]float64 `json:"model_price"`
+	CompletionRatio map[string]float64 `json:"completion_ratio"`
+	CacheRatio      map[string]float64 `json:"cache_ratio"`
+	GroupRatio      map[string]float64 `json:"group_ratio"`
+	TopN            int                `json:"top_n"`
+}
+
+// PriceSimulationItem 按维度聚合的模拟结果
+type PriceSimulationItem struct {
+	Key            string `json:"key"`
+	Count          int    `json:"count"`
+	OriginalQuota  int64  `json:"original_quota"`
+	SimulatedQuota int64  `json:"simulated_quota"`
+	Delta          int64  `json:"delta"`
+}
+
+type PriceSimulationResult struct {
+	StartTimestamp int64                  `json:"start_timestamp"`
+	EndTimestamp   int64                  `json:"end_timestamp"`
+	QuotaPerUnit   float64                `json:"quota_per_unit"`
+	LogCount       int                    `json:"log_count"`
+	SkippedCount   int                    `json:"skipped_count"`
+	Total          PriceSimulationItem    `json:"total"`
+	ByModel        []*PriceSimulationItem `json:"by_model"`
+	ByGroup        []*PriceSimulationItem `json:"by_group"`
+	ByUser         []*PriceSimulationItem `json:"by_user"`
+}
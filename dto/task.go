@@ -1,5 +1,7 @@
 package dto
 
+import "encoding/json"
+
 type TaskError struct {
 	Code       string `json:"code"`
 	Message    string `json:"message"`
@@ -8,3 +10,30 @@ type TaskError struct {
 	LocalError bool   `json:"-"`
 	Error      error  `json:"-"`
 }
+
+const (
+	TaskObjectStatusQueued     = "queued"
+	TaskObjectStatusInProgress = "in_progress"
+	TaskObjectStatusCompleted  = "completed"
+	TaskObjectStatusFailed     = "failed"
+)
+
+// TaskObject 统一任务查询接口 /v1/tasks/:task_id 的响应，字段风格与 OpenAI 视频接口一致
+type TaskObject struct {
+	Id          string           `json:"id"`
+	Object      string           `json:"object"`
+	Platform    string           `json:"platform"`
+	Action      string           `json:"action"`
+	Status      string           `json:"status"`
+	Progress    int              `json:"progress"`
+	CreatedAt   int64            `json:"created_at"`
+	CompletedAt int64            `json:"completed_at,omitempty"`
+	Url         string           `json:"url,omitempty"`
+	Error       *TaskObjectError `json:"error,omitempty"`
+	Data        json.RawMessage  `json:"data,omitempty"`
+}
+
+type TaskObjectError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,23 @@
+package dto
+
+import "encoding/json"
+
+const TaskWebhookEventStatusChanged = "task.status_changed"
+
+// TaskWebhookPayload 异步任务状态变化回调的请求体
+type TaskWebhookPayload struct {
+	Event      string          `json:"event"`
+	TaskId     string          `json:"task_id"`
+	Platform   string          `json:"platform"`
+	Action     string          `json:"action"`
+	Status     string          `json:"status"`
+	Progress   string          `json:"progress"`
+	FailReason string          `json:"fail_reason,omitempty"`
+	SubmitTime int64           `json:"submit_time"`
+	StartTime  int64           `json:"start_time"`
+	FinishTime int64           `json:"finish_time"`
+	ImageUrl   string          `json:"image_url,omitempty"`
+	VideoUrl   string          `json:"video_url,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	Timestamp  int64           `json:"timestamp"`
+}
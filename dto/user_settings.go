@@ -8,6 +8,10 @@ type UserSetting struct {
 	NotificationEmail     string  `json:"notification_email,omitempty"`             // NotificationEmail 通知邮箱地址
 	AcceptUnsetRatioModel bool    `json:"accept_unset_model_ratio_model,omitempty"` // AcceptUnsetRatioModel 是否接受未设置价格的模型
 	RecordIpLog           bool    `json:"record_ip_log,omitempty"`                  // 是否记录请求和错误日志IP
+	Currency              string  `json:"currency,omitempty"`                       // Currency 展示币种
+	AllowIps              string  `json:"allow_ips,omitempty"`                      // AllowIps 管理员设置的账户 IP 白名单
+	DenyIps               string  `json:"deny_ips,omitempty"`                       // DenyIps 管理员设置的账户 IP 黑名单
+	AssetRetentionDays    int     `json:"asset_retention_days,omitempty"`           // AssetRetentionDays 管理员设置的任务结果文件保留天数，-1 为永久保留
 }
 
 var (
@@ -6,7 +6,6 @@ import (
 	"log"
 	"net/http"
 	"one-api/common"
-	"one-api/constant"
 	"one-api/controller"
 	"one-api/middleware"
 	"one-api/model"
@@ -39,6 +38,24 @@ func main() {
 		return
 	}
 
+	if *common.RotateChannelKeys {
+		rotated, err := model.RotateChannelKeys()
+		if err != nil {
+			common.FatalLog("failed to rotate channel keys: " + err.Error())
+		}
+		common.SysLog(fmt.Sprintf("channel keys rotated: %d", rotated))
+		_ = model.CloseDB()
+		return
+	}
+
+	if *common.ConfigBundle != "" {
+		plan, err := service.ApplyConfigBundleFile(*common.ConfigBundle)
+		if err != nil {
+			common.FatalLog("failed to apply config bundle: " + err.Error())
+		}
+		common.SysLog(fmt.Sprintf("config bundle applied: %d changes, unmanaged channels: %v", len(plan.Changes), plan.UnmanagedChannels))
+	}
+
 	common.SysLog("MIX API " + common.Version + " started")
 	if os.Getenv("GIN_MODE") != "debug" {
 		gin.SetMode(gin.ReleaseMode)
@@ -86,6 +103,47 @@ func main() {
 	// 数据看板
 	go model.UpdateQuotaData()
 
+	// 汇率同步
+	if common.IsMasterNode {
+		gopool.Go(service.AutomaticallySyncExchangeRates)
+	}
+
+	// 审计日志清理
+	if common.IsMasterNode {
+		gopool.Go(service.AutomaticallyCleanAuditLogs)
+	}
+
+	// 异步任务回调重试
+	if common.IsMasterNode {
+		gopool.Go(service.AutomaticallyRetryTaskWebhooks)
+	}
+
+	// 任务结果转存文件清理
+	if common.IsMasterNode {
+		gopool.Go(service.AutomaticallyCleanupTaskAssets)
+	}
+
+	// 令牌过期提醒与轮换旧令牌清理
+	if common.IsMasterNode {
+		gopool.Go(service.AutomaticallyCheckTokenExpiry)
+	}
+
+	// 自动禁用模型的重新测试
+	if common.IsMasterNode {
+		gopool.Go(controller.AutomaticallyRetestDisabledAbilities)
+	}
+
+	// 渠道维护窗口与排空进度检查
+	if common.IsMasterNode {
+		gopool.Go(service.AutomaticallyRunMaintenanceWindows)
+	}
+	// 上游模型定时同步
+	if common.IsMasterNode {
+		gopool.Go(controller.AutomaticallySyncChannelModels)
+	}
+	// 启用 Redis 时各节点上报进行中的请求数
+	gopool.Go(service.AutomaticallySyncChannelInflight)
+
 	if os.Getenv("CHANNEL_UPDATE_FREQUENCY") != "" {
 		frequency, err := strconv.Atoi(os.Getenv("CHANNEL_UPDATE_FREQUENCY"))
 		if err != nil {
@@ -100,12 +158,13 @@ func main() {
 		}
 		go controller.AutomaticallyTestChannels(frequency)
 	}
-	if common.IsMasterNode && constant.UpdateTask {
+	// 启用 Redis 时所有节点都参与任务轮询，通过租约避免重复查询
+	if service.ShouldRunTaskPoller() {
 		gopool.Go(func() {
-			controller.UpdateMidjourneyTaskBulk()
+			controller.UpdateTaskBulk()
 		})
 		gopool.Go(func() {
-			controller.UpdateTaskBulk()
+			controller.SweepTimeoutTasks()
 		})
 	}
 	if os.Getenv("BATCH_UPDATE_ENABLED") == "true" {
@@ -133,6 +192,21 @@ func main() {
 			},
 		})
 	}))
+	// 受信任的反向代理，只有来自这些地址的请求才会使用 X-Forwarded-For 等请求头识别客户端 IP；
+	// 未设置时不信任任何代理，避免客户端伪造请求头绕过 IP 与国家访问规则
+	var trustedProxies []string
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" && proxies != "none" {
+		trustedProxies = common.SplitIpRules(proxies)
+	} else {
+		common.SysLog("TRUSTED_PROXIES not set, X-Forwarded-For is ignored and the connecting address is used as client IP; set TRUSTED_PROXIES when running behind a reverse proxy")
+	}
+	if err = server.SetTrustedProxies(trustedProxies); err != nil {
+		common.FatalLog("failed to set trusted proxies: " + err.Error())
+	}
+	// 由 CDN 提供的客户端 IP 请求头，如 CF-Connecting-IP
+	if trustedPlatform := os.Getenv("TRUSTED_PLATFORM"); trustedPlatform != "" {
+		server.TrustedPlatform = trustedPlatform
+	}
 	// This will cause SSE not to work!!!
 	//server.Use(gzip.Gzip(gzip.DefaultCompression))
 	server.Use(middleware.RequestId())
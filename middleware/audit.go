@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"one-api/common"
+	"one-api/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditAdminRequest 执行管理接口，若处理函数没有记录审计日志则按请求记录一条
+func auditAdminRequest(c *gin.Context) {
+	if !service.IsAuditedMethod(c.Request.Method) {
+		c.Next()
+		return
+	}
+	var body []byte
+	if strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json") {
+		body, _ = common.GetRequestBody(c)
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+	}
+	c.Next()
+	if !service.IsAuditRecorded(c) {
+		service.RecordAuditRequest(c, body)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"one-api/common"
 	"one-api/constant"
 	"one-api/model"
+	"one-api/service"
 	"strconv"
 	"strings"
 
@@ -26,6 +27,45 @@ func validUserInfo(username string, role int) bool {
 }
 
 func authHelper(c *gin.Context, minRole int) {
+	if !authenticate(c, minRole) {
+		return
+	}
+	c.Next()
+}
+
+// getManagementToken 返回本次请求使用的管理令牌，会话登录时返回 nil
+func getManagementToken(c *gin.Context) *model.ManagementToken {
+	token, ok := common.GetContextKey(c, constant.ContextKeyManagementToken)
+	if !ok {
+		return nil
+	}
+	return token.(*model.ManagementToken)
+}
+
+// checkTokenScope 使用管理令牌访问时要求拥有指定的权限范围
+func checkTokenScope(c *gin.Context, scope string) bool {
+	token := getManagementToken(c)
+	if token == nil || token.HasScope(scope) {
+		return true
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": false,
+		"message": "无权进行此操作，access token 缺少权限范围 " + scope,
+	})
+	c.Abort()
+	return false
+}
+
+// defaultSelfScope 个人接口的读操作需要 self.read，写操作需要 self.write
+func defaultSelfScope(c *gin.Context) string {
+	if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+		return common.ScopeSelfRead
+	}
+	return common.ScopeSelfWrite
+}
+
+// authenticate 校验登录状态与权限等级，失败时写入响应并中止请求
+func authenticate(c *gin.Context, minRole int) bool {
 	session := sessions.Default(c)
 	username := session.Get("username")
 	role := session.Get("role")
@@ -41,32 +81,32 @@ func authHelper(c *gin.Context, minRole int) {
 				"message": "无权进行此操作，未登录且未提供 access token",
 			})
 			c.Abort()
-			return
+			return false
 		}
-		user := model.ValidateAccessToken(accessToken)
-		if user != nil && user.Username != "" {
-			if !validUserInfo(user.Username, user.Role) {
-				c.JSON(http.StatusOK, gin.H{
-					"success": false,
-					"message": "无权进行此操作，用户信息无效",
-				})
-				c.Abort()
-				return
-			}
-			// Token is valid
-			username = user.Username
-			role = user.Role
-			id = user.Id
-			status = user.Status
-			useAccessToken = true
-		} else {
+		managementToken, user, err := model.ValidateManagementToken(accessToken, c.ClientIP())
+		if err != nil {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
-				"message": "无权进行此操作，access token 无效",
+				"message": "无权进行此操作，" + err.Error(),
 			})
 			c.Abort()
-			return
+			return false
+		}
+		if !validUserInfo(user.Username, user.Role) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无权进行此操作，用户信息无效",
+			})
+			c.Abort()
+			return false
 		}
+		// Token is valid
+		username = user.Username
+		role = user.Role
+		id = user.Id
+		status = user.Status
+		useAccessToken = true
+		common.SetContextKey(c, constant.ContextKeyManagementToken, managementToken)
 	}
 	// get header New-Api-User
 	apiUserIdStr := c.Request.Header.Get("New-Api-User")
@@ -76,7 +116,7 @@ func authHelper(c *gin.Context, minRole int) {
 			"message": "无权进行此操作，未提供 New-Api-User",
 		})
 		c.Abort()
-		return
+		return false
 	}
 	apiUserId, err := strconv.Atoi(apiUserIdStr)
 	if err != nil {
@@ -85,7 +125,7 @@ func authHelper(c *gin.Context, minRole int) {
 			"message": "无权进行此操作，New-Api-User 格式错误",
 		})
 		c.Abort()
-		return
+		return false
 
 	}
 	if id != apiUserId {
@@ -94,7 +134,7 @@ func authHelper(c *gin.Context, minRole int) {
 			"message": "无权进行此操作，New-Api-User 与登录用户不匹配",
 		})
 		c.Abort()
-		return
+		return false
 	}
 	if status.(int) == common.UserStatusDisabled {
 		c.JSON(http.StatusOK, gin.H{
@@ -102,7 +142,7 @@ func authHelper(c *gin.Context, minRole int) {
 			"message": "用户已被封禁",
 		})
 		c.Abort()
-		return
+		return false
 	}
 	if role.(int) < minRole {
 		c.JSON(http.StatusOK, gin.H{
@@ -110,7 +150,7 @@ func authHelper(c *gin.Context, minRole int) {
 			"message": "无权进行此操作，权限不足",
 		})
 		c.Abort()
-		return
+		return false
 	}
 	if !validUserInfo(username.(string), role.(int)) {
 		c.JSON(http.StatusOK, gin.H{
@@ -118,7 +158,7 @@ func authHelper(c *gin.Context, minRole int) {
 			"message": "无权进行此操作，用户信息无效",
 		})
 		c.Abort()
-		return
+		return false
 	}
 	c.Set("username", username)
 	c.Set("role", role)
@@ -137,7 +177,7 @@ func authHelper(c *gin.Context, minRole int) {
 	//}
 	//userCache.WriteContext(c)
 
-	c.Next()
+	return true
 }
 
 func TryUserAuth() func(c *gin.Context) {
@@ -153,7 +193,35 @@ func TryUserAuth() func(c *gin.Context) {
 
 func UserAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		authHelper(c, common.RoleCommonUser)
+		if !authenticate(c, common.RoleCommonUser) || !checkTokenScope(c, defaultSelfScope(c)) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// ScopedUserAuth 与 UserAuth 相同，但使用管理令牌访问时要求指定的权限范围
+func ScopedUserAuth(scope string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if !authenticate(c, common.RoleCommonUser) || !checkTokenScope(c, scope) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// SessionOnly 仅允许登录会话访问，用于管理令牌、两步验证等不应由令牌操作的接口，需放在 UserAuth 之后
+func SessionOnly() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if c.GetBool("use_access_token") {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无权进行此操作，该接口不支持 access token",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
 	}
 }
 
@@ -169,6 +237,75 @@ func RootAuth() func(c *gin.Context) {
 	}
 }
 
+// PermissionAuth 要求登录用户拥有任一指定权限，权限由自定义角色或权限等级的默认权限决定
+func PermissionAuth(permissions ...string) func(c *gin.Context) {
+	return permissionAuth(permissions, false)
+}
+
+// PermissionAuthAll 要求登录用户同时拥有全部指定权限
+func PermissionAuthAll(permissions ...string) func(c *gin.Context) {
+	return permissionAuth(permissions, true)
+}
+
+func permissionAuth(permissions []string, requireAll bool) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if !authenticate(c, common.RoleCommonUser) {
+			return
+		}
+		// 使用管理令牌时，仅令牌权限范围内的权限有效
+		required := permissions
+		if token := getManagementToken(c); token != nil {
+			required = make([]string, 0, len(permissions))
+			for _, permission := range permissions {
+				if token.HasScope(permission) {
+					required = append(required, permission)
+				}
+			}
+			if requireAll && len(required) != len(permissions) {
+				required = nil
+			}
+		}
+		granted := len(required) > 0
+		if granted && requireAll {
+			granted = model.UserHasAllPermissions(c.GetInt("id"), c.GetInt("role"), required...)
+		} else if granted {
+			granted = model.UserHasPermission(c.GetInt("id"), c.GetInt("role"), required...)
+		}
+		if !granted {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无权进行此操作，权限不足",
+			})
+			c.Abort()
+			return
+		}
+		if !checkTwoFactorEnforcement(c) {
+			return
+		}
+		auditAdminRequest(c)
+	}
+}
+
+// checkTwoFactorEnforcement 要求管理员启用两步验证时，会话必须经过两步验证或通行密钥登录（access token 不受影响）
+func checkTwoFactorEnforcement(c *gin.Context) bool {
+	if c.GetBool("use_access_token") || !service.IsTwoFactorEnforced(c.GetInt("role")) {
+		return true
+	}
+	if mfa, _ := sessions.Default(c).Get(common.SessionKeyMFA).(bool); mfa {
+		return true
+	}
+	message := "管理员要求启用两步验证，请先在个人设置中启用两步验证或添加通行密钥"
+	if model.HasSecondFactor(c.GetInt("id")) {
+		message = "管理员要求两步验证，请重新登录并完成两步验证"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": false,
+		"message": message,
+	})
+	c.Abort()
+	return false
+}
+
 func WssAuth(c *gin.Context) {
 
 }
@@ -257,7 +394,7 @@ func TokenAuth() func(c *gin.Context) {
 
 		// 增加Token使用次数
 		go func() {
-			if increaseErr := model.IncreaseTokenUsageCount(token.Key); increaseErr != nil {
+			if increaseErr := model.IncreaseTokenUsageCount(token.Id); increaseErr != nil {
 				common.SysError("failed to increase token usage count: " + increaseErr.Error())
 			}
 		}()
@@ -280,6 +417,7 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	c.Set("id", token.UserId)
 	c.Set("token_id", token.Id)
 	c.Set("token_key", token.Key)
+	c.Set("token_key_hash", token.GetKeyHash())
 	c.Set("token_name", token.Name)
 	c.Set("token_unlimited_quota", token.UnlimitedQuota)
 	if !token.UnlimitedQuota {
@@ -291,8 +429,11 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	} else {
 		c.Set("token_model_limit_enabled", false)
 	}
-	c.Set("allow_ips", token.GetIpLimitsMap())
+	common.SetContextKey(c, constant.ContextKeyTokenAllowIps, token.GetAllowIpRules())
+	common.SetContextKey(c, constant.ContextKeyTokenDenyIps, token.GetDenyIpRules())
+	common.SetContextKey(c, constant.ContextKeyTokenCallbackUrl, token.CallbackUrl)
 	c.Set("token_group", token.Group)
+	common.SetContextKey(c, constant.ContextKeyTokenOrganizationId, token.OrganizationId)
 
 	// 设置令牌渠道标签到上下文中
 	if token.ChannelTag != nil && *token.ChannelTag != "" {
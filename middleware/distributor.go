@@ -3,11 +3,13 @@ package middleware
 import (
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"one-api/common"
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
+	"one-api/relay/channel"
 	relayconstant "one-api/relay/constant"
 	"one-api/service"
 	"one-api/setting"
@@ -27,13 +29,12 @@ type ModelRequest struct {
 
 func Distribute() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		allowIpsMap := common.GetContextKeyStringMap(c, constant.ContextKeyTokenAllowIps)
-		if len(allowIpsMap) != 0 {
-			clientIp := c.ClientIP()
-			if _, ok := allowIpsMap[clientIp]; !ok {
-				abortWithOpenAiMessage(c, http.StatusForbidden, "您的 IP 不在令牌允许访问的列表中")
-				return
-			}
+		userSetting, _ := common.GetContextKeyType[dto.UserSetting](c, constant.ContextKeyUserSetting)
+		tokenAllowIps, _ := common.GetContextKeyType[[]*net.IPNet](c, constant.ContextKeyTokenAllowIps)
+		tokenDenyIps, _ := common.GetContextKeyType[[]*net.IPNet](c, constant.ContextKeyTokenDenyIps)
+		if err := service.CheckIpAccess(c.ClientIP(), userSetting, tokenAllowIps, tokenDenyIps); err != nil {
+			abortWithOpenAiMessage(c, http.StatusForbidden, err.Error())
+			return
 		}
 		var channel *model.Channel
 		channelId, ok := common.GetContextKey(c, constant.ContextKeyTokenSpecificChannelId)
@@ -161,35 +162,27 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 			modelRequest.Model = midjourneyModel
 		}
 		c.Set("relay_mode", relayMode)
-	} else if strings.Contains(c.Request.URL.Path, "/suno/") {
-		relayMode := relayconstant.Path2RelaySuno(c.Request.Method, c.Request.URL.Path)
-		if relayMode == relayconstant.RelayModeSunoFetch ||
-			relayMode == relayconstant.RelayModeSunoFetchByID {
+	} else if platform := c.GetString("platform"); platform != "" {
+		// 平台原生任务接口，路由注册时已写入平台与 relay_mode
+		provider := channel.GetTaskProvider(constant.TaskPlatform(platform))
+		if c.GetBool("task_fetch") {
 			shouldSelectChannel = false
+		} else if provider != nil && provider.ModelName != nil {
+			modelRequest.Model = provider.ModelName(c)
 		} else {
-			modelName := service.CoverTaskActionToModelName(constant.TaskPlatformSuno, c.Param("action"))
-			modelRequest.Model = modelName
+			err = common.UnmarshalBodyReusable(c, &modelRequest)
 		}
-		c.Set("platform", string(constant.TaskPlatformSuno))
-		c.Set("relay_mode", relayMode)
-	} else if strings.Contains(c.Request.URL.Path, "/v1/video/generations") {
-		err = common.UnmarshalBodyReusable(c, &modelRequest)
-		var platform string
-		var relayMode int
-		if strings.HasPrefix(modelRequest.Model, "jimeng") {
-			platform = string(constant.TaskPlatformJimeng)
-			relayMode = relayconstant.Path2RelayJimeng(c.Request.Method, c.Request.URL.Path)
-			if relayMode == relayconstant.RelayModeJimengFetchByID {
-				shouldSelectChannel = false
+	} else if strings.HasPrefix(c.Request.URL.Path, "/v1/video/generations") || strings.HasPrefix(c.Request.URL.Path, "/v1/tasks/") {
+		relayMode := relayconstant.Path2RelayVideo(c.Request.Method, c.Request.URL.Path)
+		if relayMode == relayconstant.RelayModeVideoSubmit {
+			err = common.UnmarshalBodyReusable(c, &modelRequest)
+			// 模型名无法匹配平台时，由所选渠道的类型决定
+			if provider := channel.MatchVideoTaskProvider(modelRequest.Model); provider != nil {
+				c.Set("platform", string(provider.Platform))
 			}
 		} else {
-			platform = string(constant.TaskPlatformKling)
-			relayMode = relayconstant.Path2RelayKling(c.Request.Method, c.Request.URL.Path)
-			if relayMode == relayconstant.RelayModeKlingFetchByID {
-				shouldSelectChannel = false
-			}
+			shouldSelectChannel = false
 		}
-		c.Set("platform", platform)
 		c.Set("relay_mode", relayMode)
 	} else if strings.HasPrefix(c.Request.URL.Path, "/v1beta/models/") || strings.HasPrefix(c.Request.URL.Path, "/v1/models/") {
 		// Gemini API 路径处理: /v1beta/models/gemini-2.0-flash:generateContent
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"one-api/dto"
+	"one-api/service"
+	"one-api/setting/system_setting"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func abortWithScimError(c *gin.Context, status int, detail string) {
+	c.Header("Content-Type", "application/scim+json")
+	c.JSON(status, dto.ScimError{
+		Schemas: []string{dto.ScimSchemaError},
+		Status:  strconv.Itoa(status),
+		Detail:  detail,
+	})
+	c.Abort()
+}
+
+// ScimAuth 校验身份提供商的 SCIM Bearer Token，审计日志中操作人记为 scim
+func ScimAuth() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if !system_setting.GetScimSettings().Enabled {
+			abortWithScimError(c, http.StatusForbidden, "SCIM is disabled")
+			return
+		}
+		if !service.ValidateScimToken(c.Request.Header.Get("Authorization")) {
+			abortWithScimError(c, http.StatusUnauthorized, "invalid bearer token")
+			return
+		}
+		c.Set("username", "scim")
+		c.Next()
+	}
+}
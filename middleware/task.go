@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"one-api/constant"
+	"one-api/relay/channel"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TaskRoute 平台原生任务接口在分发前写入平台与 relay_mode，由 Distribute 据此解析模型
+func TaskRoute(platform constant.TaskPlatform, route channel.TaskRoute) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("platform", string(platform))
+		c.Set("relay_mode", route.RelayMode)
+		c.Set("task_fetch", route.Fetch)
+		c.Next()
+	}
+}
@@ -0,0 +1,170 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+// AbilityHealth 渠道中单个模型的健康状态。错误只与模型有关时只禁用该模型的 abilities，渠道的其他模型不受影响；
+// 状态取值与渠道一致：启用、手动禁用、自动禁用
+type AbilityHealth struct {
+	ChannelId     int    `json:"channel_id" gorm:"primaryKey;autoIncrement:false"`
+	Model         string `json:"model" gorm:"type:varchar(255);primaryKey;autoIncrement:false"`
+	Status        int    `json:"status" gorm:"default:1;index"`
+	StatusReason  string `json:"status_reason" gorm:"type:text"`
+	StatusTime    int64  `json:"status_time" gorm:"bigint"`
+	FailCount     int    `json:"fail_count"` // 连续失败次数
+	LastError     string `json:"last_error" gorm:"type:text"`
+	LastErrorTime int64  `json:"last_error_time" gorm:"bigint"`
+	RetestTime    int64  `json:"retest_time" gorm:"bigint"` // 禁用后最近一次重新测试的时间
+}
+
+// unhealthyAbilityCondition 匹配已被按模型禁用的 abilities
+const unhealthyAbilityCondition = "EXISTS (SELECT 1 FROM ability_healths WHERE ability_healths.channel_id = abilities.channel_id AND ability_healths.model = abilities.model AND ability_healths.status <> ?)"
+
+func GetAbilityHealth(channelId int, modelName string) (*AbilityHealth, error) {
+	var health AbilityHealth
+	err := DB.Where("channel_id = ? AND model = ?", channelId, modelName).First(&health).Error
+	if err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// GetAbilityHealths 返回渠道的模型健康记录，channelIds 为空时返回全部
+func GetAbilityHealths(channelIds []int) ([]*AbilityHealth, error) {
+	var healths []*AbilityHealth
+	tx := DB.Model(&AbilityHealth{})
+	if len(channelIds) > 0 {
+		tx = tx.Where("channel_id IN ?", channelIds)
+	}
+	err := tx.Find(&healths).Error
+	return healths, err
+}
+
+// GetAbilityHealthsToRetest 返回自动禁用且距上次状态变更或重新测试已超过间隔的模型
+func GetAbilityHealthsToRetest(before int64, limit int) ([]*AbilityHealth, error) {
+	var healths []*AbilityHealth
+	err := DB.Where("status = ? AND status_time <= ? AND retest_time <= ?", common.ChannelStatusAutoDisabled, before, before).
+		Order("retest_time").Limit(limit).Find(&healths).Error
+	return healths, err
+}
+
+// getDisabledAbilityModels 返回渠道中被按模型禁用的模型
+func getDisabledAbilityModels(db *gorm.DB, channelId int) map[string]bool {
+	var models []string
+	db.Model(&AbilityHealth{}).Where("channel_id = ? AND status <> ?", channelId, common.ChannelStatusEnabled).Pluck("model", &models)
+	disabled := make(map[string]bool, len(models))
+	for _, m := range models {
+		disabled[m] = true
+	}
+	return disabled
+}
+
+// UpdateAbilityHealthStatus 修改渠道中单个模型的状态并同步 abilities 与渠道缓存，状态未变化时返回 false
+func UpdateAbilityHealthStatus(channelId int, modelName string, status int, reason string) (bool, error) {
+	channel, err := GetChannelById(channelId, true)
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var health AbilityHealth
+		err := tx.Where("channel_id = ? AND model = ?", channelId, modelName).First(&health).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err != nil {
+			// 没有记录时视为启用
+			if status == common.ChannelStatusEnabled {
+				return nil
+			}
+			health = AbilityHealth{ChannelId: channelId, Model: modelName}
+		} else if health.Status == status {
+			return nil
+		}
+		health.Status = status
+		health.StatusReason = reason
+		health.StatusTime = common.GetTimestamp()
+		if status == common.ChannelStatusEnabled {
+			health.FailCount = 0
+		}
+		if err := tx.Save(&health).Error; err != nil {
+			return err
+		}
+		enabled := status == common.ChannelStatusEnabled && channel.Status == common.ChannelStatusEnabled
+		if err := tx.Model(&Ability{}).Where("channel_id = ? AND model = ?", channelId, modelName).
+			Select("enabled").Update("enabled", enabled).Error; err != nil {
+			return err
+		}
+		changed = true
+		return nil
+	})
+	if err != nil || !changed {
+		return false, err
+	}
+	InitChannelCache()
+	return true, nil
+}
+
+// RecordAbilityError 记录模型的一次失败，返回记录后的连续失败次数
+func RecordAbilityError(channelId int, modelName string, message string) int {
+	now := common.GetTimestamp()
+	result := DB.Model(&AbilityHealth{}).Where("channel_id = ? AND model = ?", channelId, modelName).Updates(map[string]interface{}{
+		"fail_count":      gorm.Expr("fail_count + ?", 1),
+		"last_error":      message,
+		"last_error_time": now,
+	})
+	if result.Error == nil && result.RowsAffected == 0 {
+		result = DB.Create(&AbilityHealth{
+			ChannelId:     channelId,
+			Model:         modelName,
+			Status:        common.ChannelStatusEnabled,
+			FailCount:     1,
+			LastError:     message,
+			LastErrorTime: now,
+		})
+	}
+	if result.Error != nil {
+		common.SysError("failed to record ability error: " + result.Error.Error())
+		return 0
+	}
+	var failCount int
+	DB.Model(&AbilityHealth{}).Select("fail_count").Where("channel_id = ? AND model = ?", channelId, modelName).Scan(&failCount)
+	return failCount
+}
+
+// RecordAbilitySuccess 测试成功时清空连续失败次数
+func RecordAbilitySuccess(channelId int, modelName string) {
+	err := DB.Model(&AbilityHealth{}).Where("channel_id = ? AND model = ? AND fail_count > 0", channelId, modelName).
+		Update("fail_count", 0).Error
+	if err != nil {
+		common.SysError("failed to record ability success: " + err.Error())
+	}
+}
+
+func UpdateAbilityRetestTime(channelId int, modelName string) {
+	DB.Model(&AbilityHealth{}).Where("channel_id = ? AND model = ?", channelId, modelName).
+		Update("retest_time", common.GetTimestamp())
+}
+
+func DeleteAbilityHealth(channelId int, modelName string) error {
+	return DB.Where("channel_id = ? AND model = ?", channelId, modelName).Delete(&AbilityHealth{}).Error
+}
+
+func DeleteAbilityHealths(channelIds ...int) error {
+	if len(channelIds) == 0 {
+		return nil
+	}
+	return DB.Where("channel_id IN ?", channelIds).Delete(&AbilityHealth{}).Error
+}
+
+// deleteOrphanAbilityHealths 清理已删除渠道的模型健康记录
+func deleteOrphanAbilityHealths() {
+	err := DB.Where("channel_id NOT IN (?)", DB.Model(&Channel{}).Select("id")).Delete(&AbilityHealth{}).Error
+	if err != nil {
+		common.SysError("failed to delete ability healths: " + err.Error())
+	}
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"context"
+)
+
+// AuditLog 管理操作审计日志，存储在主库中，不受消费日志清理影响
+type AuditLog struct {
+	Id         int    `json:"id"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint;index"`
+	ActorId    int    `json:"actor_id" gorm:"index"`
+	ActorName  string `json:"actor_name" gorm:"type:varchar(64);default:''"`
+	ActorRole  int    `json:"actor_role" gorm:"default:0"`
+	Action     string `json:"action" gorm:"type:varchar(128);index"`
+	TargetType string `json:"target_type" gorm:"type:varchar(32);index"`
+	TargetId   string `json:"target_id" gorm:"type:varchar(128);index"`
+	Before     string `json:"before" gorm:"type:text"` // JSON，仅包含变更字段，敏感字段已脱敏
+	After      string `json:"after" gorm:"type:text"`
+	Ip         string `json:"ip" gorm:"type:varchar(64);default:''"`
+	UserAgent  string `json:"user_agent" gorm:"type:varchar(255);default:''"`
+	RequestId  string `json:"request_id" gorm:"type:varchar(64);index;default:''"`
+	StatusCode int    `json:"status_code" gorm:"default:0"`
+}
+
+type AuditLogFilter struct {
+	ActorId        int
+	Action         string
+	TargetType     string
+	TargetId       string
+	RequestId      string
+	StartTimestamp int64
+	EndTimestamp   int64
+}
+
+func (log *AuditLog) Insert() error {
+	return DB.Create(log).Error
+}
+
+func GetAuditLogs(filter AuditLogFilter, startIdx int, num int) (logs []*AuditLog, total int64, err error) {
+	tx := DB.Model(&AuditLog{})
+	if filter.ActorId != 0 {
+		tx = tx.Where("actor_id = ?", filter.ActorId)
+	}
+	if filter.Action != "" {
+		tx = tx.Where("action LIKE ?", filter.Action+"%")
+	}
+	if filter.TargetType != "" {
+		tx = tx.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetId != "" {
+		tx = tx.Where("target_id = ?", filter.TargetId)
+	}
+	if filter.RequestId != "" {
+		tx = tx.Where("request_id = ?", filter.RequestId)
+	}
+	if filter.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", filter.StartTimestamp)
+	}
+	if filter.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", filter.EndTimestamp)
+	}
+	err = tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	tx = tx.Order("id desc")
+	if num > 0 {
+		tx = tx.Limit(num).Offset(startIdx)
+	}
+	err = tx.Find(&logs).Error
+	return logs, total, err
+}
+
+// DeleteOldAuditLog 按保留期限分批清理审计日志
+func DeleteOldAuditLog(ctx context.Context, targetTimestamp int64, limit int) (int64, error) {
+	var total int64 = 0
+	for {
+		if nil != ctx.Err() {
+			return total, ctx.Err()
+		}
+		result := DB.Where("created_at < ?", targetTimestamp).Limit(limit).Delete(&AuditLog{})
+		if nil != result.Error {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < int64(limit) {
+			break
+		}
+	}
+	return total, nil
+}
@@ -10,6 +10,7 @@ import (
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/types"
+	"sort"
 	"strings"
 	"sync"
 
@@ -46,6 +47,8 @@ type Channel struct {
 	ParamOverride     *string `json:"param_override" gorm:"type:text"`
 	// add after v0.8.5
 	ChannelInfo ChannelInfo `json:"channel_info" gorm:"type:json"`
+	// 写入数据库期间暂存明文密钥
+	plainKey string
 }
 
 type ChannelInfo struct {
@@ -68,10 +71,11 @@ func (c *ChannelInfo) Scan(value interface{}) error {
 }
 
 func (channel *Channel) getKeys() []string {
-	if channel.Key == "" {
+	key := channel.decryptedKey()
+	if key == "" {
 		return []string{}
 	}
-	trimmed := strings.TrimSpace(channel.Key)
+	trimmed := strings.TrimSpace(key)
 	// If the key starts with '[', try to parse it as a JSON array (e.g., for Vertex AI scenarios)
 	if strings.HasPrefix(trimmed, "[") {
 		var arr []json.RawMessage
@@ -84,14 +88,14 @@ func (channel *Channel) getKeys() []string {
 		}
 	}
 	// Otherwise, fall back to splitting by newline
-	keys := strings.Split(strings.Trim(channel.Key, "\n"), "\n")
+	keys := strings.Split(strings.Trim(key, "\n"), "\n")
 	return keys
 }
 
 func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 	// If not in multi-key mode, return the original key string directly.
 	if !channel.ChannelInfo.IsMultiKey {
-		return channel.Key, 0, nil
+		return channel.decryptedKey(), 0, nil
 	}
 
 	// Obtain all keys (split by \n)
@@ -344,22 +348,77 @@ func BatchInsertChannels(channels []Channel) error {
 
 func BatchDeleteChannels(ids []int) error {
 	//使用事务 删除channel表和channel_ability表
-	tx := DB.Begin()
-	err := tx.Where("id in (?)", ids).Delete(&Channel{}).Error
-	if err != nil {
-		// 回滚事务
-		tx.Rollback()
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return deleteChannelsInTx(tx, ids)
+	})
+}
+
+// deleteChannelsInTx 在事务中删除渠道及其能力、测试结果、健康状态与余额历史
+func deleteChannelsInTx(tx *gorm.DB, ids []int) error {
+	if err := tx.Where("id in (?)", ids).Delete(&Channel{}).Error; err != nil {
 		return err
 	}
-	err = tx.Where("channel_id in (?)", ids).Delete(&Ability{}).Error
+	for _, table := range []any{&Ability{}, &ChannelTestResult{}, &AbilityHealth{}, &ChannelBalanceHistory{}} {
+		if err := tx.Where("channel_id in (?)", ids).Delete(table).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChannelChange 配置包中要创建（Columns 为 nil）或更新指定字段的渠道
+type ChannelChange struct {
+	Channel *Channel
+	Columns []string
+}
+
+// ApplyConfigChanges 在同一事务中保存配置、创建或更新渠道并删除渠道，
+// 提交成功后才刷新内存中的配置与渠道缓存
+func ApplyConfigChanges(options map[string]string, changes []ChannelChange, deleteIds []int) error {
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, key := range keys {
+			if err := tx.Save(&Option{Key: key, Value: options[key]}).Error; err != nil {
+				return fmt.Errorf("更新配置 %s 失败：%s", key, err.Error())
+			}
+		}
+		for _, change := range changes {
+			var err error
+			if change.Columns == nil {
+				err = tx.Create(change.Channel).Error
+			} else {
+				err = tx.Model(change.Channel).Select(change.Columns).Updates(change.Channel).Error
+			}
+			if err == nil {
+				err = change.Channel.UpdateAbilities(tx)
+			}
+			if err != nil {
+				return fmt.Errorf("保存渠道 %s 失败：%s", change.Channel.Name, err.Error())
+			}
+		}
+		if len(deleteIds) > 0 {
+			if err := deleteChannelsInTx(tx, deleteIds); err != nil {
+				return fmt.Errorf("删除渠道失败：%s", err.Error())
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		// 回滚事务
-		tx.Rollback()
 		return err
 	}
-	// 提交事务
-	tx.Commit()
-	return err
+	for _, key := range keys {
+		if err = updateOptionMap(key, options[key]); err != nil {
+			common.SysError(fmt.Sprintf("failed to update option %s: %s", key, err.Error()))
+		}
+	}
+	if len(changes) > 0 || len(deleteIds) > 0 {
+		InitChannelCache()
+	}
+	return nil
 }
 
 func (channel *Channel) GetPriority() int64 {
@@ -408,6 +467,10 @@ func (channel *Channel) Insert() error {
 }
 
 func (channel *Channel) Update() error {
+	// 脱敏后的密钥视为未修改
+	if common.IsMaskedSecret(channel.Key) {
+		channel.Key = ""
+	}
 	// If this is a multi-key channel, recalculate MultiKeySize based on the current key list to avoid inconsistency after editing keys
 	if channel.ChannelInfo.IsMultiKey {
 		var keyStr string
@@ -483,7 +546,18 @@ func (channel *Channel) Delete() error {
 		return err
 	}
 	err = channel.DeleteAbilities()
-	return err
+	if err != nil {
+		return err
+	}
+	err = DeleteChannelTestResults(channel.Id)
+	if err != nil {
+		return err
+	}
+	err = DeleteAbilityHealths(channel.Id)
+	if err != nil {
+		return err
+	}
+	return DeleteChannelBalanceHistories(channel.Id)
 }
 
 var channelStatusLock sync.Mutex
@@ -637,6 +711,145 @@ func DisableChannelByTag(tag string) error {
 	return err
 }
 
+// DrainChannels 将启用的渠道切换为排空状态，不再分配新请求，返回实际切换的渠道
+func DrainChannels(channelIds []int, reason string) ([]int, error) {
+	return switchChannelsStatus(channelIds, common.ChannelStatusEnabled, common.ChannelStatusDraining, reason)
+}
+
+// RestoreDrainingChannels 将排空中的渠道恢复为启用，返回实际恢复的渠道
+func RestoreDrainingChannels(channelIds []int) ([]int, error) {
+	return switchChannelsStatus(channelIds, common.ChannelStatusDraining, common.ChannelStatusEnabled, "")
+}
+
+func switchChannelsStatus(channelIds []int, from int, to int, reason string) ([]int, error) {
+	if len(channelIds) == 0 {
+		return nil, nil
+	}
+	var channels []*Channel
+	if err := DB.Where("id IN ? AND status = ?", channelIds, from).Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	switched := make([]int, 0, len(channels))
+	for _, channel := range channels {
+		info := channel.GetOtherInfo()
+		info["status_reason"] = reason
+		info["status_time"] = common.GetTimestamp()
+		delete(info, "drained_time")
+		channel.SetOtherInfo(info)
+		// 带上原状态条件，避免覆盖期间被其他流程修改的状态
+		result := DB.Model(&Channel{}).Where("id = ? AND status = ?", channel.Id, from).Updates(map[string]interface{}{
+			"status":     to,
+			"other_info": channel.OtherInfo,
+		})
+		if result.Error != nil {
+			return switched, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		if err := UpdateAbilityStatus(channel.Id, to == common.ChannelStatusEnabled); err != nil {
+			common.SysError("failed to update ability status: " + err.Error())
+		}
+		switched = append(switched, channel.Id)
+	}
+	if len(switched) > 0 {
+		InitChannelCache()
+	}
+	return switched, nil
+}
+
+func GetDrainingChannels() ([]*Channel, error) {
+	var channels []*Channel
+	err := DB.Omit("key").Where("status = ?", common.ChannelStatusDraining).Order("id").Find(&channels).Error
+	return channels, err
+}
+
+// MarkChannelDrained 记录排空完成的时间，避免重复通知
+func MarkChannelDrained(channel *Channel) error {
+	info := channel.GetOtherInfo()
+	info["drained_time"] = common.GetTimestamp()
+	channel.SetOtherInfo(info)
+	return DB.Model(&Channel{}).Where("id = ? AND status = ?", channel.Id, common.ChannelStatusDraining).
+		Update("other_info", channel.OtherInfo).Error
+}
+
+// UpdateChannelModels 修改渠道的模型列表并重建 abilities
+func UpdateChannelModels(channel *Channel, models []string) error {
+	channel.Models = strings.Join(models, ",")
+	if err := DB.Model(&Channel{}).Where("id = ?", channel.Id).Update("models", channel.Models).Error; err != nil {
+		return err
+	}
+	// 按数据库中最新的状态、分组与优先级重建，避免期间被禁用或排空的渠道重新启用
+	current, err := GetChannelById(channel.Id, true)
+	if err != nil {
+		return err
+	}
+	if err := current.UpdateAbilities(nil); err != nil {
+		return err
+	}
+	InitChannelCache()
+	return nil
+}
+
+// UpdateChannelPriority 修改渠道及其 abilities 的优先级
+func UpdateChannelPriority(channel *Channel, priority int64) error {
+	channel.Priority = &priority
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Channel{}).Where("id = ?", channel.Id).Update("priority", priority).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Ability{}).Where("channel_id = ?", channel.Id).Update("priority", priority).Error
+	})
+	if err != nil {
+		return err
+	}
+	InitChannelCache()
+	return nil
+}
+
+// UpdateFields 只更新指定字段（包括零值）并重建 abilities
+func (channel *Channel) UpdateFields(columns []string) error {
+	err := DB.Model(channel).Select(columns).Updates(channel).Error
+	if err != nil {
+		return err
+	}
+	return channel.UpdateAbilities(nil)
+}
+
+// PatchChannelOtherInfo 基于数据库中最新的 other_info 修改 patch 中的键，值为 nil 时删除该键。
+// 以读取到的旧值为条件更新，并发修改时重试，避免覆盖其他流程写入的键
+func PatchChannelOtherInfo(channel *Channel, patch map[string]interface{}) error {
+	for i := 0; i < 5; i++ {
+		var current Channel
+		if err := DB.Select("id", "other_info").First(&current, "id = ?", channel.Id).Error; err != nil {
+			return err
+		}
+		info := current.GetOtherInfo()
+		for key, value := range patch {
+			if value == nil {
+				delete(info, key)
+			} else {
+				info[key] = value
+			}
+		}
+		origin := current.OtherInfo
+		current.SetOtherInfo(info)
+		if current.OtherInfo == origin {
+			channel.OtherInfo = origin
+			return nil
+		}
+		result := DB.Model(&Channel{}).Where("id = ? AND other_info = ?", channel.Id, origin).Update("other_info", current.OtherInfo)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			channel.OtherInfo = current.OtherInfo
+			return nil
+		}
+	}
+	return errors.New("渠道信息被并发修改，请稍后重试")
+}
+
 func EditChannelByTag(tag string, newTag *string, modelMapping *string, models *string, group *string, priority *int64, weight *uint) error {
 	updateData := Channel{}
 	shouldReCreateAbilities := false
@@ -704,11 +917,21 @@ func updateChannelUsedQuota(id int, quota int) {
 
 func DeleteChannelByStatus(status int64) (int64, error) {
 	result := DB.Where("status = ?", status).Delete(&Channel{})
+	if result.RowsAffected > 0 {
+		deleteOrphanChannelTestResults()
+		deleteOrphanAbilityHealths()
+		deleteOrphanChannelBalanceHistories()
+	}
 	return result.RowsAffected, result.Error
 }
 
 func DeleteDisabledChannel() (int64, error) {
 	result := DB.Where("status = ? or status = ?", common.ChannelStatusAutoDisabled, common.ChannelStatusManuallyDisabled).Delete(&Channel{})
+	if result.RowsAffected > 0 {
+		deleteOrphanChannelTestResults()
+		deleteOrphanAbilityHealths()
+		deleteOrphanChannelBalanceHistories()
+	}
 	return result.RowsAffected, result.Error
 }
 
@@ -0,0 +1,46 @@
+package model
+
+import "one-api/common"
+
+// ChannelBalanceHistory 渠道余额的历史记录，用于估算消耗速度
+type ChannelBalanceHistory struct {
+	Id          int     `json:"id"`
+	ChannelId   int     `json:"channel_id" gorm:"index:idx_channel_balance_time"`
+	Balance     float64 `json:"balance"`
+	CreatedTime int64   `json:"created_time" gorm:"bigint;index:idx_channel_balance_time;index"`
+}
+
+func RecordChannelBalance(channelId int, balance float64) error {
+	return DB.Create(&ChannelBalanceHistory{
+		ChannelId:   channelId,
+		Balance:     balance,
+		CreatedTime: common.GetTimestamp(),
+	}).Error
+}
+
+// GetChannelBalanceHistory 返回渠道自 since 起的余额记录，按时间升序
+func GetChannelBalanceHistory(channelId int, since int64) ([]*ChannelBalanceHistory, error) {
+	var histories []*ChannelBalanceHistory
+	err := DB.Where("channel_id = ? AND created_time >= ?", channelId, since).Order("created_time").Find(&histories).Error
+	return histories, err
+}
+
+func DeleteChannelBalanceHistoryBefore(timestamp int64) (int64, error) {
+	result := DB.Where("created_time < ?", timestamp).Delete(&ChannelBalanceHistory{})
+	return result.RowsAffected, result.Error
+}
+
+func DeleteChannelBalanceHistories(channelIds ...int) error {
+	if len(channelIds) == 0 {
+		return nil
+	}
+	return DB.Where("channel_id IN ?", channelIds).Delete(&ChannelBalanceHistory{}).Error
+}
+
+// deleteOrphanChannelBalanceHistories 清理已删除渠道的余额记录
+func deleteOrphanChannelBalanceHistories() {
+	err := DB.Where("channel_id NOT IN (?)", DB.Model(&Channel{}).Select("id")).Delete(&ChannelBalanceHistory{}).Error
+	if err != nil {
+		common.SysError("failed to delete channel balance histories: " + err.Error())
+	}
+}
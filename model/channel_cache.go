@@ -39,6 +39,16 @@ func InitChannelCache() {
 	for group := range groups {
 		newGroup2model2channels[group] = make(map[string][]int)
 	}
+	// 按模型禁用的渠道不参与该模型的选择
+	var disabledHealths []*AbilityHealth
+	DB.Where("status <> ?", common.ChannelStatusEnabled).Find(&disabledHealths)
+	disabledAbilities := make(map[int]map[string]bool)
+	for _, health := range disabledHealths {
+		if disabledAbilities[health.ChannelId] == nil {
+			disabledAbilities[health.ChannelId] = make(map[string]bool)
+		}
+		disabledAbilities[health.ChannelId][health.Model] = true
+	}
 	for _, channel := range channels {
 		if channel.Status != common.ChannelStatusEnabled {
 			continue // skip disabled channels
@@ -47,6 +57,9 @@ func InitChannelCache() {
 		for _, group := range groups {
 			models := strings.Split(channel.Models, ",")
 			for _, model := range models {
+				if disabledAbilities[channel.Id][model] {
+					continue
+				}
 				if _, ok := newGroup2model2channels[group][model]; !ok {
 					newGroup2model2channels[group][model] = make([]int, 0)
 				}
@@ -0,0 +1,124 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+// writesChannelKey 判断本次写入是否包含该渠道的 key 字段
+func writesChannelKey(tx *gorm.DB, channel *Channel) bool {
+	switch dest := tx.Statement.Dest.(type) {
+	case *Channel:
+		if dest != channel {
+			return false
+		}
+	case *[]Channel, []Channel, *[]*Channel, []*Channel:
+	default:
+		return false
+	}
+	for _, omit := range tx.Statement.Omits {
+		if omit == "key" || omit == "Key" {
+			return false
+		}
+	}
+	if len(tx.Statement.Selects) == 0 {
+		return true
+	}
+	for _, column := range tx.Statement.Selects {
+		if column == "*" || column == "key" || column == "Key" {
+			return true
+		}
+	}
+	return false
+}
+
+// BeforeSave 写入数据库前加密渠道密钥，内存中的对象在 AfterSave 中恢复明文
+func (channel *Channel) BeforeSave(tx *gorm.DB) error {
+	if !common.SecretEncryptionEnabled() || channel.Key == "" || common.IsEncryptedSecret(channel.Key) {
+		return nil
+	}
+	if !writesChannelKey(tx, channel) {
+		return nil
+	}
+	encrypted, err := common.EncryptSecret(channel.Key)
+	if err != nil {
+		return err
+	}
+	channel.plainKey = channel.Key
+	channel.Key = encrypted
+	return nil
+}
+
+func (channel *Channel) AfterSave(tx *gorm.DB) error {
+	if channel.plainKey != "" {
+		channel.Key = channel.plainKey
+		channel.plainKey = ""
+	}
+	return nil
+}
+
+// AfterFind 读取后解密渠道密钥，解密失败时保留密文并记录错误
+func (channel *Channel) AfterFind(tx *gorm.DB) error {
+	if !common.IsEncryptedSecret(channel.Key) {
+		return nil
+	}
+	plain, err := common.DecryptSecret(channel.Key)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to decrypt key of channel #%d: %s", channel.Id, err.Error()))
+		return nil
+	}
+	channel.Key = plain
+	return nil
+}
+
+// decryptedKey 返回明文密钥，兼容未经 AfterFind 解密的对象
+func (channel *Channel) decryptedKey() string {
+	if !common.IsEncryptedSecret(channel.Key) {
+		return channel.Key
+	}
+	plain, err := common.DecryptSecret(channel.Key)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to decrypt key of channel #%d: %s", channel.Id, err.Error()))
+		return ""
+	}
+	return plain
+}
+
+// MaskKey 将密钥替换为脱敏值，用于接口返回
+func (channel *Channel) MaskKey() {
+	channel.Key = common.MaskSecret(channel.decryptedKey())
+}
+
+// RotateChannelKeys 使用当前主密钥重新加密所有渠道密钥，明文密钥会被加密，返回更新的渠道数量
+func RotateChannelKeys() (int, error) {
+	if !common.SecretEncryptionEnabled() {
+		return 0, errors.New("未配置 CHANNEL_KEY_MASTER_KEY，无法加密渠道密钥")
+	}
+	type channelKey struct {
+		Id  int
+		Key string
+	}
+	rotated := 0
+	var rows []channelKey
+	tx := DB.Session(&gorm.Session{SkipHooks: true})
+	err := tx.Model(&Channel{}).Select("id", commonKeyCol).FindInBatches(&rows, 100, func(batch *gorm.DB, _ int) error {
+		for _, row := range rows {
+			rewrapped, changed, err := common.RewrapSecret(row.Key)
+			if err != nil {
+				return fmt.Errorf("channel #%d: %w", row.Id, err)
+			}
+			if !changed {
+				continue
+			}
+			if err = tx.Model(&Channel{}).Where("id = ?", row.Id).UpdateColumn("key", rewrapped).Error; err != nil {
+				return err
+			}
+			rotated++
+		}
+		return nil
+	}).Error
+	return rotated, err
+}
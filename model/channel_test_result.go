@@ -0,0 +1,60 @@
+package model
+
+import (
+	"one-api/common"
+
+	"gorm.io/gorm/clause"
+)
+
+// ChannelTestResult 渠道每个模型最近一次的测试结果，任务平台渠道的探测结果模型为空
+type ChannelTestResult struct {
+	Id           int    `json:"id"`
+	ChannelId    int    `json:"channel_id" gorm:"uniqueIndex:idx_channel_test_model"`
+	Model        string `json:"model" gorm:"type:varchar(255);uniqueIndex:idx_channel_test_model"`
+	Mode         string `json:"mode" gorm:"type:varchar(16)"`
+	Success      bool   `json:"success"`
+	ResponseTime int    `json:"response_time"` // in milliseconds
+	Message      string `json:"message" gorm:"type:text"`
+	TestTime     int64  `json:"test_time" gorm:"bigint"`
+}
+
+// SaveChannelTestResult 按渠道与模型覆盖保存最近一次测试结果
+func SaveChannelTestResult(result *ChannelTestResult) error {
+	result.TestTime = common.GetTimestamp()
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "channel_id"}, {Name: "model"}},
+		DoUpdates: clause.AssignmentColumns([]string{"mode", "success", "response_time", "message", "test_time"}),
+	}).Create(result).Error
+}
+
+func GetChannelTestResults(channelId int) ([]*ChannelTestResult, error) {
+	var results []*ChannelTestResult
+	err := DB.Where("channel_id = ?", channelId).Order("model").Find(&results).Error
+	return results, err
+}
+
+// GetChannelTestResultsByChannelIds 返回多个渠道的测试结果，channelIds 为空时返回全部
+func GetChannelTestResultsByChannelIds(channelIds []int) ([]*ChannelTestResult, error) {
+	var results []*ChannelTestResult
+	tx := DB.Model(&ChannelTestResult{})
+	if len(channelIds) > 0 {
+		tx = tx.Where("channel_id IN ?", channelIds)
+	}
+	err := tx.Find(&results).Error
+	return results, err
+}
+
+func DeleteChannelTestResults(channelIds ...int) error {
+	if len(channelIds) == 0 {
+		return nil
+	}
+	return DB.Where("channel_id IN ?", channelIds).Delete(&ChannelTestResult{}).Error
+}
+
+// deleteOrphanChannelTestResults 清理已删除渠道的测试结果
+func deleteOrphanChannelTestResults() {
+	err := DB.Where("channel_id NOT IN (?)", DB.Model(&Channel{}).Select("id")).Delete(&ChannelTestResult{}).Error
+	if err != nil {
+		common.SysError("failed to delete channel test results: " + err.Error())
+	}
+}
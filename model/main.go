@@ -196,7 +196,13 @@ func InitDB() (err error) {
 		}
 		common.SysLog("database migration started")
 		err = migrateDB()
-		return err
+		if err != nil {
+			return err
+		}
+		if err = migrateMidjourneyTasks(); err != nil {
+			return err
+		}
+		return migrateLegacyAccessTokens()
 	} else {
 		common.FatalLog(err)
 	}
@@ -246,13 +252,32 @@ func migrateDB() error {
 		&Redemption{},
 		&Ability{},
 		&Log{},
-		&Midjourney{},
 		&TopUp{},
 		&QuotaData{},
 		&Task{},
 		&Setup{},
 		&UsageStatistics{},
 		&TokenUsageLog{},
+		&Refund{},
+		&Role{},
+		&Organization{},
+		&OrganizationMember{},
+		&OrganizationInvitation{},
+		&AuditLog{},
+		&TwoFA{},
+		&TwoFARecoveryCode{},
+		&PasskeyCredential{},
+		&ManagementToken{},
+		&ScimUser{},
+		&ScimGroup{},
+		&ScimGroupMember{},
+		&TaskWebhookDelivery{},
+		&TaskAsset{},
+		&ChannelTestResult{},
+		&AbilityHealth{},
+		&MaintenanceWindow{},
+		&ModelSyncReport{},
+		&ChannelBalanceHistory{},
 	)
 	if err != nil {
 		return err
@@ -274,13 +299,32 @@ func migrateDBFast() error {
 		{&Redemption{}, "Redemption"},
 		{&Ability{}, "Ability"},
 		{&Log{}, "Log"},
-		{&Midjourney{}, "Midjourney"},
 		{&TopUp{}, "TopUp"},
 		{&QuotaData{}, "QuotaData"},
 		{&Task{}, "Task"},
 		{&Setup{}, "Setup"},
 		{&UsageStatistics{}, "UsageStatistics"},
 		{&TokenUsageLog{}, "TokenUsageLog"},
+		{&Refund{}, "Refund"},
+		{&Role{}, "Role"},
+		{&Organization{}, "Organization"},
+		{&OrganizationMember{}, "OrganizationMember"},
+		{&OrganizationInvitation{}, "OrganizationInvitation"},
+		{&AuditLog{}, "AuditLog"},
+		{&TwoFA{}, "TwoFA"},
+		{&TwoFARecoveryCode{}, "TwoFARecoveryCode"},
+		{&PasskeyCredential{}, "PasskeyCredential"},
+		{&ManagementToken{}, "ManagementToken"},
+		{&ScimUser{}, "ScimUser"},
+		{&ScimGroup{}, "ScimGroup"},
+		{&ScimGroupMember{}, "ScimGroupMember"},
+		{&TaskWebhookDelivery{}, "TaskWebhookDelivery"},
+		{&TaskAsset{}, "TaskAsset"},
+		{&ChannelTestResult{}, "ChannelTestResult"},
+		{&AbilityHealth{}, "AbilityHealth"},
+		{&MaintenanceWindow{}, "MaintenanceWindow"},
+		{&ModelSyncReport{}, "ModelSyncReport"},
+		{&ChannelBalanceHistory{}, "ChannelBalanceHistory"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
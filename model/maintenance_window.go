@@ -0,0 +1,111 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"strconv"
+	"strings"
+)
+
+// MaintenanceWindow 渠道维护窗口，开始时将渠道切换为排空状态，结束时恢复启用。
+// 一次性窗口使用开始与结束时间，周期窗口使用 cron 表达式与持续时长
+type MaintenanceWindow struct {
+	Id              int    `json:"id"`
+	Name            string `json:"name" gorm:"type:varchar(64)"`
+	ChannelIds      string `json:"channel_ids" gorm:"type:text"` // 以逗号分隔
+	Tag             string `json:"tag" gorm:"type:varchar(255);default:''"`
+	StartTime       int64  `json:"start_time" gorm:"bigint"`
+	EndTime         int64  `json:"end_time" gorm:"bigint"`
+	Cron            string `json:"cron" gorm:"type:varchar(128);default:''"`
+	DurationMinutes int    `json:"duration_minutes"`
+	Enabled         bool   `json:"enabled"`
+	// 当前是否处于维护中，以及本次维护排空的渠道，结束时只恢复这些渠道
+	Active          bool   `json:"active"`
+	ActiveStartTime int64  `json:"active_start_time" gorm:"bigint"`
+	ActiveEndTime   int64  `json:"active_end_time" gorm:"bigint"`
+	DrainedChannels string `json:"drained_channels" gorm:"type:text"` // 以逗号分隔
+	Remark          string `json:"remark" gorm:"type:varchar(255);default:''"`
+	CreatedTime     int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime     int64  `json:"updated_time" gorm:"bigint"`
+}
+
+func (window *MaintenanceWindow) GetChannelIds() []int {
+	return parseChannelIdList(window.ChannelIds)
+}
+
+func (window *MaintenanceWindow) SetChannelIds(ids []int) {
+	window.ChannelIds = formatChannelIdList(ids)
+}
+
+func (window *MaintenanceWindow) GetDrainedChannels() []int {
+	return parseChannelIdList(window.DrainedChannels)
+}
+
+func (window *MaintenanceWindow) SetDrainedChannels(ids []int) {
+	window.DrainedChannels = formatChannelIdList(ids)
+}
+
+func parseChannelIdList(s string) []int {
+	ids := make([]int, 0)
+	for _, part := range strings.Split(s, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err == nil && id > 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func formatChannelIdList(ids []int) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.Itoa(id))
+	}
+	return strings.Join(parts, ",")
+}
+
+func GetAllMaintenanceWindows() ([]*MaintenanceWindow, error) {
+	var windows []*MaintenanceWindow
+	err := DB.Order("id desc").Find(&windows).Error
+	return windows, err
+}
+
+// GetPendingMaintenanceWindows 返回启用或仍处于维护中的窗口
+func GetPendingMaintenanceWindows() ([]*MaintenanceWindow, error) {
+	var windows []*MaintenanceWindow
+	err := DB.Where("enabled = ? OR active = ?", true, true).Order("id").Find(&windows).Error
+	return windows, err
+}
+
+func GetMaintenanceWindowById(id int) (*MaintenanceWindow, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	var window MaintenanceWindow
+	err := DB.First(&window, "id = ?", id).Error
+	return &window, err
+}
+
+func (window *MaintenanceWindow) Insert() error {
+	window.CreatedTime = common.GetTimestamp()
+	window.UpdatedTime = window.CreatedTime
+	return DB.Create(window).Error
+}
+
+// Update 保存窗口配置，不修改维护状态
+func (window *MaintenanceWindow) Update() error {
+	window.UpdatedTime = common.GetTimestamp()
+	return DB.Model(window).Select("name", "channel_ids", "tag", "start_time", "end_time", "cron", "duration_minutes", "enabled", "remark", "updated_time").Updates(window).Error
+}
+
+// UpdateState 保存维护状态
+func (window *MaintenanceWindow) UpdateState() error {
+	return DB.Model(window).Select("active", "active_start_time", "active_end_time", "drained_channels").Updates(window).Error
+}
+
+func DeleteMaintenanceWindowById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	return DB.Delete(&MaintenanceWindow{}, "id = ?", id).Error
+}
@@ -0,0 +1,239 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"one-api/common"
+	"strings"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"gorm.io/gorm"
+)
+
+const (
+	ManagementTokenStatusActive  = 1
+	ManagementTokenStatusRevoked = 2
+)
+
+const managementTokenKeyPrefix = "mgt-"
+
+// 最近使用时间的更新间隔（秒），避免每次请求都写库
+const managementTokenTouchInterval = 60
+
+// ManagementToken 用于调用管理接口的令牌，替代 User.AccessToken，只保存哈希
+type ManagementToken struct {
+	Id           int    `json:"id"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	Name         string `json:"name" gorm:"type:varchar(64);default:''"`
+	TokenHash    string `json:"-" gorm:"type:varchar(64);uniqueIndex"`
+	Prefix       string `json:"prefix" gorm:"type:varchar(16);default:''"` // 用于辨认令牌
+	Scopes       string `json:"scopes" gorm:"type:text"`                   // 以逗号分隔
+	AllowIps     string `json:"allow_ips" gorm:"type:text"`                // IP 或 CIDR，以逗号或换行分隔，为空时不限制
+	ExpiredTime  int64  `json:"expired_time" gorm:"bigint;default:0"`      // 0 表示永不过期
+	Status       int    `json:"status" gorm:"default:1"`
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+	LastUsedTime int64  `json:"last_used_time" gorm:"bigint;default:0"`
+	LastUsedIp   string `json:"last_used_ip" gorm:"type:varchar(64);default:''"`
+}
+
+func hashManagementToken(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func getManagementTokenPrefix(key string) string {
+	if len(key) > 8 {
+		return key[:8]
+	}
+	return key
+}
+
+// GenerateManagementTokenKey 生成新的令牌明文，仅在创建时返回给用户
+func GenerateManagementTokenKey() (string, error) {
+	key, err := common.GenerateRandomCharsKey(40)
+	if err != nil {
+		return "", err
+	}
+	return managementTokenKeyPrefix + key, nil
+}
+
+func (token *ManagementToken) GetScopes() []string {
+	scopes := make([]string, 0)
+	for _, scope := range strings.Split(token.Scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// SetScopes 校验并保存权限范围，管理权限不能超出用户自身拥有的权限
+func (token *ManagementToken) SetScopes(scopes []string, userPermissions []string) error {
+	cleaned := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if !common.IsValidScope(scope) {
+			return fmt.Errorf("未知的权限范围：%s", scope)
+		}
+		if common.IsValidPermission(scope) && !common.StringsContains(userPermissions, scope) {
+			return fmt.Errorf("无法授予自己没有的权限：%s", scope)
+		}
+		if !common.StringsContains(cleaned, scope) {
+			cleaned = append(cleaned, scope)
+		}
+	}
+	if len(cleaned) == 0 {
+		return errors.New("至少需要一个权限范围")
+	}
+	token.Scopes = strings.Join(cleaned, ",")
+	return nil
+}
+
+func (token *ManagementToken) HasScope(scope string) bool {
+	scopes := token.GetScopes()
+	return common.StringsContains(scopes, common.ScopeAll) || common.StringsContains(scopes, scope)
+}
+
+// ValidateAllowIps 校验 IP 白名单格式并规范化
+func ValidateAllowIps(allowIps string) (string, error) {
+	if _, err := common.ValidateIpRules(allowIps); err != nil {
+		return "", err
+	}
+	return strings.Join(common.SplitIpRules(allowIps), ","), nil
+}
+
+func (token *ManagementToken) IsIpAllowed(ip string) bool {
+	if len(common.SplitIpRules(token.AllowIps)) == 0 {
+		return true
+	}
+	return common.IpMatchRules(net.ParseIP(ip), common.ParseIpRules(token.AllowIps))
+}
+
+func GetUserManagementTokens(userId int) ([]*ManagementToken, error) {
+	var tokens []*ManagementToken
+	err := DB.Where("user_id = ?", userId).Order("id desc").Find(&tokens).Error
+	return tokens, err
+}
+
+func GetUserManagementTokenById(id int, userId int) (*ManagementToken, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	var token ManagementToken
+	err := DB.Where("id = ? AND user_id = ?", id, userId).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Insert 保存令牌哈希，key 为令牌明文
+func (token *ManagementToken) Insert(key string) error {
+	token.TokenHash = hashManagementToken(key)
+	token.Prefix = getManagementTokenPrefix(key)
+	token.Status = ManagementTokenStatusActive
+	token.CreatedTime = common.GetTimestamp()
+	return DB.Create(token).Error
+}
+
+func (token *ManagementToken) Update() error {
+	return DB.Model(token).Select("name", "scopes", "allow_ips", "expired_time").Updates(token).Error
+}
+
+func (token *ManagementToken) Revoke() error {
+	token.Status = ManagementTokenStatusRevoked
+	return DB.Model(token).Update("status", token.Status).Error
+}
+
+func DeleteUserManagementToken(id int, userId int) error {
+	result := DB.Where("id = ? AND user_id = ?", id, userId).Delete(&ManagementToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("令牌不存在")
+	}
+	return nil
+}
+
+// RevokeUserManagementTokensByName 吊销用户指定名称的令牌，用于重新生成默认令牌
+func RevokeUserManagementTokensByName(userId int, name string) error {
+	return DB.Model(&ManagementToken{}).Where("user_id = ? AND name = ?", userId, name).
+		Update("status", ManagementTokenStatusRevoked).Error
+}
+
+// ValidateManagementToken 校验 Authorization 中的管理令牌，返回令牌与所属用户
+func ValidateManagementToken(key string, ip string) (*ManagementToken, *User, error) {
+	key = strings.TrimSpace(strings.TrimPrefix(key, "Bearer "))
+	if key == "" {
+		return nil, nil, errors.New("access token 无效")
+	}
+	var token ManagementToken
+	if err := DB.Where("token_hash = ?", hashManagementToken(key)).First(&token).Error; err != nil {
+		return nil, nil, errors.New("access token 无效")
+	}
+	if token.Status != ManagementTokenStatusActive {
+		return nil, nil, errors.New("access token 已被吊销")
+	}
+	now := common.GetTimestamp()
+	if token.ExpiredTime != 0 && token.ExpiredTime < now {
+		return nil, nil, errors.New("access token 已过期")
+	}
+	if !token.IsIpAllowed(ip) {
+		return nil, nil, errors.New("当前 IP 不在 access token 的白名单中")
+	}
+	user, err := GetUserById(token.UserId, false)
+	if err != nil {
+		return nil, nil, errors.New("access token 无效")
+	}
+	if now-token.LastUsedTime >= managementTokenTouchInterval || token.LastUsedIp != ip {
+		tokenId := token.Id
+		gopool.Go(func() {
+			err := DB.Model(&ManagementToken{}).Where("id = ?", tokenId).Updates(map[string]interface{}{
+				"last_used_time": now,
+				"last_used_ip":   ip,
+			}).Error
+			if err != nil {
+				common.SysError("failed to update management token last used: " + err.Error())
+			}
+		})
+	}
+	return &token, user, nil
+}
+
+// migrateLegacyAccessTokens 将旧的 User.AccessToken 转换为拥有全部权限的管理令牌，并清除明文
+func migrateLegacyAccessTokens() error {
+	var users []User
+	err := DB.Select("id", "access_token").Where("access_token IS NOT NULL AND access_token <> ''").Find(&users).Error
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		key := user.GetAccessToken()
+		err = DB.Transaction(func(tx *gorm.DB) error {
+			token := &ManagementToken{
+				UserId:      user.Id,
+				Name:        "legacy",
+				TokenHash:   hashManagementToken(key),
+				Prefix:      getManagementTokenPrefix(key),
+				Scopes:      common.ScopeAll,
+				Status:      ManagementTokenStatusActive,
+				CreatedTime: common.GetTimestamp(),
+			}
+			if err := tx.Create(token).Error; err != nil {
+				return err
+			}
+			return tx.Model(&User{}).Where("id = ?", user.Id).Update("access_token", nil).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(users) > 0 {
+		common.SysLog(fmt.Sprintf("migrated %d legacy access tokens to management tokens", len(users)))
+	}
+	return nil
+}
@@ -1,28 +1,64 @@
 package model
 
+import (
+	"encoding/json"
+	"one-api/common"
+	"one-api/constant"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// Midjourney 是 Midjourney 任务的视图，数据保存在 tasks 表中（platform 为 mj），
+// 时间字段沿用 Midjourney 接口的毫秒时间戳
 type Midjourney struct {
 	Id          int    `json:"id"`
 	Code        int    `json:"code"`
-	UserId      int    `json:"user_id" gorm:"index"`
-	Action      string `json:"action" gorm:"type:varchar(40);index"`
-	MjId        string `json:"mj_id" gorm:"index"`
+	UserId      int    `json:"user_id"`
+	Action      string `json:"action"`
+	MjId        string `json:"mj_id"`
 	Prompt      string `json:"prompt"`
 	PromptEn    string `json:"prompt_en"`
 	Description string `json:"description"`
 	State       string `json:"state"`
-	SubmitTime  int64  `json:"submit_time" gorm:"index"`
-	StartTime   int64  `json:"start_time" gorm:"index"`
-	FinishTime  int64  `json:"finish_time" gorm:"index"`
+	SubmitTime  int64  `json:"submit_time"`
+	StartTime   int64  `json:"start_time"`
+	FinishTime  int64  `json:"finish_time"`
 	ImageUrl    string `json:"image_url"`
 	VideoUrl    string `json:"video_url"`
 	VideoUrls   string `json:"video_urls"`
-	Status      string `json:"status" gorm:"type:varchar(20);index"`
-	Progress    string `json:"progress" gorm:"type:varchar(30);index"`
+	Status      string `json:"status"`
+	Progress    string `json:"progress"`
 	FailReason  string `json:"fail_reason"`
 	ChannelId   int    `json:"channel_id"`
 	Quota       int    `json:"quota"`
 	Buttons     string `json:"buttons"`
 	Properties  string `json:"properties"`
+	// 通过组织令牌提交的任务，失败补偿退回组织额度池
+	OrganizationId int `json:"organization_id"`
+	// 任务状态变化时推送的回调地址
+	CallbackUrl string `json:"callback_url"`
+	// 上游 notifyHook 地址中的凭证
+	NotifyToken string `json:"-"`
+	// 提交任务使用的令牌，退款时同步退还令牌额度
+	TokenId int `json:"-"`
+}
+
+// midjourneyTaskData 保存在 tasks.data 中的 Midjourney 特有字段
+type midjourneyTaskData struct {
+	Code        int    `json:"code"`
+	PromptEn    string `json:"prompt_en,omitempty"`
+	Description string `json:"description,omitempty"`
+	State       string `json:"state,omitempty"`
+	ImageUrl    string `json:"image_url,omitempty"`
+	VideoUrl    string `json:"video_url,omitempty"`
+	VideoUrls   string `json:"video_urls,omitempty"`
+	Buttons     string `json:"buttons,omitempty"`
+	Properties  string `json:"properties,omitempty"`
+	// tasks 表中的时间为秒，这里保留毫秒精度
+	SubmitTime int64 `json:"submit_time"`
+	StartTime  int64 `json:"start_time"`
+	FinishTime int64 `json:"finish_time"`
 }
 
 // TaskQueryParams 用于包含所有搜索条件的结构体，可以根据需求添加更多字段
@@ -33,138 +69,209 @@ type TaskQueryParams struct {
 	EndTimestamp   string
 }
 
-func GetAllUserTask(userId int, startIdx int, num int, queryParams TaskQueryParams) []*Midjourney {
-	var tasks []*Midjourney
-	var err error
+func (midjourney *Midjourney) toTask() *Task {
+	task := &Task{
+		ID:         int64(midjourney.Id),
+		TaskID:     midjourney.MjId,
+		Platform:   constant.TaskPlatformMidjourney,
+		UserId:     midjourney.UserId,
+		ChannelId:  midjourney.ChannelId,
+		Quota:      midjourney.Quota,
+		Action:     midjourney.Action,
+		Status:     TaskStatus(midjourney.Status),
+		FailReason: midjourney.FailReason,
+		SubmitTime: midjourney.SubmitTime / 1000,
+		StartTime:  midjourney.StartTime / 1000,
+		FinishTime: midjourney.FinishTime / 1000,
+		Progress:   midjourney.Progress,
+		Properties: Properties{Input: midjourney.Prompt},
 
-	// 初始化查询构建器
-	query := DB.Where("user_id = ?", userId)
+		OrganizationId: midjourney.OrganizationId,
+		CallbackUrl:    midjourney.CallbackUrl,
+		NotifyToken:    midjourney.NotifyToken,
+		TokenId:        midjourney.TokenId,
+	}
+	task.SetData(midjourneyTaskData{
+		Code:        midjourney.Code,
+		PromptEn:    midjourney.PromptEn,
+		Description: midjourney.Description,
+		State:       midjourney.State,
+		ImageUrl:    midjourney.ImageUrl,
+		VideoUrl:    midjourney.VideoUrl,
+		VideoUrls:   midjourney.VideoUrls,
+		Buttons:     midjourney.Buttons,
+		Properties:  midjourney.Properties,
+		SubmitTime:  midjourney.SubmitTime,
+		StartTime:   midjourney.StartTime,
+		FinishTime:  midjourney.FinishTime,
+	})
+	return task
+}
 
-	if queryParams.MjID != "" {
-		query = query.Where("mj_id = ?", queryParams.MjID)
+// MidjourneyFromTask 将 tasks 表中的 Midjourney 任务转换为 Midjourney 视图
+func MidjourneyFromTask(task *Task) *Midjourney {
+	var data midjourneyTaskData
+	if len(task.Data) > 0 {
+		_ = json.Unmarshal(task.Data, &data)
 	}
-	if queryParams.StartTimestamp != "" {
-		// 假设您已将前端传来的时间戳转换为数据库所需的时间格式，并处理了时间戳的验证和解析
-		query = query.Where("submit_time >= ?", queryParams.StartTimestamp)
+	midjourney := &Midjourney{
+		Id:          int(task.ID),
+		Code:        data.Code,
+		UserId:      task.UserId,
+		Action:      task.Action,
+		MjId:        task.TaskID,
+		Prompt:      task.Properties.Input,
+		PromptEn:    data.PromptEn,
+		Description: data.Description,
+		State:       data.State,
+		SubmitTime:  data.SubmitTime,
+		StartTime:   data.StartTime,
+		FinishTime:  data.FinishTime,
+		ImageUrl:    data.ImageUrl,
+		VideoUrl:    data.VideoUrl,
+		VideoUrls:   data.VideoUrls,
+		Status:      string(task.Status),
+		Progress:    task.Progress,
+		FailReason:  task.FailReason,
+		ChannelId:   task.ChannelId,
+		Quota:       task.Quota,
+		Buttons:     data.Buttons,
+		Properties:  data.Properties,
+
+		OrganizationId: task.OrganizationId,
+		CallbackUrl:    task.CallbackUrl,
+		NotifyToken:    task.NotifyToken,
+		TokenId:        task.TokenId,
 	}
-	if queryParams.EndTimestamp != "" {
-		query = query.Where("submit_time <= ?", queryParams.EndTimestamp)
+	if midjourney.SubmitTime == 0 {
+		midjourney.SubmitTime = task.SubmitTime * 1000
 	}
-
-	// 获取数据
-	err = query.Order("id desc").Limit(num).Offset(startIdx).Find(&tasks).Error
-	if err != nil {
-		return nil
+	if midjourney.StartTime == 0 {
+		midjourney.StartTime = task.StartTime * 1000
 	}
-
-	return tasks
+	if midjourney.FinishTime == 0 {
+		midjourney.FinishTime = task.FinishTime * 1000
+	}
+	return midjourney
 }
 
-func GetAllTasks(startIdx int, num int, queryParams TaskQueryParams) []*Midjourney {
-	var tasks []*Midjourney
-	var err error
+func midjourneysFromTasks(tasks []*Task) []*Midjourney {
+	midjourneys := make([]*Midjourney, 0, len(tasks))
+	for _, task := range tasks {
+		midjourneys = append(midjourneys, MidjourneyFromTask(task))
+	}
+	return midjourneys
+}
 
-	// 初始化查询构建器
-	query := DB
+func midjourneyQuery() *gorm.DB {
+	return DB.Model(&Task{}).Where("platform = ?", constant.TaskPlatformMidjourney)
+}
 
-	// 添加过滤条件
-	if queryParams.ChannelID != "" {
-		query = query.Where("channel_id = ?", queryParams.ChannelID)
-	}
+// applyTaskQueryParams 前端传入的是毫秒时间戳，tasks 表中保存的是秒
+func applyTaskQueryParams(query *gorm.DB, queryParams TaskQueryParams) *gorm.DB {
 	if queryParams.MjID != "" {
-		query = query.Where("mj_id = ?", queryParams.MjID)
+		query = query.Where("task_id = ?", queryParams.MjID)
 	}
 	if queryParams.StartTimestamp != "" {
-		query = query.Where("submit_time >= ?", queryParams.StartTimestamp)
+		if ts, err := strconv.ParseInt(queryParams.StartTimestamp, 10, 64); err == nil {
+			query = query.Where("submit_time >= ?", ts/1000)
+		}
 	}
 	if queryParams.EndTimestamp != "" {
-		query = query.Where("submit_time <= ?", queryParams.EndTimestamp)
+		if ts, err := strconv.ParseInt(queryParams.EndTimestamp, 10, 64); err == nil {
+			query = query.Where("submit_time <= ?", ts/1000)
+		}
 	}
+	return query
+}
 
-	// 获取数据
-	err = query.Order("id desc").Limit(num).Offset(startIdx).Find(&tasks).Error
+func GetAllUserTask(userId int, startIdx int, num int, queryParams TaskQueryParams) []*Midjourney {
+	var tasks []*Task
+	query := applyTaskQueryParams(midjourneyQuery().Where("user_id = ?", userId), queryParams)
+	err := query.Order("id desc").Limit(num).Offset(startIdx).Find(&tasks).Error
 	if err != nil {
 		return nil
 	}
-
-	return tasks
+	return midjourneysFromTasks(tasks)
 }
 
-func GetAllUnFinishTasks() []*Midjourney {
-	var tasks []*Midjourney
-	var err error
-	// get all tasks progress is not 100%
-	err = DB.Where("progress != ?", "100%").Find(&tasks).Error
+func GetAllTasks(startIdx int, num int, queryParams TaskQueryParams) []*Midjourney {
+	var tasks []*Task
+	query := midjourneyQuery()
+	if queryParams.ChannelID != "" {
+		query = query.Where("channel_id = ?", queryParams.ChannelID)
+	}
+	query = applyTaskQueryParams(query, queryParams)
+	err := query.Order("id desc").Limit(num).Offset(startIdx).Find(&tasks).Error
 	if err != nil {
 		return nil
 	}
-	return tasks
+	return midjourneysFromTasks(tasks)
 }
 
 func GetByOnlyMJId(mjId string) *Midjourney {
-	var mj *Midjourney
-	var err error
-	err = DB.Where("mj_id = ?", mjId).First(&mj).Error
+	var task Task
+	err := midjourneyQuery().Where("task_id = ?", mjId).First(&task).Error
 	if err != nil {
 		return nil
 	}
-	return mj
+	return MidjourneyFromTask(&task)
 }
 
 func GetByMJId(userId int, mjId string) *Midjourney {
-	var mj *Midjourney
-	var err error
-	err = DB.Where("user_id = ? and mj_id = ?", userId, mjId).First(&mj).Error
+	var task Task
+	err := midjourneyQuery().Where("user_id = ? and task_id = ?", userId, mjId).First(&task).Error
 	if err != nil {
 		return nil
 	}
-	return mj
+	return MidjourneyFromTask(&task)
 }
 
 func GetByMJIds(userId int, mjIds []string) []*Midjourney {
-	var mj []*Midjourney
-	var err error
-	err = DB.Where("user_id = ? and mj_id in (?)", userId, mjIds).Find(&mj).Error
+	var tasks []*Task
+	err := midjourneyQuery().Where("user_id = ? and task_id in (?)", userId, mjIds).Find(&tasks).Error
 	if err != nil {
 		return nil
 	}
-	return mj
+	return midjourneysFromTasks(tasks)
 }
 
 func GetMjByuId(id int) *Midjourney {
-	var mj *Midjourney
-	var err error
-	err = DB.Where("id = ?", id).First(&mj).Error
+	var task Task
+	err := midjourneyQuery().Where("id = ?", id).First(&task).Error
 	if err != nil {
 		return nil
 	}
-	return mj
+	return MidjourneyFromTask(&task)
 }
 
 func UpdateProgress(id int, progress string) error {
-	return DB.Model(&Midjourney{}).Where("id = ?", id).Update("progress", progress).Error
+	return midjourneyQuery().Where("id = ?", id).Update("progress", progress).Error
 }
 
 func (midjourney *Midjourney) Insert() error {
-	var err error
-	err = DB.Create(midjourney).Error
-	return err
+	task := midjourney.toTask()
+	err := DB.Create(task).Error
+	if err != nil {
+		return err
+	}
+	midjourney.Id = int(task.ID)
+	return nil
 }
 
 func (midjourney *Midjourney) Update() error {
-	var err error
-	err = DB.Save(midjourney).Error
-	return err
+	// 视图不携带创建时间、轮询进度与已退额度，更新时保留原值
+	return DB.Omit("created_at", "next_poll_time", "poll_count", "refunded_quota").Save(midjourney.toTask()).Error
 }
 
 func MjBulkUpdate(mjIds []string, params map[string]any) error {
-	return DB.Model(&Midjourney{}).
-		Where("mj_id in (?)", mjIds).
+	return midjourneyQuery().
+		Where("task_id in (?)", mjIds).
 		Updates(params).Error
 }
 
 func MjBulkUpdateByTaskIds(taskIDs []int, params map[string]any) error {
-	return DB.Model(&Midjourney{}).
+	return midjourneyQuery().
 		Where("id in (?)", taskIDs).
 		Updates(params).Error
 }
@@ -172,36 +279,48 @@ func MjBulkUpdateByTaskIds(taskIDs []int, params map[string]any) error {
 // CountAllTasks returns total midjourney tasks for admin query
 func CountAllTasks(queryParams TaskQueryParams) int64 {
 	var total int64
-	query := DB.Model(&Midjourney{})
+	query := midjourneyQuery()
 	if queryParams.ChannelID != "" {
 		query = query.Where("channel_id = ?", queryParams.ChannelID)
 	}
-	if queryParams.MjID != "" {
-		query = query.Where("mj_id = ?", queryParams.MjID)
-	}
-	if queryParams.StartTimestamp != "" {
-		query = query.Where("submit_time >= ?", queryParams.StartTimestamp)
-	}
-	if queryParams.EndTimestamp != "" {
-		query = query.Where("submit_time <= ?", queryParams.EndTimestamp)
-	}
-	_ = query.Count(&total).Error
+	_ = applyTaskQueryParams(query, queryParams).Count(&total).Error
 	return total
 }
 
 // CountAllUserTask returns total midjourney tasks for user
 func CountAllUserTask(userId int, queryParams TaskQueryParams) int64 {
 	var total int64
-	query := DB.Model(&Midjourney{}).Where("user_id = ?", userId)
-	if queryParams.MjID != "" {
-		query = query.Where("mj_id = ?", queryParams.MjID)
-	}
-	if queryParams.StartTimestamp != "" {
-		query = query.Where("submit_time >= ?", queryParams.StartTimestamp)
+	query := midjourneyQuery().Where("user_id = ?", userId)
+	_ = applyTaskQueryParams(query, queryParams).Count(&total).Error
+	return total
+}
+
+// migrateMidjourneyTasks 将旧版 midjourneys 表中的任务迁移到 tasks 表，完成后旧表重命名为 midjourneys_migrated 作为备份
+func migrateMidjourneyTasks() error {
+	if !DB.Migrator().HasTable("midjourneys") {
+		return nil
 	}
-	if queryParams.EndTimestamp != "" {
-		query = query.Where("submit_time <= ?", queryParams.EndTimestamp)
+	common.SysLog("migrating midjourney tasks to tasks table")
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var legacy []*Midjourney
+		err := tx.Table("midjourneys").FindInBatches(&legacy, 500, func(batchTx *gorm.DB, batch int) error {
+			tasks := make([]*Task, 0, len(legacy))
+			for _, midjourney := range legacy {
+				task := midjourney.toTask()
+				// 旧表与 tasks 表的自增 id 互不相关，由数据库重新分配
+				task.ID = 0
+				tasks = append(tasks, task)
+			}
+			return tx.Create(&tasks).Error
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Migrator().RenameTable("midjourneys", "midjourneys_migrated")
+	})
+	if err != nil {
+		return err
 	}
-	_ = query.Count(&total).Error
-	return total
+	common.SysLog("midjourney tasks migrated")
+	return nil
 }
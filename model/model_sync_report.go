@@ -0,0 +1,46 @@
+package model
+
+import (
+	"one-api/common"
+)
+
+// ModelSyncReport 一次上游模型同步的变更报告，模型列表以逗号分隔
+type ModelSyncReport struct {
+	Id          int    `json:"id"`
+	ChannelId   int    `json:"channel_id" gorm:"index"`
+	ChannelName string `json:"channel_name" gorm:"type:varchar(255)"`
+	Applied     bool   `json:"applied"`                   // 是否已写入渠道，手动预览时为 false
+	Added       string `json:"added" gorm:"type:text"`    // 自动添加的模型
+	Removed     string `json:"removed" gorm:"type:text"`  // 自动移除的模型
+	New         string `json:"new" gorm:"type:text"`      // 上游新增但未自动添加的模型
+	Unpriced    string `json:"unpriced" gorm:"type:text"` // 上游新增但未配置价格的模型
+	Missing     string `json:"missing" gorm:"type:text"`  // 上游已不存在但未移除的模型
+	Error       string `json:"error" gorm:"type:text"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint;index"`
+}
+
+func (report *ModelSyncReport) HasChanges() bool {
+	return report.Added != "" || report.Removed != "" || report.New != "" || report.Unpriced != "" || report.Missing != ""
+}
+
+func (report *ModelSyncReport) Insert() error {
+	report.CreatedTime = common.GetTimestamp()
+	return DB.Create(report).Error
+}
+
+func GetModelSyncReports(channelId int, startIdx int, num int) (reports []*ModelSyncReport, total int64, err error) {
+	tx := DB.Model(&ModelSyncReport{})
+	if channelId != 0 {
+		tx = tx.Where("channel_id = ?", channelId)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&reports).Error
+	return reports, total, err
+}
+
+func DeleteModelSyncReportsBefore(timestamp int64) (int64, error) {
+	result := DB.Where("created_time < ?", timestamp).Delete(&ModelSyncReport{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,495 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OrganizationRoleOwner  = "owner"
+	OrganizationRoleAdmin  = "admin"
+	OrganizationRoleMember = "member"
+)
+
+const (
+	OrganizationInvitationStatusPending  = "pending"
+	OrganizationInvitationStatusAccepted = "accepted"
+	OrganizationInvitationStatusRevoked  = "revoked"
+)
+
+var ErrOrganizationNotMember = errors.New("不是该组织的成员")
+var ErrOrganizationQuotaInsufficient = errors.New("组织额度不足")
+
+// Organization 组织拥有共享额度池，成员绑定到组织的令牌从额度池扣费
+type Organization struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(64);index"`
+	OwnerId     int    `json:"owner_id" gorm:"index"`
+	Quota       int    `json:"quota" gorm:"type:int;default:0"`
+	UsedQuota   int    `json:"used_quota" gorm:"type:int;default:0"`
+	Status      int    `json:"status" gorm:"type:int;default:1"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+// OrganizationMember 组织成员，SpendLimit 为 0 表示不限制该成员的用量
+type OrganizationMember struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"uniqueIndex:idx_org_member"`
+	UserId         int    `json:"user_id" gorm:"uniqueIndex:idx_org_member;index"`
+	Username       string `json:"username" gorm:"-:all"`
+	Role           string `json:"role" gorm:"type:varchar(16)"`
+	SpendLimit     int    `json:"spend_limit" gorm:"type:int;default:0"`
+	UsedQuota      int    `json:"used_quota" gorm:"type:int;default:0"`
+	// 成员累计转入组织额度池的额度，删除组织时按此比例退回
+	DepositedQuota int   `json:"deposited_quota" gorm:"type:int;default:0"`
+	CreatedTime    int64 `json:"created_time" gorm:"bigint"`
+}
+
+type OrganizationInvitation struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"index"`
+	Email          string `json:"email" gorm:"type:varchar(64);index"`
+	Role           string `json:"role" gorm:"type:varchar(16)"`
+	Code           string `json:"-" gorm:"type:varchar(32);uniqueIndex"`
+	InviterId      int    `json:"inviter_id"`
+	Status         string `json:"status" gorm:"type:varchar(16);index"`
+	ExpiredTime    int64  `json:"expired_time" gorm:"bigint"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+}
+
+// OrganizationWithRole 用户所在组织及其角色
+type OrganizationWithRole struct {
+	Organization
+	Role       string `json:"role"`
+	SpendLimit int    `json:"spend_limit"`
+	MemberUsed int    `json:"member_used_quota"`
+}
+
+func IsValidOrganizationRole(role string) bool {
+	return role == OrganizationRoleOwner || role == OrganizationRoleAdmin || role == OrganizationRoleMember
+}
+
+// IsOrganizationManager 所有者与管理员可以管理成员与邀请
+func (member *OrganizationMember) IsOrganizationManager() bool {
+	return member.Role == OrganizationRoleOwner || member.Role == OrganizationRoleAdmin
+}
+
+// CreateOrganization 创建组织，创建者成为所有者
+func CreateOrganization(name string, ownerId int) (*Organization, error) {
+	org := &Organization{
+		Name:        name,
+		OwnerId:     ownerId,
+		Status:      common.UserStatusEnabled,
+		CreatedTime: common.GetTimestamp(),
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrganizationId: org.Id,
+			UserId:         ownerId,
+			Role:           OrganizationRoleOwner,
+			CreatedTime:    org.CreatedTime,
+		}).Error
+	})
+	return org, err
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	var org Organization
+	err := DB.First(&org, "id = ?", id).Error
+	return &org, err
+}
+
+func GetAllOrganizations(keyword string, startIdx int, num int) (orgs []*Organization, total int64, err error) {
+	tx := DB.Model(&Organization{})
+	if keyword != "" {
+		tx = tx.Where("name LIKE ?", "%"+keyword+"%")
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&orgs).Error
+	return orgs, total, err
+}
+
+func GetUserOrganizations(userId int) ([]*OrganizationWithRole, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("user_id = ?", userId).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	result := make([]*OrganizationWithRole, 0, len(members))
+	for _, member := range members {
+		org, err := GetOrganizationById(member.OrganizationId)
+		if err != nil {
+			continue
+		}
+		result = append(result, &OrganizationWithRole{
+			Organization: *org,
+			Role:         member.Role,
+			SpendLimit:   member.SpendLimit,
+			MemberUsed:   member.UsedQuota,
+		})
+	}
+	return result, nil
+}
+
+func (org *Organization) UpdateName(name string) error {
+	org.Name = name
+	return DB.Model(org).Update("name", name).Error
+}
+
+// DeleteOrganization 删除组织，剩余额度按成员转入额度的比例退回（每人不超过其转入额度），
+// 管理员直接设置的额度不退回，绑定该组织的令牌会被禁用
+func DeleteOrganization(id int) error {
+	var org Organization
+	var tokens []*Token
+	var members []*OrganizationMember
+	refunds := make(map[int]int)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&org, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Find(&members).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Find(&tokens).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Token{}).Where("organization_id = ?", id).Updates(map[string]interface{}{
+			"status":          common.TokenStatusDisabled,
+			"organization_id": 0,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&OrganizationInvitation{}).Error; err != nil {
+			return err
+		}
+		for userId, quota := range organizationRefunds(org.Quota, members) {
+			if err := tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", quota)).Error; err != nil {
+				return err
+			}
+			refunds[userId] = quota
+		}
+		return tx.Delete(&org).Error
+	})
+	if err != nil {
+		return err
+	}
+	deleteTokensCache(tokens)
+	for userId, quota := range refunds {
+		_ = invalidateUserCache(userId)
+		RecordLog(userId, LogTypeManage, fmt.Sprintf("组织 %s 已删除，退回转入的额度 %s", org.Name, common.LogQuota(quota)))
+	}
+	return nil
+}
+
+// organizationRefunds 按成员转入额度的比例分配组织剩余额度
+func organizationRefunds(remain int, members []*OrganizationMember) map[int]int {
+	refunds := make(map[int]int)
+	var deposited int64
+	for _, member := range members {
+		deposited += int64(member.DepositedQuota)
+	}
+	if remain <= 0 || deposited == 0 {
+		return refunds
+	}
+	for _, member := range members {
+		if member.DepositedQuota <= 0 {
+			continue
+		}
+		quota := int(int64(remain) * int64(member.DepositedQuota) / deposited)
+		if quota > member.DepositedQuota {
+			quota = member.DepositedQuota
+		}
+		if quota > 0 {
+			refunds[member.UserId] = quota
+		}
+	}
+	return refunds
+}
+
+func GetOrganizationMember(orgId int, userId int) (*OrganizationMember, error) {
+	var member OrganizationMember
+	err := DB.Where("organization_id = ? and user_id = ?", orgId, userId).First(&member).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrganizationNotMember
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+func GetOrganizationMembers(orgId int) ([]*OrganizationMember, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("organization_id = ?", orgId).Order("id asc").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		member.Username, _ = GetUsernameById(member.UserId, false)
+	}
+	return members, nil
+}
+
+// UpdateOrganizationMember 更新成员角色与额度上限，resetUsed 为 true 时清零成员已用额度
+func UpdateOrganizationMember(member *OrganizationMember, resetUsed bool) error {
+	updates := map[string]interface{}{
+		"role":        member.Role,
+		"spend_limit": member.SpendLimit,
+	}
+	if resetUsed {
+		updates["used_quota"] = 0
+		member.UsedQuota = 0
+	}
+	return DB.Model(&OrganizationMember{}).Where("id = ?", member.Id).Updates(updates).Error
+}
+
+// RemoveOrganizationMember 移除成员，并禁用其绑定到该组织的令牌
+func RemoveOrganizationMember(orgId int, userId int) error {
+	var tokens []*Token
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ? and user_id = ?", orgId, userId).Find(&tokens).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Token{}).Where("organization_id = ? and user_id = ?", orgId, userId).Updates(map[string]interface{}{
+			"status":          common.TokenStatusDisabled,
+			"organization_id": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("organization_id = ? and user_id = ?", orgId, userId).Delete(&OrganizationMember{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	deleteTokensCache(tokens)
+	return nil
+}
+
+func deleteTokensCache(tokens []*Token) {
+	if !common.RedisEnabled {
+		return
+	}
+	for _, token := range tokens {
+		_ = cacheDeleteToken(token.GetKeyHash())
+	}
+}
+
+func CreateOrganizationInvitation(invitation *OrganizationInvitation) error {
+	invitation.Email = strings.ToLower(strings.TrimSpace(invitation.Email))
+	invitation.Code = common.GetUUID()
+	invitation.Status = OrganizationInvitationStatusPending
+	invitation.CreatedTime = common.GetTimestamp()
+	return DB.Create(invitation).Error
+}
+
+func GetOrganizationInvitations(orgId int) ([]*OrganizationInvitation, error) {
+	var invitations []*OrganizationInvitation
+	err := DB.Where("organization_id = ?", orgId).Order("id desc").Find(&invitations).Error
+	return invitations, err
+}
+
+func RevokeOrganizationInvitation(orgId int, id int) error {
+	result := DB.Model(&OrganizationInvitation{}).
+		Where("id = ? and organization_id = ? and status = ?", id, orgId, OrganizationInvitationStatusPending).
+		Update("status", OrganizationInvitationStatusRevoked)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("邀请不存在或已处理")
+	}
+	return nil
+}
+
+// AcceptOrganizationInvitation 接受邀请，邀请邮箱必须与用户绑定的邮箱一致
+func AcceptOrganizationInvitation(code string, user *User) (*OrganizationMember, error) {
+	var member *OrganizationMember
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var invitation OrganizationInvitation
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("code = ?", code).First(&invitation).Error; err != nil {
+			return errors.New("邀请不存在")
+		}
+		if invitation.Status != OrganizationInvitationStatusPending {
+			return errors.New("邀请已失效")
+		}
+		if invitation.ExpiredTime != 0 && invitation.ExpiredTime < common.GetTimestamp() {
+			return errors.New("邀请已过期")
+		}
+		if !strings.EqualFold(invitation.Email, strings.TrimSpace(user.Email)) {
+			return errors.New("邀请邮箱与当前账号绑定的邮箱不一致")
+		}
+		var count int64
+		if err := tx.Model(&OrganizationMember{}).Where("organization_id = ? and user_id = ?", invitation.OrganizationId, user.Id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("已经是该组织的成员")
+		}
+		member = &OrganizationMember{
+			OrganizationId: invitation.OrganizationId,
+			UserId:         user.Id,
+			Role:           invitation.Role,
+			CreatedTime:    common.GetTimestamp(),
+		}
+		if err := tx.Create(member).Error; err != nil {
+			return err
+		}
+		return tx.Model(&invitation).Update("status", OrganizationInvitationStatusAccepted).Error
+	})
+	return member, err
+}
+
+// TransferQuotaToOrganization 成员将个人额度转入组织额度池
+func TransferQuotaToOrganization(orgId int, userId int, quota int) error {
+	if quota <= 0 {
+		return errors.New("转入额度必须大于 0")
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ? and quota >= ?", userId, quota).Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("个人额度不足")
+		}
+		if err := tx.Model(&Organization{}).Where("id = ?", orgId).Update("quota", gorm.Expr("quota + ?", quota)).Error; err != nil {
+			return err
+		}
+		result = tx.Model(&OrganizationMember{}).Where("organization_id = ? and user_id = ?", orgId, userId).
+			Update("deposited_quota", gorm.Expr("deposited_quota + ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrganizationNotMember
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return invalidateUserCache(userId)
+}
+
+// SetOrganizationQuota 管理员直接设置组织额度池
+func SetOrganizationQuota(orgId int, quota int) error {
+	return DB.Model(&Organization{}).Where("id = ?", orgId).Update("quota", quota).Error
+}
+
+func SetOrganizationStatus(orgId int, status int) error {
+	if status != common.UserStatusEnabled && status != common.UserStatusDisabled {
+		return errors.New("无效的状态")
+	}
+	return DB.Model(&Organization{}).Where("id = ?", orgId).Update("status", status).Error
+}
+
+// GetOrganizationBillingQuota 返回成员通过组织令牌可用的额度，受组织额度池与成员额度上限共同限制
+func GetOrganizationBillingQuota(orgId int, userId int) (int, error) {
+	org, err := GetOrganizationById(orgId)
+	if err != nil {
+		return 0, err
+	}
+	if org.Status != common.UserStatusEnabled {
+		return 0, errors.New("组织已被禁用")
+	}
+	member, err := GetOrganizationMember(orgId, userId)
+	if err != nil {
+		return 0, err
+	}
+	quota := org.Quota
+	if member.SpendLimit > 0 && member.SpendLimit-member.UsedQuota < quota {
+		quota = member.SpendLimit - member.UsedQuota
+	}
+	return quota, nil
+}
+
+// changeOrganizationQuota 从组织额度池扣除 quota（为负时退回），同时累计成员用量。
+// 扣除时以剩余额度充足为条件，避免并发结算将额度池扣为负数
+func changeOrganizationQuota(orgId int, userId int, quota int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&Organization{}).Where("id = ?", orgId)
+		if quota > 0 {
+			query = query.Where("quota >= ?", quota)
+		}
+		result := query.Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota - ?", quota),
+			"used_quota": gorm.Expr("used_quota + ?", quota),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if quota > 0 && result.RowsAffected == 0 {
+			return ErrOrganizationQuotaInsufficient
+		}
+		return tx.Model(&OrganizationMember{}).Where("organization_id = ? and user_id = ?", orgId, userId).
+			Update("used_quota", gorm.Expr("used_quota + ?", quota)).Error
+	})
+}
+
+// GetBillingQuota 返回计费主体的剩余额度，orgId 为 0 时为用户个人额度
+func GetBillingQuota(userId int, orgId int) (int, error) {
+	if orgId == 0 {
+		return GetUserQuota(userId, false)
+	}
+	return GetOrganizationBillingQuota(orgId, userId)
+}
+
+func DecreaseBillingQuota(userId int, orgId int, quota int) error {
+	if orgId == 0 {
+		return DecreaseUserQuota(userId, quota)
+	}
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	return changeOrganizationQuota(orgId, userId, quota)
+}
+
+func IncreaseBillingQuota(userId int, orgId int, quota int) error {
+	if orgId == 0 {
+		return IncreaseUserQuota(userId, quota, false)
+	}
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	return changeOrganizationQuota(orgId, userId, -quota)
+}
+
+type OrganizationUsageItem struct {
+	UserId    int    `json:"user_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	ModelName string `json:"model_name,omitempty"`
+	Quota     int    `json:"quota"`
+	Count     int    `json:"count"`
+	Tokens    int    `json:"tokens"`
+}
+
+// GetOrganizationUsage 按成员与模型汇总组织在时间范围内的消费
+func GetOrganizationUsage(orgId int, startTimestamp int64, endTimestamp int64) (byMember []*OrganizationUsageItem, byModel []*OrganizationUsageItem, err error) {
+	tx := LOG_DB.Table("logs").Where("organization_id = ? and type = ?", orgId, LogTypeConsume)
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	selectSum := "sum(quota) as quota, count(*) as count, sum(prompt_tokens) + sum(completion_tokens) as tokens"
+	err = tx.Session(&gorm.Session{}).Select("user_id, username, " + selectSum).Group("user_id, username").Order("quota desc").Scan(&byMember).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	err = tx.Session(&gorm.Session{}).Select("model_name, " + selectSum).Group("model_name").Order("quota desc").Scan(&byModel).Error
+	return byMember, byModel, err
+}
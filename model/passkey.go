@@ -0,0 +1,68 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+)
+
+// PasskeyCredential 用户注册的 WebAuthn 通行密钥，Credential 为 webauthn.Credential 的 JSON
+type PasskeyCredential struct {
+	Id           int    `json:"id"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	Name         string `json:"name" gorm:"type:varchar(64);default:''"`
+	CredentialId string `json:"-" gorm:"type:varchar(255);uniqueIndex"` // base64url 编码
+	Credential   string `json:"-" gorm:"type:text"`
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+	LastUsedTime int64  `json:"last_used_time" gorm:"bigint;default:0"`
+}
+
+func GetUserPasskeys(userId int) ([]*PasskeyCredential, error) {
+	var passkeys []*PasskeyCredential
+	err := DB.Where("user_id = ?", userId).Order("id asc").Find(&passkeys).Error
+	return passkeys, err
+}
+
+func GetPasskeyByCredentialId(credentialId string) (*PasskeyCredential, error) {
+	var passkey PasskeyCredential
+	err := DB.Where("credential_id = ?", credentialId).First(&passkey).Error
+	if err != nil {
+		return nil, err
+	}
+	return &passkey, nil
+}
+
+func HasPasskey(userId int) bool {
+	var count int64
+	DB.Model(&PasskeyCredential{}).Where("user_id = ?", userId).Count(&count)
+	return count > 0
+}
+
+func (passkey *PasskeyCredential) Insert() error {
+	passkey.CreatedTime = common.GetTimestamp()
+	return DB.Create(passkey).Error
+}
+
+// UpdateCredential 登录成功后更新签名计数等信息
+func (passkey *PasskeyCredential) UpdateCredential(credential string) error {
+	passkey.Credential = credential
+	passkey.LastUsedTime = common.GetTimestamp()
+	return DB.Model(passkey).Updates(map[string]interface{}{
+		"credential":     passkey.Credential,
+		"last_used_time": passkey.LastUsedTime,
+	}).Error
+}
+
+func DeleteUserPasskey(id int, userId int) error {
+	result := DB.Where("id = ? AND user_id = ?", id, userId).Delete(&PasskeyCredential{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("通行密钥不存在")
+	}
+	return nil
+}
+
+func DeleteAllUserPasskeys(userId int) error {
+	return DB.Where("user_id = ?", userId).Delete(&PasskeyCredential{}).Error
+}
@@ -0,0 +1,283 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+type Refund struct {
+	Id            int    `json:"id"`
+	UserId        int    `json:"user_id" gorm:"index"`
+	Username      string `json:"username" gorm:"index;default:''"`
+	LogId         int    `json:"log_id" gorm:"uniqueIndex:uk_refunds_log_id;default:null"` // 没有消费日志的自动退款为空
+	LogCreatedAt  int64  `json:"log_created_at" gorm:"bigint"`
+	TokenId       int    `json:"token_id" gorm:"default:0"`
+	TokenName     string `json:"token_name" gorm:"default:''"`
+	ModelName     string `json:"model_name" gorm:"default:''"`
+	LogQuota      int    `json:"log_quota" gorm:"default:0"`
+	Quota         int    `json:"quota" gorm:"default:0"`
+	Reason        string `json:"reason" gorm:"type:varchar(255);default:''"`
+	Status        string `json:"status" gorm:"type:varchar(16);index"`
+	Auto          bool   `json:"auto" gorm:"default:false"`
+	AdminId       int    `json:"admin_id" gorm:"default:0"`
+	Remark        string `json:"remark" gorm:"type:varchar(255);default:''"`
+	CreatedTime   int64  `json:"created_time" gorm:"bigint;index"`
+	ProcessedTime int64  `json:"processed_time" gorm:"bigint"`
+	// 组织令牌产生的消费退回组织额度池
+	OrganizationId int `json:"organization_id" gorm:"default:0"`
+}
+
+// GetUserConsumeLog 根据用户可见的日志编号（id % 1024）与创建时间定位消费日志
+func GetUserConsumeLog(userId int, maskedId int, createdAt int64) (*Log, error) {
+	var logs []*Log
+	err := LOG_DB.Where("user_id = ? and type = ? and created_at = ?", userId, LogTypeConsume, createdAt).Find(&logs).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, log := range logs {
+		if log.Id%1024 == maskedId {
+			return log, nil
+		}
+	}
+	return nil, errors.New("消费日志不存在")
+}
+
+func GetRefundByLogId(logId int) (*Refund, error) {
+	var refund Refund
+	err := DB.Where("log_id = ?", logId).First(&refund).Error
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+func GetRefundById(id int) (*Refund, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	var refund Refund
+	err := DB.First(&refund, "id = ?", id).Error
+	return &refund, err
+}
+
+func GetAllRefunds(status string, username string, startIdx int, num int) (refunds []*Refund, total int64, err error) {
+	tx := DB.Model(&Refund{})
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	if username != "" {
+		tx = tx.Where("username = ?", username)
+	}
+	err = tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&refunds).Error
+	return refunds, total, err
+}
+
+func GetUserRefunds(userId int, status string, startIdx int, num int) (refunds []*Refund, total int64, err error) {
+	tx := DB.Model(&Refund{}).Where("user_id = ?", userId)
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	err = tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&refunds).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, refund := range refunds {
+		refund.LogId = refund.LogId % 1024
+		refund.AdminId = 0
+	}
+	return refunds, total, nil
+}
+
+// NewRefundFromLog 根据消费日志构造退款记录
+func NewRefundFromLog(log *Log, reason string) *Refund {
+	return &Refund{
+		UserId:         log.UserId,
+		Username:       log.Username,
+		LogId:          log.Id,
+		LogCreatedAt:   log.CreatedAt,
+		TokenId:        log.TokenId,
+		TokenName:      log.TokenName,
+		ModelName:      log.ModelName,
+		LogQuota:       log.Quota,
+		Quota:          log.Quota,
+		Reason:         reason,
+		Status:         common.RefundStatusPending,
+		CreatedTime:    common.GetTimestamp(),
+		OrganizationId: log.OrganizationId,
+	}
+}
+
+func (refund *Refund) Insert() error {
+	if refund.LogId != 0 {
+		var count int64
+		if err := DB.Model(&Refund{}).Where("log_id = ?", refund.LogId).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("该日志已提交过退款申请")
+		}
+	}
+	return DB.Create(refund).Error
+}
+
+// InsertApproved 直接写入一条已通过的退款记录并退还额度，用于自动退款
+func (refund *Refund) InsertApproved() error {
+	refund.Status = common.RefundStatusApproved
+	refund.ProcessedTime = common.GetTimestamp()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(refund).Error; err != nil {
+			return err
+		}
+		return creditRefundQuota(tx, refund)
+	})
+	if err != nil {
+		return err
+	}
+	refund.afterCredited()
+	return nil
+}
+
+// ApproveRefund 审核通过退款申请，quota 为 0 时按申请额度退还
+func ApproveRefund(id int, adminId int, quota int, remark string) (*Refund, error) {
+	refund := &Refund{}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.First(refund, "id = ?", id).Error
+		if err != nil {
+			return errors.New("退款申请不存在")
+		}
+		if refund.Status != common.RefundStatusPending {
+			return errors.New("该退款申请已处理")
+		}
+		if quota > 0 {
+			if quota > refund.LogQuota {
+				return errors.New("退款额度不能超过原消费额度")
+			}
+			refund.Quota = quota
+		}
+		refund.Status = common.RefundStatusApproved
+		refund.AdminId = adminId
+		refund.Remark = remark
+		refund.ProcessedTime = common.GetTimestamp()
+		if err = updatePendingRefund(tx, refund, "status", "quota", "admin_id", "remark", "processed_time"); err != nil {
+			return err
+		}
+		return creditRefundQuota(tx, refund)
+	})
+	if err != nil {
+		return nil, err
+	}
+	refund.afterCredited()
+	return refund, nil
+}
+
+func RejectRefund(id int, adminId int, remark string) (*Refund, error) {
+	refund := &Refund{}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.First(refund, "id = ?", id).Error
+		if err != nil {
+			return errors.New("退款申请不存在")
+		}
+		if refund.Status != common.RefundStatusPending {
+			return errors.New("该退款申请已处理")
+		}
+		refund.Status = common.RefundStatusRejected
+		refund.AdminId = adminId
+		refund.Remark = remark
+		refund.ProcessedTime = common.GetTimestamp()
+		return updatePendingRefund(tx, refund, "status", "admin_id", "remark", "processed_time")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// updatePendingRefund 仅在申请仍为待处理时更新，并发处理同一申请时只有一个能成功
+func updatePendingRefund(tx *gorm.DB, refund *Refund, columns ...string) error {
+	result := tx.Model(&Refund{}).Where("id = ? AND status = ?", refund.Id, common.RefundStatusPending).Select(columns).Updates(refund)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("该退款申请已处理")
+	}
+	return nil
+}
+
+func creditRefundQuota(tx *gorm.DB, refund *Refund) error {
+	if refund.Quota <= 0 {
+		return errors.New("退款额度必须大于 0")
+	}
+	if refund.OrganizationId != 0 {
+		if err := tx.Model(&Organization{}).Where("id = ?", refund.OrganizationId).Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota + ?", refund.Quota),
+			"used_quota": gorm.Expr("used_quota - ?", refund.Quota),
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&OrganizationMember{}).Where("organization_id = ? and user_id = ?", refund.OrganizationId, refund.UserId).
+			Update("used_quota", gorm.Expr("used_quota - ?", refund.Quota)).Error
+	}
+	return tx.Model(&User{}).Where("id = ?", refund.UserId).Updates(map[string]interface{}{
+		"quota":      gorm.Expr("quota + ?", refund.Quota),
+		"used_quota": gorm.Expr("used_quota - ?", refund.Quota),
+	}).Error
+}
+
+// afterCredited 同步缓存、退还令牌额度并记录退款日志
+func (refund *Refund) afterCredited() {
+	if refund.OrganizationId == 0 {
+		if err := cacheIncrUserQuota(refund.UserId, int64(refund.Quota)); err != nil {
+			common.SysError("failed to increase user quota cache: " + err.Error())
+		}
+	}
+	if refund.TokenId != 0 {
+		token, err := GetTokenById(refund.TokenId)
+		if err == nil && !token.UnlimitedQuota {
+			if err = IncreaseTokenQuota(token.Id, token.GetKeyHash(), refund.Quota); err != nil {
+				common.SysError("failed to increase token quota: " + err.Error())
+			}
+		}
+	}
+	RecordRefundLog(refund)
+}
+
+func RecordRefundLog(refund *Refund) {
+	content := fmt.Sprintf("消费日志 %d 退款 %s", refund.LogId, common.LogQuota(refund.Quota))
+	if refund.Auto {
+		content = "自动" + content + "，原因：" + refund.Reason
+	} else if refund.Remark != "" {
+		content += "，处理意见：" + refund.Remark
+	}
+	other := map[string]interface{}{
+		"refund_id": refund.Id,
+		"log_id":    refund.LogId % 1024,
+		"auto":      refund.Auto,
+	}
+	log := &Log{
+		UserId:    refund.UserId,
+		Username:  refund.Username,
+		CreatedAt: common.GetTimestamp(),
+		Type:      LogTypeRefund,
+		Content:   content,
+		TokenName: refund.TokenName,
+		ModelName: refund.ModelName,
+		Quota:     refund.Quota,
+		TokenId:   refund.TokenId,
+		Other:     common.MapToJsonStr(other),
+	}
+	if err := LOG_DB.Create(log).Error; err != nil {
+		common.SysError("failed to record refund log: " + err.Error())
+	}
+}
@@ -0,0 +1,197 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"strings"
+	"sync"
+)
+
+// 角色权限的内存缓存，本节点修改角色时立即失效，其他节点的修改在 SyncFrequency 秒内生效
+var (
+	rolePermissionCache     map[int][]string
+	rolePermissionCacheTime int64
+	rolePermissionCacheLock sync.RWMutex
+)
+
+// Role 自定义角色，分配给用户后替代其权限等级对应的默认权限（超级管理员始终拥有全部权限）
+type Role struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(64);uniqueIndex"`
+	Description string `json:"description" gorm:"type:varchar(255);default:''"`
+	Permissions string `json:"permissions" gorm:"type:text"` // 以逗号分隔
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+}
+
+func (role *Role) GetPermissions() []string {
+	permissions := make([]string, 0)
+	for _, p := range strings.Split(role.Permissions, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			permissions = append(permissions, p)
+		}
+	}
+	return permissions
+}
+
+// SetPermissions 校验并去重后保存权限列表
+func (role *Role) SetPermissions(permissions []string) error {
+	cleaned := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		p = strings.TrimSpace(p)
+		if !common.IsValidPermission(p) {
+			return fmt.Errorf("未知的权限：%s", p)
+		}
+		if !common.StringsContains(cleaned, p) {
+			cleaned = append(cleaned, p)
+		}
+	}
+	role.Permissions = strings.Join(cleaned, ",")
+	return nil
+}
+
+func GetAllRoles() ([]*Role, error) {
+	var roles []*Role
+	err := DB.Order("id asc").Find(&roles).Error
+	return roles, err
+}
+
+func GetRoleById(id int) (*Role, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	var role Role
+	err := DB.First(&role, "id = ?", id).Error
+	return &role, err
+}
+
+func (role *Role) Insert() error {
+	role.CreatedTime = common.GetTimestamp()
+	role.UpdatedTime = role.CreatedTime
+	defer invalidateRolePermissionCache()
+	return DB.Create(role).Error
+}
+
+func (role *Role) Update() error {
+	role.UpdatedTime = common.GetTimestamp()
+	defer invalidateRolePermissionCache()
+	return DB.Model(role).Select("name", "description", "permissions", "updated_time").Updates(role).Error
+}
+
+// DeleteRoleById 删除角色，并将已分配该角色的用户恢复为默认权限
+func DeleteRoleById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	var userIds []int
+	if err := DB.Model(&User{}).Where("role_id = ?", id).Pluck("id", &userIds).Error; err != nil {
+		return err
+	}
+	if err := DB.Model(&User{}).Where("role_id = ?", id).Update("role_id", 0).Error; err != nil {
+		return err
+	}
+	for _, userId := range userIds {
+		if err := invalidateUserCache(userId); err != nil {
+			common.SysError("failed to invalidate user cache: " + err.Error())
+		}
+	}
+	defer invalidateRolePermissionCache()
+	return DB.Delete(&Role{}, "id = ?", id).Error
+}
+
+// AssignUserRole 为用户分配自定义角色，roleId 为 0 表示取消
+func AssignUserRole(userId int, roleId int) error {
+	if roleId != 0 {
+		if _, err := GetRoleById(roleId); err != nil {
+			return errors.New("角色不存在")
+		}
+	}
+	if err := DB.Model(&User{}).Where("id = ?", userId).Update("role_id", roleId).Error; err != nil {
+		return err
+	}
+	return invalidateUserCache(userId)
+}
+
+func invalidateRolePermissionCache() {
+	rolePermissionCacheLock.Lock()
+	defer rolePermissionCacheLock.Unlock()
+	rolePermissionCache = nil
+}
+
+// getRolePermissions 从缓存读取角色的权限，缓存为空或过期时重新加载全部角色
+func getRolePermissions(roleId int) ([]string, error) {
+	rolePermissionCacheLock.RLock()
+	permissions, ok := rolePermissionCache[roleId]
+	fresh := rolePermissionCache != nil && common.GetTimestamp()-rolePermissionCacheTime < int64(common.SyncFrequency)
+	rolePermissionCacheLock.RUnlock()
+	if ok && fresh {
+		return permissions, nil
+	}
+
+	rolePermissionCacheLock.Lock()
+	defer rolePermissionCacheLock.Unlock()
+	if rolePermissionCache == nil || common.GetTimestamp()-rolePermissionCacheTime >= int64(common.SyncFrequency) {
+		roles, err := GetAllRoles()
+		if err != nil {
+			return nil, err
+		}
+		cache := make(map[int][]string, len(roles))
+		for _, role := range roles {
+			cache[role.Id] = role.GetPermissions()
+		}
+		rolePermissionCache = cache
+		rolePermissionCacheTime = common.GetTimestamp()
+	}
+	permissions, ok = rolePermissionCache[roleId]
+	if !ok {
+		return nil, errors.New("角色不存在")
+	}
+	return permissions, nil
+}
+
+// GetUserPermissions 计算用户的有效权限
+func GetUserPermissions(userId int, role int) ([]string, error) {
+	if role >= common.RoleRootUser {
+		return common.AllPermissions, nil
+	}
+	userCache, err := GetUserCache(userId)
+	if err != nil {
+		return nil, err
+	}
+	if userCache.RoleId == 0 {
+		return common.GetDefaultPermissions(role), nil
+	}
+	return getRolePermissions(userCache.RoleId)
+}
+
+// UserHasPermission 判断用户是否拥有任一指定权限
+func UserHasPermission(userId int, role int, permissions ...string) bool {
+	granted, err := GetUserPermissions(userId, role)
+	if err != nil {
+		common.SysError("failed to get user permissions: " + err.Error())
+		return false
+	}
+	for _, p := range permissions {
+		if common.StringsContains(granted, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// UserHasAllPermissions 判断用户是否同时拥有全部指定权限
+func UserHasAllPermissions(userId int, role int, permissions ...string) bool {
+	granted, err := GetUserPermissions(userId, role)
+	if err != nil {
+		common.SysError("failed to get user permissions: " + err.Error())
+		return false
+	}
+	for _, p := range permissions {
+		if !common.StringsContains(granted, p) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,326 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ScimUser 记录由身份提供商通过 SCIM 创建或关联的用户，保存 IdP 侧的 userName 与 externalId
+type ScimUser struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id" gorm:"uniqueIndex"`
+	UserName    string `json:"user_name" gorm:"type:varchar(128);uniqueIndex"`
+	ExternalId  string `json:"external_id" gorm:"type:varchar(128);index"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// ScimGroup 身份提供商推送的用户组，通过 idp_group 设置映射到网关分组
+type ScimGroup struct {
+	Id          int    `json:"id"`
+	DisplayName string `json:"display_name" gorm:"type:varchar(128);uniqueIndex"`
+	ExternalId  string `json:"external_id" gorm:"type:varchar(128);index"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+}
+
+type ScimGroupMember struct {
+	Id      int `json:"id"`
+	GroupId int `json:"group_id" gorm:"uniqueIndex:idx_scim_group_member"`
+	UserId  int `json:"user_id" gorm:"uniqueIndex:idx_scim_group_member;index"`
+}
+
+// ScimUserFilter SCIM 列表接口支持的 eq 过滤条件，为空表示不过滤
+type ScimUserFilter struct {
+	UserName   string
+	ExternalId string
+	Email      string
+}
+
+func GetScimUserByUserId(userId int) (*ScimUser, error) {
+	var scimUser ScimUser
+	err := DB.Where("user_id = ?", userId).First(&scimUser).Error
+	if err != nil {
+		return nil, err
+	}
+	return &scimUser, nil
+}
+
+func GetScimUserByUserName(userName string) (*ScimUser, error) {
+	var scimUser ScimUser
+	err := DB.Where("user_name = ?", userName).First(&scimUser).Error
+	if err != nil {
+		return nil, err
+	}
+	return &scimUser, nil
+}
+
+// GetScimUsers 返回符合条件的 SCIM 用户，已删除的网关用户不会返回
+func GetScimUsers(filter ScimUserFilter, startIdx int, num int) ([]*ScimUser, int64, error) {
+	query := DB.Model(&ScimUser{}).Joins("JOIN users ON users.id = scim_users.user_id AND users.deleted_at IS NULL")
+	if filter.UserName != "" {
+		query = query.Where("scim_users.user_name = ?", filter.UserName)
+	}
+	if filter.ExternalId != "" {
+		query = query.Where("scim_users.external_id = ?", filter.ExternalId)
+	}
+	if filter.Email != "" {
+		query = query.Where("users.email = ?", filter.Email)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var scimUsers []*ScimUser
+	err := query.Select("scim_users.*").Order("scim_users.id asc").Offset(startIdx).Limit(num).Find(&scimUsers).Error
+	return scimUsers, total, err
+}
+
+// FindScimUserForOidc 查找尚未绑定 OIDC 的 SCIM 用户，优先按 externalId 匹配 OIDC sub，其次按邮箱匹配
+func FindScimUserForOidc(sub string, email string) (*User, error) {
+	var user User
+	err := DB.Joins("JOIN scim_users ON scim_users.user_id = users.id").
+		Where("scim_users.external_id = ? AND (users.oidc_id = '' OR users.oidc_id IS NULL)", sub).
+		First(&user).Error
+	if err == nil {
+		return &user, nil
+	}
+	if email == "" {
+		return nil, err
+	}
+	err = DB.Joins("JOIN scim_users ON scim_users.user_id = users.id").
+		Where("users.email = ? AND (users.oidc_id = '' OR users.oidc_id IS NULL)", email).
+		First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateScimUser 创建网关用户并记录 SCIM 关联，user.Password 为空时使用随机密码（用户通过 OIDC 登录）
+func CreateScimUser(user *User, userName string, externalId string) (*ScimUser, error) {
+	if user.Password == "" {
+		user.Password = common.GetRandomString(32)
+	}
+	if err := user.Insert(0); err != nil {
+		return nil, err
+	}
+	now := common.GetTimestamp()
+	scimUser := &ScimUser{
+		UserId:      user.Id,
+		UserName:    userName,
+		ExternalId:  externalId,
+		CreatedTime: now,
+		UpdatedTime: now,
+	}
+	if err := DB.Create(scimUser).Error; err != nil {
+		_ = user.HardDelete()
+		return nil, err
+	}
+	return scimUser, nil
+}
+
+func (scimUser *ScimUser) Update() error {
+	scimUser.UpdatedTime = common.GetTimestamp()
+	return DB.Model(scimUser).Select("user_name", "external_id", "updated_time").Updates(scimUser).Error
+}
+
+// UpdateScimUserFields 只更新指定的用户字段，避免覆盖并发修改的额度
+func UpdateScimUserFields(userId int, updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := DB.Model(&User{}).Where("id = ?", userId).Updates(updates).Error; err != nil {
+		return err
+	}
+	return invalidateUserCache(userId)
+}
+
+// DeleteScimUser 删除 SCIM 关联与组成员关系，并软删除网关用户
+func DeleteScimUser(userId int) error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userId).Delete(&ScimGroupMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userId).Delete(&ScimUser{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&User{Id: userId}).Error
+	})
+	if err != nil {
+		return err
+	}
+	return invalidateUserCache(userId)
+}
+
+// GenerateScimUsername 根据 IdP 的 userName 生成符合长度限制且未被占用的网关用户名
+func GenerateScimUsername(userName string) string {
+	candidate := userName
+	if at := strings.Index(candidate, "@"); at > 0 {
+		candidate = candidate[:at]
+	}
+	if candidate != "" && len(candidate) <= 12 {
+		if exist, err := CheckUserExistOrDeleted(candidate, ""); err == nil && !exist {
+			return candidate
+		}
+	}
+	for i := 0; i < 5; i++ {
+		candidate = "scim_" + common.GetRandomString(7)
+		if exist, err := CheckUserExistOrDeleted(candidate, ""); err == nil && !exist {
+			break
+		}
+	}
+	return candidate
+}
+
+func GetScimGroupById(id int) (*ScimGroup, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	var group ScimGroup
+	err := DB.First(&group, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func GetScimGroups(displayName string, externalId string, startIdx int, num int) ([]*ScimGroup, int64, error) {
+	query := DB.Model(&ScimGroup{})
+	if displayName != "" {
+		query = query.Where("display_name = ?", displayName)
+	}
+	if externalId != "" {
+		query = query.Where("external_id = ?", externalId)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var groups []*ScimGroup
+	err := query.Order("id asc").Offset(startIdx).Limit(num).Find(&groups).Error
+	return groups, total, err
+}
+
+func (group *ScimGroup) Insert() error {
+	now := common.GetTimestamp()
+	group.CreatedTime = now
+	group.UpdatedTime = now
+	return DB.Create(group).Error
+}
+
+func (group *ScimGroup) Update() error {
+	group.UpdatedTime = common.GetTimestamp()
+	return DB.Model(group).Select("display_name", "external_id", "updated_time").Updates(group).Error
+}
+
+// DeleteScimGroup 删除组并返回原成员，调用方需重新计算这些用户的网关分组
+func DeleteScimGroup(id int) ([]int, error) {
+	memberIds, err := GetScimGroupMemberIds(id)
+	if err != nil {
+		return nil, err
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", id).Delete(&ScimGroupMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&ScimGroup{}, "id = ?", id).Error
+	})
+	return memberIds, err
+}
+
+func GetScimGroupMemberIds(groupId int) ([]int, error) {
+	var userIds []int
+	err := DB.Model(&ScimGroupMember{}).Where("group_id = ?", groupId).Order("user_id asc").Pluck("user_id", &userIds).Error
+	return userIds, err
+}
+
+// GetUserScimGroupNames 返回用户所属 SCIM 组的名称
+func GetUserScimGroupNames(userId int) ([]string, error) {
+	var names []string
+	err := DB.Model(&ScimGroup{}).
+		Joins("JOIN scim_group_members ON scim_group_members.group_id = scim_groups.id").
+		Where("scim_group_members.user_id = ?", userId).
+		Order("scim_groups.id asc").
+		Pluck("scim_groups.display_name", &names).Error
+	return names, err
+}
+
+// GetUserScimGroups 返回用户所属的 SCIM 组
+func GetUserScimGroups(userId int) ([]*ScimGroup, error) {
+	var groups []*ScimGroup
+	err := DB.Joins("JOIN scim_group_members ON scim_group_members.group_id = scim_groups.id").
+		Where("scim_group_members.user_id = ?", userId).
+		Order("scim_groups.id asc").
+		Find(&groups).Error
+	return groups, err
+}
+
+// AddScimGroupMembers 添加组成员，忽略已存在的成员与不存在的用户，返回实际新增的用户
+func AddScimGroupMembers(groupId int, userIds []int) ([]int, error) {
+	added := make([]int, 0, len(userIds))
+	for _, userId := range userIds {
+		var count int64
+		if err := DB.Model(&User{}).Where("id = ?", userId).Count(&count).Error; err != nil {
+			return added, err
+		}
+		if count == 0 {
+			continue
+		}
+		if err := DB.Model(&ScimGroupMember{}).Where("group_id = ? AND user_id = ?", groupId, userId).Count(&count).Error; err != nil {
+			return added, err
+		}
+		if count > 0 {
+			continue
+		}
+		if err := DB.Create(&ScimGroupMember{GroupId: groupId, UserId: userId}).Error; err != nil {
+			return added, err
+		}
+		added = append(added, userId)
+	}
+	return added, nil
+}
+
+func RemoveScimGroupMembers(groupId int, userIds []int) error {
+	if len(userIds) == 0 {
+		return nil
+	}
+	return DB.Where("group_id = ? AND user_id IN ?", groupId, userIds).Delete(&ScimGroupMember{}).Error
+}
+
+// ReplaceScimGroupMembers 替换组的全部成员，返回成员变化涉及的用户
+func ReplaceScimGroupMembers(groupId int, userIds []int) ([]int, error) {
+	oldIds, err := GetScimGroupMemberIds(groupId)
+	if err != nil {
+		return nil, err
+	}
+	if err = DB.Where("group_id = ?", groupId).Delete(&ScimGroupMember{}).Error; err != nil {
+		return nil, err
+	}
+	added, err := AddScimGroupMembers(groupId, userIds)
+	if err != nil {
+		return nil, err
+	}
+	affected := oldIds
+	for _, userId := range added {
+		if !slices.Contains(affected, userId) {
+			affected = append(affected, userId)
+		}
+	}
+	return affected, nil
+}
+
+// UpdateUserIdpGroup 更新用户的网关分组，quotaFloor 大于 0 时将剩余额度补足至该值
+func UpdateUserIdpGroup(userId int, group string, quotaFloor int) error {
+	updates := map[string]interface{}{
+		"group": group,
+	}
+	if quotaFloor > 0 {
+		updates["quota"] = gorm.Expr("CASE WHEN quota < ? THEN ? ELSE quota END", quotaFloor, quotaFloor)
+	}
+	return UpdateScimUserFields(userId, updates)
+}
@@ -3,6 +3,9 @@ package model
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common"
 	"one-api/constant"
 	commonRelay "one-api/relay/common"
 	"time"
@@ -37,6 +40,19 @@ type Task struct {
 	FinishTime int64                 `json:"finish_time" gorm:"index"`
 	Progress   string                `json:"progress" gorm:"type:varchar(20);index"`
 	Properties Properties            `json:"properties" gorm:"type:json"`
+	// 通过组织令牌提交的任务，失败补偿退回组织额度池
+	OrganizationId int `json:"organization_id" gorm:"default:0"`
+	// 任务状态变化时推送的回调地址
+	CallbackUrl string `json:"callback_url" gorm:"type:varchar(512);default:''"`
+	// 下一次查询上游的时间与已查询次数，用于自适应轮询
+	NextPollTime int64 `json:"next_poll_time" gorm:"index;default:0"`
+	PollCount    int   `json:"poll_count" gorm:"default:0"`
+	// 上游主动推送任务状态时回调地址中的凭证
+	NotifyToken string `json:"-" gorm:"type:varchar(64);index;default:''"`
+	// 提交任务使用的令牌，退款时同步退还令牌额度
+	TokenId int `json:"token_id" gorm:"default:0"`
+	// 已退还的额度，失败全额退款或按生成结果部分退款时累加，不会超过 Quota
+	RefundedQuota int `json:"refunded_quota" gorm:"default:0"`
 
 	Data json.RawMessage `json:"data" gorm:"type:json"`
 }
@@ -85,6 +101,9 @@ func InitTask(platform constant.TaskPlatform, relayInfo *commonRelay.TaskRelayIn
 		Progress:   "0%",
 		ChannelId:  relayInfo.ChannelId,
 		Platform:   platform,
+
+		OrganizationId: relayInfo.OrganizationId,
+		TokenId:        relayInfo.TokenId,
 	}
 	return t
 }
@@ -170,17 +189,102 @@ func TaskGetAllTasks(startIdx int, num int, queryParams SyncTaskQueryParams) []*
 	return tasks
 }
 
-func GetAllUnFinishSyncTasks(limit int) []*Task {
+// GetDueUnFinishTasks 返回指定平台中已到查询时间的未完成任务
+func GetDueUnFinishTasks(now int64, limit int, platforms []constant.TaskPlatform) []*Task {
 	var tasks []*Task
 	var err error
+	if len(platforms) == 0 {
+		return nil
+	}
 	// get all tasks progress is not 100%
-	err = DB.Where("progress != ?", "100%").Limit(limit).Order("id").Find(&tasks).Error
+	err = DB.Where("progress != ? AND platform IN ? AND next_poll_time <= ?", "100%", platforms, now).
+		Limit(limit).Order("next_poll_time, id").Find(&tasks).Error
 	if err != nil {
 		return nil
 	}
 	return tasks
 }
 
+// UpdateTaskPollSchedule 记录下一次查询时间，只更新轮询字段，避免覆盖并发写入的任务状态
+func UpdateTaskPollSchedule(id int64, nextPollTime int64, pollCount int) error {
+	return DB.Model(&Task{}).Where("id = ?", id).Updates(map[string]any{
+		"next_poll_time": nextPollTime,
+		"poll_count":     pollCount,
+	}).Error
+}
+
+// GetTimeoutUnFinishTasks 返回指定平台中提交时间早于 submittedBefore 仍未完成的任务
+func GetTimeoutUnFinishTasks(platform constant.TaskPlatform, submittedBefore int64, limit int) []*Task {
+	var tasks []*Task
+	err := DB.Where("progress != ? AND platform = ? AND submit_time > 0 AND submit_time < ?", "100%", platform, submittedBefore).
+		Limit(limit).Order("id").Find(&tasks).Error
+	if err != nil {
+		return nil
+	}
+	return tasks
+}
+
+func GetTaskById(id int64) (*Task, error) {
+	var task Task
+	err := DB.First(&task, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// UpdateTaskRefundedQuota 以已退额度为条件更新，多个节点同时结算同一任务时只有一个成功
+func UpdateTaskRefundedQuota(id int64, oldRefundedQuota int, refundedQuota int) (bool, error) {
+	result := DB.Model(&Task{}).Where("id = ? AND refunded_quota = ?", id, oldRefundedQuota).
+		Update("refunded_quota", refundedQuota)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RecordTaskRefundLog 记录异步任务退款日志，other 中携带任务 ID 便于关联
+func RecordTaskRefundLog(task *Task, quota int, reason string) {
+	content := fmt.Sprintf("异步任务 %s 退款 %s", task.TaskID, common.LogQuota(quota))
+	if reason != "" {
+		content += "，原因：" + reason
+	}
+	other := map[string]interface{}{
+		"task_id":  task.TaskID,
+		"platform": task.Platform,
+		"action":   task.Action,
+	}
+	username, _ := GetUsernameById(task.UserId, false)
+	log := &Log{
+		UserId:         task.UserId,
+		Username:       username,
+		CreatedAt:      common.GetTimestamp(),
+		Type:           LogTypeRefund,
+		Content:        content,
+		Quota:          quota,
+		ChannelId:      task.ChannelId,
+		TokenId:        task.TokenId,
+		Other:          common.MapToJsonStr(other),
+		OrganizationId: task.OrganizationId,
+	}
+	if err := LOG_DB.Create(log).Error; err != nil {
+		common.SysError("failed to record task refund log: " + err.Error())
+	}
+}
+
+// GetTaskByNotifyToken 根据上游推送地址中的凭证查找任务
+func GetTaskByNotifyToken(platform constant.TaskPlatform, token string) (*Task, error) {
+	if token == "" {
+		return nil, errors.New("token 为空")
+	}
+	var task Task
+	err := DB.Where("platform = ? AND notify_token = ?", platform, token).First(&task).Error
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
 func GetByOnlyTaskId(taskId string) (*Task, bool, error) {
 	if taskId == "" {
 		return nil, false, nil
@@ -236,7 +340,8 @@ func (Task *Task) Insert() error {
 
 func (Task *Task) Update() error {
 	var err error
-	err = DB.Save(Task).Error
+	// 已退额度只由退款结算更新
+	err = DB.Omit("refunded_quota").Save(Task).Error
 	return err
 }
 
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"encoding/hex"
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+)
+
+// TaskAsset 转存到网关存储中的任务结果文件（图片、音频、视频），通过签名地址访问
+type TaskAsset struct {
+	Id          int                   `json:"id"`
+	UserId      int                   `json:"user_id" gorm:"index"`
+	TaskRowId   int64                 `json:"task_row_id" gorm:"index;uniqueIndex:uk_task_assets_source"`
+	TaskId      string                `json:"task_id" gorm:"type:varchar(64);index"`
+	Platform    constant.TaskPlatform `json:"platform" gorm:"type:varchar(30)"`
+	SourceUrl   string                `json:"source_url" gorm:"type:text"`
+	SourceHash  string                `json:"-" gorm:"type:char(64);uniqueIndex:uk_task_assets_source;default:null"` // 来源地址的 SHA-256，避免并发重复转存，历史记录为空
+	Backend     string                `json:"backend" gorm:"type:varchar(16)"`
+	StorageKey  string                `json:"-" gorm:"type:varchar(255)"`
+	ContentType string                `json:"content_type" gorm:"type:varchar(128)"`
+	Size        int64                 `json:"size"`
+	// 存储扣除的额度
+	Quota int `json:"quota" gorm:"default:0"`
+	// 到期后文件被清理，任务结果恢复为上游地址，0 表示永久保留
+	ExpiresAt   int64 `json:"expires_at" gorm:"bigint;index"`
+	CreatedTime int64 `json:"created_time" gorm:"bigint;index"`
+}
+
+func (asset *TaskAsset) Insert() error {
+	asset.CreatedTime = common.GetTimestamp()
+	asset.SourceHash = hex.EncodeToString(common.Sha256Raw([]byte(asset.SourceUrl)))
+	return DB.Create(asset).Error
+}
+
+func (asset *TaskAsset) Delete() error {
+	return DB.Delete(asset).Error
+}
+
+func GetTaskAssetById(id int) (*TaskAsset, error) {
+	var asset TaskAsset
+	err := DB.First(&asset, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// GetTaskAssetsByTaskRowIds 返回任务未过期的转存文件
+func GetTaskAssetsByTaskRowIds(taskRowIds []int64) ([]*TaskAsset, error) {
+	var assets []*TaskAsset
+	if len(taskRowIds) == 0 {
+		return assets, nil
+	}
+	err := DB.Where("task_row_id IN ? AND (expires_at = 0 OR expires_at > ?)", taskRowIds, common.GetTimestamp()).
+		Find(&assets).Error
+	return assets, err
+}
+
+func GetUserTaskAssets(userId int, startIdx int, num int) (assets []*TaskAsset, total int64, err error) {
+	tx := DB.Model(&TaskAsset{})
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&assets).Error
+	return assets, total, err
+}
+
+// SumUserTaskAssetSize 返回用户当前占用的存储空间（字节）
+func SumUserTaskAssetSize(userId int) (int64, error) {
+	var size int64
+	err := DB.Model(&TaskAsset{}).Where("user_id = ?", userId).
+		Select("COALESCE(SUM(size), 0)").Scan(&size).Error
+	return size, err
+}
+
+// GetExpiredTaskAssets 返回已过保留期的转存文件
+func GetExpiredTaskAssets(now int64, limit int) ([]*TaskAsset, error) {
+	var assets []*TaskAsset
+	err := DB.Where("expires_at > 0 AND expires_at <= ?", now).Order("id").Limit(limit).Find(&assets).Error
+	return assets, err
+}
+
+func TaskAssetExists(taskRowId int64, sourceUrl string) bool {
+	var count int64
+	DB.Model(&TaskAsset{}).Where("task_row_id = ? AND source_url = ?", taskRowId, sourceUrl).Count(&count)
+	return count > 0
+}
+
+// RecordTaskAssetLog 记录转存文件扣除的存储额度
+func RecordTaskAssetLog(asset *TaskAsset, organizationId int) {
+	content := fmt.Sprintf("任务 %s 结果转存 %.2f MB，扣除 %s", asset.TaskId, float64(asset.Size)/(1<<20), common.LogQuota(asset.Quota))
+	other := map[string]interface{}{
+		"task_id":  asset.TaskId,
+		"asset_id": asset.Id,
+		"size":     asset.Size,
+	}
+	username, _ := GetUsernameById(asset.UserId, false)
+	log := &Log{
+		UserId:         asset.UserId,
+		Username:       username,
+		CreatedAt:      common.GetTimestamp(),
+		Type:           LogTypeConsume,
+		Content:        content,
+		Quota:          asset.Quota,
+		Other:          common.MapToJsonStr(other),
+		OrganizationId: organizationId,
+	}
+	if err := LOG_DB.Create(log).Error; err != nil {
+		common.SysError("failed to record task asset log: " + err.Error())
+	}
+}
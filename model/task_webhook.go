@@ -0,0 +1,122 @@
+package model
+
+import (
+	"context"
+	"one-api/common"
+)
+
+const (
+	TaskWebhookStatePending = "pending"
+	TaskWebhookStateSuccess = "success"
+	TaskWebhookStateFailed  = "failed"
+)
+
+const (
+	TaskWebhookTypeTask       = "task"
+	TaskWebhookTypeMidjourney = "midjourney"
+)
+
+// TaskWebhookDelivery 异步任务状态变化的回调投递记录，失败后按退避时间重试
+type TaskWebhookDelivery struct {
+	Id            int    `json:"id"`
+	UserId        int    `json:"user_id" gorm:"index"`
+	TaskType      string `json:"task_type" gorm:"type:varchar(20)"`
+	TaskId        string `json:"task_id" gorm:"type:varchar(64);index"`
+	TaskStatus    string `json:"task_status" gorm:"type:varchar(20)"`
+	Url           string `json:"url" gorm:"type:varchar(512)"`
+	Payload       string `json:"payload" gorm:"type:text"`
+	State         string `json:"state" gorm:"type:varchar(16);index"`
+	Attempts      int    `json:"attempts" gorm:"default:0"`
+	LastError     string `json:"last_error" gorm:"type:text"`
+	NextRetryTime int64  `json:"next_retry_time" gorm:"bigint;index"`
+	CreatedTime   int64  `json:"created_time" gorm:"bigint;index"`
+	UpdatedTime   int64  `json:"updated_time" gorm:"bigint"`
+}
+
+type TaskWebhookDeliveryFilter struct {
+	UserId int
+	TaskId string
+	State  string
+}
+
+func (delivery *TaskWebhookDelivery) Insert() error {
+	now := common.GetTimestamp()
+	delivery.CreatedTime = now
+	delivery.UpdatedTime = now
+	return DB.Create(delivery).Error
+}
+
+// UpdateResult 保存一次投递的结果
+func (delivery *TaskWebhookDelivery) UpdateResult() error {
+	delivery.UpdatedTime = common.GetTimestamp()
+	return DB.Model(delivery).Select("state", "attempts", "last_error", "next_retry_time", "updated_time").Updates(delivery).Error
+}
+
+func GetTaskWebhookDeliveryById(id int) (*TaskWebhookDelivery, error) {
+	var delivery TaskWebhookDelivery
+	err := DB.First(&delivery, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func GetTaskWebhookDeliveries(filter TaskWebhookDeliveryFilter, startIdx int, num int) (deliveries []*TaskWebhookDelivery, total int64, err error) {
+	tx := DB.Model(&TaskWebhookDelivery{})
+	if filter.UserId != 0 {
+		tx = tx.Where("user_id = ?", filter.UserId)
+	}
+	if filter.TaskId != "" {
+		tx = tx.Where("task_id = ?", filter.TaskId)
+	}
+	if filter.State != "" {
+		tx = tx.Where("state = ?", filter.State)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&deliveries).Error
+	return deliveries, total, err
+}
+
+// GetDueTaskWebhookDeliveries 返回到达重试时间的待投递记录
+func GetDueTaskWebhookDeliveries(limit int) ([]*TaskWebhookDelivery, error) {
+	var deliveries []*TaskWebhookDelivery
+	err := DB.Where("state = ? AND next_retry_time <= ?", TaskWebhookStatePending, common.GetTimestamp()).
+		Order("next_retry_time asc").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ClaimTaskWebhookDelivery 将记录的重试时间推迟到 leaseUntil，返回是否抢占成功，避免同一记录被重复投递
+func ClaimTaskWebhookDelivery(delivery *TaskWebhookDelivery, leaseUntil int64) (bool, error) {
+	result := DB.Model(&TaskWebhookDelivery{}).
+		Where("id = ? AND state = ? AND next_retry_time = ?", delivery.Id, TaskWebhookStatePending, delivery.NextRetryTime).
+		Update("next_retry_time", leaseUntil)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	delivery.NextRetryTime = leaseUntil
+	return true, nil
+}
+
+// DeleteOldTaskWebhookDeliveries 分批清理已结束的投递记录
+func DeleteOldTaskWebhookDeliveries(ctx context.Context, targetTimestamp int64, limit int) (int64, error) {
+	var total int64 = 0
+	for {
+		if nil != ctx.Err() {
+			return total, ctx.Err()
+		}
+		result := DB.Where("created_time < ? AND state <> ?", targetTimestamp, TaskWebhookStatePending).Limit(limit).Delete(&TaskWebhookDelivery{})
+		if nil != result.Error {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < int64(limit) {
+			break
+		}
+	}
+	return total, nil
+}
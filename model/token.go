@@ -1,9 +1,12 @@
 package model
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"one-api/common"
 	"strings"
 	"time"
@@ -15,7 +18,9 @@ import (
 type Token struct {
 	Id                 int            `json:"id"`
 	UserId             int            `json:"user_id" gorm:"index"`
-	Key                string         `json:"key" gorm:"type:char(48);uniqueIndex"`
+	Key                string         `json:"key" gorm:"type:char(48);uniqueIndex;default:null"`  // 仅旧令牌保存明文，新令牌为空
+	KeyHash            string         `json:"-" gorm:"type:varchar(64);uniqueIndex;default:null"` // 令牌的 SHA-256 哈希
+	KeyPrefix          string         `json:"key_prefix" gorm:"type:varchar(16);default:''"`      // 用于辨认令牌
 	Status             int            `json:"status" gorm:"default:1"`
 	Name               string         `json:"name" gorm:"index" `
 	CreatedTime        int64          `json:"created_time" gorm:"bigint"`
@@ -26,7 +31,9 @@ type Token struct {
 	ModelLimitsEnabled bool           `json:"model_limits_enabled"`
 	ModelLimits        string         `json:"model_limits" gorm:"type:varchar(1024);default:''"`
 	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
-	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
+	DenyIps            *string        `json:"deny_ips" gorm:"default:''"`                       // 禁止访问的 IP 或 CIDR，优先于 AllowIps
+	CallbackUrl        string         `json:"callback_url" gorm:"type:varchar(512);default:''"` // 异步任务默认回调地址
+	UsedQuota          int            `json:"used_quota" gorm:"default:0"`                      // used quota
 	Group              string         `json:"group" gorm:"default:''"`
 	DailyUsageCount    int            `json:"daily_usage_count" gorm:"default:0"`     // 今日使用次数
 	TotalUsageCount    int            `json:"total_usage_count" gorm:"default:0"`     // 总使用次数
@@ -36,33 +43,78 @@ type Token struct {
 	LastRateLimitReset int64          `json:"last_rate_limit_reset" gorm:"default:0"` // 最后重置时间戳
 	ChannelTag         *string        `json:"channel_tag" gorm:"default:''"`          // 渠道标签限制
 	TotalUsageLimit    *int           `json:"total_usage_limit" gorm:"default:null"`  // 总使用次数限制，nil表示不限制
+	OrganizationId     int            `json:"organization_id" gorm:"default:0;index"` // 绑定组织后从组织额度池扣费
+	ReplacedById       int            `json:"replaced_by_id" gorm:"default:0;index"`  // 轮换后的新令牌，宽限期内旧令牌按新令牌鉴权与计费
+	RotationGraceUntil int64          `json:"rotation_grace_until" gorm:"bigint;default:0"`
+	ExpiryNotifiedTime int64          `json:"-" gorm:"bigint;default:0"` // 已发送过期提醒的时间，修改过期时间后重置
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
+// 列表中展示的令牌前缀长度
+const tokenKeyPrefixLength = 8
+
+var ErrTokenRotated = errors.New("该令牌已轮换，请使用新令牌")
+
 func (token *Token) Clean() {
 	token.Key = ""
 }
 
-func (token *Token) GetIpLimitsMap() map[string]any {
-	// delete empty spaces
-	//split with \n
-	ipLimitsMap := make(map[string]any)
+func HashTokenKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// SetKey 设置新的令牌，数据库只保存哈希与前缀，明文仅保留在内存中用于返回给用户
+func (token *Token) SetKey(key string) {
+	token.Key = key
+	token.KeyHash = HashTokenKey(key)
+	token.KeyPrefix = key
+	if len(key) > tokenKeyPrefixLength {
+		token.KeyPrefix = key[:tokenKeyPrefixLength]
+	}
+}
+
+// GetKeyHash 返回令牌哈希，旧令牌根据明文计算，用作缓存键
+func (token *Token) GetKeyHash() string {
+	if token.KeyHash != "" {
+		return token.KeyHash
+	}
+	return HashTokenKey(token.Key)
+}
+
+// IsLegacyKey 旧令牌以明文保存，重新生成后改为只保存哈希
+func (token *Token) IsLegacyKey() bool {
+	return token.KeyHash == ""
+}
+
+// GetAllowIpRules 返回令牌允许访问的 IP 网段，为空表示不限制
+func (token *Token) GetAllowIpRules() []*net.IPNet {
 	if token.AllowIps == nil {
-		return ipLimitsMap
-	}
-	cleanIps := strings.ReplaceAll(*token.AllowIps, " ", "")
-	if cleanIps == "" {
-		return ipLimitsMap
-	}
-	ips := strings.Split(cleanIps, "\n")
-	for _, ip := range ips {
-		ip = strings.TrimSpace(ip)
-		ip = strings.ReplaceAll(ip, ",", "")
-		if common.IsIP(ip) {
-			ipLimitsMap[ip] = true
+		return nil
+	}
+	return common.ParseIpRules(*token.AllowIps)
+}
+
+func (token *Token) GetDenyIpRules() []*net.IPNet {
+	if token.DenyIps == nil {
+		return nil
+	}
+	return common.ParseIpRules(*token.DenyIps)
+}
+
+// NormalizeIpRules 校验并规范化令牌的 IP 白名单与黑名单
+func (token *Token) NormalizeIpRules() error {
+	for _, rules := range []*string{token.AllowIps, token.DenyIps} {
+		if rules == nil {
+			continue
+		}
+		normalized, err := common.ValidateIpRules(*rules)
+		if err != nil {
+			return err
 		}
+		*rules = normalized
 	}
-	return ipLimitsMap
+	return nil
 }
 
 func GetAllUserTokens(userId int, startIdx int, num int) ([]*Token, error) {
@@ -73,10 +125,13 @@ func GetAllUserTokens(userId int, startIdx int, num int) ([]*Token, error) {
 }
 
 func SearchUserTokens(userId int, keyword string, token string) (tokens []*Token, err error) {
+	query := DB.Where("user_id = ?", userId).Where("name LIKE ?", "%"+keyword+"%")
 	if token != "" {
 		token = strings.Trim(token, "sk-")
+		// 新令牌只能通过完整令牌或前缀搜索
+		query = query.Where("(key_hash = ? OR key_prefix LIKE ? OR "+commonKeyCol+" LIKE ?)", HashTokenKey(token), "%"+token+"%", "%"+token+"%")
 	}
-	err = DB.Where("user_id = ?", userId).Where("name LIKE ?", "%"+keyword+"%").Where(commonKeyCol+" LIKE ?", "%"+token+"%").Find(&tokens).Error
+	err = query.Find(&tokens).Error
 	return tokens, err
 }
 
@@ -86,6 +141,9 @@ func ValidateUserToken(key string) (token *Token, err error) {
 		return nil, errors.New("未提供令牌")
 	}
 	token, err = GetTokenByKey(key, false)
+	if errors.Is(err, ErrTokenRotated) {
+		return nil, err
+	}
 	if err == nil {
 		if token.Status == common.TokenStatusExhausted {
 			keyPrefix := key[:3]
@@ -165,7 +223,24 @@ func GetTokenById(id int) (*Token, error) {
 	return &token, err
 }
 
-func GetTokenByKey(key string, fromDB bool) (token *Token, err error) {
+// GetTokenByKey 轮换宽限期内的旧令牌返回对应的新令牌，Key 仍为请求使用的明文
+func GetTokenByKey(key string, fromDB bool) (*Token, error) {
+	token, err := getTokenByKey(key, fromDB)
+	if err != nil || token.ReplacedById == 0 {
+		return token, err
+	}
+	if token.RotationGraceUntil < common.GetTimestamp() {
+		return nil, ErrTokenRotated
+	}
+	replacement, err := GetTokenById(token.ReplacedById)
+	if err != nil {
+		return nil, err
+	}
+	replacement.Key = key
+	return replacement, nil
+}
+
+func getTokenByKey(key string, fromDB bool) (token *Token, err error) {
 	defer func() {
 		// Update Redis cache asynchronously on successful DB read
 		if shouldUpdateRedis(fromDB, err) && token != nil {
@@ -185,16 +260,173 @@ func GetTokenByKey(key string, fromDB bool) (token *Token, err error) {
 		// Don't return error - fall through to DB
 	}
 	fromDB = true
-	err = DB.Where(commonKeyCol+" = ?", key).First(&token).Error
+	err = DB.Where("key_hash = ? OR "+commonKeyCol+" = ?", HashTokenKey(key), key).First(&token).Error
+	if err == nil {
+		token.Key = key
+	}
 	return token, err
 }
 
+// Insert 新令牌需先调用 SetKey，明文不会写入数据库
 func (token *Token) Insert() error {
 	var err error
-	err = DB.Create(token).Error
+	if token.KeyHash != "" {
+		err = DB.Omit("key").Create(token).Error
+	} else {
+		err = DB.Create(token).Error
+	}
 	return err
 }
 
+// ResetKey 重新生成令牌，旧令牌立即失效，旧的明文令牌同时改为只保存哈希
+func (token *Token) ResetKey(key string) error {
+	oldKeyHash := token.GetKeyHash()
+	token.SetKey(key)
+	err := DB.Model(&Token{}).Where("id = ?", token.Id).Updates(map[string]interface{}{
+		"key":        nil,
+		"key_hash":   token.KeyHash,
+		"key_prefix": token.KeyPrefix,
+	}).Error
+	if err != nil {
+		return err
+	}
+	if common.RedisEnabled {
+		gopool.Go(func() {
+			if err := cacheDeleteToken(oldKeyHash); err != nil {
+				common.SysError("failed to delete token cache: " + err.Error())
+			}
+		})
+	}
+	return nil
+}
+
+// RotateToken 以相同配置与剩余额度创建新令牌，旧令牌在宽限期内仍可使用并按新令牌计费。
+// 轮换前发起、轮换后才结算的请求仍按旧令牌 id 结算，由 updateTokenQuota 转记到新令牌
+func RotateToken(old *Token, key string, expiredTime int64, graceSeconds int64) (*Token, error) {
+	now := common.GetTimestamp()
+	newToken := &Token{
+		UserId:             old.UserId,
+		Name:               old.Name,
+		Status:             common.TokenStatusEnabled,
+		CreatedTime:        now,
+		AccessedTime:       now,
+		ExpiredTime:        expiredTime,
+		UnlimitedQuota:     old.UnlimitedQuota,
+		ModelLimitsEnabled: old.ModelLimitsEnabled,
+		ModelLimits:        old.ModelLimits,
+		AllowIps:           old.AllowIps,
+		DenyIps:            old.DenyIps,
+		CallbackUrl:        old.CallbackUrl,
+		Group:              old.Group,
+		DailyUsageCount:    old.DailyUsageCount,
+		TotalUsageCount:    old.TotalUsageCount,
+		LastUsageDate:      old.LastUsageDate,
+		RateLimitPerMinute: old.RateLimitPerMinute,
+		RateLimitPerDay:    old.RateLimitPerDay,
+		ChannelTag:         old.ChannelTag,
+		TotalUsageLimit:    old.TotalUsageLimit,
+		OrganizationId:     old.OrganizationId,
+	}
+	newToken.SetKey(key)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("key").Create(newToken).Error; err != nil {
+			return err
+		}
+		// 先标记旧令牌已被轮换（同时锁定该行），之后的额度变更都会转记到新令牌
+		result := tx.Model(&Token{}).Where("id = ? AND replaced_by_id = 0", old.Id).Updates(map[string]interface{}{
+			"replaced_by_id":       newToken.Id,
+			"rotation_grace_until": now + graceSeconds,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("该令牌已被轮换")
+		}
+		// 按锁定后的最新额度转移到新令牌
+		var current Token
+		if err := tx.Select("remain_quota", "used_quota").First(&current, "id = ?", old.Id).Error; err != nil {
+			return err
+		}
+		newToken.RemainQuota = current.RemainQuota
+		newToken.UsedQuota = current.UsedQuota
+		if err := tx.Model(&Token{}).Where("id = ?", newToken.Id).Updates(map[string]interface{}{
+			"remain_quota": gorm.Expr("remain_quota + ?", current.RemainQuota),
+			"used_quota":   gorm.Expr("used_quota + ?", current.UsedQuota),
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Token{}).Where("id = ?", old.Id).
+			Update("remain_quota", gorm.Expr("remain_quota - ?", current.RemainQuota)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if common.RedisEnabled {
+		oldKeyHash := old.GetKeyHash()
+		gopool.Go(func() {
+			if err := cacheDeleteToken(oldKeyHash); err != nil {
+				common.SysError("failed to delete token cache: " + err.Error())
+			}
+		})
+	}
+	return newToken, nil
+}
+
+// GetTokensExpiringBefore 返回将在 deadline 前过期且尚未提醒的令牌
+func GetTokensExpiringBefore(deadline int64) ([]*Token, error) {
+	var tokens []*Token
+	err := DB.Where("status = ? AND expired_time <> -1 AND expired_time > ? AND expired_time <= ? AND replaced_by_id = 0 AND expiry_notified_time = 0",
+		common.TokenStatusEnabled, common.GetTimestamp(), deadline).Order("user_id asc, expired_time asc").Find(&tokens).Error
+	return tokens, err
+}
+
+func MarkTokensExpiryNotified(ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return DB.Model(&Token{}).Where("id IN ?", ids).Update("expiry_notified_time", common.GetTimestamp()).Error
+}
+
+// DeleteExpiredRotatedTokens 删除宽限期已过的旧令牌，返回删除数量
+func DeleteExpiredRotatedTokens() (int, error) {
+	var tokens []*Token
+	err := DB.Where("replaced_by_id <> 0 AND rotation_grace_until < ?", common.GetTimestamp()).Find(&tokens).Error
+	if err != nil || len(tokens) == 0 {
+		return 0, err
+	}
+	for _, token := range tokens {
+		if err = token.Delete(); err != nil {
+			return 0, err
+		}
+	}
+	return len(tokens), nil
+}
+
+// HashLegacyTokenKeys 将旧令牌的明文替换为哈希，令牌仍然可用但不再能查看，返回处理数量
+func HashLegacyTokenKeys() (int, error) {
+	count := 0
+	var tokens []*Token
+	err := DB.Select("id", commonKeyCol).Where("(key_hash IS NULL OR key_hash = '') AND "+commonKeyCol+" IS NOT NULL AND "+commonKeyCol+" <> ''").
+		FindInBatches(&tokens, 100, func(tx *gorm.DB, _ int) error {
+			for _, token := range tokens {
+				key := token.Key
+				token.SetKey(key)
+				err := DB.Model(&Token{}).Where("id = ?", token.Id).Updates(map[string]interface{}{
+					"key":        nil,
+					"key_hash":   token.KeyHash,
+					"key_prefix": token.KeyPrefix,
+				}).Error
+				if err != nil {
+					return err
+				}
+				count++
+			}
+			return nil
+		}).Error
+	return count, err
+}
+
 // Update Make sure your token's fields is completed, because this will update non-zero values
 func (token *Token) Update() (err error) {
 	defer func() {
@@ -208,8 +440,9 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group", "daily_usage_count", "total_usage_count", "last_usage_date",
-		"rate_limit_per_minute", "rate_limit_per_day", "last_rate_limit_reset", "channel_tag", "total_usage_limit").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "deny_ips", "callback_url", "group", "daily_usage_count", "total_usage_count", "last_usage_date",
+		"rate_limit_per_minute", "rate_limit_per_day", "last_rate_limit_reset", "channel_tag", "total_usage_limit", "organization_id",
+		"expiry_notified_time").Updates(token).Error
 	return err
 }
 
@@ -232,7 +465,7 @@ func (token *Token) Delete() (err error) {
 	defer func() {
 		if shouldUpdateRedis(true, err) {
 			gopool.Go(func() {
-				err := cacheDeleteToken(token.Key)
+				err := cacheDeleteToken(token.GetKeyHash())
 				if err != nil {
 					common.SysError("failed to delete token cache: " + err.Error())
 				}
@@ -286,13 +519,14 @@ func DeleteTokenById(id int, userId int) (err error) {
 	return token.Delete()
 }
 
-func IncreaseTokenQuota(id int, key string, quota int) (err error) {
+// IncreaseTokenQuota keyHash 为令牌哈希，用于同步缓存
+func IncreaseTokenQuota(id int, keyHash string, quota int) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
 	if common.RedisEnabled {
 		gopool.Go(func() {
-			err := cacheIncrTokenQuota(key, int64(quota))
+			err := cacheIncrTokenQuota(keyHash, int64(quota))
 			if err != nil {
 				common.SysError("failed to increase token quota: " + err.Error())
 			}
@@ -306,23 +540,20 @@ func IncreaseTokenQuota(id int, key string, quota int) (err error) {
 }
 
 func increaseTokenQuota(id int, quota int) (err error) {
-	err = DB.Model(&Token{}).Where("id = ?", id).Updates(
-		map[string]interface{}{
-			"remain_quota":  gorm.Expr("remain_quota + ?", quota),
-			"used_quota":    gorm.Expr("used_quota - ?", quota),
-			"accessed_time": common.GetTimestamp(),
-		},
-	).Error
-	return err
+	return updateTokenQuota(id, map[string]interface{}{
+		"remain_quota":  gorm.Expr("remain_quota + ?", quota),
+		"used_quota":    gorm.Expr("used_quota - ?", quota),
+		"accessed_time": common.GetTimestamp(),
+	})
 }
 
-func DecreaseTokenQuota(id int, key string, quota int) (err error) {
+func DecreaseTokenQuota(id int, keyHash string, quota int) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
 	if common.RedisEnabled {
 		gopool.Go(func() {
-			err := cacheDecrTokenQuota(key, int64(quota))
+			err := cacheDecrTokenQuota(keyHash, int64(quota))
 			if err != nil {
 				common.SysError("failed to decrease token quota: " + err.Error())
 			}
@@ -336,14 +567,32 @@ func DecreaseTokenQuota(id int, key string, quota int) (err error) {
 }
 
 func decreaseTokenQuota(id int, quota int) (err error) {
-	err = DB.Model(&Token{}).Where("id = ?", id).Updates(
-		map[string]interface{}{
-			"remain_quota":  gorm.Expr("remain_quota - ?", quota),
-			"used_quota":    gorm.Expr("used_quota + ?", quota),
-			"accessed_time": common.GetTimestamp(),
-		},
-	).Error
-	return err
+	return updateTokenQuota(id, map[string]interface{}{
+		"remain_quota":  gorm.Expr("remain_quota - ?", quota),
+		"used_quota":    gorm.Expr("used_quota + ?", quota),
+		"accessed_time": common.GetTimestamp(),
+	})
+}
+
+// updateTokenQuota 更新令牌额度，令牌已被轮换时转记到新令牌，避免轮换前发起的请求结算到旧令牌
+func updateTokenQuota(id int, updates map[string]interface{}) error {
+	// 限制跳转次数，避免异常数据导致死循环
+	for i := 0; i < 5; i++ {
+		result := DB.Model(&Token{}).Where("id = ? AND replaced_by_id = 0", id).Updates(updates)
+		if result.Error != nil || result.RowsAffected > 0 {
+			return result.Error
+		}
+		// 轮换宽限期结束后旧令牌会被软删除，仍需按其记录找到新令牌，避免迟到的结算丢失
+		var replacedById int
+		if err := DB.Unscoped().Model(&Token{}).Select("replaced_by_id").Where("id = ?", id).Scan(&replacedById).Error; err != nil {
+			return err
+		}
+		if replacedById == 0 {
+			return nil
+		}
+		id = replacedById
+	}
+	return nil
 }
 
 // CountUserTokens returns total number of tokens for the given user, used for pagination
@@ -379,7 +628,7 @@ func BatchDeleteTokens(ids []int, userId int) (int, error) {
 	if common.RedisEnabled {
 		gopool.Go(func() {
 			for _, t := range tokens {
-				_ = cacheDeleteToken(t.Key)
+				_ = cacheDeleteToken(t.GetKeyHash())
 			}
 		})
 	}
@@ -388,16 +637,16 @@ func BatchDeleteTokens(ids []int, userId int) (int, error) {
 }
 
 // IncreaseTokenUsageCount 增加Token的使用次数
-func IncreaseTokenUsageCount(key string) error {
-	if key == "" {
-		return errors.New("key 不能为空")
+func IncreaseTokenUsageCount(tokenId int) error {
+	if tokenId <= 0 {
+		return errors.New("tokenId不能为空")
 	}
 
 	// 获取当前日期
 	currentDate := common.GetTimeString()[:10] // YYYY-MM-DD格式
 
 	// 更新数据库
-	err := DB.Model(&Token{}).Where(commonKeyCol+" = ?", key).Updates(map[string]interface{}{
+	err := DB.Model(&Token{}).Where("id = ?", tokenId).Updates(map[string]interface{}{
 		"total_usage_count": gorm.Expr("total_usage_count + 1"),
 		"daily_usage_count": gorm.Expr("CASE WHEN last_usage_date = ? THEN daily_usage_count + 1 ELSE 1 END", currentDate),
 		"last_usage_date":   currentDate,
@@ -408,7 +657,7 @@ func IncreaseTokenUsageCount(key string) error {
 	if common.RedisEnabled && err == nil {
 		gopool.Go(func() {
 			// 重新缓存Token信息
-			token, getErr := GetTokenByKey(key, true) // 从DB获取最新数据
+			token, getErr := GetTokenById(tokenId) // 从DB获取最新数据
 			if getErr == nil {
 				_ = cacheSetToken(*token)
 			}
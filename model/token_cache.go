@@ -8,7 +8,7 @@ import (
 )
 
 func cacheSetToken(token Token) error {
-	key := common.GenerateHMAC(token.Key)
+	key := common.GenerateHMAC(token.GetKeyHash())
 	token.Clean()
 	err := common.RedisHSetObj(fmt.Sprintf("token:%s", key), &token, time.Duration(common.RedisKeyCacheSeconds())*time.Second)
 	if err != nil {
@@ -17,8 +17,9 @@ func cacheSetToken(token Token) error {
 	return nil
 }
 
-func cacheDeleteToken(key string) error {
-	key = common.GenerateHMAC(key)
+// 缓存键由令牌哈希生成，新旧令牌一致
+func cacheDeleteToken(keyHash string) error {
+	key := common.GenerateHMAC(keyHash)
 	err := common.RedisDelKey(fmt.Sprintf("token:%s", key))
 	if err != nil {
 		return err
@@ -26,8 +27,8 @@ func cacheDeleteToken(key string) error {
 	return nil
 }
 
-func cacheIncrTokenQuota(key string, increment int64) error {
-	key = common.GenerateHMAC(key)
+func cacheIncrTokenQuota(keyHash string, increment int64) error {
+	key := common.GenerateHMAC(keyHash)
 	err := common.RedisHIncrBy(fmt.Sprintf("token:%s", key), constant.TokenFiledRemainQuota, increment)
 	if err != nil {
 		return err
@@ -35,12 +36,12 @@ func cacheIncrTokenQuota(key string, increment int64) error {
 	return nil
 }
 
-func cacheDecrTokenQuota(key string, decrement int64) error {
-	return cacheIncrTokenQuota(key, -decrement)
+func cacheDecrTokenQuota(keyHash string, decrement int64) error {
+	return cacheIncrTokenQuota(keyHash, -decrement)
 }
 
-func cacheSetTokenField(key string, field string, value string) error {
-	key = common.GenerateHMAC(key)
+func cacheSetTokenField(keyHash string, field string, value string) error {
+	key := common.GenerateHMAC(keyHash)
 	err := common.RedisHSetField(fmt.Sprintf("token:%s", key), field, value)
 	if err != nil {
 		return err
@@ -50,7 +51,7 @@ func cacheSetTokenField(key string, field string, value string) error {
 
 // CacheGetTokenByKey 从缓存中获取 token，如果缓存中不存在，则从数据库中获取
 func cacheGetTokenByKey(key string) (*Token, error) {
-	hmacKey := common.GenerateHMAC(key)
+	hmacKey := common.GenerateHMAC(HashTokenKey(key))
 	if !common.RedisEnabled {
 		return nil, fmt.Errorf("redis is not enabled")
 	}
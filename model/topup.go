@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"one-api/common"
+	"strings"
 
 	"gorm.io/gorm"
 )
@@ -17,6 +18,66 @@ type TopUp struct {
 	CreateTime   int64   `json:"create_time"`
 	CompleteTime int64   `json:"complete_time"`
 	Status       string  `json:"status"`
+	// 支付币种及下单时的汇率（1 USD 额度需支付的支付币种金额）
+	Currency     string  `json:"currency" gorm:"type:varchar(8);default:''"`
+	ExchangeRate float64 `json:"exchange_rate" gorm:"default:0"`
+	// 按下单时汇率应到账的额度，为 0 表示历史订单
+	Quota int64 `json:"quota" gorm:"default:0"`
+	// 支付渠道（epay、stripe、alipay、wechat、paypal、bank_transfer）及渠道内的支付方式
+	PaymentProvider string `json:"payment_provider" gorm:"type:varchar(32);index;default:''"`
+	PaymentMethod   string `json:"payment_method" gorm:"type:varchar(32);default:''"`
+	// 支付平台侧的交易号
+	ProviderTradeNo string `json:"provider_trade_no" gorm:"type:varchar(128);default:''"`
+	Remark          string `json:"remark" gorm:"type:varchar(255);default:''"`
+}
+
+// topUpTransitions 订单状态机：pending 可转为 success/expired/cancelled/failed，success 可转为 refunded
+var topUpTransitions = map[string][]string{
+	common.TopUpStatusPending: {
+		common.TopUpStatusSuccess,
+		common.TopUpStatusExpired,
+		common.TopUpStatusCancelled,
+		common.TopUpStatusFailed,
+	},
+	common.TopUpStatusSuccess: {
+		common.TopUpStatusRefunded,
+	},
+}
+
+var ErrTopUpNotFound = errors.New("充值订单不存在")
+
+// ErrTopUpAlreadyCompleted 订单已到账，重复回调时返回，调用方应视为成功
+var ErrTopUpAlreadyCompleted = errors.New("充值订单已完成")
+
+var ErrTopUpRefundQuotaInsufficient = errors.New("用户余额不足以扣回该订单的到账额度，如需允许余额扣为负数请选择强制扣回")
+
+// TopUpPayment 支付完成时由支付渠道回传的信息
+type TopUpPayment struct {
+	// 实际支付金额与币种，为空时保留下单记录
+	PaidMoney       float64
+	Currency        string
+	ProviderTradeNo string
+	// Stripe 客户编号
+	CustomerId string
+	// 历史订单（未记录 Quota）应到账的额度
+	FallbackQuota int
+}
+
+func CanTransitTopUpStatus(from string, to string) bool {
+	for _, status := range topUpTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// GetQuotaToAdd 返回订单应到账的额度，历史订单按 fallback 计算
+func (topUp *TopUp) GetQuotaToAdd(fallback float64) int {
+	if topUp.Quota > 0 {
+		return int(topUp.Quota)
+	}
+	return int(fallback)
 }
 
 func (topUp *TopUp) Insert() error {
@@ -25,6 +86,7 @@ func (topUp *TopUp) Insert() error {
 	return err
 }
 
+// Update 保存订单，状态变更请使用 TransitTopUpStatus / CompleteTopUp
 func (topUp *TopUp) Update() error {
 	var err error
 	err = DB.Save(topUp).Error
@@ -41,6 +103,28 @@ func GetTopUpById(id int) *TopUp {
 	return topUp
 }
 
+func GetAllTopUps(userId int, status string, provider string, tradeNo string, startIdx int, num int) (topUps []*TopUp, total int64, err error) {
+	tx := DB.Model(&TopUp{})
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	if provider != "" {
+		tx = tx.Where("payment_provider = ?", provider)
+	}
+	if tradeNo != "" {
+		tx = tx.Where("trade_no = ?", tradeNo)
+	}
+	err = tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&topUps).Error
+	return topUps, total, err
+}
+
 func GetTopUpByTradeNo(tradeNo string) *TopUp {
 	var topUp *TopUp
 	var err error
@@ -51,50 +135,201 @@ func GetTopUpByTradeNo(tradeNo string) *TopUp {
 	return topUp
 }
 
-func Recharge(referenceId string, customerId string) (err error) {
-	if referenceId == "" {
-		return errors.New("未提供支付单号")
+func lockTopUp(tx *gorm.DB, tradeNo string) (*TopUp, error) {
+	if tradeNo == "" {
+		return nil, errors.New("未提供支付单号")
 	}
-
-	var quota float64
 	topUp := &TopUp{}
+	err := tx.Set("gorm:query_option", "FOR UPDATE").Where("trade_no = ?", tradeNo).First(topUp).Error
+	if err != nil {
+		return nil, ErrTopUpNotFound
+	}
+	return topUp, nil
+}
 
-	refCol := "`trade_no`"
-	if common.UsingPostgreSQL {
-		refCol = `"trade_no"`
+// updateTopUpStatus 以当前状态为条件更新，保证多节点并发回调时只有一次状态变更生效
+func updateTopUpStatus(tx *gorm.DB, topUp *TopUp, from string, fields map[string]interface{}) error {
+	result := tx.Model(&TopUp{}).Where("id = ? and status = ?", topUp.Id, from).Updates(fields)
+	if result.Error != nil {
+		return result.Error
 	}
+	if result.RowsAffected == 0 {
+		return errors.New("充值订单状态已变更")
+	}
+	return nil
+}
 
-	err = DB.Transaction(func(tx *gorm.DB) error {
-		err := tx.Set("gorm:query_option", "FOR UPDATE").Where(refCol+" = ?", referenceId).First(topUp).Error
+// TransitTopUpStatus 将订单转为目标状态（不涉及额度变动），用于过期、取消、失败
+func TransitTopUpStatus(tradeNo string, to string, remark string) (*TopUp, error) {
+	if to == common.TopUpStatusSuccess || to == common.TopUpStatusRefunded {
+		return nil, errors.New("请使用 CompleteTopUp / RefundTopUp 变更为该状态")
+	}
+	var topUp *TopUp
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		topUp, err = lockTopUp(tx, tradeNo)
 		if err != nil {
-			return errors.New("充值订单不存在")
+			return err
 		}
-
-		if topUp.Status != common.TopUpStatusPending {
-			return errors.New("充值订单状态错误")
+		if topUp.Status == to {
+			return nil
 		}
-
+		if !CanTransitTopUpStatus(topUp.Status, to) {
+			return fmt.Errorf("充值订单状态错误：%s 无法变更为 %s", topUp.Status, to)
+		}
+		from := topUp.Status
+		topUp.Status = to
 		topUp.CompleteTime = common.GetTimestamp()
-		topUp.Status = common.TopUpStatusSuccess
-		err = tx.Save(topUp).Error
+		if remark != "" {
+			topUp.Remark = remark
+		}
+		return updateTopUpStatus(tx, topUp, from, map[string]interface{}{
+			"status":        topUp.Status,
+			"complete_time": topUp.CompleteTime,
+			"remark":        topUp.Remark,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return topUp, nil
+}
+
+// CompleteTopUp 将待支付订单标记为成功并为用户增加额度，重复调用返回 ErrTopUpAlreadyCompleted
+func CompleteTopUp(tradeNo string, payment TopUpPayment) (*TopUp, error) {
+	var topUp *TopUp
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		topUp, err = lockTopUp(tx, tradeNo)
 		if err != nil {
 			return err
 		}
-
-		quota = topUp.Money * common.QuotaPerUnit
-		err = tx.Model(&User{}).Where("id = ?", topUp.UserId).Updates(map[string]interface{}{"stripe_customer": customerId, "quota": gorm.Expr("quota + ?", quota)}).Error
+		if topUp.Status == common.TopUpStatusSuccess {
+			return ErrTopUpAlreadyCompleted
+		}
+		if !CanTransitTopUpStatus(topUp.Status, common.TopUpStatusSuccess) {
+			return fmt.Errorf("充值订单状态错误：%s", topUp.Status)
+		}
+		if err = topUp.checkPayment(payment); err != nil {
+			return err
+		}
+		quota := topUp.GetQuotaToAdd(float64(payment.FallbackQuota))
+		if quota <= 0 {
+			return errors.New("充值额度无效")
+		}
+		topUp.Status = common.TopUpStatusSuccess
+		topUp.CompleteTime = common.GetTimestamp()
+		topUp.Quota = int64(quota)
+		if payment.PaidMoney > 0 && payment.Currency != "" {
+			topUp.Money = payment.PaidMoney
+			topUp.Currency = payment.Currency
+		}
+		if payment.ProviderTradeNo != "" {
+			topUp.ProviderTradeNo = payment.ProviderTradeNo
+		}
+		err = updateTopUpStatus(tx, topUp, common.TopUpStatusPending, map[string]interface{}{
+			"status":            topUp.Status,
+			"complete_time":     topUp.CompleteTime,
+			"quota":             topUp.Quota,
+			"money":             topUp.Money,
+			"currency":          topUp.Currency,
+			"provider_trade_no": topUp.ProviderTradeNo,
+		})
 		if err != nil {
 			return err
 		}
-
-		return nil
+		userFields := map[string]interface{}{"quota": gorm.Expr("quota + ?", quota)}
+		if payment.CustomerId != "" {
+			userFields["stripe_customer"] = payment.CustomerId
+		}
+		return tx.Model(&User{}).Where("id = ?", topUp.UserId).Updates(userFields).Error
 	})
-
 	if err != nil {
-		return errors.New("充值失败，" + err.Error())
+		if errors.Is(err, ErrTopUpAlreadyCompleted) {
+			return topUp, err
+		}
+		return nil, errors.New("充值失败，" + err.Error())
+	}
+	if err = cacheIncrUserQuota(topUp.UserId, topUp.Quota); err != nil {
+		common.SysError("failed to increase user quota cache: " + err.Error())
 	}
+	RecordLog(topUp.UserId, LogTypeTopup, fmt.Sprintf("使用在线充值成功，充值金额: %v，支付金额：%.2f %s", common.LogQuota(int(topUp.Quota)), topUp.Money, topUp.Currency))
+	return topUp, nil
+}
 
-	RecordLog(topUp.UserId, LogTypeTopup, fmt.Sprintf("使用在线充值成功，充值金额: %v，支付金额：%d", common.FormatQuota(int(quota)), topUp.Amount))
+// checkPayment 校验支付渠道回传的实付金额与币种，少付或币种不一致时拒绝到账。
+// 未回传币种的渠道（如易支付）按订单币种校验实付金额
+func (topUp *TopUp) checkPayment(payment TopUpPayment) error {
+	if topUp.Currency == "" || (payment.Currency == "" && payment.PaidMoney == 0) {
+		return nil
+	}
+	if payment.Currency != "" && !strings.EqualFold(payment.Currency, topUp.Currency) {
+		return fmt.Errorf("支付币种 %s 与订单币种 %s 不一致", payment.Currency, topUp.Currency)
+	}
+	// 允许 0.01 的舍入误差
+	if topUp.Money > 0 && payment.PaidMoney < topUp.Money-0.01 {
+		return fmt.Errorf("实付金额 %.2f %s 少于订单金额 %.2f %s", payment.PaidMoney, topUp.Currency, topUp.Money, topUp.Currency)
+	}
+	return nil
+}
 
+// CheckTopUpRefundable 在支付平台退款前检查用户余额是否足以扣回到账额度，clawback 为 true 时允许扣为负数
+func CheckTopUpRefundable(topUp *TopUp, clawback bool) error {
+	if clawback {
+		return nil
+	}
+	quota, err := GetUserQuota(topUp.UserId, true)
+	if err != nil {
+		return err
+	}
+	if int64(quota) < topUp.Quota {
+		return ErrTopUpRefundQuotaInsufficient
+	}
 	return nil
 }
+
+// RefundTopUp 将已完成订单标记为已退款并扣回到账额度。
+// 调用时支付平台已完成退款，余额不足时仍全额扣回，调用方应先通过 CheckTopUpRefundable 检查
+func RefundTopUp(tradeNo string, remark string) (*TopUp, error) {
+	var topUp *TopUp
+	var remainQuota int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		topUp, err = lockTopUp(tx, tradeNo)
+		if err != nil {
+			return err
+		}
+		if !CanTransitTopUpStatus(topUp.Status, common.TopUpStatusRefunded) {
+			return fmt.Errorf("充值订单状态错误：%s", topUp.Status)
+		}
+		topUp.Status = common.TopUpStatusRefunded
+		if remark != "" {
+			topUp.Remark = remark
+		}
+		err = updateTopUpStatus(tx, topUp, common.TopUpStatusSuccess, map[string]interface{}{
+			"status": topUp.Status,
+			"remark": topUp.Remark,
+		})
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("quota", gorm.Expr("quota - ?", topUp.Quota)).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&User{}).Where("id = ?", topUp.UserId).Select("quota").Scan(&remainQuota).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = cacheDecrUserQuota(topUp.UserId, topUp.Quota); err != nil {
+		common.SysError("failed to decrease user quota cache: " + err.Error())
+	}
+	content := fmt.Sprintf("充值订单 %s 已退款，扣除额度 %s", topUp.TradeNo, common.LogQuota(int(topUp.Quota)))
+	if remainQuota < 0 {
+		content += fmt.Sprintf("，扣回后余额为负：%s", common.LogQuota(int(remainQuota)))
+		common.SysLog(fmt.Sprintf("user %d quota is negative (%d) after refunding top-up %s", topUp.UserId, remainQuota, topUp.TradeNo))
+	}
+	RecordLog(topUp.UserId, LogTypeManage, content)
+	return topUp, nil
+}
@@ -0,0 +1,220 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"one-api/common"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 连续验证失败达到次数后锁定一段时间
+const (
+	TwoFAMaxFailedAttempts = 5
+	TwoFALockSeconds       = 300
+)
+
+// TwoFA 用户的 TOTP 两步验证配置，密钥在配置了主密钥时加密存储
+type TwoFA struct {
+	Id             int    `json:"id"`
+	UserId         int    `json:"user_id" gorm:"uniqueIndex"`
+	Secret         string `json:"-" gorm:"type:text"`
+	Enabled        bool   `json:"enabled" gorm:"default:false"`
+	FailedAttempts int    `json:"-" gorm:"default:0"`
+	LockedUntil    int64  `json:"-" gorm:"bigint;default:0"`
+	LastUsedStep   int64  `json:"-" gorm:"bigint;default:0"` // 防止同一验证码被重复使用
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime    int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// TwoFARecoveryCode 一次性恢复码，仅保存哈希
+type TwoFARecoveryCode struct {
+	Id       int    `json:"id"`
+	UserId   int    `json:"user_id" gorm:"index"`
+	CodeHash string `json:"-" gorm:"type:varchar(64);index"`
+	UsedTime int64  `json:"used_time" gorm:"bigint;default:0"`
+}
+
+var ErrTwoFALocked = errors.New("验证失败次数过多，请稍后再试")
+
+var ErrTwoFACodeUsed = errors.New("验证码已使用，请等待下一个验证码")
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func GetTwoFAByUserId(userId int) (*TwoFA, error) {
+	var twoFA TwoFA
+	err := DB.Where("user_id = ?", userId).First(&twoFA).Error
+	if err != nil {
+		return nil, err
+	}
+	return &twoFA, nil
+}
+
+func IsTwoFAEnabled(userId int) bool {
+	var count int64
+	DB.Model(&TwoFA{}).Where("user_id = ? AND enabled = ?", userId, true).Count(&count)
+	return count > 0
+}
+
+// HasSecondFactor 用户是否启用了 TOTP 或注册了通行密钥
+func HasSecondFactor(userId int) bool {
+	return IsTwoFAEnabled(userId) || HasPasskey(userId)
+}
+
+func (twoFA *TwoFA) GetSecret() (string, error) {
+	return common.DecryptSecret(twoFA.Secret)
+}
+
+// SaveTwoFASecret 保存新生成的密钥，启用前不生效；已启用时不允许覆盖
+func SaveTwoFASecret(userId int, secret string) error {
+	encrypted, err := common.EncryptSecret(secret)
+	if err != nil {
+		return err
+	}
+	now := common.GetTimestamp()
+	twoFA, err := GetTwoFAByUserId(userId)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		return DB.Create(&TwoFA{
+			UserId:      userId,
+			Secret:      encrypted,
+			CreatedTime: now,
+			UpdatedTime: now,
+		}).Error
+	}
+	if twoFA.Enabled {
+		return errors.New("两步验证已启用，请先关闭")
+	}
+	return DB.Model(twoFA).Updates(map[string]interface{}{
+		"secret":          encrypted,
+		"failed_attempts": 0,
+		"locked_until":    0,
+		"last_used_step":  0,
+		"updated_time":    now,
+	}).Error
+}
+
+// CheckTwoFALock 检查是否处于锁定状态
+func (twoFA *TwoFA) CheckTwoFALock() error {
+	if twoFA.LockedUntil > common.GetTimestamp() {
+		return ErrTwoFALocked
+	}
+	return nil
+}
+
+// RecordTwoFAResult 记录一次验证结果，失败次数过多时锁定，成功时记录已使用的时间步。
+// 均为条件更新：并发验证时失败次数不会丢失，同一时间步的验证码只能成功使用一次，锁定期间的成功验证也会被拒绝
+func (twoFA *TwoFA) RecordTwoFAResult(success bool, step int64) error {
+	now := common.GetTimestamp()
+	if !success {
+		err := DB.Model(&TwoFA{}).Where("id = ?", twoFA.Id).
+			Update("failed_attempts", gorm.Expr("failed_attempts + 1")).Error
+		if err != nil {
+			return err
+		}
+		return DB.Model(&TwoFA{}).Where("id = ? AND failed_attempts >= ?", twoFA.Id, TwoFAMaxFailedAttempts).
+			Updates(map[string]interface{}{
+				"failed_attempts": 0,
+				"locked_until":    now + TwoFALockSeconds,
+			}).Error
+	}
+	query := DB.Model(&TwoFA{}).Where("id = ? AND locked_until <= ?", twoFA.Id, now)
+	updates := map[string]interface{}{
+		"failed_attempts": 0,
+		"locked_until":    0,
+	}
+	if step > 0 {
+		query = query.Where("last_used_step < ?", step)
+		updates["last_used_step"] = step
+	}
+	result := query.Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	// 未更新时区分锁定与验证码重放；MySQL 在值未变化时也返回 0，恢复码验证需要再确认
+	var locked int64
+	if err := DB.Model(&TwoFA{}).Where("id = ? AND locked_until > ?", twoFA.Id, now).Count(&locked).Error; err != nil {
+		return err
+	}
+	if locked > 0 {
+		return ErrTwoFALocked
+	}
+	if step > 0 {
+		return ErrTwoFACodeUsed
+	}
+	return nil
+}
+
+// EnableTwoFA 启用两步验证并替换恢复码
+func EnableTwoFA(userId int, step int64, recoveryCodes []string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&TwoFA{}).Where("user_id = ?", userId).Updates(map[string]interface{}{
+			"enabled":         true,
+			"failed_attempts": 0,
+			"last_used_step":  step,
+			"updated_time":    common.GetTimestamp(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("请先生成两步验证密钥")
+		}
+		return replaceRecoveryCodes(tx, userId, recoveryCodes)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userId int, recoveryCodes []string) error {
+	if err := tx.Where("user_id = ?", userId).Delete(&TwoFARecoveryCode{}).Error; err != nil {
+		return err
+	}
+	codes := make([]TwoFARecoveryCode, 0, len(recoveryCodes))
+	for _, code := range recoveryCodes {
+		codes = append(codes, TwoFARecoveryCode{UserId: userId, CodeHash: hashRecoveryCode(code)})
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Create(&codes).Error
+}
+
+func ReplaceRecoveryCodes(userId int, recoveryCodes []string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userId, recoveryCodes)
+	})
+}
+
+// UseRecoveryCode 校验并消耗一个恢复码
+func UseRecoveryCode(userId int, code string) bool {
+	result := DB.Model(&TwoFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_time = 0", userId, hashRecoveryCode(code)).
+		Update("used_time", common.GetTimestamp())
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+func CountRemainingRecoveryCodes(userId int) int64 {
+	var count int64
+	DB.Model(&TwoFARecoveryCode{}).Where("user_id = ? AND used_time = 0", userId).Count(&count)
+	return count
+}
+
+// DisableTwoFA 关闭两步验证并删除恢复码
+func DisableTwoFA(userId int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userId).Delete(&TwoFA{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userId).Delete(&TwoFARecoveryCode{}).Error
+	})
+}
@@ -21,8 +21,9 @@ type User struct {
 	Password         string         `json:"password" gorm:"not null;" validate:"min=8,max=20"`
 	OriginalPassword string         `json:"original_password" gorm:"-:all"` // this field is only for Password change verification, don't save it to database!
 	DisplayName      string         `json:"display_name" gorm:"index" validate:"max=20"`
-	Role             int            `json:"role" gorm:"type:int;default:1"`   // admin, common
-	Status           int            `json:"status" gorm:"type:int;default:1"` // enabled, disabled
+	Role             int            `json:"role" gorm:"type:int;default:1"`          // admin, common
+	RoleId           int            `json:"role_id" gorm:"type:int;default:0;index"` // 自定义角色
+	Status           int            `json:"status" gorm:"type:int;default:1"`        // enabled, disabled
 	Email            string         `json:"email" gorm:"index" validate:"max=50"`
 	GitHubId         string         `json:"github_id" gorm:"column:github_id;index"`
 	OidcId           string         `json:"oidc_id" gorm:"column:oidc_id;index"`
@@ -55,6 +56,7 @@ func (user *User) ToBaseUser() *UserBase {
 		Username: user.Username,
 		Setting:  user.Setting,
 		Email:    user.Email,
+		RoleId:   user.RoleId,
 	}
 	return cache
 }
@@ -553,18 +555,6 @@ func IsAdmin(userId int) bool {
 //	return user.Status == common.UserStatusEnabled, nil
 //}
 
-func ValidateAccessToken(token string) (user *User) {
-	if token == "" {
-		return nil
-	}
-	token = strings.Replace(token, "Bearer ", "", 1)
-	user = &User{}
-	if DB.Where("access_token = ?", token).First(user).RowsAffected == 1 {
-		return user
-	}
-	return nil
-}
-
 // GetUserQuota gets quota from Redis first, falls back to DB if needed
 func GetUserQuota(id int, fromDB bool) (quota int, err error) {
 	defer func() {
@@ -662,6 +652,36 @@ func GetUserSetting(id int, fromDB bool) (settingMap dto.UserSetting, err error)
 	return userBase.GetSetting(), nil
 }
 
+// UpdateUserIpRules 更新用户设置中的 IP 白名单与黑名单，只修改 setting 字段
+func UpdateUserIpRules(id int, allowIps string, denyIps string) error {
+	user, err := GetUserById(id, true)
+	if err != nil {
+		return err
+	}
+	setting := user.GetSetting()
+	setting.AllowIps = allowIps
+	setting.DenyIps = denyIps
+	user.SetSetting(setting)
+	if err = DB.Model(&User{}).Where("id = ?", id).Update("setting", user.Setting).Error; err != nil {
+		return err
+	}
+	return invalidateUserCache(id)
+}
+
+func UpdateUserAssetRetention(id int, retentionDays int) error {
+	user, err := GetUserById(id, true)
+	if err != nil {
+		return err
+	}
+	setting := user.GetSetting()
+	setting.AssetRetentionDays = retentionDays
+	user.SetSetting(setting)
+	if err = DB.Model(&User{}).Where("id = ?", id).Update("setting", user.Setting).Error; err != nil {
+		return err
+	}
+	return invalidateUserCache(id)
+}
+
 func IncreaseUserQuota(id int, quota int, db bool) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
@@ -21,6 +21,7 @@ type UserBase struct {
 	Status   int    `json:"status"`
 	Username string `json:"username"`
 	Setting  string `json:"setting"`
+	RoleId   int    `json:"role_id"`
 }
 
 func (user *UserBase) WriteContext(c *gin.Context) {
@@ -106,6 +107,7 @@ func GetUserCache(userId int) (userCache *UserBase, err error) {
 		Username: user.Username,
 		Setting:  user.Setting,
 		Email:    user.Email,
+		RoleId:   user.RoleId,
 	}
 
 	return userCache, nil
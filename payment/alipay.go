@@ -0,0 +1,283 @@
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting"
+	"one-api/setting/payment_setting"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const PaymentProviderAlipay = "alipay"
+
+// AlipayProvider 支付宝开放平台原生接口（RSA2 签名）
+type AlipayProvider struct {
+}
+
+type alipayTradeResponse struct {
+	Code        string `json:"code"`
+	Msg         string `json:"msg"`
+	SubCode     string `json:"sub_code"`
+	SubMsg      string `json:"sub_msg"`
+	QrCode      string `json:"qr_code"`
+	TradeNo     string `json:"trade_no"`
+	OutTradeNo  string `json:"out_trade_no"`
+	TradeStatus string `json:"trade_status"`
+	TotalAmount string `json:"total_amount"`
+}
+
+func (r *alipayTradeResponse) error() error {
+	if r.Code == "10000" {
+		return nil
+	}
+	msg := r.SubMsg
+	if msg == "" {
+		msg = r.Msg
+	}
+	return fmt.Errorf("支付宝接口返回错误：%s %s", r.Code, msg)
+}
+
+func (*AlipayProvider) Name() string {
+	return PaymentProviderAlipay
+}
+
+func (*AlipayProvider) Enabled() bool {
+	s := payment_setting.GetAlipaySetting()
+	return s.Enabled && s.AppId != "" && s.PrivateKey != "" && s.AlipayPublicKey != ""
+}
+
+func (*AlipayProvider) GetMinTopUp() int64 {
+	return toMinTopUp(payment_setting.GetAlipaySetting().MinTopUp)
+}
+
+func (*AlipayProvider) Quote(amount int64, group string) (*Quote, error) {
+	return standardQuote(amount, group, payment_setting.GetAlipaySetting().UnitPrice, "CNY")
+}
+
+// alipaySignContent 按参数名排序拼接待签名字符串，忽略空值与 sign（及异步通知中的 sign_type）
+func alipaySignContent(params map[string]string, excludeSignType bool) string {
+	keys := make([]string, 0, len(params))
+	for k, v := range params {
+		if k == "sign" || v == "" || (excludeSignType && k == "sign_type") {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+params[k])
+	}
+	return strings.Join(pairs, "&")
+}
+
+func alipayCommonParams(method string, bizContent map[string]any) (map[string]string, error) {
+	s := payment_setting.GetAlipaySetting()
+	bizBytes, err := json.Marshal(bizContent)
+	if err != nil {
+		return nil, err
+	}
+	params := map[string]string{
+		"app_id":      s.AppId,
+		"method":      method,
+		"format":      "JSON",
+		"charset":     "utf-8",
+		"sign_type":   "RSA2",
+		"timestamp":   time.Now().Format("2006-01-02 15:04:05"),
+		"version":     "1.0",
+		"biz_content": string(bizBytes),
+	}
+	return params, nil
+}
+
+func signAlipayParams(params map[string]string) (url.Values, error) {
+	sign, err := signSHA256WithRSA(payment_setting.GetAlipaySetting().PrivateKey, alipaySignContent(params, false))
+	if err != nil {
+		return nil, err
+	}
+	values := url.Values{}
+	for k, v := range params {
+		values.Set(k, v)
+	}
+	values.Set("sign", sign)
+	return values, nil
+}
+
+// alipayRequest 调用支付宝 OpenAPI，返回 {method}_response 节点
+func alipayRequest(method string, bizContent map[string]any, extra map[string]string) (*alipayTradeResponse, error) {
+	params, err := alipayCommonParams(method, bizContent)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range extra {
+		params[k] = v
+	}
+	values, err := signAlipayParams(params)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := service.GetHttpClient().PostForm(payment_setting.GetAlipaySetting().Gateway, values)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]json.RawMessage
+	if err = common.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+	node, ok := raw[strings.ReplaceAll(method, ".", "_")+"_response"]
+	if !ok {
+		return nil, errors.New("支付宝接口响应格式错误")
+	}
+	var result alipayTradeResponse
+	if err = common.Unmarshal(node, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (*AlipayProvider) CreateOrder(c *gin.Context, req *OrderRequest, topUp *model.TopUp) (*OrderResult, error) {
+	s := payment_setting.GetAlipaySetting()
+	notifyUrl := service.GetCallbackAddress() + "/api/payment/alipay/notify"
+	bizContent := map[string]any{
+		"out_trade_no": topUp.TradeNo,
+		"total_amount": strconv.FormatFloat(topUp.Money, 'f', 2, 64),
+		"subject":      fmt.Sprintf("TUC%d", req.Amount),
+	}
+	extra := map[string]string{"notify_url": notifyUrl}
+	if s.Mode == "page" {
+		bizContent["product_code"] = "FAST_INSTANT_TRADE_PAY"
+		params, err := alipayCommonParams("alipay.trade.page.pay", bizContent)
+		if err != nil {
+			return nil, err
+		}
+		params["notify_url"] = notifyUrl
+		params["return_url"] = setting.ServerAddress + "/console/log"
+		values, err := signAlipayParams(params)
+		if err != nil {
+			return nil, err
+		}
+		return &OrderResult{PayUrl: s.Gateway + "?" + values.Encode()}, nil
+	}
+	result, err := alipayRequest("alipay.trade.precreate", bizContent, extra)
+	if err != nil {
+		common.SysError("failed to create alipay order: " + err.Error())
+		return nil, errors.New("拉起支付失败")
+	}
+	if err = result.error(); err != nil {
+		common.SysError("failed to create alipay order: " + err.Error())
+		return nil, errors.New("拉起支付失败")
+	}
+	return &OrderResult{QrCode: result.QrCode}, nil
+}
+
+func alipayStatusToTopUpStatus(tradeStatus string) string {
+	switch tradeStatus {
+	case "TRADE_SUCCESS", "TRADE_FINISHED":
+		return common.TopUpStatusSuccess
+	case "TRADE_CLOSED":
+		return common.TopUpStatusCancelled
+	default:
+		return common.TopUpStatusPending
+	}
+}
+
+func (*AlipayProvider) VerifyCallback(c *gin.Context) (*Event, error) {
+	if err := c.Request.ParseForm(); err != nil {
+		return nil, err
+	}
+	params := make(map[string]string)
+	for k := range c.Request.PostForm {
+		params[k] = c.Request.PostForm.Get(k)
+	}
+	s := payment_setting.GetAlipaySetting()
+	if err := verifySHA256WithRSA(s.AlipayPublicKey, alipaySignContent(params, true), params["sign"]); err != nil {
+		return nil, errors.New("支付宝回调签名验证失败")
+	}
+	if params["app_id"] != s.AppId {
+		return nil, errors.New("支付宝回调 app_id 不匹配")
+	}
+	status := alipayStatusToTopUpStatus(params["trade_status"])
+	if status == common.TopUpStatusPending {
+		return nil, nil
+	}
+	if status == common.TopUpStatusCancelled {
+		// 已支付的交易全额退款后支付宝同样推送 TRADE_CLOSED，订单不再待支付时直接应答，避免支付宝反复重试
+		if topUp := model.GetTopUpByTradeNo(params["out_trade_no"]); topUp != nil && topUp.Status != common.TopUpStatusPending {
+			return nil, nil
+		}
+	}
+	paid, _ := strconv.ParseFloat(params["total_amount"], 64)
+	return &Event{
+		TradeNo: params["out_trade_no"],
+		Status:  status,
+		Payment: model.TopUpPayment{
+			PaidMoney:       paid,
+			Currency:        "CNY",
+			ProviderTradeNo: params["trade_no"],
+		},
+	}, nil
+}
+
+func (*AlipayProvider) WriteCallbackResponse(c *gin.Context, err error) {
+	if err != nil {
+		c.String(http.StatusOK, "fail")
+		return
+	}
+	c.String(http.StatusOK, "success")
+}
+
+func (*AlipayProvider) QueryOrder(topUp *model.TopUp) (*Event, error) {
+	result, err := alipayRequest("alipay.trade.query", map[string]any{"out_trade_no": topUp.TradeNo}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if result.SubCode == "ACQ.TRADE_NOT_EXIST" {
+		// 用户尚未扫码时支付宝侧不存在交易
+		return &Event{Status: common.TopUpStatusPending}, nil
+	}
+	if err = result.error(); err != nil {
+		return nil, err
+	}
+	paid, _ := strconv.ParseFloat(result.TotalAmount, 64)
+	return &Event{
+		Status: alipayStatusToTopUpStatus(result.TradeStatus),
+		Payment: model.TopUpPayment{
+			PaidMoney:       paid,
+			Currency:        "CNY",
+			ProviderTradeNo: result.TradeNo,
+		},
+	}, nil
+}
+
+func (*AlipayProvider) Refund(topUp *model.TopUp, reason string) error {
+	bizContent := map[string]any{
+		"out_trade_no":   topUp.TradeNo,
+		"refund_amount":  strconv.FormatFloat(topUp.Money, 'f', 2, 64),
+		"out_request_no": topUp.TradeNo + "R",
+	}
+	if reason != "" {
+		bizContent["refund_reason"] = reason
+	}
+	result, err := alipayRequest("alipay.trade.refund", bizContent, nil)
+	if err != nil {
+		return err
+	}
+	return result.error()
+}
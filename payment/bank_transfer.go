@@ -0,0 +1,72 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/model"
+	"one-api/setting/payment_setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const PaymentProviderBankTransfer = "bank_transfer"
+
+// BankTransferProvider 线下银行转账，用户按说明转账后由管理员确认到账
+type BankTransferProvider struct {
+}
+
+func (*BankTransferProvider) Name() string {
+	return PaymentProviderBankTransfer
+}
+
+func (*BankTransferProvider) Enabled() bool {
+	s := payment_setting.GetBankTransferSetting()
+	return s.Enabled && s.AccountNumber != ""
+}
+
+func (*BankTransferProvider) GetMinTopUp() int64 {
+	return toMinTopUp(payment_setting.GetBankTransferSetting().MinTopUp)
+}
+
+func (*BankTransferProvider) Quote(amount int64, group string) (*Quote, error) {
+	s := payment_setting.GetBankTransferSetting()
+	return standardQuote(amount, group, s.UnitPrice, strings.ToUpper(s.Currency))
+}
+
+func (*BankTransferProvider) CreateOrder(c *gin.Context, req *OrderRequest, topUp *model.TopUp) (*OrderResult, error) {
+	s := payment_setting.GetBankTransferSetting()
+	lines := []string{
+		fmt.Sprintf("开户行：%s", s.BankName),
+		fmt.Sprintf("户名：%s", s.AccountName),
+		fmt.Sprintf("账号：%s", s.AccountNumber),
+		fmt.Sprintf("金额：%.2f %s", topUp.Money, topUp.Currency),
+		fmt.Sprintf("转账备注：%s", topUp.TradeNo),
+	}
+	if s.Instructions != "" {
+		lines = append(lines, s.Instructions)
+	}
+	return &OrderResult{Instructions: strings.Join(lines, "\n")}, nil
+}
+
+func (*BankTransferProvider) VerifyCallback(c *gin.Context) (*Event, error) {
+	return nil, ErrNotSupported
+}
+
+func (*BankTransferProvider) WriteCallbackResponse(c *gin.Context, err error) {
+	c.Status(http.StatusNotFound)
+}
+
+// QueryOrder 线下转账没有可查询的支付平台，订单状态以管理员确认为准
+func (*BankTransferProvider) QueryOrder(topUp *model.TopUp) (*Event, error) {
+	return nil, nil
+}
+
+// Refund 线下退款由管理员自行完成，此处仅校验订单
+func (*BankTransferProvider) Refund(topUp *model.TopUp, reason string) error {
+	if topUp.PaymentProvider != PaymentProviderBankTransfer {
+		return errors.New("订单不是银行转账订单")
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"strconv"
+
+	"github.com/Calcium-Ion/go-epay/epay"
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+const PaymentProviderEpay = "epay"
+
+type EpayProvider struct {
+}
+
+func GetEpayClient() *epay.Client {
+	if setting.PayAddress == "" || setting.EpayId == "" || setting.EpayKey == "" {
+		return nil
+	}
+	withUrl, err := epay.NewClient(&epay.Config{
+		PartnerID: setting.EpayId,
+		Key:       setting.EpayKey,
+	}, setting.PayAddress)
+	if err != nil {
+		return nil
+	}
+	return withUrl
+}
+
+func (*EpayProvider) Name() string {
+	return PaymentProviderEpay
+}
+
+func (*EpayProvider) Enabled() bool {
+	return GetEpayClient() != nil
+}
+
+func (*EpayProvider) GetMinTopUp() int64 {
+	return toMinTopUp(setting.MinTopUp)
+}
+
+func (*EpayProvider) Quote(amount int64, group string) (*Quote, error) {
+	return standardQuote(amount, group, setting.Price, operation_setting.GetCurrencySetting().EpayCurrency)
+}
+
+func (*EpayProvider) CreateOrder(c *gin.Context, req *OrderRequest, topUp *model.TopUp) (*OrderResult, error) {
+	if !setting.ContainsPayMethod(req.PaymentMethod) {
+		return nil, errors.New("支付方式不存在")
+	}
+	client := GetEpayClient()
+	if client == nil {
+		return nil, errors.New("当前管理员未配置支付信息")
+	}
+	returnUrl, _ := url.Parse(setting.ServerAddress + "/console/log")
+	notifyUrl, _ := url.Parse(service.GetCallbackAddress() + "/api/payment/epay/notify")
+	uri, params, err := client.Purchase(&epay.PurchaseArgs{
+		Type:           req.PaymentMethod,
+		ServiceTradeNo: topUp.TradeNo,
+		Name:           fmt.Sprintf("TUC%d", req.Amount),
+		Money:          strconv.FormatFloat(topUp.Money, 'f', 2, 64),
+		Device:         epay.PC,
+		NotifyUrl:      notifyUrl,
+		ReturnUrl:      returnUrl,
+	})
+	if err != nil {
+		common.SysError("failed to purchase epay order: " + err.Error())
+		return nil, errors.New("拉起支付失败")
+	}
+	return &OrderResult{PayUrl: uri, Params: params}, nil
+}
+
+func (*EpayProvider) VerifyCallback(c *gin.Context) (*Event, error) {
+	client := GetEpayClient()
+	if client == nil {
+		return nil, errors.New("未找到易支付配置信息")
+	}
+	query := c.Request.URL.Query()
+	if c.Request.Method == http.MethodPost {
+		_ = c.Request.ParseForm()
+		query = c.Request.Form
+	}
+	params := lo.Reduce(lo.Keys(query), func(r map[string]string, t string, i int) map[string]string {
+		r[t] = query.Get(t)
+		return r
+	}, map[string]string{})
+	verifyInfo, err := client.Verify(params)
+	if err != nil || !verifyInfo.VerifyStatus {
+		return nil, errors.New("易支付回调签名验证失败")
+	}
+	if verifyInfo.TradeStatus != epay.StatusTradeSuccess {
+		common.SysLog(fmt.Sprintf("易支付异常回调: %v", verifyInfo))
+		return nil, nil
+	}
+	// 易支付不回传币种，实付金额按订单币种计算
+	paid, _ := strconv.ParseFloat(verifyInfo.Money, 64)
+	return &Event{
+		TradeNo: verifyInfo.ServiceTradeNo,
+		Status:  common.TopUpStatusSuccess,
+		Payment: model.TopUpPayment{ProviderTradeNo: verifyInfo.TradeNo, PaidMoney: paid},
+	}, nil
+}
+
+func (*EpayProvider) WriteCallbackResponse(c *gin.Context, err error) {
+	if err != nil {
+		c.String(http.StatusOK, "fail")
+		return
+	}
+	c.String(http.StatusOK, "success")
+}
+
+type epayQueryResponse struct {
+	Code    int         `json:"code"`
+	Msg     string      `json:"msg"`
+	TradeNo string      `json:"trade_no"`
+	Status  int         `json:"status"`
+	Money   json.Number `json:"money"`
+}
+
+// epayApi 调用易支付标准 api.php 接口
+func epayApi(values url.Values, result any) error {
+	values.Set("pid", setting.EpayId)
+	values.Set("key", setting.EpayKey)
+	apiUrl := setting.PayAddress + "/api.php"
+	var resp *http.Response
+	var err error
+	if values.Get("act") == "refund" {
+		resp, err = service.GetHttpClient().PostForm(apiUrl, values)
+	} else {
+		resp, err = service.GetHttpClient().Get(apiUrl + "?" + values.Encode())
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return common.Unmarshal(body, result)
+}
+
+func (*EpayProvider) QueryOrder(topUp *model.TopUp) (*Event, error) {
+	var resp epayQueryResponse
+	err := epayApi(url.Values{"act": {"order"}, "out_trade_no": {topUp.TradeNo}}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Code != 1 {
+		return nil, errors.New("易支付查询订单失败：" + resp.Msg)
+	}
+	if resp.Status != 1 {
+		return &Event{Status: common.TopUpStatusPending}, nil
+	}
+	paid, _ := resp.Money.Float64()
+	return &Event{
+		Status:  common.TopUpStatusSuccess,
+		Payment: model.TopUpPayment{ProviderTradeNo: resp.TradeNo, PaidMoney: paid},
+	}, nil
+}
+
+func (*EpayProvider) Refund(topUp *model.TopUp, reason string) error {
+	var resp epayQueryResponse
+	err := epayApi(url.Values{
+		"act":          {"refund"},
+		"out_trade_no": {topUp.TradeNo},
+		"money":        {strconv.FormatFloat(topUp.Money, 'f', 2, 64)},
+	}, &resp)
+	if err != nil {
+		return err
+	}
+	if resp.Code != 1 {
+		return errors.New("易支付退款失败：" + resp.Msg)
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+package payment
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting"
+	"one-api/setting/payment_setting"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const PaymentProviderPayPal = "paypal"
+
+// PayPalProvider PayPal Orders v2 接口，买家确认后由回调或主动查询完成扣款
+type PayPalProvider struct {
+}
+
+type payPalLink struct {
+	Href string `json:"href"`
+	Rel  string `json:"rel"`
+}
+
+type payPalAmount struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+type payPalCapture struct {
+	Id       string       `json:"id"`
+	Status   string       `json:"status"`
+	Amount   payPalAmount `json:"amount"`
+	CustomId string       `json:"custom_id"`
+}
+
+type payPalOrder struct {
+	Id            string       `json:"id"`
+	Status        string       `json:"status"`
+	Links         []payPalLink `json:"links"`
+	PurchaseUnits []struct {
+		CustomId string `json:"custom_id"`
+		Payments struct {
+			Captures []payPalCapture `json:"captures"`
+		} `json:"payments"`
+	} `json:"purchase_units"`
+}
+
+type payPalWebhookEvent struct {
+	EventType string          `json:"event_type"`
+	Resource  json.RawMessage `json:"resource"`
+}
+
+var (
+	payPalAccessToken       string
+	payPalAccessTokenExpiry time.Time
+	payPalAccessTokenLock   sync.Mutex
+)
+
+func (*PayPalProvider) Name() string {
+	return PaymentProviderPayPal
+}
+
+func (*PayPalProvider) Enabled() bool {
+	s := payment_setting.GetPayPalSetting()
+	return s.Enabled && s.ClientId != "" && s.ClientSecret != "" && s.WebhookId != ""
+}
+
+func (*PayPalProvider) GetMinTopUp() int64 {
+	return toMinTopUp(payment_setting.GetPayPalSetting().MinTopUp)
+}
+
+func (*PayPalProvider) Quote(amount int64, group string) (*Quote, error) {
+	s := payment_setting.GetPayPalSetting()
+	return standardQuote(amount, group, s.UnitPrice, strings.ToUpper(s.Currency))
+}
+
+func payPalApiBase() string {
+	if payment_setting.GetPayPalSetting().Sandbox {
+		return "https://api-m.sandbox.paypal.com"
+	}
+	return "https://api-m.paypal.com"
+}
+
+func getPayPalAccessToken() (string, error) {
+	payPalAccessTokenLock.Lock()
+	defer payPalAccessTokenLock.Unlock()
+	if payPalAccessToken != "" && time.Now().Before(payPalAccessTokenExpiry) {
+		return payPalAccessToken, nil
+	}
+	s := payment_setting.GetPayPalSetting()
+	req, err := http.NewRequest(http.MethodPost, payPalApiBase()+"/v1/oauth2/token", strings.NewReader("grant_type=client_credentials"))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(s.ClientId, s.ClientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := service.GetHttpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if err = common.Unmarshal(body, &result); err != nil || result.AccessToken == "" {
+		return "", fmt.Errorf("获取 PayPal 访问令牌失败：%s", string(body))
+	}
+	payPalAccessToken = result.AccessToken
+	// 提前一分钟刷新
+	payPalAccessTokenExpiry = time.Now().Add(time.Duration(result.ExpiresIn-60) * time.Second)
+	return payPalAccessToken, nil
+}
+
+func payPalRequest(method string, path string, body any, result any) error {
+	token, err := getPayPalAccessToken()
+	if err != nil {
+		return err
+	}
+	var payload io.Reader
+	if body != nil {
+		data, err := common.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, payPalApiBase()+path, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := service.GetHttpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("PayPal 接口返回错误：%d %s", resp.StatusCode, string(respBody))
+	}
+	if result == nil {
+		return nil
+	}
+	return common.Unmarshal(respBody, result)
+}
+
+func (*PayPalProvider) CreateOrder(c *gin.Context, req *OrderRequest, topUp *model.TopUp) (*OrderResult, error) {
+	body := map[string]any{
+		"intent": "CAPTURE",
+		"purchase_units": []map[string]any{
+			{
+				"custom_id":   topUp.TradeNo,
+				"invoice_id":  topUp.TradeNo,
+				"description": fmt.Sprintf("TUC%d", req.Amount),
+				"amount": payPalAmount{
+					CurrencyCode: topUp.Currency,
+					Value:        strconv.FormatFloat(topUp.Money, 'f', 2, 64),
+				},
+			},
+		},
+		"application_context": map[string]any{
+			"return_url": setting.ServerAddress + "/console/log",
+			"cancel_url": setting.ServerAddress + "/console/topup",
+		},
+	}
+	var order payPalOrder
+	if err := payPalRequest(http.MethodPost, "/v2/checkout/orders", body, &order); err != nil {
+		common.SysError("failed to create paypal order: " + err.Error())
+		return nil, errors.New("拉起支付失败")
+	}
+	topUp.ProviderTradeNo = order.Id
+	for _, link := range order.Links {
+		if link.Rel == "approve" || link.Rel == "payer-action" {
+			return &OrderResult{PayUrl: link.Href}, nil
+		}
+	}
+	return nil, errors.New("拉起支付失败")
+}
+
+func captureToEvent(capture *payPalCapture) *Event {
+	paid, _ := strconv.ParseFloat(capture.Amount.Value, 64)
+	event := &Event{
+		TradeNo: capture.CustomId,
+		Status:  common.TopUpStatusPending,
+		Payment: model.TopUpPayment{
+			PaidMoney: paid,
+			Currency:  capture.Amount.CurrencyCode,
+		},
+	}
+	switch capture.Status {
+	case "COMPLETED":
+		event.Status = common.TopUpStatusSuccess
+	case "DECLINED", "DENIED", "FAILED":
+		event.Status = common.TopUpStatusFailed
+	}
+	return event
+}
+
+// orderToEvent 买家已确认（APPROVED）的订单会先执行扣款
+func orderToEvent(order *payPalOrder) (*Event, error) {
+	if order.Status == "APPROVED" {
+		captured := &payPalOrder{}
+		if err := payPalRequest(http.MethodPost, "/v2/checkout/orders/"+url.PathEscape(order.Id)+"/capture", map[string]any{}, captured); err != nil {
+			return nil, err
+		}
+		order = captured
+	}
+	switch order.Status {
+	case "COMPLETED":
+		for _, unit := range order.PurchaseUnits {
+			for _, capture := range unit.Payments.Captures {
+				if capture.CustomId == "" {
+					capture.CustomId = unit.CustomId
+				}
+				event := captureToEvent(&capture)
+				event.Payment.ProviderTradeNo = order.Id
+				return event, nil
+			}
+		}
+		return nil, errors.New("PayPal 订单缺少扣款记录")
+	case "VOIDED":
+		return &Event{Status: common.TopUpStatusCancelled}, nil
+	default:
+		return &Event{Status: common.TopUpStatusPending}, nil
+	}
+}
+
+func (*PayPalProvider) VerifyCallback(c *gin.Context) (*Event, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	verifyBody := map[string]any{
+		"auth_algo":         c.GetHeader("PAYPAL-AUTH-ALGO"),
+		"cert_url":          c.GetHeader("PAYPAL-CERT-URL"),
+		"transmission_id":   c.GetHeader("PAYPAL-TRANSMISSION-ID"),
+		"transmission_sig":  c.GetHeader("PAYPAL-TRANSMISSION-SIG"),
+		"transmission_time": c.GetHeader("PAYPAL-TRANSMISSION-TIME"),
+		"webhook_id":        payment_setting.GetPayPalSetting().WebhookId,
+		"webhook_event":     json.RawMessage(body),
+	}
+	var verifyResult struct {
+		VerificationStatus string `json:"verification_status"`
+	}
+	if err = payPalRequest(http.MethodPost, "/v1/notifications/verify-webhook-signature", verifyBody, &verifyResult); err != nil {
+		return nil, err
+	}
+	if verifyResult.VerificationStatus != "SUCCESS" {
+		return nil, errors.New("PayPal 回调签名验证失败")
+	}
+	var event payPalWebhookEvent
+	if err = common.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	switch event.EventType {
+	case "CHECKOUT.ORDER.APPROVED":
+		var order payPalOrder
+		if err = common.Unmarshal(event.Resource, &order); err != nil {
+			return nil, err
+		}
+		result, err := orderToEvent(&order)
+		if err != nil {
+			return nil, err
+		}
+		if result.TradeNo == "" && len(order.PurchaseUnits) > 0 {
+			result.TradeNo = order.PurchaseUnits[0].CustomId
+		}
+		return result, nil
+	case "PAYMENT.CAPTURE.COMPLETED", "PAYMENT.CAPTURE.DENIED":
+		var capture payPalCapture
+		if err = common.Unmarshal(event.Resource, &capture); err != nil {
+			return nil, err
+		}
+		result := captureToEvent(&capture)
+		// 扣款被拒事件中的 status 可能为 DENIED 等值，按事件类型判定为失败
+		if event.EventType == "PAYMENT.CAPTURE.DENIED" {
+			result.Status = common.TopUpStatusFailed
+		}
+		return result, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (*PayPalProvider) WriteCallbackResponse(c *gin.Context, err error) {
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func getPayPalOrder(topUp *model.TopUp) (*payPalOrder, error) {
+	if topUp.ProviderTradeNo == "" {
+		return nil, errors.New("订单未记录 PayPal 订单号")
+	}
+	var order payPalOrder
+	if err := payPalRequest(http.MethodGet, "/v2/checkout/orders/"+url.PathEscape(topUp.ProviderTradeNo), nil, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (*PayPalProvider) QueryOrder(topUp *model.TopUp) (*Event, error) {
+	order, err := getPayPalOrder(topUp)
+	if err != nil {
+		return nil, err
+	}
+	return orderToEvent(order)
+}
+
+func (*PayPalProvider) Refund(topUp *model.TopUp, reason string) error {
+	order, err := getPayPalOrder(topUp)
+	if err != nil {
+		return err
+	}
+	for _, unit := range order.PurchaseUnits {
+		for _, capture := range unit.Payments.Captures {
+			if capture.Status != "COMPLETED" {
+				continue
+			}
+			body := map[string]any{}
+			if reason != "" {
+				body["note_to_payer"] = reason
+			}
+			return payPalRequest(http.MethodPost, "/v2/payments/captures/"+url.PathEscape(capture.Id)+"/refund", body, nil)
+		}
+	}
+	return errors.New("PayPal 订单未找到可退款的扣款记录")
+}
@@ -0,0 +1,282 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// Quote 充值报价：根据用户输入的充值数量计算支付金额与到账额度
+type Quote struct {
+	// 订单记录的充值数量
+	Amount int64
+	// 需支付的金额（支付币种）
+	Money float64
+	// 到账额度
+	Quota int64
+	// 支付币种及 1 USD 额度对应的支付金额
+	Currency     string
+	ExchangeRate float64
+}
+
+type OrderRequest struct {
+	UserId        int
+	Amount        int64
+	PaymentMethod string
+	Group         string
+	Email         string
+	// Stripe 等渠道的客户编号
+	CustomerId string
+}
+
+// OrderResult 创建订单后返回给前端的支付信息，不同渠道按需填充
+type OrderResult struct {
+	TradeNo string `json:"trade_no"`
+	// 跳转支付链接
+	PayUrl string `json:"pay_url,omitempty"`
+	// 需要以表单提交的参数（易支付）
+	Params map[string]string `json:"params,omitempty"`
+	// 扫码支付的二维码内容
+	QrCode string `json:"qr_code,omitempty"`
+	// 线下转账说明
+	Instructions string  `json:"instructions,omitempty"`
+	Money        float64 `json:"money"`
+	Currency     string  `json:"currency"`
+}
+
+// Event 支付渠道回调或主动查询得到的订单状态
+type Event struct {
+	TradeNo string
+	// 目标状态，为空表示无需处理
+	Status  string
+	Payment model.TopUpPayment
+}
+
+type Provider interface {
+	// Name 渠道标识，用于路由与订单记录
+	Name() string
+	Enabled() bool
+	// GetMinTopUp 最低充值数量，与 OrderRequest.Amount 单位一致
+	GetMinTopUp() int64
+	Quote(amount int64, group string) (*Quote, error)
+	// CreateOrder 在支付平台下单，可回写 topUp.ProviderTradeNo
+	CreateOrder(c *gin.Context, req *OrderRequest, topUp *model.TopUp) (*OrderResult, error)
+	// VerifyCallback 校验支付平台回调并解析订单状态
+	VerifyCallback(c *gin.Context) (*Event, error)
+	// WriteCallbackResponse 按支付平台要求应答回调，err 为 nil 表示处理成功
+	WriteCallbackResponse(c *gin.Context, err error)
+	// QueryOrder 向支付平台查询订单状态
+	QueryOrder(topUp *model.TopUp) (*Event, error)
+	// Refund 在支付平台发起全额退款
+	Refund(topUp *model.TopUp, reason string) error
+}
+
+var ErrNotSupported = errors.New("该支付渠道不支持此操作")
+
+var (
+	providers     = make(map[string]Provider)
+	providersLock sync.RWMutex
+)
+
+// Register 注册支付渠道，重复注册会覆盖
+func Register(provider Provider) {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	providers[provider.Name()] = provider
+}
+
+func GetProvider(name string) (Provider, error) {
+	providersLock.RLock()
+	defer providersLock.RUnlock()
+	provider, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("支付渠道 %s 不存在", name)
+	}
+	return provider, nil
+}
+
+// GetEnabledProvider 获取已启用的支付渠道
+func GetEnabledProvider(name string) (Provider, error) {
+	provider, err := GetProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	if !provider.Enabled() {
+		return nil, errors.New("当前管理员未配置支付信息")
+	}
+	return provider, nil
+}
+
+func GetEnabledProviders() []Provider {
+	providersLock.RLock()
+	defer providersLock.RUnlock()
+	result := make([]Provider, 0, len(providers))
+	for _, provider := range providers {
+		if provider.Enabled() {
+			result = append(result, provider)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name() < result[j].Name()
+	})
+	return result
+}
+
+func init() {
+	Register(&EpayProvider{})
+	Register(&StripeProvider{})
+	Register(&AlipayProvider{})
+	Register(&WeChatPayProvider{})
+	Register(&PayPalProvider{})
+	Register(&BankTransferProvider{})
+}
+
+// GetOrderProvider 获取订单所属的支付渠道，兼容未记录渠道的历史订单
+func GetOrderProvider(topUp *model.TopUp) (Provider, error) {
+	name := topUp.PaymentProvider
+	if name == "" {
+		name = PaymentProviderEpay
+		if strings.HasPrefix(topUp.TradeNo, "ref_") {
+			name = PaymentProviderStripe
+		}
+	}
+	return GetProvider(name)
+}
+
+// legacyQuota 历史订单未记录到账额度，沿用旧的计算方式
+func legacyQuota(topUp *model.TopUp) int {
+	if topUp.PaymentProvider == PaymentProviderStripe || strings.HasPrefix(topUp.TradeNo, "ref_") {
+		return int(topUp.Money * common.QuotaPerUnit)
+	}
+	return int(decimal.NewFromInt(topUp.Amount).Mul(decimal.NewFromFloat(common.QuotaPerUnit)).IntPart())
+}
+
+func newTradeNo(userId int) string {
+	return fmt.Sprintf("USR%dNO%s%d", userId, common.GetRandomString(6), time.Now().Unix())
+}
+
+// CreateOrder 计算报价、在支付平台下单并写入待支付订单
+func CreateOrder(c *gin.Context, provider Provider, req *OrderRequest) (*OrderResult, error) {
+	if req.Amount < provider.GetMinTopUp() {
+		return nil, fmt.Errorf("充值数量不能小于 %d", provider.GetMinTopUp())
+	}
+	quote, err := provider.Quote(req.Amount, req.Group)
+	if err != nil {
+		return nil, err
+	}
+	if quote.Money < 0.01 {
+		return nil, errors.New("充值金额过低")
+	}
+	topUp := &model.TopUp{
+		UserId:          req.UserId,
+		Amount:          quote.Amount,
+		Money:           quote.Money,
+		TradeNo:         newTradeNo(req.UserId),
+		CreateTime:      common.GetTimestamp(),
+		Status:          common.TopUpStatusPending,
+		Currency:        quote.Currency,
+		ExchangeRate:    quote.ExchangeRate,
+		Quota:           quote.Quota,
+		PaymentProvider: provider.Name(),
+		PaymentMethod:   req.PaymentMethod,
+	}
+	result, err := provider.CreateOrder(c, req, topUp)
+	if err != nil {
+		return nil, err
+	}
+	if err = topUp.Insert(); err != nil {
+		common.SysError("failed to insert top up order: " + err.Error())
+		return nil, errors.New("创建订单失败")
+	}
+	result.TradeNo = topUp.TradeNo
+	result.Money = topUp.Money
+	result.Currency = topUp.Currency
+	return result, nil
+}
+
+// ApplyEvent 按事件推进订单状态机，重复到账视为成功
+func ApplyEvent(event *Event) (*model.TopUp, error) {
+	if event == nil || event.Status == "" {
+		return nil, nil
+	}
+	switch event.Status {
+	case common.TopUpStatusSuccess:
+		if event.Payment.FallbackQuota == 0 {
+			topUp := model.GetTopUpByTradeNo(event.TradeNo)
+			if topUp == nil {
+				return nil, model.ErrTopUpNotFound
+			}
+			event.Payment.FallbackQuota = legacyQuota(topUp)
+		}
+		topUp, err := model.CompleteTopUp(event.TradeNo, event.Payment)
+		if errors.Is(err, model.ErrTopUpAlreadyCompleted) {
+			return topUp, nil
+		}
+		return topUp, err
+	case common.TopUpStatusPending:
+		return model.GetTopUpByTradeNo(event.TradeNo), nil
+	default:
+		return model.TransitTopUpStatus(event.TradeNo, event.Status, "")
+	}
+}
+
+// HandleCallback 校验并处理支付平台回调，同时完成应答
+func HandleCallback(c *gin.Context, provider Provider) {
+	event, err := provider.VerifyCallback(c)
+	if err != nil {
+		common.SysError(fmt.Sprintf("%s 支付回调校验失败: %s", provider.Name(), err.Error()))
+		provider.WriteCallbackResponse(c, err)
+		return
+	}
+	_, err = ApplyEvent(event)
+	if err != nil {
+		common.SysError(fmt.Sprintf("%s 支付回调处理失败: %s, 订单: %s", provider.Name(), err.Error(), event.TradeNo))
+	}
+	provider.WriteCallbackResponse(c, err)
+}
+
+// SyncOrder 主动查询待支付订单在支付平台的状态并同步
+func SyncOrder(topUp *model.TopUp) (*model.TopUp, error) {
+	if topUp.Status != common.TopUpStatusPending {
+		return topUp, nil
+	}
+	provider, err := GetOrderProvider(topUp)
+	if err != nil {
+		return nil, err
+	}
+	event, err := provider.QueryOrder(topUp)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil || event.Status == "" || event.Status == common.TopUpStatusPending {
+		return topUp, nil
+	}
+	event.TradeNo = topUp.TradeNo
+	return ApplyEvent(event)
+}
+
+// RefundOrder 在支付平台退款并扣回额度，用户余额不足时仅在 clawback 为 true 时退款并将余额扣为负数
+func RefundOrder(topUp *model.TopUp, reason string, clawback bool) (*model.TopUp, error) {
+	if !model.CanTransitTopUpStatus(topUp.Status, common.TopUpStatusRefunded) {
+		return nil, fmt.Errorf("充值订单状态错误：%s", topUp.Status)
+	}
+	if err := model.CheckTopUpRefundable(topUp, clawback); err != nil {
+		return nil, err
+	}
+	provider, err := GetOrderProvider(topUp)
+	if err != nil {
+		return nil, err
+	}
+	if err = provider.Refund(topUp, reason); err != nil {
+		return nil, err
+	}
+	return model.RefundTopUp(topUp.TradeNo, reason)
+}
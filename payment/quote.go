@@ -0,0 +1,54 @@
+package payment
+
+import (
+	"errors"
+	"one-api/common"
+	"one-api/setting/operation_setting"
+
+	"github.com/shopspring/decimal"
+)
+
+// toMinTopUp 将以美元计的最低充值数量换算为请求单位
+func toMinTopUp(minTopUp int) int64 {
+	if !common.DisplayInCurrencyEnabled {
+		return decimal.NewFromInt(int64(minTopUp)).Mul(decimal.NewFromFloat(common.QuotaPerUnit)).IntPart()
+	}
+	return int64(minTopUp)
+}
+
+// getUnitPrice 返回 1 USD 额度对应的支付金额，未配置单价时按汇率表换算
+func getUnitPrice(unitPrice float64, currency string) (float64, error) {
+	if unitPrice > 0 {
+		return unitPrice, nil
+	}
+	rate, ok := operation_setting.GetExchangeRate(currency)
+	if !ok {
+		return 0, errors.New("未配置支付币种 " + currency + " 的汇率")
+	}
+	return rate, nil
+}
+
+// standardQuote 通用报价：充值数量在展示为货币时以美元计，否则以额度计
+func standardQuote(amount int64, group string, unitPrice float64, currency string) (*Quote, error) {
+	price, err := getUnitPrice(unitPrice, currency)
+	if err != nil {
+		return nil, err
+	}
+	dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
+	dUsd := decimal.NewFromInt(amount)
+	if !common.DisplayInCurrencyEnabled {
+		dUsd = dUsd.Div(dQuotaPerUnit)
+	}
+	topupGroupRatio := common.GetTopupGroupRatio(group)
+	if topupGroupRatio == 0 {
+		topupGroupRatio = 1
+	}
+	money := dUsd.Mul(decimal.NewFromFloat(price)).Mul(decimal.NewFromFloat(topupGroupRatio))
+	return &Quote{
+		Amount:       dUsd.IntPart(),
+		Money:        money.Round(2).InexactFloat64(),
+		Quota:        dUsd.Mul(dQuotaPerUnit).IntPart(),
+		Currency:     currency,
+		ExchangeRate: price,
+	}, nil
+}
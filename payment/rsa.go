@@ -0,0 +1,87 @@
+package payment
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"strings"
+)
+
+// decodeKeyBytes 兼容 PEM 与去掉头尾的 base64 密钥
+func decodeKeyBytes(key string) ([]byte, error) {
+	key = strings.TrimSpace(key)
+	if block, _ := pem.Decode([]byte(key)); block != nil {
+		return block.Bytes, nil
+	}
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(key), ""))
+}
+
+func parseRSAPrivateKey(key string) (*rsa.PrivateKey, error) {
+	der, err := decodeKeyBytes(key)
+	if err != nil {
+		return nil, errors.New("私钥格式错误")
+	}
+	if privateKey, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return privateKey, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, errors.New("私钥格式错误")
+	}
+	privateKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("私钥不是 RSA 密钥")
+	}
+	return privateKey, nil
+}
+
+func parseRSAPublicKey(key string) (*rsa.PublicKey, error) {
+	der, err := decodeKeyBytes(key)
+	if err != nil {
+		return nil, errors.New("公钥格式错误")
+	}
+	if parsed, err := x509.ParsePKIXPublicKey(der); err == nil {
+		if publicKey, ok := parsed.(*rsa.PublicKey); ok {
+			return publicKey, nil
+		}
+		return nil, errors.New("公钥不是 RSA 密钥")
+	}
+	if cert, err := x509.ParseCertificate(der); err == nil {
+		if publicKey, ok := cert.PublicKey.(*rsa.PublicKey); ok {
+			return publicKey, nil
+		}
+	}
+	return x509.ParsePKCS1PublicKey(der)
+}
+
+// signSHA256WithRSA 返回 base64 编码的 SHA256WithRSA 签名
+func signSHA256WithRSA(privateKey string, content string) (string, error) {
+	key, err := parseRSAPrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	hashed := sha256.Sum256([]byte(content))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+func verifySHA256WithRSA(publicKey string, content string, signature string) error {
+	key, err := parseRSAPublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return errors.New("签名格式错误")
+	}
+	hashed := sha256.Sum256([]byte(content))
+	return rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], sig)
+}
@@ -0,0 +1,238 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/checkout/session"
+	"github.com/stripe/stripe-go/v81/refund"
+	"github.com/stripe/stripe-go/v81/webhook"
+)
+
+const PaymentProviderStripe = "stripe"
+
+// Stripe 单次最多购买数量
+const stripeMaxTopUp = 10000
+
+type StripeProvider struct {
+}
+
+func (*StripeProvider) Name() string {
+	return PaymentProviderStripe
+}
+
+func (*StripeProvider) Enabled() bool {
+	return setting.StripeApiSecret != "" && setting.StripeWebhookSecret != "" && setting.StripePriceId != ""
+}
+
+func (*StripeProvider) GetMinTopUp() int64 {
+	minTopup := setting.StripeMinTopUp
+	if !common.DisplayInCurrencyEnabled {
+		minTopup = minTopup * int(common.QuotaPerUnit)
+	}
+	return int64(minTopup)
+}
+
+// Stripe 金额以货币最小单位表示，零小数位与三位小数的货币需要单独换算
+// https://docs.stripe.com/currencies#special-cases
+var stripeZeroDecimalCurrencies = []string{"BIF", "CLP", "DJF", "GNF", "JPY", "KMF", "KRW", "MGA", "PYG", "RWF", "UGX", "VND", "VUV", "XAF", "XOF", "XPF"}
+var stripeThreeDecimalCurrencies = []string{"BHD", "JOD", "KWD", "OMR", "TND"}
+
+// stripeAmountToMoney 将 Stripe 的最小单位金额换算为支付金额
+func stripeAmountToMoney(amount int64, currency string) float64 {
+	currency = strings.ToUpper(currency)
+	switch {
+	case common.StringsContains(stripeZeroDecimalCurrencies, currency):
+		return float64(amount)
+	case common.StringsContains(stripeThreeDecimalCurrencies, currency):
+		return float64(amount) / 1000
+	default:
+		return float64(amount) / 100
+	}
+}
+
+func getStripeTopupGroupRatio(group string) float64 {
+	topupGroupRatio := common.GetTopupGroupRatio(group)
+	if topupGroupRatio == 0 {
+		topupGroupRatio = 1
+	}
+	return topupGroupRatio
+}
+
+// Quote Stripe 按数量购买固定价格的商品，分组充值倍率作用于到账额度。
+// 此处金额仅为预估，创建 Checkout 会话后以 Stripe 实际收取的金额为准
+func (*StripeProvider) Quote(amount int64, group string) (*Quote, error) {
+	if amount > stripeMaxTopUp {
+		return nil, fmt.Errorf("充值数量不能大于 %d", stripeMaxTopUp)
+	}
+	topupGroupRatio := getStripeTopupGroupRatio(group)
+	payAmount := float64(amount)
+	if !common.DisplayInCurrencyEnabled {
+		payAmount = payAmount / common.QuotaPerUnit
+	}
+	// Using float64 for monetary calculations is acceptable here due to the small amounts involved
+	payMoney := payAmount * setting.StripeUnitPrice * topupGroupRatio
+	return &Quote{
+		Amount:       amount,
+		Money:        payMoney,
+		Quota:        int64(float64(amount) * topupGroupRatio * common.QuotaPerUnit),
+		Currency:     operation_setting.GetCurrencySetting().StripeCurrency,
+		ExchangeRate: setting.StripeUnitPrice,
+	}, nil
+}
+
+func (*StripeProvider) CreateOrder(c *gin.Context, req *OrderRequest, topUp *model.TopUp) (*OrderResult, error) {
+	if !strings.HasPrefix(setting.StripeApiSecret, "sk_") && !strings.HasPrefix(setting.StripeApiSecret, "rk_") {
+		return nil, errors.New("无效的Stripe API密钥")
+	}
+	stripe.Key = setting.StripeApiSecret
+
+	params := &stripe.CheckoutSessionParams{
+		ClientReferenceID: stripe.String(topUp.TradeNo),
+		SuccessURL:        stripe.String(setting.ServerAddress + "/log"),
+		CancelURL:         stripe.String(setting.ServerAddress + "/topup"),
+		LineItems: []*stripe.CheckoutSessionLineItemParams{
+			{
+				Price:    stripe.String(setting.StripePriceId),
+				Quantity: stripe.Int64(req.Amount),
+			},
+		},
+		Mode: stripe.String(string(stripe.CheckoutSessionModePayment)),
+	}
+
+	if "" == req.CustomerId {
+		if "" != req.Email {
+			params.CustomerEmail = stripe.String(req.Email)
+		}
+
+		params.CustomerCreation = stripe.String(string(stripe.CheckoutSessionCustomerCreationAlways))
+	} else {
+		params.Customer = stripe.String(req.CustomerId)
+	}
+
+	result, err := session.New(params)
+	if err != nil {
+		common.SysError("获取Stripe Checkout支付链接失败: " + err.Error())
+		return nil, errors.New("拉起支付失败")
+	}
+	topUp.ProviderTradeNo = result.ID
+	// Stripe 按价格对象的单价乘以数量收费，订单金额以会话金额为准，避免与预估金额不一致时拒绝到账
+	if result.AmountTotal > 0 {
+		topUp.Money = stripeAmountToMoney(result.AmountTotal, string(result.Currency))
+		topUp.Currency = strings.ToUpper(string(result.Currency))
+	}
+	return &OrderResult{PayUrl: result.URL}, nil
+}
+
+func (*StripeProvider) VerifyCallback(c *gin.Context) (*Event, error) {
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("解析Stripe Webhook参数失败: %w", err)
+	}
+
+	signature := c.GetHeader("Stripe-Signature")
+	event, err := webhook.ConstructEventWithOptions(payload, signature, setting.StripeWebhookSecret, webhook.ConstructEventOptions{
+		IgnoreAPIVersionMismatch: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Stripe Webhook验签失败: %w", err)
+	}
+
+	referenceId := event.GetObjectValue("client_reference_id")
+	status := event.GetObjectValue("status")
+	switch event.Type {
+	case stripe.EventTypeCheckoutSessionCompleted:
+		if "complete" != status {
+			common.SysLog(fmt.Sprintf("错误的Stripe Checkout完成状态: %s, %s", status, referenceId))
+			return nil, nil
+		}
+		total, _ := strconv.ParseInt(event.GetObjectValue("amount_total"), 10, 64)
+		currency := strings.ToUpper(event.GetObjectValue("currency"))
+		paidMoney := stripeAmountToMoney(total, currency)
+		common.SysLog(fmt.Sprintf("收到款项：%s, %.2f(%s)", referenceId, paidMoney, currency))
+		return &Event{
+			TradeNo: referenceId,
+			Status:  common.TopUpStatusSuccess,
+			Payment: model.TopUpPayment{
+				PaidMoney:       paidMoney,
+				Currency:        currency,
+				ProviderTradeNo: event.GetObjectValue("id"),
+				CustomerId:      event.GetObjectValue("customer"),
+			},
+		}, nil
+	case stripe.EventTypeCheckoutSessionExpired:
+		if "expired" != status {
+			common.SysLog(fmt.Sprintf("错误的Stripe Checkout过期状态: %s, %s", status, referenceId))
+			return nil, nil
+		}
+		return &Event{TradeNo: referenceId, Status: common.TopUpStatusExpired}, nil
+	default:
+		common.SysLog("不支持的Stripe Webhook事件类型: " + string(event.Type))
+		return nil, nil
+	}
+}
+
+func (*StripeProvider) WriteCallbackResponse(c *gin.Context, err error) {
+	if err != nil && !errors.Is(err, model.ErrTopUpNotFound) {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func getStripeSession(topUp *model.TopUp) (*stripe.CheckoutSession, error) {
+	if topUp.ProviderTradeNo == "" {
+		return nil, errors.New("订单未记录 Stripe Checkout 会话")
+	}
+	stripe.Key = setting.StripeApiSecret
+	return session.Get(topUp.ProviderTradeNo, nil)
+}
+
+func (*StripeProvider) QueryOrder(topUp *model.TopUp) (*Event, error) {
+	s, err := getStripeSession(topUp)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case s.Status == stripe.CheckoutSessionStatusComplete && s.PaymentStatus == stripe.CheckoutSessionPaymentStatusPaid:
+		payment := model.TopUpPayment{
+			PaidMoney:       stripeAmountToMoney(s.AmountTotal, string(s.Currency)),
+			Currency:        strings.ToUpper(string(s.Currency)),
+			ProviderTradeNo: s.ID,
+		}
+		if s.Customer != nil {
+			payment.CustomerId = s.Customer.ID
+		}
+		return &Event{Status: common.TopUpStatusSuccess, Payment: payment}, nil
+	case s.Status == stripe.CheckoutSessionStatusExpired:
+		return &Event{Status: common.TopUpStatusExpired}, nil
+	default:
+		return &Event{Status: common.TopUpStatusPending}, nil
+	}
+}
+
+func (*StripeProvider) Refund(topUp *model.TopUp, reason string) error {
+	s, err := getStripeSession(topUp)
+	if err != nil {
+		return err
+	}
+	if s.PaymentIntent == nil {
+		return errors.New("Stripe 订单未找到支付记录")
+	}
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(s.PaymentIntent.ID),
+		Reason:        stripe.String(string(stripe.RefundReasonRequestedByCustomer)),
+	}
+	_, err = refund.New(params)
+	return err
+}
@@ -0,0 +1,269 @@
+package payment
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/payment_setting"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const PaymentProviderWeChatPay = "wechat"
+
+const weChatPayApiBase = "https://api.mch.weixin.qq.com"
+
+// WeChatPayProvider 微信支付 APIv3 Native 支付
+type WeChatPayProvider struct {
+}
+
+type weChatPayTransaction struct {
+	OutTradeNo    string `json:"out_trade_no"`
+	TransactionId string `json:"transaction_id"`
+	TradeState    string `json:"trade_state"`
+	Amount        struct {
+		Total         int64  `json:"total"`
+		PayerTotal    int64  `json:"payer_total"`
+		Currency      string `json:"currency"`
+		PayerCurrency string `json:"payer_currency"`
+	} `json:"amount"`
+}
+
+type weChatPayNotify struct {
+	EventType string `json:"event_type"`
+	Resource  struct {
+		Algorithm      string `json:"algorithm"`
+		Ciphertext     string `json:"ciphertext"`
+		AssociatedData string `json:"associated_data"`
+		Nonce          string `json:"nonce"`
+	} `json:"resource"`
+}
+
+func (*WeChatPayProvider) Name() string {
+	return PaymentProviderWeChatPay
+}
+
+func (*WeChatPayProvider) Enabled() bool {
+	s := payment_setting.GetWeChatPaySetting()
+	return s.Enabled && s.AppId != "" && s.MchId != "" && s.MchPrivateKey != "" && s.ApiV3Key != "" && s.PlatformPublicKey != ""
+}
+
+func (*WeChatPayProvider) GetMinTopUp() int64 {
+	return toMinTopUp(payment_setting.GetWeChatPaySetting().MinTopUp)
+}
+
+func (*WeChatPayProvider) Quote(amount int64, group string) (*Quote, error) {
+	return standardQuote(amount, group, payment_setting.GetWeChatPaySetting().UnitPrice, "CNY")
+}
+
+func toFen(money float64) int64 {
+	return int64(math.Round(money * 100))
+}
+
+// weChatPayRequest 以商户私钥签名调用微信支付 APIv3
+func weChatPayRequest(method string, path string, body any, result any) error {
+	s := payment_setting.GetWeChatPaySetting()
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = common.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := common.GetRandomString(32)
+	message := fmt.Sprintf("%s\n%s\n%s\n%s\n%s\n", method, path, timestamp, nonce, payload)
+	signature, err := signSHA256WithRSA(s.MchPrivateKey, message)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, weChatPayApiBase+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf(`WECHATPAY2-SHA256-RSA2048 mchid="%s",nonce_str="%s",signature="%s",timestamp="%s",serial_no="%s"`,
+		s.MchId, nonce, signature, timestamp, s.MchCertSerialNo))
+	resp, err := service.GetHttpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var errResp struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		_ = common.Unmarshal(respBody, &errResp)
+		return &weChatPayError{StatusCode: resp.StatusCode, Code: errResp.Code, Message: errResp.Message}
+	}
+	if result == nil || len(respBody) == 0 {
+		return nil
+	}
+	return common.Unmarshal(respBody, result)
+}
+
+type weChatPayError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *weChatPayError) Error() string {
+	return fmt.Sprintf("微信支付接口返回错误：%d %s %s", e.StatusCode, e.Code, e.Message)
+}
+
+func (*WeChatPayProvider) CreateOrder(c *gin.Context, req *OrderRequest, topUp *model.TopUp) (*OrderResult, error) {
+	s := payment_setting.GetWeChatPaySetting()
+	body := map[string]any{
+		"appid":        s.AppId,
+		"mchid":        s.MchId,
+		"description":  fmt.Sprintf("TUC%d", req.Amount),
+		"out_trade_no": topUp.TradeNo,
+		"notify_url":   service.GetCallbackAddress() + "/api/payment/wechat/notify",
+		"amount": map[string]any{
+			"total":    toFen(topUp.Money),
+			"currency": "CNY",
+		},
+	}
+	var result struct {
+		CodeUrl string `json:"code_url"`
+	}
+	if err := weChatPayRequest(http.MethodPost, "/v3/pay/transactions/native", body, &result); err != nil {
+		common.SysError("failed to create wechat pay order: " + err.Error())
+		return nil, errors.New("拉起支付失败")
+	}
+	return &OrderResult{QrCode: result.CodeUrl}, nil
+}
+
+func weChatPayStatusToTopUpStatus(tradeState string) string {
+	switch tradeState {
+	case "SUCCESS":
+		return common.TopUpStatusSuccess
+	case "CLOSED", "REVOKED":
+		return common.TopUpStatusCancelled
+	case "PAYERROR":
+		return common.TopUpStatusFailed
+	default:
+		return common.TopUpStatusPending
+	}
+}
+
+func transactionToEvent(transaction *weChatPayTransaction) *Event {
+	return &Event{
+		TradeNo: transaction.OutTradeNo,
+		Status:  weChatPayStatusToTopUpStatus(transaction.TradeState),
+		Payment: model.TopUpPayment{
+			PaidMoney:       float64(transaction.Amount.Total) / 100,
+			Currency:        transaction.Amount.Currency,
+			ProviderTradeNo: transaction.TransactionId,
+		},
+	}
+}
+
+func decryptWeChatPayResource(apiV3Key string, ciphertext string, associatedData string, nonce string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher([]byte(apiV3Key))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, []byte(nonce), data, []byte(associatedData))
+}
+
+func (*WeChatPayProvider) VerifyCallback(c *gin.Context) (*Event, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	s := payment_setting.GetWeChatPaySetting()
+	timestamp := c.GetHeader("Wechatpay-Timestamp")
+	nonce := c.GetHeader("Wechatpay-Nonce")
+	message := fmt.Sprintf("%s\n%s\n%s\n", timestamp, nonce, body)
+	if err = verifySHA256WithRSA(s.PlatformPublicKey, message, c.GetHeader("Wechatpay-Signature")); err != nil {
+		return nil, errors.New("微信支付回调签名验证失败")
+	}
+	if ts, _ := strconv.ParseInt(timestamp, 10, 64); math.Abs(float64(time.Now().Unix()-ts)) > 300 {
+		return nil, errors.New("微信支付回调时间戳已过期")
+	}
+	var notify weChatPayNotify
+	if err = common.Unmarshal(body, &notify); err != nil {
+		return nil, err
+	}
+	if notify.EventType != "TRANSACTION.SUCCESS" {
+		return nil, nil
+	}
+	plain, err := decryptWeChatPayResource(s.ApiV3Key, notify.Resource.Ciphertext, notify.Resource.AssociatedData, notify.Resource.Nonce)
+	if err != nil {
+		return nil, errors.New("微信支付回调解密失败")
+	}
+	var transaction weChatPayTransaction
+	if err = common.Unmarshal(plain, &transaction); err != nil {
+		return nil, err
+	}
+	return transactionToEvent(&transaction), nil
+}
+
+func (*WeChatPayProvider) WriteCallbackResponse(c *gin.Context, err error) {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": "FAIL", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": "SUCCESS", "message": ""})
+}
+
+func (*WeChatPayProvider) QueryOrder(topUp *model.TopUp) (*Event, error) {
+	s := payment_setting.GetWeChatPaySetting()
+	path := "/v3/pay/transactions/out-trade-no/" + url.PathEscape(topUp.TradeNo) + "?mchid=" + url.QueryEscape(s.MchId)
+	var transaction weChatPayTransaction
+	err := weChatPayRequest(http.MethodGet, path, nil, &transaction)
+	if err != nil {
+		var weChatErr *weChatPayError
+		if errors.As(err, &weChatErr) && weChatErr.Code == "ORDER_NOT_EXIST" {
+			return &Event{Status: common.TopUpStatusPending}, nil
+		}
+		return nil, err
+	}
+	return transactionToEvent(&transaction), nil
+}
+
+func (*WeChatPayProvider) Refund(topUp *model.TopUp, reason string) error {
+	total := toFen(topUp.Money)
+	body := map[string]any{
+		"out_trade_no":  topUp.TradeNo,
+		"out_refund_no": topUp.TradeNo + "R",
+		"amount": map[string]any{
+			"refund":   total,
+			"total":    total,
+			"currency": "CNY",
+		},
+	}
+	if reason != "" {
+		body["reason"] = reason
+	}
+	return weChatPayRequest(http.MethodPost, "/v3/refund/domestic/refunds", body, nil)
+}
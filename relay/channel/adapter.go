@@ -48,3 +48,21 @@ type TaskAdaptor interface {
 
 	ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error)
 }
+
+// TaskCallbackAdaptor 由支持上游主动推送任务状态的平台实现，
+// 提交时通过 TaskRelayInfo.UpstreamCallbackUrl 把推送地址传给上游
+type TaskCallbackAdaptor interface {
+	// ParseTaskCallback 解析上游推送的请求体，返回任务状态与需要保存的任务数据（与 FetchTask 响应格式一致）
+	ParseTaskCallback(body []byte) (taskInfo *relaycommon.TaskInfo, taskData []byte, err error)
+}
+
+// TaskSettlementAdaptor 由按生成结果数量计费的平台实现，任务成功但部分结果生成失败时按比例退款
+type TaskSettlementAdaptor interface {
+	// CountTaskItems 从任务数据中统计结果总数与失败数，无法统计时 total 返回 0
+	CountTaskItems(taskData []byte) (total int, failed int)
+}
+
+// TaskAssetAdaptor 由结果文件地址保存在任务数据中的平台实现，开启结果转存时下载这些文件
+type TaskAssetAdaptor interface {
+	GetTaskAssetUrls(taskData []byte) []string
+}
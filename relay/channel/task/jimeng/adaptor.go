@@ -207,6 +207,40 @@ func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any) (*http
 	return service.GetHttpClient().Do(req)
 }
 
+// probeChannel 查询一个不存在的任务，签名或密钥错误时火山引擎在 ResponseMetadata 中返回错误，
+// 任务不存在属于业务错误，说明密钥可用
+func probeChannel(baseUrl, key string) error {
+	resp, err := (&TaskAdaptor{}).FetchTask(baseUrl, key, map[string]any{
+		"task_id": "0",
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var probeResponse struct {
+		ResponseMetadata struct {
+			Error *struct {
+				Code    string `json:"Code"`
+				Message string `json:"Message"`
+			} `json:"Error"`
+		} `json:"ResponseMetadata"`
+	}
+	if err := json.Unmarshal(responseBody, &probeResponse); err != nil {
+		return fmt.Errorf("status code: %d, body: %s", resp.StatusCode, string(responseBody))
+	}
+	if metaErr := probeResponse.ResponseMetadata.Error; metaErr != nil && metaErr.Code != "" {
+		return fmt.Errorf("status code: %d, code: %s, message: %s", resp.StatusCode, metaErr.Code, metaErr.Message)
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden || resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("status code: %d, body: %s", resp.StatusCode, string(responseBody))
+	}
+	return nil
+}
+
 func (a *TaskAdaptor) GetModelList() []string {
 	return []string{"jimeng_vgfm_t2v_l20"}
 }
@@ -0,0 +1,20 @@
+package jimeng
+
+import (
+	"one-api/constant"
+	"one-api/relay/channel"
+)
+
+func init() {
+	channel.RegisterTaskProvider(&channel.TaskProvider{
+		Platform:      constant.TaskPlatformJimeng,
+		Video:         true,
+		ModelPrefixes: []string{"jimeng"},
+		ChannelTypes:  []int{constant.ChannelTypeJimeng},
+		PollMode:      channel.TaskPollSingle,
+		Probe:         probeChannel,
+		New: func() channel.TaskAdaptor {
+			return &TaskAdaptor{}
+		},
+	})
+}
@@ -45,26 +45,30 @@ type requestPayload struct {
 	AspectRatio string  `json:"aspect_ratio,omitempty"`
 	ModelName   string  `json:"model_name,omitempty"`
 	CfgScale    float64 `json:"cfg_scale,omitempty"`
+	CallbackUrl string  `json:"callback_url,omitempty"`
 }
 
 type responsePayload struct {
-	Code      int    `json:"code"`
-	Message   string `json:"message"`
-	RequestId string `json:"request_id"`
-	Data      struct {
-		TaskId        string `json:"task_id"`
-		TaskStatus    string `json:"task_status"`
-		TaskStatusMsg string `json:"task_status_msg"`
-		TaskResult    struct {
-			Videos []struct {
-				Id       string `json:"id"`
-				Url      string `json:"url"`
-				Duration string `json:"duration"`
-			} `json:"videos"`
-		} `json:"task_result"`
-		CreatedAt int64 `json:"created_at"`
-		UpdatedAt int64 `json:"updated_at"`
-	} `json:"data"`
+	Code      int          `json:"code"`
+	Message   string       `json:"message"`
+	RequestId string       `json:"request_id"`
+	Data      responseData `json:"data"`
+}
+
+// responseData 任务信息，上游回调推送的请求体即为该结构
+type responseData struct {
+	TaskId        string `json:"task_id"`
+	TaskStatus    string `json:"task_status"`
+	TaskStatusMsg string `json:"task_status_msg"`
+	TaskResult    struct {
+		Videos []struct {
+			Id       string `json:"id"`
+			Url      string `json:"url"`
+			Duration string `json:"duration"`
+		} `json:"videos"`
+	} `json:"task_result"`
+	CreatedAt int64 `json:"created_at"`
+	UpdatedAt int64 `json:"updated_at"`
 }
 
 // ============================
@@ -143,6 +147,7 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.TaskRel
 	if err != nil {
 		return nil, err
 	}
+	body.CallbackUrl = info.UpstreamCallbackUrl
 	data, err := json.Marshal(body)
 	if err != nil {
 		return nil, err
@@ -219,6 +224,42 @@ func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any) (*http
 	return service.GetHttpClient().Do(req)
 }
 
+// probeChannel 查询最近一条文生视频任务，校验地址与密钥是否可用
+func probeChannel(baseUrl, key string) error {
+	req, err := http.NewRequest(http.MethodGet, baseUrl+"/v1/videos/text2video?pageNum=1&pageSize=1", nil)
+	if err != nil {
+		return err
+	}
+	a := &TaskAdaptor{}
+	token, err := a.createJWTTokenWithKey(key)
+	if err != nil {
+		token = key
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("User-Agent", "kling-sdk/1.0")
+	resp, err := service.GetHttpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var listResponse struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(responseBody, &listResponse); err != nil {
+		return fmt.Errorf("status code: %d, body: %s", resp.StatusCode, string(responseBody))
+	}
+	if resp.StatusCode != http.StatusOK || listResponse.Code != 0 {
+		return fmt.Errorf("status code: %d, code: %d, message: %s", resp.StatusCode, listResponse.Code, listResponse.Message)
+	}
+	return nil
+}
+
 func (a *TaskAdaptor) GetModelList() []string {
 	return []string{"kling-v1", "kling-v1-6", "kling-v2-master"}
 }
@@ -320,6 +361,30 @@ func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, e
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to unmarshal response body")
 	}
+	return parseResponsePayload(&resPayload)
+}
+
+// ParseTaskCallback 回调请求体只包含任务信息，包装为查询响应格式后保存，便于统一解析
+func (a *TaskAdaptor) ParseTaskCallback(body []byte) (*relaycommon.TaskInfo, []byte, error) {
+	resPayload := responsePayload{}
+	if err := json.Unmarshal(body, &resPayload.Data); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to unmarshal callback body")
+	}
+	if resPayload.Data.TaskId == "" {
+		return nil, nil, fmt.Errorf("task_id is empty")
+	}
+	taskInfo, err := parseResponsePayload(&resPayload)
+	if err != nil {
+		return nil, nil, err
+	}
+	taskData, err := json.Marshal(resPayload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return taskInfo, taskData, nil
+}
+
+func parseResponsePayload(resPayload *responsePayload) (*relaycommon.TaskInfo, error) {
 	taskInfo := &relaycommon.TaskInfo{}
 	taskInfo.Code = resPayload.Code
 	taskInfo.TaskID = resPayload.Data.TaskId
@@ -335,6 +400,9 @@ func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, e
 		taskInfo.Status = model.TaskStatusSuccess
 	case "failed":
 		taskInfo.Status = model.TaskStatusFailure
+		if resPayload.Data.TaskStatusMsg != "" {
+			taskInfo.Reason = resPayload.Data.TaskStatusMsg
+		}
 	default:
 		return nil, fmt.Errorf("unknown task status: %s", status)
 	}
@@ -1,16 +1,38 @@
-package middleware
+package kling
 
 import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"net/http"
 	"one-api/common"
 	"one-api/constant"
+	"one-api/relay/channel"
+	relayconstant "one-api/relay/constant"
 
 	"github.com/gin-gonic/gin"
 )
 
-func KlingRequestConvert() func(c *gin.Context) {
+func init() {
+	channel.RegisterTaskProvider(&channel.TaskProvider{
+		Platform:      constant.TaskPlatformKling,
+		Video:         true,
+		ModelPrefixes: []string{"kling"},
+		ChannelTypes:  []int{constant.ChannelTypeKling},
+		Routes: []channel.TaskRoute{
+			{Method: http.MethodPost, Path: "/kling/v1/videos/text2video", RelayMode: relayconstant.RelayModeVideoSubmit, Middlewares: []gin.HandlerFunc{RequestConvert()}},
+			{Method: http.MethodPost, Path: "/kling/v1/videos/image2video", RelayMode: relayconstant.RelayModeVideoSubmit, Middlewares: []gin.HandlerFunc{RequestConvert()}},
+		},
+		PollMode: channel.TaskPollSingle,
+		Probe:    probeChannel,
+		New: func() channel.TaskAdaptor {
+			return &TaskAdaptor{}
+		},
+	})
+}
+
+// RequestConvert 将可灵原生请求转换为统一视频接口格式
+func RequestConvert() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var originalReq map[string]interface{}
 		if err := common.UnmarshalBodyReusable(c, &originalReq); err != nil {
@@ -158,6 +158,63 @@ func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any) (*http
 	return resp, nil
 }
 
+// probeChannel 以空任务列表调用查询接口，校验地址与密钥是否可用
+func probeChannel(baseUrl, key string) error {
+	resp, err := (&TaskAdaptor{}).FetchTask(baseUrl, key, map[string]any{
+		"ids": []string{},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code: %d, body: %s", resp.StatusCode, string(responseBody))
+	}
+	var fetchResponse dto.TaskResponse[[]dto.SunoDataResponse]
+	if err := json.Unmarshal(responseBody, &fetchResponse); err != nil {
+		return fmt.Errorf("parse body error: %v, body: %s", err, string(responseBody))
+	}
+	if !fetchResponse.IsSuccess() {
+		return fmt.Errorf("fetch failed: %s", fetchResponse.Message)
+	}
+	return nil
+}
+
+// CountTaskItems 一次生成通常返回多首歌曲，状态为 error 的歌曲按比例退款
+func (a *TaskAdaptor) CountTaskItems(taskData []byte) (total int, failed int) {
+	var songs []dto.SunoSong
+	if err := json.Unmarshal(taskData, &songs); err != nil {
+		return 0, 0
+	}
+	for _, song := range songs {
+		total++
+		if song.Status == "error" {
+			failed++
+		}
+	}
+	return total, failed
+}
+
+// GetTaskAssetUrls 返回成功歌曲的音频、封面与视频地址
+func (a *TaskAdaptor) GetTaskAssetUrls(taskData []byte) []string {
+	var songs []dto.SunoSong
+	if err := json.Unmarshal(taskData, &songs); err != nil {
+		return nil
+	}
+	urls := make([]string, 0, len(songs)*3)
+	for _, song := range songs {
+		if song.Status == "error" {
+			continue
+		}
+		urls = append(urls, song.AudioURL, song.ImageURL, song.VideoURL)
+	}
+	return urls
+}
+
 func actionValidate(c *gin.Context, sunoRequest *dto.SunoSubmitReq, action string) (err error) {
 	switch action {
 	case constant.SunoActionMusic:
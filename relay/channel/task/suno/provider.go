@@ -0,0 +1,31 @@
+package suno
+
+import (
+	"net/http"
+	"one-api/constant"
+	"one-api/relay/channel"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	channel.RegisterTaskProvider(&channel.TaskProvider{
+		Platform:     constant.TaskPlatformSuno,
+		ChannelTypes: []int{constant.ChannelTypeSunoAPI},
+		Routes: []channel.TaskRoute{
+			{Method: http.MethodPost, Path: "/suno/submit/:action", RelayMode: relayconstant.RelayModeSunoSubmit},
+			{Method: http.MethodPost, Path: "/suno/fetch", RelayMode: relayconstant.RelayModeSunoFetch, Fetch: true},
+			{Method: http.MethodGet, Path: "/suno/fetch/:id", RelayMode: relayconstant.RelayModeSunoFetchByID, Fetch: true},
+		},
+		ModelName: func(c *gin.Context) string {
+			return service.CoverTaskActionToModelName(constant.TaskPlatformSuno, c.Param("action"))
+		},
+		PollMode: channel.TaskPollBatch,
+		Probe:    probeChannel,
+		New: func() channel.TaskAdaptor {
+			return &TaskAdaptor{}
+		},
+	})
+}
@@ -0,0 +1,143 @@
+package channel
+
+import (
+	"fmt"
+	"one-api/constant"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TaskPollMode 任务进度的轮询方式
+type TaskPollMode int
+
+const (
+	// TaskPollSingle 逐个任务调用 FetchTask 查询，并由 ParseTaskResult 解析状态，新接入的视频平台默认使用
+	TaskPollSingle TaskPollMode = iota
+	// TaskPollBatch 按渠道一次性调用 FetchTask 批量查询，响应为 Suno 格式的任务列表
+	TaskPollBatch
+	// TaskPollNone 不参与任务轮询，任务状态只由上游推送更新
+	TaskPollNone
+	// TaskPollCustom 平台协议与 TaskAdaptor 不同，由 controller 中按平台注册的查询函数处理（如 Midjourney）
+	TaskPollCustom
+)
+
+// TaskRoute 平台原生接口路由，由 router 统一注册
+type TaskRoute struct {
+	Method    string
+	Path      string
+	RelayMode int
+	// Fetch 为 true 时只查询本地任务，不选择渠道
+	Fetch bool
+	// Middlewares 在令牌鉴权之前执行，通常用于把原生请求转换为统一格式
+	Middlewares []gin.HandlerFunc
+}
+
+// TaskProvider 描述一个异步任务平台：路由、模型与轮询方式
+type TaskProvider struct {
+	Platform constant.TaskPlatform
+	// Video 为 true 时参与统一视频接口 /v1/video/generations 的模型匹配
+	Video bool
+	// ModelPrefixes 统一视频接口按模型名前缀匹配平台，同时也会精确匹配 GetModelList 中的模型
+	ModelPrefixes []string
+	// ChannelTypes 模型名无法匹配时，按所选渠道的类型确定平台
+	ChannelTypes []int
+	Routes       []TaskRoute
+	// ModelName 从原生接口请求中解析模型名，为空时读取请求体中的 model 字段
+	ModelName func(c *gin.Context) string
+	PollMode  TaskPollMode
+	// New 创建任务适配器，不通过 TaskAdaptor 转发的平台为空
+	New func() TaskAdaptor
+	// Probe 渠道测试时调用的轻量接口（账户、任务列表等），不提交任务也不产生费用，为空时不支持测试
+	Probe func(baseUrl, key string) error
+
+	models []string
+}
+
+var (
+	taskProviders     []*TaskProvider
+	taskProviderMap   = make(map[constant.TaskPlatform]*TaskProvider)
+	taskProviderMutex sync.RWMutex
+)
+
+// RegisterTaskProvider 注册异步任务平台，通常在平台包的 init 中调用
+func RegisterTaskProvider(provider *TaskProvider) {
+	taskProviderMutex.Lock()
+	defer taskProviderMutex.Unlock()
+	if _, ok := taskProviderMap[provider.Platform]; ok {
+		panic(fmt.Sprintf("task platform %s already registered", provider.Platform))
+	}
+	if provider.New != nil {
+		provider.models = provider.New().GetModelList()
+	}
+	taskProviders = append(taskProviders, provider)
+	taskProviderMap[provider.Platform] = provider
+}
+
+func GetTaskProvider(platform constant.TaskPlatform) *TaskProvider {
+	taskProviderMutex.RLock()
+	defer taskProviderMutex.RUnlock()
+	return taskProviderMap[platform]
+}
+
+// GetTaskProviders 按注册顺序返回全部任务平台
+func GetTaskProviders() []*TaskProvider {
+	taskProviderMutex.RLock()
+	defer taskProviderMutex.RUnlock()
+	providers := make([]*TaskProvider, len(taskProviders))
+	copy(providers, taskProviders)
+	return providers
+}
+
+// MatchVideoTaskProvider 根据模型名选择统一视频接口使用的平台，精确匹配优先于前缀匹配
+func MatchVideoTaskProvider(modelName string) *TaskProvider {
+	if modelName == "" {
+		return nil
+	}
+	providers := GetTaskProviders()
+	for _, provider := range providers {
+		if !provider.Video {
+			continue
+		}
+		for _, m := range provider.models {
+			if m == modelName {
+				return provider
+			}
+		}
+	}
+	for _, provider := range providers {
+		if !provider.Video {
+			continue
+		}
+		for _, prefix := range provider.ModelPrefixes {
+			if strings.HasPrefix(modelName, prefix) {
+				return provider
+			}
+		}
+	}
+	return nil
+}
+
+// GetTaskProviderByChannelType 返回支持该渠道类型的任务平台
+func GetTaskProviderByChannelType(channelType int) *TaskProvider {
+	for _, provider := range GetTaskProviders() {
+		for _, t := range provider.ChannelTypes {
+			if t == channelType {
+				return provider
+			}
+		}
+	}
+	return nil
+}
+
+// GetPolledTaskPlatforms 返回需要参与通用任务轮询的平台
+func GetPolledTaskPlatforms() []constant.TaskPlatform {
+	platforms := make([]constant.TaskPlatform, 0)
+	for _, provider := range GetTaskProviders() {
+		if provider.PollMode == TaskPollCustom || (provider.PollMode != TaskPollNone && provider.New != nil) {
+			platforms = append(platforms, provider.Platform)
+		}
+	}
+	return platforms
+}
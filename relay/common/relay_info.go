@@ -64,7 +64,9 @@ type RelayInfo struct {
 	ChannelId         int
 	TokenId           int
 	TokenKey          string
+	TokenKeyHash      string // 额度缓存使用的令牌哈希
 	UserId            int
+	OrganizationId    int    // 令牌绑定的组织，非 0 时从组织额度池扣费
 	UsingGroup        string // 使用的分组
 	UserGroup         string // 用户所在分组
 	TokenUnlimited    bool
@@ -237,7 +239,9 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 		ChannelId:         channelId,
 		TokenId:           tokenId,
 		TokenKey:          tokenKey,
+		TokenKeyHash:      common.GetContextKeyString(c, constant.ContextKeyTokenKeyHash),
 		UserId:            userId,
+		OrganizationId:    common.GetContextKeyInt(c, constant.ContextKeyTokenOrganizationId),
 		UsingGroup:        common.GetContextKeyString(c, constant.ContextKeyUsingGroup),
 		UserGroup:         common.GetContextKeyString(c, constant.ContextKeyUserGroup),
 		TokenUnlimited:    tokenUnlimited,
@@ -313,6 +317,8 @@ type TaskRelayInfo struct {
 	*RelayInfo
 	Action       string
 	OriginTaskID string
+	// 上游支持主动推送时，提交请求中携带的推送地址
+	UpstreamCallbackUrl string
 
 	ConsumeQuota bool
 }
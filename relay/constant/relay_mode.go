@@ -40,11 +40,10 @@ const (
 	RelayModeSunoFetchByID
 	RelayModeSunoSubmit
 
-	RelayModeKlingFetchByID
-	RelayModeKlingSubmit
+	RelayModeVideoFetchByID
+	RelayModeVideoSubmit
 
-	RelayModeJimengFetchByID
-	RelayModeJimengSubmit
+	RelayModeTaskFetchByID
 
 	RelayModeRerank
 
@@ -134,34 +133,15 @@ func Path2RelayModeMidjourney(path string) int {
 	return relayMode
 }
 
-func Path2RelaySuno(method, path string) int {
-	relayMode := RelayModeUnknown
-	if method == http.MethodPost && strings.HasSuffix(path, "/fetch") {
-		relayMode = RelayModeSunoFetch
-	} else if method == http.MethodGet && strings.Contains(path, "/fetch/") {
-		relayMode = RelayModeSunoFetchByID
-	} else if strings.Contains(path, "/submit/") {
-		relayMode = RelayModeSunoSubmit
-	}
-	return relayMode
-}
-
-func Path2RelayKling(method, path string) int {
-	relayMode := RelayModeUnknown
-	if method == http.MethodPost && strings.HasSuffix(path, "/video/generations") {
-		relayMode = RelayModeKlingSubmit
-	} else if method == http.MethodGet && strings.Contains(path, "/video/generations/") {
-		relayMode = RelayModeKlingFetchByID
-	}
-	return relayMode
-}
-
-func Path2RelayJimeng(method, path string) int {
+// Path2RelayVideo 统一视频接口 /v1/video/generations 与任务查询接口 /v1/tasks/:task_id
+func Path2RelayVideo(method, path string) int {
 	relayMode := RelayModeUnknown
 	if method == http.MethodPost && strings.HasSuffix(path, "/video/generations") {
-		relayMode = RelayModeJimengSubmit
+		relayMode = RelayModeVideoSubmit
 	} else if method == http.MethodGet && strings.Contains(path, "/video/generations/") {
-		relayMode = RelayModeJimengFetchByID
+		relayMode = RelayModeVideoFetchByID
+	} else if method == http.MethodGet && strings.Contains(path, "/tasks/") {
+		relayMode = RelayModeTaskFetchByID
 	}
 	return relayMode
 }
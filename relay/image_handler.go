@@ -170,7 +170,7 @@ func ImageHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 		// reset model price
 		priceData.ModelPrice *= sizeRatio * qualityRatio * float64(imageRequest.N)
 		quota = int(priceData.ModelPrice * priceData.GroupRatioInfo.GroupRatio * common.QuotaPerUnit)
-		userQuota, err = model.GetUserQuota(relayInfo.UserId, false)
+		userQuota, err = model.GetBillingQuota(relayInfo.UserId, relayInfo.OrganizationId)
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeQueryDataError)
 		}
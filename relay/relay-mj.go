@@ -2,6 +2,7 @@ package relay
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
+	"one-api/relay/channel"
 	relaycommon "one-api/relay/common"
 	relayconstant "one-api/relay/constant"
 	"one-api/relay/helper"
@@ -23,6 +25,45 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+func init() {
+	// Midjourney 使用独立的 /mj 接口与批量查询，任务统一保存在 tasks 表中
+	channel.RegisterTaskProvider(&channel.TaskProvider{
+		Platform:     constant.TaskPlatformMidjourney,
+		ChannelTypes: []int{constant.ChannelTypeMidjourney, constant.ChannelTypeMidjourneyPlus},
+		PollMode:     channel.TaskPollCustom,
+		Probe:        probeMidjourneyChannel,
+	})
+}
+
+// probeMidjourneyChannel 以空任务列表调用 list-by-condition，校验地址与 mj-api-secret 是否可用
+func probeMidjourneyChannel(baseUrl, key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseUrl+"/mj/task/list-by-condition", strings.NewReader(`{"ids":[]}`))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("mj-api-secret", key)
+	resp, err := service.GetHttpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code: %d, body: %s", resp.StatusCode, string(responseBody))
+	}
+	var responseItems []dto.MidjourneyDto
+	if err := json.Unmarshal(responseBody, &responseItems); err != nil {
+		return fmt.Errorf("parse body error: %v, body: %s", err, string(responseBody))
+	}
+	return nil
+}
+
 func RelayMidjourneyImage(c *gin.Context) {
 	taskId := c.Param("id")
 	midjourneyTask := model.GetByOnlyMJId(taskId)
@@ -32,6 +73,11 @@ func RelayMidjourneyImage(c *gin.Context) {
 		})
 		return
 	}
+	// 已转存的图片直接跳转到网关存储
+	if assetUrl, ok := service.GetTaskAssetUrlMap(int64(midjourneyTask.Id))[midjourneyTask.ImageUrl]; ok {
+		c.Redirect(http.StatusFound, assetUrl)
+		return
+	}
 	var httpClient *http.Client
 	if channel, err := model.CacheGetChannel(midjourneyTask.ChannelId); err == nil {
 		proxy := channel.GetSetting().Proxy
@@ -98,6 +144,20 @@ func RelayMidjourneyNotify(c *gin.Context) *dto.MidjourneyResponse {
 			Result:      "",
 		}
 	}
+	err = ApplyMidjourneyNotify(midjourneyTask, midjRequest)
+	if err != nil {
+		return &dto.MidjourneyResponse{
+			Code:        4,
+			Description: "update_midjourney_task_failed",
+		}
+	}
+
+	return nil
+}
+
+// ApplyMidjourneyNotify 根据上游推送的任务信息更新 Midjourney 任务，任务失败时退还额度
+func ApplyMidjourneyNotify(midjourneyTask *model.Midjourney, midjRequest dto.MidjourneyDto) error {
+	oldStatus := midjourneyTask.Status
 	midjourneyTask.Progress = midjRequest.Progress
 	midjourneyTask.PromptEn = midjRequest.PromptEn
 	midjourneyTask.State = midjRequest.State
@@ -110,14 +170,29 @@ func RelayMidjourneyNotify(c *gin.Context) *dto.MidjourneyResponse {
 	midjourneyTask.VideoUrls = string(videoUrlsStr)
 	midjourneyTask.Status = midjRequest.Status
 	midjourneyTask.FailReason = midjRequest.FailReason
-	err = midjourneyTask.Update()
-	if err != nil {
-		return &dto.MidjourneyResponse{
-			Code:        4,
-			Description: "update_midjourney_task_failed",
+	if midjRequest.Buttons != nil {
+		buttonStr, _ := json.Marshal(midjRequest.Buttons)
+		midjourneyTask.Buttons = string(buttonStr)
+	}
+	if midjRequest.Properties != nil {
+		propertiesStr, _ := json.Marshal(midjRequest.Properties)
+		midjourneyTask.Properties = string(propertiesStr)
+	}
+	if midjourneyTask.Status == "FAILURE" {
+		midjourneyTask.Progress = "100%"
+	}
+	if err := midjourneyTask.Update(); err != nil {
+		return err
+	}
+	if midjourneyTask.Status == "FAILURE" && midjourneyTask.Quota != 0 {
+		if _, err := service.SettleTaskRefund(context.Background(), int64(midjourneyTask.Id), midjourneyTask.Quota, midjourneyTask.FailReason); err != nil {
+			common.SysError("fail to refund midjourney task: " + err.Error())
 		}
 	}
-
+	if midjourneyTask.Status != oldStatus {
+		service.ArchiveMidjourneyTaskAssets(midjourneyTask)
+		service.NotifyMidjourneyStatusChange(midjourneyTask)
+	}
 	return nil
 }
 
@@ -167,6 +242,22 @@ func coverMidjourneyTaskDto(c *gin.Context, originTask *model.Midjourney) (midjo
 			midjourneyTask.Properties = &properties
 		}
 	}
+	// 已转存的结果返回网关签名地址
+	if originTask.Status == "SUCCESS" {
+		if urlMap := service.GetTaskAssetUrlMap(int64(originTask.Id)); len(urlMap) > 0 {
+			if assetUrl, ok := urlMap[originTask.ImageUrl]; ok {
+				midjourneyTask.ImageUrl = assetUrl
+			}
+			if assetUrl, ok := urlMap[originTask.VideoUrl]; ok {
+				midjourneyTask.VideoUrl = assetUrl
+			}
+			for i, videoUrl := range midjourneyTask.VideoUrls {
+				if assetUrl, ok := urlMap[videoUrl.Url]; ok {
+					midjourneyTask.VideoUrls[i].Url = assetUrl
+				}
+			}
+		}
+	}
 	return
 }
 
@@ -185,11 +276,15 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 	if swapFaceRequest.SourceBase64 == "" || swapFaceRequest.TargetBase64 == "" {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "sour_base64_and_target_base64_is_required")
 	}
+	callbackUrl, err := service.GetTaskCallbackUrl(c)
+	if err != nil {
+		return service.MidjourneyErrorWrapper(constant.MjRequestError, "invalid_callback_url")
+	}
 	modelName := service.CoverActionToModelName(constant.MjActionSwapFace)
 
 	priceData := helper.ModelPriceHelperPerCall(c, relayInfo)
 
-	userQuota, err := model.GetUserQuota(userId, false)
+	userQuota, err := model.GetBillingQuota(userId, relayInfo.OrganizationId)
 	if err != nil {
 		return &dto.MidjourneyResponse{
 			Code:        4,
@@ -238,23 +333,26 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 	}()
 	midjResponse := &mjResp.Response
 	midjourneyTask := &model.Midjourney{
-		UserId:      userId,
-		Code:        midjResponse.Code,
-		Action:      constant.MjActionSwapFace,
-		MjId:        midjResponse.Result,
-		Prompt:      "InsightFace",
-		PromptEn:    "",
-		Description: midjResponse.Description,
-		State:       "",
-		SubmitTime:  startTime,
-		StartTime:   time.Now().UnixNano() / int64(time.Millisecond),
-		FinishTime:  0,
-		ImageUrl:    "",
-		Status:      "",
-		Progress:    "0%",
-		FailReason:  "",
-		ChannelId:   c.GetInt("channel_id"),
-		Quota:       priceData.Quota,
+		UserId:         userId,
+		Code:           midjResponse.Code,
+		Action:         constant.MjActionSwapFace,
+		MjId:           midjResponse.Result,
+		Prompt:         "InsightFace",
+		PromptEn:       "",
+		Description:    midjResponse.Description,
+		State:          "",
+		SubmitTime:     startTime,
+		StartTime:      time.Now().UnixNano() / int64(time.Millisecond),
+		FinishTime:     0,
+		ImageUrl:       "",
+		Status:         "",
+		Progress:       "0%",
+		FailReason:     "",
+		ChannelId:      c.GetInt("channel_id"),
+		Quota:          priceData.Quota,
+		OrganizationId: relayInfo.OrganizationId,
+		TokenId:        relayInfo.TokenId,
+		CallbackUrl:    callbackUrl,
 	}
 	err = midjourneyTask.Insert()
 	if err != nil {
@@ -384,6 +482,10 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 	if err != nil {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "bind_request_body_failed")
 	}
+	callbackUrl, err := service.GetTaskCallbackUrl(c)
+	if err != nil {
+		return service.MidjourneyErrorWrapper(constant.MjRequestError, "invalid_callback_url")
+	}
 
 	if relayMode == relayconstant.RelayModeMidjourneyAction { // midjourney plus，需要从customId中获取任务信息
 		mjErr := service.CoverPlusActionToNormalAction(&midjRequest)
@@ -489,7 +591,13 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 
 	baseURL := c.GetString("base_url")
 
-	//midjRequest.NotifyHook = "http://127.0.0.1:3000/mj/notify"
+	// 未使用用户自己的 notifyHook 时，请求上游把任务状态推送到网关
+	var notifyToken string
+	if midjRequest.NotifyHook == "" || !setting.MjNotifyEnabled {
+		var notifyHook string
+		notifyToken, notifyHook = service.GenerateTaskNotifyUrl(constant.TaskPlatformMidjourney)
+		c.Set("mj_notify_hook", notifyHook)
+	}
 
 	fullRequestURL := fmt.Sprintf("%s%s", baseURL, requestURL)
 
@@ -497,7 +605,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 
 	priceData := helper.ModelPriceHelperPerCall(c, relayInfo)
 
-	userQuota, err := model.GetUserQuota(userId, false)
+	userQuota, err := model.GetBillingQuota(userId, relayInfo.OrganizationId)
 	if err != nil {
 		return &dto.MidjourneyResponse{
 			Code:        4,
@@ -552,23 +660,27 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 	// 24-prompt包含敏感词 {"code":24,"description":"可能包含敏感词","properties":{"promptEn":"nude body","bannedWord":"nude"}}
 	// other: 提交错误，description为错误描述
 	midjourneyTask := &model.Midjourney{
-		UserId:      userId,
-		Code:        midjResponse.Code,
-		Action:      midjRequest.Action,
-		MjId:        midjResponse.Result,
-		Prompt:      midjRequest.Prompt,
-		PromptEn:    "",
-		Description: midjResponse.Description,
-		State:       "",
-		SubmitTime:  time.Now().UnixNano() / int64(time.Millisecond),
-		StartTime:   0,
-		FinishTime:  0,
-		ImageUrl:    "",
-		Status:      "",
-		Progress:    "0%",
-		FailReason:  "",
-		ChannelId:   c.GetInt("channel_id"),
-		Quota:       priceData.Quota,
+		UserId:         userId,
+		Code:           midjResponse.Code,
+		Action:         midjRequest.Action,
+		MjId:           midjResponse.Result,
+		Prompt:         midjRequest.Prompt,
+		PromptEn:       "",
+		Description:    midjResponse.Description,
+		State:          "",
+		SubmitTime:     time.Now().UnixNano() / int64(time.Millisecond),
+		StartTime:      0,
+		FinishTime:     0,
+		ImageUrl:       "",
+		Status:         "",
+		Progress:       "0%",
+		FailReason:     "",
+		ChannelId:      c.GetInt("channel_id"),
+		Quota:          priceData.Quota,
+		OrganizationId: relayInfo.OrganizationId,
+		TokenId:        relayInfo.TokenId,
+		CallbackUrl:    callbackUrl,
+		NotifyToken:    notifyToken,
 	}
 	if midjResponse.Code == 3 {
 		//无实例账号自动禁用渠道（No available account instance）
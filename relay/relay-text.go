@@ -356,7 +356,7 @@ func checkRequestSensitive(textRequest *dto.GeneralOpenAIRequest, info *relaycom
 
 // 预扣费并返回用户剩余配额
 func preConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommon.RelayInfo) (int, int, *types.NewAPIError) {
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	userQuota, err := model.GetBillingQuota(relayInfo.UserId, relayInfo.OrganizationId)
 	if err != nil {
 		return 0, 0, types.NewError(err, types.ErrorCodeQueryDataError)
 	}
@@ -390,7 +390,7 @@ func preConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 		if err != nil {
 			return 0, 0, types.NewErrorWithStatusCode(err, types.ErrorCodePreConsumeTokenQuotaFailed, http.StatusForbidden)
 		}
-		err = model.DecreaseUserQuota(relayInfo.UserId, preConsumedQuota)
+		err = model.DecreaseBillingQuota(relayInfo.UserId, relayInfo.OrganizationId, preConsumedQuota)
 		if err != nil {
 			return 0, 0, types.NewError(err, types.ErrorCodeUpdateDataError)
 		}
@@ -641,7 +641,7 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		}
 	}
 
-	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
+	consumeLog := model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     promptTokens,
 		CompletionTokens: completionTokens,
@@ -657,4 +657,5 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		Group:            relayInfo.UsingGroup,
 		Other:            other,
 	})
+	service.AutoRefundIfNeeded(ctx, relayInfo, consumeLog, quota, completionTokens)
 }
@@ -24,9 +24,10 @@ import (
 	"one-api/relay/channel/palm"
 	"one-api/relay/channel/perplexity"
 	"one-api/relay/channel/siliconflow"
-	taskjimeng "one-api/relay/channel/task/jimeng"
-	"one-api/relay/channel/task/kling"
-	"one-api/relay/channel/task/suno"
+	// 异步任务平台在 init 中注册到 channel.TaskProvider
+	_ "one-api/relay/channel/task/jimeng"
+	_ "one-api/relay/channel/task/kling"
+	_ "one-api/relay/channel/task/suno"
 	"one-api/relay/channel/tencent"
 	"one-api/relay/channel/vertex"
 	"one-api/relay/channel/volcengine"
@@ -101,15 +102,9 @@ func GetAdaptor(apiType int) channel.Adaptor {
 }
 
 func GetTaskAdaptor(platform commonconstant.TaskPlatform) channel.TaskAdaptor {
-	switch platform {
-	//case constant.APITypeAIProxyLibrary:
-	//	return &aiproxy.Adaptor{}
-	case commonconstant.TaskPlatformSuno:
-		return &suno.TaskAdaptor{}
-	case commonconstant.TaskPlatformKling:
-		return &kling.TaskAdaptor{}
-	case commonconstant.TaskPlatformJimeng:
-		return &taskjimeng.TaskAdaptor{}
+	provider := channel.GetTaskProvider(platform)
+	if provider == nil || provider.New == nil {
+		return nil
 	}
-	return nil
+	return provider.New()
 }
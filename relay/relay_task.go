@@ -11,10 +11,13 @@ import (
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
+	"one-api/relay/channel"
 	relaycommon "one-api/relay/common"
 	relayconstant "one-api/relay/constant"
 	"one-api/service"
 	"one-api/setting/ratio_setting"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -25,12 +28,22 @@ Task 任务通过平台、Action 区分任务
 func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 	platform := constant.TaskPlatform(c.GetString("platform"))
 	relayInfo := relaycommon.GenTaskRelayInfo(c)
+	if platform == "" {
+		// 统一视频接口的模型名未匹配到平台，按所选渠道类型确定
+		if provider := channel.GetTaskProviderByChannelType(relayInfo.ChannelType); provider != nil {
+			platform = provider.Platform
+		}
+	}
 
 	adaptor := GetTaskAdaptor(platform)
 	if adaptor == nil {
 		return service.TaskErrorWrapperLocal(fmt.Errorf("invalid api platform: %s", platform), "invalid_api_platform", http.StatusBadRequest)
 	}
 	adaptor.Init(relayInfo)
+	callbackUrl, err := service.GetTaskCallbackUrl(c)
+	if err != nil {
+		return service.TaskErrorWrapperLocal(err, "invalid_callback_url", http.StatusBadRequest)
+	}
 	// get & validate taskRequest 获取并验证文本请求
 	taskErr = adaptor.ValidateRequestAndSetAction(c, relayInfo)
 	if taskErr != nil {
@@ -60,7 +73,7 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 	} else {
 		ratio = modelPrice * groupRatio
 	}
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	userQuota, err := model.GetBillingQuota(relayInfo.UserId, relayInfo.OrganizationId)
 	if err != nil {
 		taskErr = service.TaskErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
 		return
@@ -99,6 +112,12 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 		}
 	}
 
+	// 上游支持主动推送时携带推送地址，任务状态以推送为主、轮询兜底
+	var notifyToken string
+	if _, ok := adaptor.(channel.TaskCallbackAdaptor); ok {
+		notifyToken, relayInfo.UpstreamCallbackUrl = service.GenerateTaskNotifyUrl(platform)
+	}
+
 	// build body
 	requestBody, err := adaptor.BuildRequestBody(c, relayInfo)
 	if err != nil {
@@ -168,6 +187,8 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 	task.Quota = quota
 	task.Data = taskData
 	task.Action = relayInfo.Action
+	task.CallbackUrl = callbackUrl
+	task.NotifyToken = notifyToken
 	err = task.Insert()
 	if err != nil {
 		taskErr = service.TaskErrorWrapper(err, "insert_task_failed", http.StatusInternalServerError)
@@ -179,13 +200,20 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 var fetchRespBuilders = map[int]func(c *gin.Context) (respBody []byte, taskResp *dto.TaskError){
 	relayconstant.RelayModeSunoFetchByID:  sunoFetchByIDRespBodyBuilder,
 	relayconstant.RelayModeSunoFetch:      sunoFetchRespBodyBuilder,
-	relayconstant.RelayModeKlingFetchByID: videoFetchByIDRespBodyBuilder,
+	relayconstant.RelayModeVideoFetchByID: videoFetchByIDRespBodyBuilder,
+	relayconstant.RelayModeTaskFetchByID:  taskFetchByIDRespBodyBuilder,
+}
+
+// IsTaskFetchRelayMode 查询类任务请求只读取本地任务记录，不转发上游
+func IsTaskFetchRelayMode(relayMode int) bool {
+	_, ok := fetchRespBuilders[relayMode]
+	return ok
 }
 
 func RelayTaskFetch(c *gin.Context, relayMode int) (taskResp *dto.TaskError) {
 	respBuilder, ok := fetchRespBuilders[relayMode]
 	if !ok {
-		taskResp = service.TaskErrorWrapperLocal(errors.New("invalid_relay_mode"), "invalid_relay_mode", http.StatusBadRequest)
+		return service.TaskErrorWrapperLocal(errors.New("invalid_relay_mode"), "invalid_relay_mode", http.StatusBadRequest)
 	}
 
 	respBody, taskErr := respBuilder(c)
@@ -275,7 +303,87 @@ func videoFetchByIDRespBodyBuilder(c *gin.Context) (respBody []byte, taskResp *d
 	return
 }
 
+// taskFetchByIDRespBodyBuilder 统一任务查询接口，适用于所有平台（含 Midjourney）的任务
+func taskFetchByIDRespBodyBuilder(c *gin.Context) (respBody []byte, taskResp *dto.TaskError) {
+	taskId := c.Param("task_id")
+	userId := c.GetInt("id")
+
+	originTask, exist, err := model.GetByTaskId(userId, taskId)
+	if err != nil {
+		taskResp = service.TaskErrorWrapper(err, "get_task_failed", http.StatusInternalServerError)
+		return
+	}
+	if !exist {
+		taskResp = service.TaskErrorWrapperLocal(errors.New("task_not_exist"), "task_not_exist", http.StatusNotFound)
+		return
+	}
+
+	respBody, err = json.Marshal(TaskModel2Object(originTask))
+	if err != nil {
+		taskResp = service.TaskErrorWrapper(err, "marshal_response_failed", http.StatusInternalServerError)
+	}
+	return
+}
+
+// withTaskAssetUrls 返回结果地址替换为网关签名地址的任务副本，未转存时返回原任务
+func withTaskAssetUrls(task *model.Task) *model.Task {
+	if task.Status != model.TaskStatusSuccess {
+		return task
+	}
+	urlMap := service.GetTaskAssetUrlMap(task.ID)
+	if len(urlMap) == 0 {
+		return task
+	}
+	taskCopy := *task
+	if assetUrl, ok := urlMap[task.FailReason]; ok {
+		taskCopy.FailReason = assetUrl
+	}
+	taskCopy.Data = service.ReplaceTaskAssetUrls(task.Data, urlMap)
+	return &taskCopy
+}
+
+// TaskModel2Object 将任务转换为 OpenAI 风格的任务对象
+func TaskModel2Object(task *model.Task) *dto.TaskObject {
+	task = withTaskAssetUrls(task)
+	object := &dto.TaskObject{
+		Id:          task.TaskID,
+		Object:      "task",
+		Platform:    string(task.Platform),
+		Action:      task.Action,
+		Status:      dto.TaskObjectStatusInProgress,
+		CreatedAt:   task.SubmitTime,
+		CompletedAt: task.FinishTime,
+		Data:        task.Data,
+	}
+	object.Progress, _ = strconv.Atoi(strings.TrimSuffix(task.Progress, "%"))
+	switch task.Status {
+	case model.TaskStatusNotStart, model.TaskStatusSubmitted, model.TaskStatusQueued:
+		object.Status = dto.TaskObjectStatusQueued
+	case model.TaskStatusSuccess:
+		object.Status = dto.TaskObjectStatusCompleted
+	case model.TaskStatusFailure:
+		object.Status = dto.TaskObjectStatusFailed
+		object.Error = &dto.TaskObjectError{
+			Code:    "task_failed",
+			Message: task.FailReason,
+		}
+	}
+	if object.Status == dto.TaskObjectStatusCompleted {
+		switch task.Platform {
+		case constant.TaskPlatformMidjourney:
+			object.Url = model.MidjourneyFromTask(task).ImageUrl
+		case constant.TaskPlatformSuno:
+			// Suno 一个任务可能生成多首歌曲，结果只在 data 中返回
+		default:
+			// 视频任务成功时 fail_reason 保存结果地址
+			object.Url = task.FailReason
+		}
+	}
+	return object
+}
+
 func TaskModel2Dto(task *model.Task) *dto.TaskDto {
+	task = withTaskAssetUrls(task)
 	return &dto.TaskDto{
 		TaskID:     task.TaskID,
 		Action:     task.Action,
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"one-api/common"
 	"one-api/controller"
 	"one-api/middleware"
 
@@ -18,7 +19,7 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/status", controller.GetStatus)
 		apiRouter.GET("/uptime/status", controller.GetUptimeKumaStatus)
 		apiRouter.GET("/models", middleware.UserAuth(), controller.DashboardListModels)
-		apiRouter.GET("/status/test", middleware.AdminAuth(), controller.TestStatus)
+		apiRouter.GET("/status/test", middleware.PermissionAuth(common.PermissionOptionRead), controller.TestStatus)
 		apiRouter.GET("/notice", controller.GetNotice)
 		apiRouter.GET("/about", controller.GetAbout)
 		//apiRouter.GET("/midjourney", controller.GetMidjourney)
@@ -39,11 +40,16 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/ratio_config", middleware.CriticalRateLimit(), controller.GetRatioConfig)
 
 		apiRouter.POST("/stripe/webhook", controller.StripeWebhook)
+		apiRouter.Any("/payment/:provider/notify", controller.PaymentNotify)
+		apiRouter.POST("/task/notify/:platform/:token", controller.TaskNotify)
 
 		userRoute := apiRouter.Group("/user")
 		{
 			userRoute.POST("/register", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.Register)
 			userRoute.POST("/login", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.Login)
+			userRoute.POST("/login/2fa", middleware.CriticalRateLimit(), controller.Login2FA)
+			userRoute.POST("/passkey/login/begin", middleware.CriticalRateLimit(), controller.PasskeyLoginBegin)
+			userRoute.POST("/passkey/login/finish", middleware.CriticalRateLimit(), controller.PasskeyLoginFinish)
 			//userRoute.POST("/tokenlog", middleware.CriticalRateLimit(), controller.TokenLog)
 			userRoute.GET("/logout", controller.Logout)
 			userRoute.GET("/epay/notify", controller.EpayNotify)
@@ -54,77 +60,140 @@ func SetApiRouter(router *gin.Engine) {
 			{
 				selfRoute.GET("/self/groups", controller.GetUserGroups)
 				selfRoute.GET("/self", controller.GetSelf)
+				selfRoute.GET("/self/permissions", controller.GetSelfPermissions)
 				selfRoute.GET("/models", controller.GetUserModels)
 				selfRoute.PUT("/self", controller.UpdateSelf)
-				selfRoute.DELETE("/self", controller.DeleteSelf)
-				selfRoute.GET("/token", controller.GenerateAccessToken)
+				selfRoute.DELETE("/self", middleware.SessionOnly(), controller.DeleteSelf)
+				selfRoute.GET("/token", middleware.SessionOnly(), controller.GenerateAccessToken)
+				selfRoute.GET("/self/access_tokens", middleware.SessionOnly(), controller.GetSelfManagementTokens)
+				selfRoute.GET("/self/access_tokens/scopes", middleware.SessionOnly(), controller.GetManagementTokenScopes)
+				selfRoute.POST("/self/access_tokens", middleware.SessionOnly(), controller.AddManagementToken)
+				selfRoute.PUT("/self/access_tokens/:id", middleware.SessionOnly(), controller.UpdateManagementToken)
+				selfRoute.POST("/self/access_tokens/:id/revoke", middleware.SessionOnly(), controller.RevokeManagementToken)
+				selfRoute.DELETE("/self/access_tokens/:id", middleware.SessionOnly(), controller.DeleteManagementToken)
 				selfRoute.GET("/aff", controller.GetAffCode)
 				selfRoute.POST("/topup", middleware.CriticalRateLimit(), controller.TopUp)
 				selfRoute.POST("/pay", middleware.CriticalRateLimit(), controller.RequestEpay)
 				selfRoute.POST("/amount", controller.RequestAmount)
 				selfRoute.POST("/stripe/pay", middleware.CriticalRateLimit(), controller.RequestStripePay)
 				selfRoute.POST("/stripe/amount", controller.RequestStripeAmount)
+				selfRoute.GET("/payment/providers", controller.GetPaymentProviders)
+				selfRoute.POST("/payment/:provider/amount", controller.RequestPaymentAmount)
+				selfRoute.POST("/payment/:provider/pay", middleware.CriticalRateLimit(), controller.RequestPayment)
+				selfRoute.GET("/payment/order", controller.GetSelfTopUps)
+				selfRoute.GET("/payment/order/:trade_no", controller.GetSelfTopUp)
 				selfRoute.POST("/aff_transfer", controller.TransferAffQuota)
 				selfRoute.PUT("/setting", controller.UpdateUserSetting)
+				selfRoute.GET("/self/2fa", middleware.SessionOnly(), controller.GetTwoFAStatus)
+				selfRoute.POST("/self/2fa/setup", middleware.SessionOnly(), controller.SetupTwoFA)
+				selfRoute.POST("/self/2fa/enable", middleware.SessionOnly(), middleware.CriticalRateLimit(), controller.EnableTwoFA)
+				selfRoute.POST("/self/2fa/disable", middleware.SessionOnly(), middleware.CriticalRateLimit(), controller.DisableTwoFA)
+				selfRoute.POST("/self/2fa/recovery_codes", middleware.SessionOnly(), middleware.CriticalRateLimit(), controller.RegenerateRecoveryCodes)
+				selfRoute.GET("/self/passkey", middleware.SessionOnly(), controller.GetSelfPasskeys)
+				selfRoute.POST("/self/passkey/register/begin", middleware.SessionOnly(), controller.PasskeyRegisterBegin)
+				selfRoute.POST("/self/passkey/register/finish", middleware.SessionOnly(), controller.PasskeyRegisterFinish)
+				selfRoute.DELETE("/self/passkey/:id", middleware.SessionOnly(), controller.DeleteSelfPasskey)
 			}
 
 			adminRoute := userRoute.Group("/")
-			adminRoute.Use(middleware.AdminAuth())
 			{
-				adminRoute.GET("/", controller.GetAllUsers)
-				adminRoute.GET("/search", controller.SearchUsers)
-				adminRoute.GET("/:id", controller.GetUser)
-				adminRoute.POST("/", controller.CreateUser)
-				adminRoute.POST("/manage", controller.ManageUser)
-				adminRoute.PUT("/", controller.UpdateUser)
-				adminRoute.DELETE("/:id", controller.DeleteUser)
+				adminRoute.GET("/", middleware.PermissionAuth(common.PermissionUserRead), controller.GetAllUsers)
+				adminRoute.GET("/search", middleware.PermissionAuth(common.PermissionUserRead), controller.SearchUsers)
+				adminRoute.GET("/:id", middleware.PermissionAuth(common.PermissionUserRead), controller.GetUser)
+				adminRoute.POST("/", middleware.PermissionAuth(common.PermissionUserWrite), controller.CreateUser)
+				adminRoute.POST("/manage", middleware.PermissionAuth(common.PermissionUserWrite), controller.ManageUser)
+				adminRoute.PUT("/", middleware.PermissionAuth(common.PermissionUserWrite, common.PermissionUserQuotaAdjust), controller.UpdateUser)
+				adminRoute.PUT("/:id/role", middleware.PermissionAuth(common.PermissionRoleManage), controller.AssignUserRole)
+				adminRoute.PUT("/:id/ip_rules", middleware.PermissionAuth(common.PermissionUserWrite), controller.UpdateUserIpRules)
+				adminRoute.PUT("/:id/asset_retention", middleware.PermissionAuth(common.PermissionUserWrite), controller.UpdateUserAssetRetention)
+				adminRoute.DELETE("/:id", middleware.PermissionAuth(common.PermissionUserWrite), controller.DeleteUser)
+				adminRoute.DELETE("/:id/2fa", middleware.PermissionAuth(common.PermissionUserWrite), controller.ResetUserTwoFA)
 			}
 		}
 		optionRoute := apiRouter.Group("/option")
-		optionRoute.Use(middleware.RootAuth())
 		{
-			optionRoute.GET("/", controller.GetOptions)
-			optionRoute.PUT("/", controller.UpdateOption)
-			optionRoute.POST("/rest_model_ratio", controller.ResetModelRatio)
-			optionRoute.POST("/migrate_console_setting", controller.MigrateConsoleSetting) // 用于迁移检测的旧键，下个版本会删除
+			optionRoute.GET("/", middleware.PermissionAuth(common.PermissionOptionRead), controller.GetOptions)
+			optionRoute.PUT("/", middleware.PermissionAuth(common.PermissionOptionWrite), controller.UpdateOption)
+			optionRoute.POST("/rest_model_ratio", middleware.PermissionAuth(common.PermissionOptionWrite), controller.ResetModelRatio)
+			optionRoute.POST("/price_simulation", middleware.PermissionAuth(common.PermissionOptionRead), controller.SimulatePricing)
+			optionRoute.POST("/exchange_rates/sync", middleware.PermissionAuth(common.PermissionOptionWrite), controller.SyncExchangeRates)
+			optionRoute.POST("/scim_token", middleware.PermissionAuth(common.PermissionOptionWrite), controller.GenerateScimToken)
+			optionRoute.POST("/hash_legacy_token_keys", middleware.PermissionAuth(common.PermissionOptionWrite), controller.HashLegacyTokenKeys)
+			optionRoute.POST("/migrate_console_setting", middleware.PermissionAuth(common.PermissionOptionWrite), controller.MigrateConsoleSetting) // 用于迁移检测的旧键，下个版本会删除
+		}
+		// 配置包同时涉及系统设置与渠道，需要同时拥有两类权限
+		configBundleRoute := apiRouter.Group("/config_bundle")
+		{
+			configBundleRoute.GET("/export", middleware.PermissionAuthAll(common.PermissionOptionRead, common.PermissionChannelRead), controller.ExportConfigBundle)
+			configBundleRoute.POST("/plan", middleware.PermissionAuthAll(common.PermissionOptionRead, common.PermissionChannelRead), controller.PlanConfigBundle)
+			configBundleRoute.POST("/apply", middleware.PermissionAuthAll(common.PermissionOptionWrite, common.PermissionChannelWrite), controller.ApplyConfigBundle)
+		}
+		roleRoute := apiRouter.Group("/role")
+		roleRoute.Use(middleware.PermissionAuth(common.PermissionRoleManage))
+		{
+			roleRoute.GET("/", controller.GetAllRoles)
+			roleRoute.GET("/permissions", controller.GetAllPermissions)
+			roleRoute.POST("/", controller.AddRole)
+			roleRoute.PUT("/", controller.UpdateRole)
+			roleRoute.DELETE("/:id", controller.DeleteRole)
+		}
+		auditLogRoute := apiRouter.Group("/audit_log")
+		auditLogRoute.Use(middleware.PermissionAuth(common.PermissionAuditRead))
+		{
+			auditLogRoute.GET("/", controller.GetAuditLogs)
+			auditLogRoute.GET("/export", controller.ExportAuditLogs)
 		}
 		ratioSyncRoute := apiRouter.Group("/ratio_sync")
-		ratioSyncRoute.Use(middleware.RootAuth())
+		ratioSyncRoute.Use(middleware.PermissionAuth(common.PermissionOptionWrite))
 		{
 			ratioSyncRoute.GET("/channels", controller.GetSyncableChannels)
 			ratioSyncRoute.POST("/fetch", controller.FetchUpstreamRatios)
 		}
 		channelRoute := apiRouter.Group("/channel")
-		channelRoute.Use(middleware.AdminAuth())
-		{
-			channelRoute.GET("/", controller.GetAllChannels)
-			channelRoute.GET("/search", controller.SearchChannels)
-			channelRoute.GET("/models", controller.ChannelListModels)
-			channelRoute.GET("/models_enabled", controller.EnabledListModels)
-			channelRoute.GET("/:id", controller.GetChannel)
-			channelRoute.GET("/test", controller.TestAllChannels)
-			channelRoute.GET("/test/:id", controller.TestChannel)
-			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
-			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
-			channelRoute.POST("/", controller.AddChannel)
-			channelRoute.PUT("/", controller.UpdateChannel)
-			channelRoute.DELETE("/disabled", controller.DeleteDisabledChannel)
-			channelRoute.POST("/tag/disabled", controller.DisableTagChannels)
-			channelRoute.POST("/tag/enabled", controller.EnableTagChannels)
-			channelRoute.PUT("/tag", controller.EditTagChannels)
-			channelRoute.DELETE("/:id", controller.DeleteChannel)
-			channelRoute.POST("/batch", controller.DeleteChannelBatch)
-			channelRoute.POST("/fix", controller.FixChannelsAbilities)
-			channelRoute.GET("/fetch_models/:id", controller.FetchUpstreamModels)
-			channelRoute.POST("/fetch_models", controller.FetchModels)
-			channelRoute.POST("/batch/tag", controller.BatchSetChannelTag)
-			channelRoute.GET("/tag/models", controller.GetTagModels)
-			channelRoute.POST("/copy/:id", controller.CopyChannel)
-			channelRoute.GET("/export", controller.ExportChannels)
-			channelRoute.POST("/import", controller.ImportChannels)
+		{
+			channelRoute.GET("/", middleware.PermissionAuth(common.PermissionChannelRead), controller.GetAllChannels)
+			channelRoute.GET("/search", middleware.PermissionAuth(common.PermissionChannelRead), controller.SearchChannels)
+			channelRoute.GET("/models", middleware.PermissionAuth(common.PermissionChannelRead), controller.ChannelListModels)
+			channelRoute.GET("/models_enabled", middleware.PermissionAuth(common.PermissionChannelRead), controller.EnabledListModels)
+			channelRoute.GET("/:id", middleware.PermissionAuth(common.PermissionChannelRead), controller.GetChannel)
+			channelRoute.POST("/:id/key", middleware.PermissionAuth(common.PermissionChannelKeyReveal), controller.RevealChannelKey)
+			channelRoute.GET("/test", middleware.PermissionAuth(common.PermissionChannelWrite), controller.TestAllChannels)
+			channelRoute.GET("/test/:id", middleware.PermissionAuth(common.PermissionChannelWrite), controller.TestChannel)
+			channelRoute.GET("/:id/tests", middleware.PermissionAuth(common.PermissionChannelRead), controller.GetChannelTestResults)
+			channelRoute.GET("/health", middleware.PermissionAuth(common.PermissionChannelRead), controller.GetAbilityHealthMatrix)
+			channelRoute.PUT("/health", middleware.PermissionAuth(common.PermissionChannelWrite), controller.UpdateAbilityHealth)
+			channelRoute.GET("/drain", middleware.PermissionAuth(common.PermissionChannelRead), controller.GetDrainingChannels)
+			channelRoute.POST("/drain", middleware.PermissionAuth(common.PermissionChannelWrite), controller.DrainChannels)
+			channelRoute.POST("/drain/restore", middleware.PermissionAuth(common.PermissionChannelWrite), controller.RestoreDrainingChannels)
+			channelRoute.GET("/maintenance", middleware.PermissionAuth(common.PermissionChannelRead), controller.GetMaintenanceWindows)
+			channelRoute.POST("/maintenance", middleware.PermissionAuth(common.PermissionChannelWrite), controller.AddMaintenanceWindow)
+			channelRoute.PUT("/maintenance", middleware.PermissionAuth(common.PermissionChannelWrite), controller.UpdateMaintenanceWindow)
+			channelRoute.DELETE("/maintenance/:id", middleware.PermissionAuth(common.PermissionChannelWrite), controller.DeleteMaintenanceWindow)
+			channelRoute.GET("/update_balance", middleware.PermissionAuth(common.PermissionChannelWrite), controller.UpdateAllChannelsBalance)
+			channelRoute.GET("/update_balance/:id", middleware.PermissionAuth(common.PermissionChannelWrite), controller.UpdateChannelBalance)
+			channelRoute.GET("/:id/balance_history", middleware.PermissionAuth(common.PermissionChannelRead), controller.GetChannelBalanceHistory)
+			channelRoute.GET("/balance_forecast", middleware.PermissionAuth(common.PermissionChannelRead), controller.GetChannelBalanceForecasts)
+			channelRoute.POST("/", middleware.PermissionAuth(common.PermissionChannelWrite), controller.AddChannel)
+			channelRoute.PUT("/", middleware.PermissionAuth(common.PermissionChannelWrite), controller.UpdateChannel)
+			channelRoute.DELETE("/disabled", middleware.PermissionAuth(common.PermissionChannelWrite), controller.DeleteDisabledChannel)
+			channelRoute.POST("/tag/disabled", middleware.PermissionAuth(common.PermissionChannelWrite), controller.DisableTagChannels)
+			channelRoute.POST("/tag/enabled", middleware.PermissionAuth(common.PermissionChannelWrite), controller.EnableTagChannels)
+			channelRoute.PUT("/tag", middleware.PermissionAuth(common.PermissionChannelWrite), controller.EditTagChannels)
+			channelRoute.DELETE("/:id", middleware.PermissionAuth(common.PermissionChannelWrite), controller.DeleteChannel)
+			channelRoute.POST("/batch", middleware.PermissionAuth(common.PermissionChannelWrite), controller.DeleteChannelBatch)
+			channelRoute.POST("/fix", middleware.PermissionAuth(common.PermissionChannelWrite), controller.FixChannelsAbilities)
+			channelRoute.GET("/fetch_models/:id", middleware.PermissionAuth(common.PermissionChannelRead), controller.FetchUpstreamModels)
+			channelRoute.POST("/fetch_models", middleware.PermissionAuth(common.PermissionChannelRead), controller.FetchModels)
+			channelRoute.POST("/model_sync/:id", middleware.PermissionAuth(common.PermissionChannelWrite), controller.SyncChannelModels)
+			channelRoute.GET("/model_sync/reports", middleware.PermissionAuth(common.PermissionChannelRead), controller.GetModelSyncReports)
+			channelRoute.POST("/batch/tag", middleware.PermissionAuth(common.PermissionChannelWrite), controller.BatchSetChannelTag)
+			channelRoute.GET("/tag/models", middleware.PermissionAuth(common.PermissionChannelRead), controller.GetTagModels)
+			channelRoute.POST("/copy/:id", middleware.PermissionAuth(common.PermissionChannelWrite), controller.CopyChannel)
+			channelRoute.GET("/export", middleware.PermissionAuth(common.PermissionChannelRead), controller.ExportChannels)
+			channelRoute.POST("/import", middleware.PermissionAuth(common.PermissionChannelWrite), controller.ImportChannels)
 		}
 		tokenRoute := apiRouter.Group("/token")
-		tokenRoute.Use(middleware.UserAuth())
+		tokenRoute.Use(middleware.ScopedUserAuth(common.ScopeTokenManage))
 		{
 			tokenRoute.GET("/", controller.GetAllTokens)
 			tokenRoute.GET("/search", controller.SearchTokens)
@@ -133,30 +202,72 @@ func SetApiRouter(router *gin.Engine) {
 			tokenRoute.POST("/", controller.AddToken)
 			tokenRoute.PUT("/", controller.UpdateToken)
 			tokenRoute.DELETE("/:id", controller.DeleteToken)
+			tokenRoute.POST("/:id/key", controller.ResetTokenKey)
+			tokenRoute.POST("/:id/rotate", controller.RotateToken)
 			tokenRoute.POST("/batch", controller.DeleteTokenBatch)
 		}
+		organizationRoute := apiRouter.Group("/organization")
+		{
+			organizationRoute.GET("/admin/", middleware.PermissionAuth(common.PermissionUserRead), controller.GetAllOrganizations)
+			organizationRoute.PUT("/admin/:id", middleware.PermissionAuth(common.PermissionUserQuotaAdjust), controller.AdminUpdateOrganization)
+
+			selfOrganizationRoute := organizationRoute.Group("/")
+			selfOrganizationRoute.Use(middleware.UserAuth())
+			{
+				selfOrganizationRoute.GET("/", controller.GetSelfOrganizations)
+				selfOrganizationRoute.POST("/", controller.CreateOrganization)
+				selfOrganizationRoute.POST("/invitation/accept", controller.AcceptOrganizationInvitation)
+				selfOrganizationRoute.GET("/:id", controller.GetOrganization)
+				selfOrganizationRoute.PUT("/:id", controller.UpdateOrganization)
+				selfOrganizationRoute.DELETE("/:id", controller.DeleteOrganization)
+				selfOrganizationRoute.GET("/:id/members", controller.GetOrganizationMembers)
+				selfOrganizationRoute.PUT("/:id/members/:user_id", controller.UpdateOrganizationMember)
+				selfOrganizationRoute.DELETE("/:id/members/:user_id", controller.RemoveOrganizationMember)
+				selfOrganizationRoute.GET("/:id/invitations", controller.GetOrganizationInvitations)
+				selfOrganizationRoute.POST("/:id/invitations", controller.InviteOrganizationMember)
+				selfOrganizationRoute.DELETE("/:id/invitations/:invitation_id", controller.RevokeOrganizationInvitation)
+				selfOrganizationRoute.POST("/:id/deposit", controller.DepositOrganizationQuota)
+				selfOrganizationRoute.GET("/:id/logs", controller.GetOrganizationLogs)
+				selfOrganizationRoute.GET("/:id/usage", controller.GetOrganizationUsage)
+			}
+		}
 		redemptionRoute := apiRouter.Group("/redemption")
-		redemptionRoute.Use(middleware.AdminAuth())
 		{
-			redemptionRoute.GET("/", controller.GetAllRedemptions)
-			redemptionRoute.GET("/search", controller.SearchRedemptions)
-			redemptionRoute.GET("/:id", controller.GetRedemption)
-			redemptionRoute.POST("/", controller.AddRedemption)
-			redemptionRoute.PUT("/", controller.UpdateRedemption)
-			redemptionRoute.DELETE("/invalid", controller.DeleteInvalidRedemption)
-			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
+			redemptionRoute.GET("/", middleware.PermissionAuth(common.PermissionRedemptionRead), controller.GetAllRedemptions)
+			redemptionRoute.GET("/search", middleware.PermissionAuth(common.PermissionRedemptionRead), controller.SearchRedemptions)
+			redemptionRoute.GET("/:id", middleware.PermissionAuth(common.PermissionRedemptionRead), controller.GetRedemption)
+			redemptionRoute.POST("/", middleware.PermissionAuth(common.PermissionRedemptionWrite), controller.AddRedemption)
+			redemptionRoute.PUT("/", middleware.PermissionAuth(common.PermissionRedemptionWrite), controller.UpdateRedemption)
+			redemptionRoute.DELETE("/invalid", middleware.PermissionAuth(common.PermissionRedemptionWrite), controller.DeleteInvalidRedemption)
+			redemptionRoute.DELETE("/:id", middleware.PermissionAuth(common.PermissionRedemptionWrite), controller.DeleteRedemption)
+		}
+		topUpRoute := apiRouter.Group("/topup")
+		{
+			topUpRoute.GET("/", middleware.PermissionAuth(common.PermissionBillingRead), controller.GetAllTopUps)
+			topUpRoute.POST("/:trade_no/confirm", middleware.PermissionAuth(common.PermissionBillingWrite), controller.ConfirmTopUp)
+			topUpRoute.POST("/:trade_no/cancel", middleware.PermissionAuth(common.PermissionBillingWrite), controller.CancelTopUp)
+			topUpRoute.POST("/:trade_no/refund", middleware.PermissionAuth(common.PermissionBillingWrite), controller.RefundTopUp)
+			topUpRoute.POST("/:trade_no/sync", middleware.PermissionAuth(common.PermissionBillingWrite), controller.SyncTopUp)
+		}
+		refundRoute := apiRouter.Group("/refund")
+		{
+			refundRoute.GET("/self", middleware.UserAuth(), controller.GetUserRefunds)
+			refundRoute.POST("/self", middleware.UserAuth(), middleware.CriticalRateLimit(), controller.RequestRefund)
+			refundRoute.GET("/", middleware.PermissionAuth(common.PermissionBillingRead), controller.GetAllRefunds)
+			refundRoute.POST("/:id/approve", middleware.PermissionAuth(common.PermissionBillingWrite), controller.ApproveRefund)
+			refundRoute.POST("/:id/reject", middleware.PermissionAuth(common.PermissionBillingWrite), controller.RejectRefund)
 		}
 		logRoute := apiRouter.Group("/log")
-		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
-		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
-		logRoute.GET("/stat", middleware.AdminAuth(), controller.GetLogsStat)
+		logRoute.GET("/", middleware.PermissionAuth(common.PermissionLogReadAll), controller.GetAllLogs)
+		logRoute.DELETE("/", middleware.PermissionAuth(common.PermissionLogDelete), controller.DeleteHistoryLogs)
+		logRoute.GET("/stat", middleware.PermissionAuth(common.PermissionLogReadAll), controller.GetLogsStat)
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
-		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
+		logRoute.GET("/search", middleware.PermissionAuth(common.PermissionLogReadAll), controller.SearchAllLogs)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), controller.SearchUserLogs)
 
 		dataRoute := apiRouter.Group("/data")
-		dataRoute.GET("/", middleware.AdminAuth(), controller.GetAllQuotaDates)
+		dataRoute.GET("/", middleware.PermissionAuth(common.PermissionStatsRead), controller.GetAllQuotaDates)
 		dataRoute.GET("/self", middleware.UserAuth(), controller.GetUserQuotaDates)
 
 		logRoute.Use(middleware.CORS())
@@ -167,42 +278,48 @@ func SetApiRouter(router *gin.Engine) {
 		// 令牌查询接口
 		apiRouter.POST("/token/search", controller.SearchTokenByToken)
 		groupRoute := apiRouter.Group("/group")
-		groupRoute.Use(middleware.AdminAuth())
+		groupRoute.Use(middleware.PermissionAuth(common.PermissionUserRead, common.PermissionChannelRead, common.PermissionRedemptionRead))
 		{
 			groupRoute.GET("/", controller.GetGroups)
 		}
 		mjRoute := apiRouter.Group("/mj")
 		mjRoute.GET("/self", middleware.UserAuth(), controller.GetUserMidjourney)
-		mjRoute.GET("/", middleware.AdminAuth(), controller.GetAllMidjourney)
+		mjRoute.GET("/", middleware.PermissionAuth(common.PermissionLogReadAll), controller.GetAllMidjourney)
 
 		taskRoute := apiRouter.Group("/task")
 		{
 			taskRoute.GET("/self", middleware.UserAuth(), controller.GetUserTask)
-			taskRoute.GET("/", middleware.AdminAuth(), controller.GetAllTask)
+			taskRoute.GET("/", middleware.PermissionAuth(common.PermissionLogReadAll), controller.GetAllTask)
+			taskRoute.GET("/webhook_deliveries/self", middleware.UserAuth(), controller.GetUserTaskWebhookDeliveries)
+			taskRoute.GET("/webhook_deliveries", middleware.PermissionAuth(common.PermissionLogReadAll), controller.GetAllTaskWebhookDeliveries)
+			taskRoute.POST("/webhook_deliveries/:id/retry", middleware.UserAuth(), controller.RetryTaskWebhookDelivery)
+			taskRoute.GET("/assets/self", middleware.UserAuth(), controller.GetUserTaskAssets)
+			taskRoute.GET("/assets/self/usage", middleware.UserAuth(), controller.GetUserTaskAssetUsage)
+			taskRoute.GET("/assets", middleware.PermissionAuth(common.PermissionLogReadAll), controller.GetAllTaskAssets)
 		}
 
 		// 用量统计路由
 		usageStatsRoute := apiRouter.Group("/usage_statistics")
 		{
-			usageStatsRoute.GET("/", middleware.AdminAuth(), controller.GetUsageStatistics)
-			usageStatsRoute.GET("/summary", middleware.AdminAuth(), controller.GetUsageStatisticsSummary)
+			usageStatsRoute.GET("/", middleware.PermissionAuth(common.PermissionStatsRead), controller.GetUsageStatistics)
+			usageStatsRoute.GET("/summary", middleware.PermissionAuth(common.PermissionStatsRead), controller.GetUsageStatisticsSummary)
 			usageStatsRoute.GET("/self", middleware.UserAuth(), controller.GetUserUsageStatistics)
 		}
 
 		// 月度用量统计路由
 		monthlyUsageStatsRoute := apiRouter.Group("/usage_statistics_monthly")
 		{
-			monthlyUsageStatsRoute.GET("/", middleware.AdminAuth(), controller.GetMonthlyUsageStatistics)
-			monthlyUsageStatsRoute.GET("/summary", middleware.AdminAuth(), controller.GetMonthlyUsageStatisticsSummary)
+			monthlyUsageStatsRoute.GET("/", middleware.PermissionAuth(common.PermissionStatsRead), controller.GetMonthlyUsageStatistics)
+			monthlyUsageStatsRoute.GET("/summary", middleware.PermissionAuth(common.PermissionStatsRead), controller.GetMonthlyUsageStatisticsSummary)
 			monthlyUsageStatsRoute.GET("/self", middleware.UserAuth(), controller.GetUserMonthlyUsageStatistics)
 		}
 
 		// 统计图表路由
 		statisticsRoute := apiRouter.Group("/statistics")
 		{
-			statisticsRoute.GET("/channel", middleware.AdminAuth(), controller.GetChannelStatistics)
-			statisticsRoute.GET("/token", middleware.AdminAuth(), controller.GetTokenStatistics)
-			statisticsRoute.GET("/user", middleware.AdminAuth(), controller.GetUserStatistics)
+			statisticsRoute.GET("/channel", middleware.PermissionAuth(common.PermissionStatsRead), controller.GetChannelStatistics)
+			statisticsRoute.GET("/token", middleware.PermissionAuth(common.PermissionStatsRead), controller.GetTokenStatistics)
+			statisticsRoute.GET("/user", middleware.PermissionAuth(common.PermissionStatsRead), controller.GetUserStatistics)
 		}
 	}
 }
@@ -3,6 +3,7 @@ package router
 import (
 	"one-api/controller"
 	"one-api/middleware"
+	"one-api/relay/channel"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,12 +14,16 @@ func SetVideoRouter(router *gin.Engine) {
 	{
 		videoV1Router.POST("/video/generations", controller.RelayTask)
 		videoV1Router.GET("/video/generations/:task_id", controller.RelayTask)
+		videoV1Router.GET("/tasks/:task_id", controller.RelayTask)
 	}
 
-	klingV1Router := router.Group("/kling/v1")
-	klingV1Router.Use(middleware.KlingRequestConvert(), middleware.TokenAuth(), middleware.Distribute())
-	{
-		klingV1Router.POST("/videos/text2video", controller.RelayTask)
-		klingV1Router.POST("/videos/image2video", controller.RelayTask)
+	// 各任务平台声明的原生接口
+	for _, provider := range channel.GetTaskProviders() {
+		for _, route := range provider.Routes {
+			handlers := []gin.HandlerFunc{middleware.TaskRoute(provider.Platform, route)}
+			handlers = append(handlers, route.Middlewares...)
+			handlers = append(handlers, middleware.TokenAuth(), middleware.Distribute(), controller.RelayTask)
+			router.Handle(route.Method, route.Path, handlers...)
+		}
 	}
 }
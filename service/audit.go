@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 字段名中包含以下单词时视为敏感字段，写入审计日志前脱敏
+var auditSensitiveWords = []string{"key", "secret", "password", "token", "credential", "credentials", "private"}
+
+// splitAuditFieldWords 将 snake_case、camelCase 与点分隔的字段名拆分为小写单词
+func splitAuditFieldWords(name string) []string {
+	words := make([]string, 0)
+	var current []rune
+	runes := []rune(name)
+	for i, r := range runes {
+		if r == '_' || r == '.' || r == '-' || r == ' ' {
+			if len(current) > 0 {
+				words = append(words, strings.ToLower(string(current)))
+				current = nil
+			}
+			continue
+		}
+		if unicode.IsUpper(r) && len(current) > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			words = append(words, strings.ToLower(string(current)))
+			current = nil
+		}
+		current = append(current, r)
+	}
+	if len(current) > 0 {
+		words = append(words, strings.ToLower(string(current)))
+	}
+	return words
+}
+
+func isSensitiveAuditField(name string) bool {
+	for _, word := range splitAuditFieldWords(name) {
+		if common.StringsContains(auditSensitiveWords, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskAuditValue 递归脱敏敏感字段
+func maskAuditValue(name string, value any) any {
+	if value == nil {
+		return nil
+	}
+	if name != "" && isSensitiveAuditField(name) {
+		if s, ok := value.(string); ok {
+			return common.MaskSecret(s)
+		}
+		return "****"
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		// 形如 {"key": 配置名, "value": 配置值} 的请求（如修改系统设置）按配置名判断是否脱敏
+		if optionKey, ok := v["key"].(string); ok && len(v) == 2 {
+			if optionValue, ok := v["value"]; ok {
+				return map[string]any{"key": optionKey, "value": maskAuditValue(optionKey, optionValue)}
+			}
+		}
+		masked := make(map[string]any, len(v))
+		for k, item := range v {
+			masked[k] = maskAuditValue(k, item)
+		}
+		return masked
+	case []any:
+		masked := make([]any, len(v))
+		for i, item := range v {
+			masked[i] = maskAuditValue("", item)
+		}
+		return masked
+	}
+	return value
+}
+
+// toAuditMap 将结构体等转换为以 JSON 字段名为键的 map
+func toAuditMap(value any) map[string]any {
+	if value == nil || (reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil()) {
+		return nil
+	}
+	data, err := common.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var m map[string]any
+	if err = common.Unmarshal(data, &m); err != nil {
+		return map[string]any{"value": value}
+	}
+	return m
+}
+
+// diffAuditValues 仅保留发生变化的字段；新建或删除时保留完整内容
+func diffAuditValues(before map[string]any, after map[string]any) (map[string]any, map[string]any) {
+	if before == nil || after == nil {
+		return before, after
+	}
+	changedBefore := make(map[string]any)
+	changedAfter := make(map[string]any)
+	for k, v := range before {
+		if !reflect.DeepEqual(v, after[k]) {
+			changedBefore[k] = v
+			if afterValue, ok := after[k]; ok {
+				changedAfter[k] = afterValue
+			}
+		}
+	}
+	for k, v := range after {
+		if _, ok := before[k]; !ok {
+			changedAfter[k] = v
+		}
+	}
+	return changedBefore, changedAfter
+}
+
+func marshalAuditValue(value map[string]any) string {
+	if value == nil {
+		return ""
+	}
+	data, err := common.Marshal(maskAuditValue("", value))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func newAuditLog(c *gin.Context, action string, targetType string, targetId string) *model.AuditLog {
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	return &model.AuditLog{
+		CreatedAt:  common.GetTimestamp(),
+		ActorId:    c.GetInt("id"),
+		ActorName:  c.GetString("username"),
+		ActorRole:  c.GetInt("role"),
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   targetId,
+		Ip:         c.ClientIP(),
+		UserAgent:  userAgent,
+		RequestId:  c.GetString(common.RequestIdKey),
+		StatusCode: c.Writer.Status(),
+	}
+}
+
+func insertAuditLog(c *gin.Context, log *model.AuditLog) {
+	common.SetContextKey(c, constant.ContextKeyAuditRecorded, true)
+	if err := log.Insert(); err != nil {
+		common.SysError("failed to record audit log: " + err.Error())
+	}
+}
+
+// RecordAudit 记录一次管理操作，before / after 为操作前后的对象（新建时 before 为 nil，删除时 after 为 nil），
+// 更新时只记录变化的字段，敏感字段会被脱敏
+func RecordAudit(c *gin.Context, action string, targetType string, targetId string, before any, after any) {
+	beforeMap, afterMap := diffAuditValues(toAuditMap(before), toAuditMap(after))
+	log := newAuditLog(c, action, targetType, targetId)
+	log.Before = marshalAuditValue(beforeMap)
+	log.After = marshalAuditValue(afterMap)
+	insertAuditLog(c, log)
+}
+
+// IsAuditRecorded 判断本次请求是否已由处理函数记录审计日志
+func IsAuditRecorded(c *gin.Context) bool {
+	recorded, ok := common.GetContextKey(c, constant.ContextKeyAuditRecorded)
+	return ok && recorded.(bool)
+}
+
+// RecordAuditRequest 为没有单独记录审计日志的管理接口记录请求路径与脱敏后的请求体
+func RecordAuditRequest(c *gin.Context, body []byte) {
+	path := c.FullPath()
+	targetType := ""
+	if parts := strings.Split(strings.TrimPrefix(path, "/api/"), "/"); len(parts) > 0 {
+		targetType = parts[0]
+	}
+	targetId := c.Param("id")
+	if targetId == "" {
+		targetId = c.Param("trade_no")
+	}
+	log := newAuditLog(c, c.Request.Method+" "+path, targetType, targetId)
+	var request map[string]any
+	if len(body) > 0 && common.Unmarshal(body, &request) == nil {
+		log.After = marshalAuditValue(request)
+	}
+	insertAuditLog(c, log)
+}
+
+// IsAuditedMethod 只有会修改数据的请求需要审计
+func IsAuditedMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// AutomaticallyCleanAuditLogs 按保留天数定期清理审计日志
+func AutomaticallyCleanAuditLogs() {
+	for {
+		retentionDays := operation_setting.GetAuditSetting().RetentionDays
+		if retentionDays > 0 {
+			target := time.Now().AddDate(0, 0, -retentionDays).Unix()
+			count, err := model.DeleteOldAuditLog(context.Background(), target, 1000)
+			if err != nil {
+				common.SysError("failed to clean audit logs: " + err.Error())
+			} else if count > 0 {
+				common.SysLog(fmt.Sprintf("cleaned %d audit logs", count))
+			}
+		}
+		time.Sleep(time.Hour)
+	}
+}
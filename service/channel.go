@@ -10,6 +10,7 @@ import (
 	"one-api/setting/operation_setting"
 	"one-api/types"
 	"strings"
+	"sync"
 )
 
 func formatNotifyType(channelId int, status int) string {
@@ -35,6 +36,93 @@ func EnableChannel(channelId int, usingKey string, channelName string) {
 	}
 }
 
+func formatAbilityNotifyType(channelId int, modelName string, status int) string {
+	return fmt.Sprintf("%s_%d_%s_%d", dto.NotifyTypeChannelUpdate, channelId, modelName, status)
+}
+
+// DisableAbility 只禁用渠道的单个模型并通知
+func DisableAbility(channelError types.ChannelError, modelName string, reason string) {
+	success, err := model.UpdateAbilityHealthStatus(channelError.ChannelId, modelName, common.ChannelStatusAutoDisabled, reason)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to disable model %s of channel #%d: %s", modelName, channelError.ChannelId, err.Error()))
+		return
+	}
+	if success {
+		subject := fmt.Sprintf("通道「%s」（#%d）的模型 %s 已被禁用", channelError.ChannelName, channelError.ChannelId, modelName)
+		content := fmt.Sprintf("通道「%s」（#%d）的模型 %s 已被禁用，渠道的其他模型不受影响，原因：%s", channelError.ChannelName, channelError.ChannelId, modelName, reason)
+		NotifyRootUser(formatAbilityNotifyType(channelError.ChannelId, modelName, common.ChannelStatusAutoDisabled), subject, content)
+	}
+}
+
+// EnableAbility 重新启用自动禁用的模型，手动禁用的模型保持不变
+func EnableAbility(channelId int, channelName string, modelName string) {
+	if !common.AutomaticEnableChannelEnabled {
+		return
+	}
+	health, err := model.GetAbilityHealth(channelId, modelName)
+	if err != nil || health.Status != common.ChannelStatusAutoDisabled {
+		return
+	}
+	success, err := model.UpdateAbilityHealthStatus(channelId, modelName, common.ChannelStatusEnabled, "")
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to enable model %s of channel #%d: %s", modelName, channelId, err.Error()))
+		return
+	}
+	if success {
+		subject := fmt.Sprintf("通道「%s」（#%d）的模型 %s 已被启用", channelName, channelId, modelName)
+		NotifyRootUser(formatAbilityNotifyType(channelId, modelName, common.ChannelStatusEnabled), subject, subject)
+	}
+}
+
+// abilitiesWithFailures 本节点记录过失败的模型，请求成功时据此清空连续失败次数，避免每次成功请求都写数据库
+var abilitiesWithFailures sync.Map
+
+func abilityFailureKey(channelId int, modelName string) string {
+	return fmt.Sprintf("%d:%s", channelId, modelName)
+}
+
+// RecordAbilityFailure 记录模型的一次失败，返回连续失败次数是否已达到禁用阈值
+func RecordAbilityFailure(channelId int, modelName string, message string) bool {
+	failCount := model.RecordAbilityError(channelId, modelName, message)
+	abilitiesWithFailures.Store(abilityFailureKey(channelId, modelName), struct{}{})
+	return failCount >= max(operation_setting.GetModelHealthSetting().DisableAfterFailures, 1)
+}
+
+// RecordAbilitySuccess 请求成功时清空本节点记录过失败的模型的连续失败次数
+func RecordAbilitySuccess(channelId int, modelName string) {
+	if _, ok := abilitiesWithFailures.LoadAndDelete(abilityFailureKey(channelId, modelName)); ok {
+		model.RecordAbilitySuccess(channelId, modelName)
+	}
+}
+
+// ShouldDisableAbility 错误只与请求的模型有关（模型不存在、已下线等）时只禁用渠道的该模型
+func ShouldDisableAbility(err *types.NewAPIError) bool {
+	healthSetting := operation_setting.GetModelHealthSetting()
+	if !common.AutomaticDisableChannelEnabled || !healthSetting.Enabled {
+		return false
+	}
+	if err == nil || types.IsLocalError(err) {
+		return false
+	}
+	// 鉴权、额度类错误影响整个渠道
+	if err.StatusCode == http.StatusUnauthorized || err.StatusCode == http.StatusPaymentRequired {
+		return false
+	}
+	oaiErr := err.ToOpenAIError()
+	switch oaiErr.Code {
+	case "model_not_found", "model_not_supported":
+		return true
+	}
+	keywords := make([]string, 0, len(healthSetting.ModelErrorKeywords))
+	for _, keyword := range healthSetting.ModelErrorKeywords {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	search, _ := AcSearch(strings.ToLower(err.Error()), keywords, true)
+	return search
+}
+
 func ShouldDisableChannel(channelType int, err *types.NewAPIError) bool {
 	if !common.AutomaticDisableChannelEnabled {
 		return false
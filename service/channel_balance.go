@@ -0,0 +1,178 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"sync"
+)
+
+// BalanceFetcher 查询渠道在上游的余额（美元）
+type BalanceFetcher interface {
+	FetchBalance(channel *model.Channel) (float64, error)
+}
+
+type BalanceFetcherFunc func(channel *model.Channel) (float64, error)
+
+func (f BalanceFetcherFunc) FetchBalance(channel *model.Channel) (float64, error) {
+	return f(channel)
+}
+
+var (
+	balanceFetchers     = make(map[int]BalanceFetcher)
+	balanceFetchersLock sync.RWMutex
+)
+
+// RegisterBalanceFetcher 为渠道类型注册余额查询实现，重复注册时覆盖
+func RegisterBalanceFetcher(channelType int, fetcher BalanceFetcher) {
+	balanceFetchersLock.Lock()
+	defer balanceFetchersLock.Unlock()
+	balanceFetchers[channelType] = fetcher
+}
+
+// GetBalanceFetcher 返回渠道类型的余额查询实现，未注册时返回 nil
+func GetBalanceFetcher(channelType int) BalanceFetcher {
+	balanceFetchersLock.RLock()
+	defer balanceFetchersLock.RUnlock()
+	return balanceFetchers[channelType]
+}
+
+// ChannelBalanceForecast 按最近余额记录估算的消耗速度
+type ChannelBalanceForecast struct {
+	// 每天消耗的余额，充值不计入消耗
+	BurnRatePerDay float64 `json:"burn_rate_per_day"`
+	// 预计剩余可用天数，-1 表示没有足够的记录或没有消耗
+	DaysLeft float64 `json:"days_left"`
+}
+
+// ForecastChannelBalance 根据 histories（按时间升序）估算消耗速度与剩余天数
+func ForecastChannelBalance(histories []*model.ChannelBalanceHistory, balance float64) ChannelBalanceForecast {
+	forecast := ChannelBalanceForecast{DaysLeft: -1}
+	if len(histories) < 2 {
+		return forecast
+	}
+	consumed := 0.0
+	for i := 1; i < len(histories); i++ {
+		consumed += math.Max(histories[i-1].Balance-histories[i].Balance, 0)
+	}
+	elapsed := histories[len(histories)-1].CreatedTime - histories[0].CreatedTime
+	// 记录跨度不足一小时时不估算，避免偶然波动
+	if elapsed < 3600 || consumed <= 0 {
+		return forecast
+	}
+	forecast.BurnRatePerDay = consumed / float64(elapsed) * 24 * 3600
+	forecast.DaysLeft = math.Max(balance, 0) / forecast.BurnRatePerDay
+	return forecast
+}
+
+// GetChannelBalanceForecast 使用配置的历史天数估算渠道余额的消耗速度
+func GetChannelBalanceForecast(channelId int, balance float64) (ChannelBalanceForecast, error) {
+	windowDays := max(operation_setting.GetChannelBalanceSetting().ForecastWindowDays, 1)
+	since := common.GetTimestamp() - int64(windowDays)*24*3600
+	histories, err := model.GetChannelBalanceHistory(channelId, since)
+	if err != nil {
+		return ChannelBalanceForecast{DaysLeft: -1}, err
+	}
+	return ForecastChannelBalance(histories, balance), nil
+}
+
+// GetChannelBalanceThresholds 返回渠道生效的提醒与禁用阈值，渠道设置优先于全局配置
+func GetChannelBalanceThresholds(channel *model.Channel) (warn float64, disable float64) {
+	balanceSetting := operation_setting.GetChannelBalanceSetting()
+	channelSetting := channel.GetSetting()
+	warn, disable = balanceSetting.WarnThreshold, balanceSetting.DisableThreshold
+	if channelSetting.BalanceWarnThreshold != nil {
+		warn = *channelSetting.BalanceWarnThreshold
+	}
+	if channelSetting.BalanceDisableThreshold != nil {
+		disable = *channelSetting.BalanceDisableThreshold
+	}
+	return warn, disable
+}
+
+// ApplyChannelBalance 保存查询到的余额并按阈值处理：
+// 余额低于禁用阈值时自动禁用渠道；低于提醒阈值或预计即将用尽时通知超级管理员，
+// 并在渠道设置了降级优先级时降低优先级，余额恢复后还原优先级并通知
+func ApplyChannelBalance(channel *model.Channel, balance float64) {
+	channel.UpdateBalance(balance)
+	if err := model.RecordChannelBalance(channel.Id, balance); err != nil {
+		common.SysError(fmt.Sprintf("failed to record balance of channel #%d: %s", channel.Id, err.Error()))
+	}
+	if channel.Status != common.ChannelStatusEnabled {
+		return
+	}
+	balanceSetting := operation_setting.GetChannelBalanceSetting()
+	warnThreshold, disableThreshold := GetChannelBalanceThresholds(channel)
+	if balanceSetting.AutoDisable && balance <= disableThreshold {
+		reason := fmt.Sprintf("余额不足（%.2f）", balance)
+		DisableChannel(*types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, "", channel.GetAutoBan()), reason)
+		return
+	}
+
+	forecast, err := GetChannelBalanceForecast(channel.Id, balance)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to forecast balance of channel #%d: %s", channel.Id, err.Error()))
+	}
+	var reason string
+	if warnThreshold > 0 && balance < warnThreshold {
+		reason = fmt.Sprintf("余额 %.2f 低于提醒阈值 %.2f", balance, warnThreshold)
+	} else if balanceSetting.ForecastWarnDays > 0 && forecast.DaysLeft >= 0 && forecast.DaysLeft < balanceSetting.ForecastWarnDays {
+		reason = fmt.Sprintf("余额 %.2f 按每天消耗 %.2f 预计 %.1f 天后用尽", balance, forecast.BurnRatePerDay, forecast.DaysLeft)
+	}
+
+	info := channel.GetOtherInfo()
+	_, alerted := info["balance_alert"]
+	// 只修改余额提醒相关的键，避免覆盖期间其他流程写入的 other_info
+	patch := map[string]interface{}{}
+	if reason != "" && !alerted {
+		patch["balance_alert"] = reason
+		patch["balance_alert_time"] = common.GetTimestamp()
+		content := fmt.Sprintf("渠道「%s」（#%d）%s", channel.Name, channel.Id, reason)
+		if demotePriority := channel.GetSetting().BalanceDemotePriority; demotePriority != nil && channel.GetPriority() > *demotePriority {
+			originPriority := channel.GetPriority()
+			if err := model.UpdateChannelPriority(channel, *demotePriority); err != nil {
+				common.SysError(fmt.Sprintf("failed to demote priority of channel #%d: %s", channel.Id, err.Error()))
+			} else {
+				patch["balance_original_priority"] = originPriority
+				content += fmt.Sprintf("，优先级已降为 %d", *demotePriority)
+			}
+		}
+		subject := fmt.Sprintf("渠道「%s」（#%d）余额不足", channel.Name, channel.Id)
+		NotifyRootUser(fmt.Sprintf("%s_balance_%d", dto.NotifyTypeChannelUpdate, channel.Id), subject, content)
+	} else if reason == "" && alerted {
+		patch["balance_alert"] = nil
+		patch["balance_alert_time"] = nil
+		content := fmt.Sprintf("渠道「%s」（#%d）余额已恢复为 %.2f", channel.Name, channel.Id, balance)
+		if originPriority, ok := info["balance_original_priority"].(float64); ok {
+			patch["balance_original_priority"] = nil
+			if err := model.UpdateChannelPriority(channel, int64(originPriority)); err != nil {
+				common.SysError(fmt.Sprintf("failed to restore priority of channel #%d: %s", channel.Id, err.Error()))
+			} else {
+				content += fmt.Sprintf("，优先级已还原为 %d", int64(originPriority))
+			}
+		}
+		subject := fmt.Sprintf("渠道「%s」（#%d）余额已恢复", channel.Name, channel.Id)
+		NotifyRootUser(fmt.Sprintf("%s_balance_recovered_%d", dto.NotifyTypeChannelUpdate, channel.Id), subject, content)
+	} else {
+		return
+	}
+	if err := model.PatchChannelOtherInfo(channel, patch); err != nil {
+		common.SysError(fmt.Sprintf("failed to save balance alert of channel #%d: %s", channel.Id, err.Error()))
+	}
+}
+
+// CleanChannelBalanceHistory 按保留天数清理余额历史
+func CleanChannelBalanceHistory() {
+	retentionDays := operation_setting.GetChannelBalanceSetting().HistoryRetentionDays
+	if retentionDays <= 0 {
+		return
+	}
+	before := common.GetTimestamp() - int64(retentionDays)*24*3600
+	if _, err := model.DeleteChannelBalanceHistoryBefore(before); err != nil {
+		common.SysError("failed to clean channel balance history: " + err.Error())
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/samber/lo"
+)
+
+const (
+	channelInflightNodesKey   = "channel_inflight_nodes"
+	channelInflightSyncPeriod = 5 * time.Second
+	channelInflightTTL        = 3 * channelInflightSyncPeriod
+)
+
+// 本节点各渠道进行中的请求数
+var channelInflight sync.Map // map[int]*atomic.Int64
+
+// BeginChannelRequest 记录一个发往渠道的请求，返回请求结束时调用的函数
+func BeginChannelRequest(channelId int) func() {
+	value, _ := channelInflight.LoadOrStore(channelId, new(atomic.Int64))
+	counter := value.(*atomic.Int64)
+	counter.Add(1)
+	return func() {
+		counter.Add(-1)
+	}
+}
+
+func localChannelInflight() map[int]int64 {
+	inflight := make(map[int]int64)
+	channelInflight.Range(func(key, value any) bool {
+		if count := value.(*atomic.Int64).Load(); count > 0 {
+			inflight[key.(int)] = count
+		}
+		return true
+	})
+	return inflight
+}
+
+func channelInflightKey(node string) string {
+	return "channel_inflight:" + node
+}
+
+// GetChannelInflight 返回各渠道进行中的请求数，启用 Redis 时汇总所有节点上报的数据
+func GetChannelInflight() map[int]int64 {
+	inflight := localChannelInflight()
+	if !common.RedisEnabled {
+		return inflight
+	}
+	ctx := context.Background()
+	nodes, err := common.RDB.SMembers(ctx, channelInflightNodesKey).Result()
+	if err != nil {
+		common.SysError("failed to get channel inflight nodes: " + err.Error())
+		return inflight
+	}
+	for _, node := range nodes {
+		if node == nodeName {
+			continue
+		}
+		data, err := common.RDB.Get(ctx, channelInflightKey(node)).Result()
+		if errors.Is(err, redis.Nil) {
+			// 节点已下线
+			common.RDB.SRem(ctx, channelInflightNodesKey, node)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		var nodeInflight map[int]int64
+		if err := json.Unmarshal([]byte(data), &nodeInflight); err != nil {
+			continue
+		}
+		for channelId, count := range nodeInflight {
+			inflight[channelId] += count
+		}
+	}
+	return inflight
+}
+
+// AutomaticallySyncChannelInflight 启用 Redis 时定期上报本节点的进行中请求数
+func AutomaticallySyncChannelInflight() {
+	if !common.RedisEnabled {
+		return
+	}
+	ctx := context.Background()
+	for {
+		data, _ := json.Marshal(localChannelInflight())
+		if err := common.RDB.Set(ctx, channelInflightKey(nodeName), string(data), channelInflightTTL).Err(); err != nil {
+			common.SysError("failed to sync channel inflight: " + err.Error())
+		} else {
+			common.RDB.SAdd(ctx, channelInflightNodesKey, nodeName)
+		}
+		time.Sleep(channelInflightSyncPeriod)
+	}
+}
+
+// ResolveChannelIds 合并渠道 id 与标签下的渠道
+func ResolveChannelIds(channelIds []int, tag string) ([]int, error) {
+	ids := append([]int{}, channelIds...)
+	if tag != "" {
+		channels, err := model.GetChannelsByTag(tag, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, channel := range channels {
+			if !lo.Contains(ids, channel.Id) {
+				ids = append(ids, channel.Id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// FormatChannelIds 以逗号拼接渠道 id
+func FormatChannelIds(ids []int) string {
+	return strings.Join(lo.Map(ids, func(id int, _ int) string {
+		return strconv.Itoa(id)
+	}), ",")
+}
+
+// DrainChannels 将渠道切换为排空状态并通知管理员
+func DrainChannels(channelIds []int, reason string) ([]int, error) {
+	drained, err := model.DrainChannels(channelIds, reason)
+	if len(drained) > 0 {
+		subject := fmt.Sprintf("渠道 #%s 已进入排空状态", FormatChannelIds(drained))
+		content := fmt.Sprintf("渠道 #%s 已进入排空状态，不再分配新请求，进行中的请求将正常完成，原因：%s", FormatChannelIds(drained), reason)
+		NotifyRootUser(fmt.Sprintf("%s_drain_%s", dto.NotifyTypeChannelUpdate, FormatChannelIds(drained)), subject, content)
+	}
+	return drained, err
+}
+
+// RestoreDrainingChannels 将排空中的渠道恢复为启用并通知管理员
+func RestoreDrainingChannels(channelIds []int, reason string) ([]int, error) {
+	restored, err := model.RestoreDrainingChannels(channelIds)
+	if len(restored) > 0 {
+		subject := fmt.Sprintf("渠道 #%s 已恢复启用", FormatChannelIds(restored))
+		content := fmt.Sprintf("渠道 #%s 已结束排空并恢复启用，原因：%s", FormatChannelIds(restored), reason)
+		NotifyRootUser(fmt.Sprintf("%s_restore_%s", dto.NotifyTypeChannelUpdate, FormatChannelIds(restored)), subject, content)
+	}
+	return restored, err
+}
+
+// CheckDrainedChannels 排空中的渠道没有进行中的请求时通知管理员可以安全维护
+func CheckDrainedChannels() {
+	channels, err := model.GetDrainingChannels()
+	if err != nil {
+		common.SysError("failed to get draining channels: " + err.Error())
+		return
+	}
+	if len(channels) == 0 {
+		return
+	}
+	inflight := GetChannelInflight()
+	for _, channel := range channels {
+		if inflight[channel.Id] > 0 {
+			continue
+		}
+		if _, ok := channel.GetOtherInfo()["drained_time"]; ok {
+			continue
+		}
+		if err := model.MarkChannelDrained(channel); err != nil {
+			common.SysError(fmt.Sprintf("failed to mark channel #%d drained: %s", channel.Id, err.Error()))
+			continue
+		}
+		subject := fmt.Sprintf("渠道「%s」（#%d）已排空", channel.Name, channel.Id)
+		content := fmt.Sprintf("渠道「%s」（#%d）已没有进行中的请求，可以安全维护", channel.Name, channel.Id)
+		NotifyRootUser(fmt.Sprintf("%s_drained_%d", dto.NotifyTypeChannelUpdate, channel.Id), subject, content)
+	}
+}
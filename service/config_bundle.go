@@ -0,0 +1,670 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const ConfigBundleVersion = 1
+
+type configBundleSection struct {
+	name   string
+	values func(bundle *dto.ConfigBundle) *map[string]any
+	fields map[string]string // 字段 -> options 键
+}
+
+var configBundleSections = []configBundleSection{
+	{"ratios", func(bundle *dto.ConfigBundle) *map[string]any { return &bundle.Ratios }, map[string]string{
+		"model_ratio":      "ModelRatio",
+		"completion_ratio": "CompletionRatio",
+		"model_price":      "ModelPrice",
+		"cache_ratio":      "CacheRatio",
+	}},
+	{"groups", func(bundle *dto.ConfigBundle) *map[string]any { return &bundle.Groups }, map[string]string{
+		"group_ratio":        "GroupRatio",
+		"group_group_ratio":  "GroupGroupRatio",
+		"user_usable_groups": "UserUsableGroups",
+		"topup_group_ratio":  "TopupGroupRatio",
+	}},
+	{"auto_groups", func(bundle *dto.ConfigBundle) *map[string]any { return &bundle.AutoGroups }, map[string]string{
+		"groups":                 "AutoGroups",
+		"default_use_auto_group": "DefaultUseAutoGroup",
+	}},
+	{"rate_limits", func(bundle *dto.ConfigBundle) *map[string]any { return &bundle.RateLimits }, map[string]string{
+		"enabled":          "ModelRequestRateLimitEnabled",
+		"duration_minutes": "ModelRequestRateLimitDurationMinutes",
+		"count":            "ModelRequestRateLimitCount",
+		"success_count":    "ModelRequestRateLimitSuccessCount",
+		"group":            "ModelRequestRateLimitGroup",
+	}},
+}
+
+// isSensitiveOption 判断是否为密钥、令牌等敏感配置，这类配置不进入配置包
+func isSensitiveOption(key string) bool {
+	lower := strings.ToLower(key)
+	for _, suffix := range []string{"token", "secret", "key", "hash", "password"} {
+		if strings.HasSuffix(lower, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSectionOption(key string) bool {
+	for _, section := range configBundleSections {
+		for _, optionKey := range section.fields {
+			if optionKey == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decodeOptionValue 将 options 中的字符串转为配置包中的值，JSON 对象与数组展开，
+// 分区字段的布尔值与数字也按类型导出
+func decodeOptionValue(value string, typed bool) any {
+	trimmed := strings.TrimSpace(value)
+	if typed || strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var decoded any
+		if err := json.Unmarshal([]byte(trimmed), &decoded); err == nil {
+			return decoded
+		}
+	}
+	return value
+}
+
+// encodeOptionValue 将配置包中的值转为 options 中保存的字符串
+func encodeOptionValue(value any) (string, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// normalizeOptionValue 统一 JSON 值的格式，用于比较
+func normalizeOptionValue(value string) string {
+	trimmed := strings.TrimSpace(value)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return value
+	}
+	var decoded any
+	if err := json.Unmarshal([]byte(trimmed), &decoded); err != nil {
+		return value
+	}
+	data, _ := json.Marshal(decoded)
+	return string(data)
+}
+
+// ExportConfigBundle 导出当前配置，渠道密钥不导出
+func ExportConfigBundle() (*dto.ConfigBundle, error) {
+	bundle := &dto.ConfigBundle{
+		Version: ConfigBundleVersion,
+		Options: make(map[string]any),
+	}
+	common.OptionMapRWMutex.RLock()
+	for key, value := range common.OptionMap {
+		if isSensitiveOption(key) || isSectionOption(key) {
+			continue
+		}
+		bundle.Options[key] = decodeOptionValue(value, false)
+	}
+	for _, section := range configBundleSections {
+		values := make(map[string]any)
+		for field, optionKey := range section.fields {
+			if value, ok := common.OptionMap[optionKey]; ok {
+				values[field] = decodeOptionValue(value, true)
+			}
+		}
+		*section.values(bundle) = values
+	}
+	common.OptionMapRWMutex.RUnlock()
+
+	channels, err := model.GetAllChannels(0, 0, true, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, channel := range channels {
+		bundle.Channels = append(bundle.Channels, exportConfigBundleChannel(channel))
+	}
+	return bundle, nil
+}
+
+func decodeJSONString[T any](value *string) T {
+	var decoded T
+	if value != nil && *value != "" {
+		_ = json.Unmarshal([]byte(*value), &decoded)
+	}
+	return decoded
+}
+
+func exportConfigBundleChannel(channel *model.Channel) dto.ConfigBundleChannel {
+	enabled := channel.Status != common.ChannelStatusManuallyDisabled
+	autoBan := channel.GetAutoBan()
+	item := dto.ConfigBundleChannel{
+		Name:              channel.Name,
+		Type:              channel.Type,
+		Enabled:           &enabled,
+		BaseURL:           channel.GetBaseURL(),
+		Models:            channel.GetModels(),
+		Groups:            channel.GetGroups(),
+		Tag:               channel.GetTag(),
+		Priority:          channel.GetPriority(),
+		Weight:            uint(channel.GetWeight()),
+		AutoBan:           &autoBan,
+		ModelMapping:      decodeJSONString[map[string]string](channel.ModelMapping),
+		StatusCodeMapping: decodeJSONString[map[string]string](channel.StatusCodeMapping),
+		Setting:           decodeJSONString[map[string]any](channel.Setting),
+		ParamOverride:     decodeJSONString[map[string]any](channel.ParamOverride),
+		Other:             channel.Other,
+	}
+	if channel.ChannelInfo.IsMultiKey {
+		item.MultiKeyMode = string(channel.ChannelInfo.MultiKeyMode)
+	}
+	if channel.OpenAIOrganization != nil {
+		item.OpenAIOrganization = *channel.OpenAIOrganization
+	}
+	if channel.TestModel != nil {
+		item.TestModel = *channel.TestModel
+	}
+	return item
+}
+
+// ParseConfigBundle 解析 YAML 或 JSON 格式的配置包
+func ParseConfigBundle(data []byte) (*dto.ConfigBundle, error) {
+	// JSON 是 YAML 的子集，统一按 YAML 解析后转为 JSON，只使用 json 标签并拒绝未知字段
+	var raw any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("配置包格式错误：%s", err.Error())
+	}
+	if raw == nil {
+		return nil, errors.New("配置包为空")
+	}
+	jsonData, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("配置包格式错误：%s", err.Error())
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(jsonData)))
+	decoder.DisallowUnknownFields()
+	bundle := &dto.ConfigBundle{}
+	if err = decoder.Decode(bundle); err != nil {
+		return nil, fmt.Errorf("配置包格式错误：%s", err.Error())
+	}
+	if bundle.Version != ConfigBundleVersion {
+		return nil, fmt.Errorf("不支持的配置包版本 %d", bundle.Version)
+	}
+	return bundle, nil
+}
+
+// EncodeConfigBundle 将配置包编码为 yaml 或 json，字段按名称排序，便于在 git 中对比
+func EncodeConfigBundle(bundle *dto.ConfigBundle, format string) ([]byte, error) {
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, err
+	}
+	var raw any
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if format == "json" {
+		return json.MarshalIndent(raw, "", "  ")
+	}
+	return yaml.Marshal(raw)
+}
+
+// resolveKeyRef 读取密钥引用 env:变量名 或 file:文件路径，只用于启动时应用的配置包，
+// 通过接口提交的配置包不能读取服务器上的环境变量与文件
+func resolveKeyRef(ref string) (string, error) {
+	var key string
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		key = os.Getenv(strings.TrimPrefix(ref, "env:"))
+		if key == "" {
+			return "", fmt.Errorf("环境变量 %s 未设置", strings.TrimPrefix(ref, "env:"))
+		}
+	case strings.HasPrefix(ref, "file:"):
+		data, err := os.ReadFile(strings.TrimPrefix(ref, "file:"))
+		if err != nil {
+			return "", fmt.Errorf("读取密钥文件失败：%s", err.Error())
+		}
+		key = string(data)
+	default:
+		return "", fmt.Errorf("密钥引用 %s 无效，应为 env:变量名 或 file:文件路径", ref)
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", fmt.Errorf("密钥引用 %s 的内容为空", ref)
+	}
+	return key, nil
+}
+
+// ConfigPlanChange 配置包与当前配置的一项差异
+type ConfigPlanChange struct {
+	Kind      string   `json:"kind"`   // option 或 channel
+	Action    string   `json:"action"` // create、update 或 delete
+	Target    string   `json:"target"` // 配置键或渠道名称
+	ChannelId int      `json:"channel_id,omitempty"`
+	Fields    []string `json:"fields,omitempty"`
+	Before    any      `json:"before,omitempty"`
+	After     any      `json:"after,omitempty"`
+}
+
+type ConfigPlan struct {
+	Changes []ConfigPlanChange `json:"changes"`
+	// 未出现在配置包中且未删除的渠道
+	UnmanagedChannels []string `json:"unmanaged_channels"`
+
+	options  map[string]string
+	channels []plannedChannel
+	deletes  []int
+}
+
+type plannedChannel struct {
+	channel *model.Channel
+	columns []string
+}
+
+// PlanConfigBundle 对比配置包与当前配置，prune 为 true 时删除未出现在配置包中的渠道；
+// resolveKeyRefs 为 false 时拒绝包含 key_ref 的渠道
+func PlanConfigBundle(bundle *dto.ConfigBundle, prune bool, resolveKeyRefs bool) (*ConfigPlan, error) {
+	plan := &ConfigPlan{
+		Changes:           make([]ConfigPlanChange, 0),
+		UnmanagedChannels: make([]string, 0),
+		options:           make(map[string]string),
+	}
+	if err := planConfigBundleOptions(plan, bundle); err != nil {
+		return nil, err
+	}
+	if err := planConfigBundleChannels(plan, bundle, prune, resolveKeyRefs); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func planConfigBundleOptions(plan *ConfigPlan, bundle *dto.ConfigBundle) error {
+	desired := make(map[string]any)
+	for key, value := range bundle.Options {
+		if isSensitiveOption(key) {
+			return fmt.Errorf("敏感配置 %s 不能通过配置包导入", key)
+		}
+		if isSectionOption(key) {
+			return fmt.Errorf("配置 %s 请写在对应的分区中", key)
+		}
+		desired[key] = value
+	}
+	for _, section := range configBundleSections {
+		for field, value := range *section.values(bundle) {
+			optionKey, ok := section.fields[field]
+			if !ok {
+				return fmt.Errorf("%s 分区不支持字段 %s", section.name, field)
+			}
+			desired[optionKey] = value
+		}
+	}
+
+	keys := make([]string, 0, len(desired))
+	for key := range desired {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	encoded := make(map[string]string, len(desired))
+	for _, key := range keys {
+		value, err := encodeOptionValue(desired[key])
+		if err != nil {
+			return fmt.Errorf("配置 %s 的值无效：%s", key, err.Error())
+		}
+		encoded[key] = value
+	}
+	common.OptionMapRWMutex.RLock()
+	defer common.OptionMapRWMutex.RUnlock()
+	for _, key := range keys {
+		current, ok := common.OptionMap[key]
+		if !ok {
+			return fmt.Errorf("未知的配置 %s", key)
+		}
+		value := encoded[key]
+		if err := checkOptionValueKind(key, current, value); err != nil {
+			return err
+		}
+		if normalizeOptionValue(value) == normalizeOptionValue(current) {
+			continue
+		}
+		// 与修改设置接口使用相同的校验，依赖的其他配置以配置包中的值为准
+		if err := ValidateOption(key, value, encoded); err != nil {
+			return fmt.Errorf("配置 %s 的值无效：%s", key, err.Error())
+		}
+		plan.options[key] = value
+		plan.Changes = append(plan.Changes, ConfigPlanChange{
+			Kind:   "option",
+			Action: "update",
+			Target: key,
+			Before: current,
+			After:  value,
+		})
+	}
+	return nil
+}
+
+// checkOptionValueKind 要求新值与当前值的类型一致（JSON 对象、数组、布尔值或数字）
+func checkOptionValueKind(key string, current string, value string) error {
+	current = strings.TrimSpace(current)
+	value = strings.TrimSpace(value)
+	var decoded any
+	switch {
+	case strings.HasPrefix(current, "{"):
+		if json.Unmarshal([]byte(value), &decoded) != nil {
+			return fmt.Errorf("配置 %s 应为对象", key)
+		}
+		if _, ok := decoded.(map[string]any); !ok {
+			return fmt.Errorf("配置 %s 应为对象", key)
+		}
+	case strings.HasPrefix(current, "["):
+		if json.Unmarshal([]byte(value), &decoded) != nil {
+			return fmt.Errorf("配置 %s 应为数组", key)
+		}
+		if _, ok := decoded.([]any); !ok {
+			return fmt.Errorf("配置 %s 应为数组", key)
+		}
+	case current == "true" || current == "false":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("配置 %s 应为布尔值", key)
+		}
+	default:
+		if _, err := strconv.ParseFloat(current, 64); err == nil {
+			if _, err = strconv.ParseFloat(value, 64); err != nil {
+				return fmt.Errorf("配置 %s 应为数字", key)
+			}
+		}
+	}
+	return nil
+}
+
+func planConfigBundleChannels(plan *ConfigPlan, bundle *dto.ConfigBundle, prune bool, resolveKeyRefs bool) error {
+	channels, err := model.GetAllChannels(0, 0, true, true)
+	if err != nil {
+		return err
+	}
+	liveChannels := make(map[string][]*model.Channel)
+	for _, channel := range channels {
+		liveChannels[channel.Name] = append(liveChannels[channel.Name], channel)
+	}
+
+	managed := make(map[string]bool)
+	for i := range bundle.Channels {
+		item := bundle.Channels[i]
+		item.Name = strings.TrimSpace(item.Name)
+		if item.Name == "" {
+			return fmt.Errorf("第 %d 个渠道缺少名称", i+1)
+		}
+		if managed[item.Name] {
+			return fmt.Errorf("渠道 %s 重复", item.Name)
+		}
+		managed[item.Name] = true
+		if len(liveChannels[item.Name]) > 1 {
+			return fmt.Errorf("存在多个名为 %s 的渠道，无法按名称匹配", item.Name)
+		}
+		var live *model.Channel
+		if len(liveChannels[item.Name]) == 1 {
+			live = liveChannels[item.Name][0]
+		}
+		if err = planConfigBundleChannel(plan, &item, live, resolveKeyRefs); err != nil {
+			return fmt.Errorf("渠道 %s：%s", item.Name, err.Error())
+		}
+	}
+
+	for _, channel := range channels {
+		if managed[channel.Name] {
+			continue
+		}
+		if !prune {
+			plan.UnmanagedChannels = append(plan.UnmanagedChannels, channel.Name)
+			continue
+		}
+		plan.deletes = append(plan.deletes, channel.Id)
+		plan.Changes = append(plan.Changes, ConfigPlanChange{
+			Kind:      "channel",
+			Action:    "delete",
+			Target:    channel.Name,
+			ChannelId: channel.Id,
+		})
+	}
+	return nil
+}
+
+func planConfigBundleChannel(plan *ConfigPlan, item *dto.ConfigBundleChannel, live *model.Channel, resolveKeyRefs bool) error {
+	if item.Type <= constant.ChannelTypeUnknown || item.Type >= constant.ChannelTypeDummy {
+		return fmt.Errorf("渠道类型 %d 无效", item.Type)
+	}
+	if item.MultiKeyMode != "" && item.MultiKeyMode != string(constant.MultiKeyModeRandom) && item.MultiKeyMode != string(constant.MultiKeyModePolling) {
+		return fmt.Errorf("多密钥模式 %s 无效", item.MultiKeyMode)
+	}
+	var key string
+	if item.KeyRef != "" {
+		if !resolveKeyRefs {
+			return errors.New("key_ref 只能在启动时通过 --config-bundle 应用")
+		}
+		var err error
+		if key, err = resolveKeyRef(item.KeyRef); err != nil {
+			return err
+		}
+	} else if live == nil {
+		return errors.New("新渠道需要设置 key_ref，只能在启动时通过 --config-bundle 创建")
+	}
+
+	channel := &model.Channel{}
+	var before dto.ConfigBundleChannel
+	if live != nil {
+		copied := *live
+		channel = &copied
+		before = exportConfigBundleChannel(live)
+		// 未设置的字段保持不变，多密钥模式只随密钥一起修改
+		if item.Enabled == nil {
+			item.Enabled = before.Enabled
+		}
+		if item.AutoBan == nil {
+			item.AutoBan = before.AutoBan
+		}
+		if item.KeyRef == "" {
+			item.MultiKeyMode = before.MultiKeyMode
+		}
+	} else {
+		channel.CreatedTime = common.GetTimestamp()
+		channel.Status = common.ChannelStatusEnabled
+		enabled, autoBan := true, true
+		if item.Enabled == nil {
+			item.Enabled = &enabled
+		}
+		if item.AutoBan == nil {
+			item.AutoBan = &autoBan
+		}
+	}
+	if len(item.Groups) == 0 {
+		item.Groups = []string{"default"}
+	}
+	applyConfigBundleChannel(channel, item)
+	if err := channel.ValidateSettings(); err != nil {
+		return fmt.Errorf("渠道额外设置格式错误：%s", err.Error())
+	}
+	columns := []string{"name", "type", "status", "base_url", "openai_organization", "test_model", "models", "group",
+		"tag", "priority", "weight", "auto_ban", "model_mapping", "status_code_mapping", "setting", "param_override", "other"}
+
+	after := *item
+	after.KeyRef = ""
+	fields := diffConfigBundleChannel(before, after)
+	keyChanged := item.KeyRef != "" && (live == nil || live.Key != key)
+	if keyChanged {
+		channel.Key = key
+		channel.ChannelInfo.IsMultiKey = item.MultiKeyMode != ""
+		channel.ChannelInfo.MultiKeyMode = constant.MultiKeyMode(item.MultiKeyMode)
+		channel.ChannelInfo.MultiKeySize = 0
+		if channel.ChannelInfo.IsMultiKey {
+			for _, line := range strings.Split(key, "\n") {
+				if strings.TrimSpace(line) != "" {
+					channel.ChannelInfo.MultiKeySize++
+				}
+			}
+		}
+		channel.ChannelInfo.MultiKeyStatusList = nil
+		channel.ChannelInfo.MultiKeyPollingIndex = 0
+		columns = append(columns, "key", "channel_info")
+		fields = append(fields, "key")
+	}
+
+	if live == nil {
+		plan.channels = append(plan.channels, plannedChannel{channel: channel})
+		plan.Changes = append(plan.Changes, ConfigPlanChange{
+			Kind:   "channel",
+			Action: "create",
+			Target: item.Name,
+			After:  after,
+		})
+		return nil
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	plan.channels = append(plan.channels, plannedChannel{channel: channel, columns: columns})
+	beforeValues, afterValues := pickConfigBundleFields(before, fields), pickConfigBundleFields(after, fields)
+	if keyChanged {
+		// 不展示密钥内容
+		beforeValues["key"], afterValues["key"] = "***", "***"
+	}
+	plan.Changes = append(plan.Changes, ConfigPlanChange{
+		Kind:      "channel",
+		Action:    "update",
+		Target:    item.Name,
+		ChannelId: live.Id,
+		Fields:    fields,
+		Before:    beforeValues,
+		After:     afterValues,
+	})
+	return nil
+}
+
+func applyConfigBundleChannel(channel *model.Channel, item *dto.ConfigBundleChannel) {
+	optional := func(value string) *string {
+		if value == "" {
+			return nil
+		}
+		return &value
+	}
+	encode := func(value any) *string {
+		if reflect.ValueOf(value).Len() == 0 {
+			return nil
+		}
+		data, _ := json.Marshal(value)
+		s := string(data)
+		return &s
+	}
+	channel.Name = item.Name
+	channel.Type = item.Type
+	if *item.Enabled && channel.Status == common.ChannelStatusManuallyDisabled {
+		channel.Status = common.ChannelStatusEnabled
+	} else if !*item.Enabled {
+		channel.Status = common.ChannelStatusManuallyDisabled
+	}
+	baseURL := item.BaseURL
+	channel.BaseURL = &baseURL
+	channel.OpenAIOrganization = optional(item.OpenAIOrganization)
+	channel.TestModel = optional(item.TestModel)
+	channel.Models = strings.Join(item.Models, ",")
+	channel.Group = strings.Join(item.Groups, ",")
+	channel.Tag = optional(item.Tag)
+	priority := item.Priority
+	channel.Priority = &priority
+	weight := item.Weight
+	channel.Weight = &weight
+	autoBan := 0
+	if *item.AutoBan {
+		autoBan = 1
+	}
+	channel.AutoBan = &autoBan
+	channel.ModelMapping = encode(item.ModelMapping)
+	statusCodeMapping := ""
+	if encoded := encode(item.StatusCodeMapping); encoded != nil {
+		statusCodeMapping = *encoded
+	}
+	channel.StatusCodeMapping = &statusCodeMapping
+	channel.Setting = encode(item.Setting)
+	channel.ParamOverride = encode(item.ParamOverride)
+	channel.Other = item.Other
+}
+
+// diffConfigBundleChannel 按 JSON 字段比较渠道配置，返回不同的字段名
+func diffConfigBundleChannel(before, after dto.ConfigBundleChannel) []string {
+	beforeValues, afterValues := configBundleChannelValues(before), configBundleChannelValues(after)
+	fields := make([]string, 0)
+	for field := range afterValues {
+		if _, ok := beforeValues[field]; !ok {
+			beforeValues[field] = nil
+		}
+	}
+	for field, value := range beforeValues {
+		if !reflect.DeepEqual(value, afterValues[field]) {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func configBundleChannelValues(item dto.ConfigBundleChannel) map[string]any {
+	data, _ := json.Marshal(item)
+	values := make(map[string]any)
+	_ = json.Unmarshal(data, &values)
+	return values
+}
+
+func pickConfigBundleFields(item dto.ConfigBundleChannel, fields []string) map[string]any {
+	values := configBundleChannelValues(item)
+	picked := make(map[string]any, len(fields))
+	for _, field := range fields {
+		picked[field] = values[field]
+	}
+	return picked
+}
+
+// ApplyConfigPlan 执行对比结果：在同一事务中更新配置、创建或更新渠道、删除渠道，任一步失败时全部回滚
+func ApplyConfigPlan(plan *ConfigPlan) error {
+	if err := PrepareOptions(plan.options); err != nil {
+		return err
+	}
+	changes := make([]model.ChannelChange, 0, len(plan.channels))
+	for _, planned := range plan.channels {
+		changes = append(changes, model.ChannelChange{Channel: planned.channel, Columns: planned.columns})
+	}
+	return model.ApplyConfigChanges(plan.options, changes, plan.deletes)
+}
+
+// ApplyConfigBundleFile 启动时应用磁盘上的配置包，不删除配置包外的渠道
+func ApplyConfigBundleFile(path string) (*ConfigPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	bundle, err := ParseConfigBundle(data)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := PlanConfigBundle(bundle, false, true)
+	if err != nil {
+		return nil, err
+	}
+	return plan, ApplyConfigPlan(plan)
+}
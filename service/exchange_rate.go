@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"os"
+	"strings"
+	"time"
+)
+
+const exchangeRateFetchTimeout = 10 * time.Second
+
+// exchangeRateResponse 兼容常见汇率接口返回格式，例如 {"base":"USD","rates":{"CNY":7.1}}
+type exchangeRateResponse struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+func readExchangeRateSource(source string) ([]byte, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		ctx, cancel := context.WithTimeout(context.Background(), exchangeRateFetchTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("汇率数据源返回状态码 %d", resp.StatusCode)
+		}
+		return io.ReadAll(resp.Body)
+	}
+	return os.ReadFile(strings.TrimPrefix(source, "file://"))
+}
+
+// FetchExchangeRates 从数据源读取汇率，结果统一换算为以 USD 为基准
+func FetchExchangeRates(source string) (map[string]float64, error) {
+	if source == "" {
+		return nil, errors.New("未配置汇率数据源")
+	}
+	body, err := readExchangeRateSource(source)
+	if err != nil {
+		return nil, err
+	}
+	var resp exchangeRateResponse
+	if err = common.Unmarshal(body, &resp); err != nil || len(resp.Rates) == 0 {
+		// 兼容直接返回 {"CNY":7.1} 的格式
+		resp = exchangeRateResponse{Base: operation_setting.CurrencyUSD}
+		if err = common.Unmarshal(body, &resp.Rates); err != nil {
+			return nil, fmt.Errorf("解析汇率数据失败: %w", err)
+		}
+	}
+	base := strings.ToUpper(resp.Base)
+	if base == "" {
+		base = operation_setting.CurrencyUSD
+	}
+	baseRate := 1.0
+	if base != operation_setting.CurrencyUSD {
+		// 以其他币种为基准时换算为 USD 基准
+		usdRate, ok := resp.Rates[operation_setting.CurrencyUSD]
+		if !ok || usdRate <= 0 {
+			return nil, fmt.Errorf("汇率数据缺少 USD 汇率，无法从 %s 换算", base)
+		}
+		baseRate = usdRate
+	}
+	rates := make(map[string]float64)
+	for code, rate := range resp.Rates {
+		code = strings.ToUpper(code)
+		if rate <= 0 || code == operation_setting.CurrencyUSD {
+			continue
+		}
+		rates[code] = rate / baseRate
+	}
+	if base != operation_setting.CurrencyUSD {
+		rates[base] = 1 / baseRate
+	}
+	return rates, nil
+}
+
+// SyncExchangeRates 从配置的数据源同步汇率并保存到配置
+func SyncExchangeRates() (map[string]float64, error) {
+	rates, err := FetchExchangeRates(operation_setting.GetCurrencySetting().RateSourceUrl)
+	if err != nil {
+		return nil, err
+	}
+	if err = model.UpdateOption("currency_setting.exchange_rates", common.GetJsonString(rates)); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// AutomaticallySyncExchangeRates 按配置的间隔定时同步汇率
+func AutomaticallySyncExchangeRates() {
+	for {
+		interval := operation_setting.GetCurrencySetting().RateSyncIntervalMinutes
+		if interval <= 0 || operation_setting.GetCurrencySetting().RateSourceUrl == "" {
+			time.Sleep(time.Minute)
+			continue
+		}
+		if _, err := SyncExchangeRates(); err != nil {
+			common.SysError("failed to sync exchange rates: " + err.Error())
+		} else {
+			common.SysLog("exchange rates synced")
+		}
+		time.Sleep(time.Duration(interval) * time.Minute)
+	}
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"one-api/common"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// geoIpRange IP 段统一按 16 字节（IPv4 映射为 IPv6）比较
+type geoIpRange struct {
+	start   [16]byte
+	end     [16]byte
+	country string
+}
+
+type geoIpDb struct {
+	path   string
+	ranges []geoIpRange
+}
+
+var (
+	geoIpDbCache *geoIpDb
+	geoIpDbLock  sync.RWMutex
+)
+
+func parseGeoIpAddr(s string) (net.IP, bool) {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	if ip := net.ParseIP(s); ip != nil {
+		return ip.To16(), true
+	}
+	// IP2Location 等格式使用十进制整数表示 IP
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 || n.BitLen() > 128 {
+		return nil, false
+	}
+	if n.BitLen() <= 32 {
+		ip := make(net.IP, 4)
+		n.FillBytes(ip)
+		return ip.To16(), true
+	}
+	ip := make(net.IP, 16)
+	n.FillBytes(ip)
+	return ip, true
+}
+
+// loadGeoIpDb 读取 CSV 格式的 IP 国家数据库，每行为“起始 IP,结束 IP,国家代码”，无法解析的行（如表头）会被跳过
+func loadGeoIpDb(path string) (*geoIpDb, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	db := &geoIpDb{path: path}
+	countries := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ",")
+		if len(fields) < 3 {
+			continue
+		}
+		start, ok := parseGeoIpAddr(fields[0])
+		if !ok {
+			continue
+		}
+		end, ok := parseGeoIpAddr(fields[1])
+		if !ok {
+			continue
+		}
+		country := strings.ToUpper(strings.Trim(strings.TrimSpace(fields[2]), `"`))
+		if country == "" || country == "-" {
+			continue
+		}
+		if interned, ok := countries[country]; ok {
+			country = interned
+		} else {
+			countries[country] = country
+		}
+		r := geoIpRange{country: country}
+		copy(r.start[:], start)
+		copy(r.end[:], end)
+		db.ranges = append(db.ranges, r)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(db.ranges) == 0 {
+		return nil, errors.New("IP 国家数据库中没有有效的记录")
+	}
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return bytes.Compare(db.ranges[i].start[:], db.ranges[j].start[:]) < 0
+	})
+	return db, nil
+}
+
+// ReloadGeoIpDb 重新加载 IP 国家数据库，path 为空时清除已加载的数据库
+func ReloadGeoIpDb(path string) error {
+	if path == "" {
+		geoIpDbLock.Lock()
+		geoIpDbCache = nil
+		geoIpDbLock.Unlock()
+		return nil
+	}
+	db, err := loadGeoIpDb(path)
+	if err != nil {
+		return fmt.Errorf("加载 IP 国家数据库失败：%w", err)
+	}
+	geoIpDbLock.Lock()
+	geoIpDbCache = db
+	geoIpDbLock.Unlock()
+	common.SysLog(fmt.Sprintf("loaded %d geoip ranges from %s", len(db.ranges), path))
+	return nil
+}
+
+func getGeoIpDb(path string) *geoIpDb {
+	geoIpDbLock.RLock()
+	db := geoIpDbCache
+	geoIpDbLock.RUnlock()
+	if db != nil && db.path == path {
+		return db
+	}
+	geoIpDbLock.Lock()
+	defer geoIpDbLock.Unlock()
+	if geoIpDbCache != nil && geoIpDbCache.path == path {
+		return geoIpDbCache
+	}
+	db, err := loadGeoIpDb(path)
+	if err != nil {
+		common.SysError("failed to load geoip database: " + err.Error())
+		// 记录空数据库，避免每个请求都重新读取文件
+		db = &geoIpDb{path: path}
+	}
+	geoIpDbCache = db
+	return db
+}
+
+// LookupIpCountry 返回 IP 所属的国家代码，未找到时返回空字符串
+func LookupIpCountry(path string, ip net.IP) string {
+	if path == "" || ip == nil {
+		return ""
+	}
+	db := getGeoIpDb(path)
+	var key [16]byte
+	copy(key[:], ip.To16())
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return bytes.Compare(db.ranges[i].start[:], key[:]) > 0
+	})
+	if i == 0 {
+		return ""
+	}
+	r := db.ranges[i-1]
+	if bytes.Compare(key[:], r.end[:]) <= 0 {
+		return r.country
+	}
+	return ""
+}
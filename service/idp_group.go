@@ -0,0 +1,63 @@
+package service
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/ratio_setting"
+	"one-api/setting/system_setting"
+)
+
+// ResolveIdpGroup 按设置顺序返回用户所属 IdP 组命中的第一条映射
+func ResolveIdpGroup(idpGroups []string) (*system_setting.IdpGroupMapping, bool) {
+	for _, mapping := range system_setting.GetIdpGroupSettings().Mappings {
+		if common.StringsContains(idpGroups, mapping.IdpGroup) {
+			return &mapping, true
+		}
+	}
+	return nil, false
+}
+
+// ApplyIdpGroups 根据用户所属的 IdP 组更新网关分组，分组变化时按映射的预设额度补足剩余额度
+func ApplyIdpGroups(userId int, idpGroups []string) error {
+	group := system_setting.GetIdpGroupSettings().DefaultGroup
+	quota := 0
+	if mapping, ok := ResolveIdpGroup(idpGroups); ok {
+		group = mapping.Group
+		quota = mapping.Quota
+	}
+	if group == "" {
+		return nil
+	}
+	if !ratio_setting.ContainsGroupRatio(group) {
+		return fmt.Errorf("IdP 分组映射的目标分组 %s 不存在", group)
+	}
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		return err
+	}
+	if user.Group == group {
+		return nil
+	}
+	if err = model.UpdateUserIdpGroup(userId, group, quota); err != nil {
+		return err
+	}
+	content := fmt.Sprintf("根据身份提供商用户组将分组从 %s 调整为 %s", user.Group, group)
+	if quota > user.Quota {
+		content += fmt.Sprintf("，剩余额度补足至 %s", common.LogQuota(quota))
+	}
+	model.RecordLog(userId, model.LogTypeSystem, content)
+	return nil
+}
+
+// SyncScimUserGroup 根据用户当前所属的 SCIM 组重新计算网关分组
+func SyncScimUserGroup(userId int) {
+	names, err := model.GetUserScimGroupNames(userId)
+	if err != nil {
+		common.SysError("failed to get scim groups: " + err.Error())
+		return
+	}
+	if err = ApplyIdpGroups(userId, names); err != nil {
+		common.SysError(fmt.Sprintf("failed to apply idp groups for user %d: %s", userId, err.Error()))
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+	"net"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/setting/system_setting"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ipRuleCache 缓存全局规则的解析结果，配置变化时重新解析
+type ipRuleCache struct {
+	mu    sync.RWMutex
+	raw   string
+	rules []*net.IPNet
+}
+
+func (cache *ipRuleCache) get(raw string) []*net.IPNet {
+	cache.mu.RLock()
+	if cache.raw == raw {
+		rules := cache.rules
+		cache.mu.RUnlock()
+		return rules
+	}
+	cache.mu.RUnlock()
+	rules := common.ParseIpRules(raw)
+	cache.mu.Lock()
+	cache.raw = raw
+	cache.rules = rules
+	cache.mu.Unlock()
+	return rules
+}
+
+var (
+	globalAllowIpRules ipRuleCache
+	globalDenyIpRules  ipRuleCache
+)
+
+// CheckIpAccess 依次检查全局、账户、令牌的黑名单与白名单以及国家规则，黑名单优先，
+// 每一级白名单非空时 IP 都必须在其中
+func CheckIpAccess(clientIp string, userSetting dto.UserSetting, tokenAllow []*net.IPNet, tokenDeny []*net.IPNet) error {
+	ip := net.ParseIP(clientIp)
+	if ip == nil {
+		return errors.New("无法识别您的 IP")
+	}
+	settings := system_setting.GetIpAccessSettings()
+	if common.IpMatchRules(ip, globalDenyIpRules.get(settings.DenyIps)) ||
+		common.IpMatchRules(ip, common.ParseIpRules(userSetting.DenyIps)) ||
+		common.IpMatchRules(ip, tokenDeny) {
+		return errors.New("您的 IP 已被禁止访问")
+	}
+	if rules := globalAllowIpRules.get(settings.AllowIps); len(rules) > 0 && !common.IpMatchRules(ip, rules) {
+		return errors.New("您的 IP 不在系统允许访问的列表中")
+	}
+	if rules := common.ParseIpRules(userSetting.AllowIps); len(rules) > 0 && !common.IpMatchRules(ip, rules) {
+		return errors.New("您的 IP 不在账户允许访问的列表中")
+	}
+	if len(tokenAllow) > 0 && !common.IpMatchRules(ip, tokenAllow) {
+		return errors.New("您的 IP 不在令牌允许访问的列表中")
+	}
+	return checkIpCountry(ip, settings)
+}
+
+func checkIpCountry(ip net.IP, settings *system_setting.IpAccessSettings) error {
+	if settings.GeoIpDbPath == "" || (len(settings.AllowCountries) == 0 && len(settings.DenyCountries) == 0) {
+		return nil
+	}
+	// 内网与本机地址不在国家数据库中，不受国家规则限制
+	if ip.IsPrivate() || ip.IsLoopback() {
+		return nil
+	}
+	country := LookupIpCountry(settings.GeoIpDbPath, ip)
+	if country != "" && containsCountry(settings.DenyCountries, country) {
+		return errors.New("您所在的地区已被禁止访问")
+	}
+	if len(settings.AllowCountries) > 0 && !containsCountry(settings.AllowCountries, country) {
+		return errors.New("您所在的地区不在允许访问的范围内")
+	}
+	return nil
+}
+
+func containsCountry(countries []string, country string) bool {
+	return slices.ContainsFunc(countries, func(c string) bool {
+		return strings.EqualFold(c, country)
+	})
+}
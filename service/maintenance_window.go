@@ -0,0 +1,119 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"time"
+)
+
+const maintenanceCheckPeriod = 30 * time.Second
+
+// ValidateMaintenanceWindow 校验窗口配置：需要指定渠道或标签，并设置开始结束时间或 cron 表达式与持续时长
+func ValidateMaintenanceWindow(window *model.MaintenanceWindow) error {
+	if len(window.GetChannelIds()) == 0 && window.Tag == "" {
+		return errors.New("请指定渠道或标签")
+	}
+	if window.Cron != "" {
+		if _, err := common.ParseCronExpression(window.Cron); err != nil {
+			return err
+		}
+		if window.DurationMinutes <= 0 {
+			return errors.New("周期维护窗口的持续时长必须大于 0")
+		}
+		return nil
+	}
+	if window.StartTime <= 0 || window.EndTime <= window.StartTime {
+		return errors.New("结束时间必须晚于开始时间")
+	}
+	return nil
+}
+
+// GetMaintenanceWindowRange 返回窗口当前所在的维护时段，不在维护时段内时返回下一次维护时段
+func GetMaintenanceWindowRange(window *model.MaintenanceWindow, now time.Time) (start int64, end int64, active bool) {
+	if window.Cron == "" {
+		active = window.StartTime <= now.Unix() && now.Unix() < window.EndTime
+		return window.StartTime, window.EndTime, active
+	}
+	schedule, err := common.ParseCronExpression(window.Cron)
+	if err != nil {
+		return 0, 0, false
+	}
+	duration := time.Duration(window.DurationMinutes) * time.Minute
+	if prev := schedule.Prev(now, duration); !prev.IsZero() {
+		return prev.Unix(), prev.Add(duration).Unix(), true
+	}
+	if next := schedule.Next(now); !next.IsZero() {
+		return next.Unix(), next.Add(duration).Unix(), false
+	}
+	return 0, 0, false
+}
+
+// RunMaintenanceWindows 维护开始时排空窗口的渠道，结束或窗口关闭时恢复本次排空的渠道
+func RunMaintenanceWindows() {
+	windows, err := model.GetPendingMaintenanceWindows()
+	if err != nil {
+		common.SysError("failed to get maintenance windows: " + err.Error())
+		return
+	}
+	now := time.Now()
+	for _, window := range windows {
+		start, end, active := GetMaintenanceWindowRange(window, now)
+		active = active && window.Enabled
+		if active && !window.Active {
+			startMaintenanceWindow(window, start, end)
+		} else if !active && window.Active {
+			if err := FinishMaintenanceWindow(window); err != nil {
+				common.SysError(fmt.Sprintf("failed to finish maintenance window #%d: %s", window.Id, err.Error()))
+			}
+		}
+	}
+}
+
+func startMaintenanceWindow(window *model.MaintenanceWindow, start int64, end int64) {
+	channelIds, err := ResolveChannelIds(window.GetChannelIds(), window.Tag)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to resolve channels of maintenance window #%d: %s", window.Id, err.Error()))
+		return
+	}
+	drained, err := DrainChannels(channelIds, fmt.Sprintf("维护窗口「%s」", window.Name))
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to drain channels of maintenance window #%d: %s", window.Id, err.Error()))
+	}
+	window.Active = true
+	window.ActiveStartTime = start
+	window.ActiveEndTime = end
+	window.SetDrainedChannels(drained)
+	if err := window.UpdateState(); err != nil {
+		common.SysError(fmt.Sprintf("failed to update maintenance window #%d: %s", window.Id, err.Error()))
+		return
+	}
+	common.SysLog(fmt.Sprintf("maintenance window #%d started, drained channels: %v", window.Id, drained))
+}
+
+// FinishMaintenanceWindow 结束本次维护并恢复排空的渠道
+func FinishMaintenanceWindow(window *model.MaintenanceWindow) error {
+	restored, err := RestoreDrainingChannels(window.GetDrainedChannels(), fmt.Sprintf("维护窗口「%s」结束", window.Name))
+	if err != nil {
+		return err
+	}
+	window.Active = false
+	window.ActiveStartTime = 0
+	window.ActiveEndTime = 0
+	window.SetDrainedChannels(nil)
+	if err := window.UpdateState(); err != nil {
+		return err
+	}
+	common.SysLog(fmt.Sprintf("maintenance window #%d finished, restored channels: %v", window.Id, restored))
+	return nil
+}
+
+// AutomaticallyRunMaintenanceWindows 定时执行维护窗口并检查排空进度
+func AutomaticallyRunMaintenanceWindows() {
+	for {
+		RunMaintenanceWindows()
+		CheckDrainedChannels()
+		time.Sleep(maintenanceCheckPeriod)
+	}
+}
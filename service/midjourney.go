@@ -179,6 +179,9 @@ func DoMidjourneyHttpRequest(c *gin.Context, timeout time.Duration, fullRequestU
 		if !setting.MjNotifyEnabled {
 			delete(mapResult, "notifyHook")
 		}
+		if notifyHook := c.GetString("mj_notify_hook"); notifyHook != "" {
+			mapResult["notifyHook"] = notifyHook
+		}
 		//req, err := http.NewRequest(c.Request.Method, fullRequestURL, requestBody)
 		// make new request with mapResult
 	}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"one-api/setting/ratio_setting"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ModelSyncResult 渠道模型列表与上游模型列表的对比结果
+type ModelSyncResult struct {
+	Models   []string       // 按规则处理后的渠道模型列表
+	Added    []string       // 自动添加的模型
+	Removed  []string       // 自动移除的模型
+	New      []string       // 上游新增但未自动添加的模型
+	Unpriced []string       // 上游新增但未配置价格的模型
+	Missing  []string       // 上游已不存在但未移除的模型
+	Misses   map[string]int // 各模型连续未在上游找到的次数
+}
+
+func (result *ModelSyncResult) Changed() bool {
+	return len(result.Added) > 0 || len(result.Removed) > 0
+}
+
+func compileModelSyncPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	regexps := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("模型同步规则 %s 无效：%s", pattern, err.Error())
+		}
+		regexps = append(regexps, re)
+	}
+	return regexps, nil
+}
+
+// ValidateModelSyncPatterns 校验模型同步的正则规则
+func ValidateModelSyncPatterns(patterns []string) error {
+	_, err := compileModelSyncPatterns(patterns)
+	return err
+}
+
+func matchAnyPattern(regexps []*regexp.Regexp, name string) bool {
+	for _, re := range regexps {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetChannelModelSyncMisses 读取渠道记录的模型连续缺失次数
+func GetChannelModelSyncMisses(channel *model.Channel) map[string]int {
+	misses := make(map[string]int)
+	if value, ok := channel.GetOtherInfo()["model_sync_misses"]; ok {
+		data, _ := json.Marshal(value)
+		_ = json.Unmarshal(data, &misses)
+	}
+	return misses
+}
+
+// DiffChannelModels 按同步规则对比渠道与上游的模型列表：
+// 只处理包含规则匹配且未被排除的模型；新增模型在开启自动添加且满足价格要求时加入渠道，
+// 缺失模型在开启自动移除且连续缺失达到次数后移出渠道，其余变化只写入报告
+func DiffChannelModels(channel *model.Channel, upstreamModels []string, misses map[string]int) (*ModelSyncResult, error) {
+	// 上游返回空列表多半是接口异常，不能当作所有模型都已下线，否则自动移除会清空渠道
+	if len(upstreamModels) == 0 {
+		return nil, errors.New("上游返回的模型列表为空，已跳过本次同步")
+	}
+	syncSetting := operation_setting.GetModelSyncSetting()
+	channelSetting := channel.GetSetting()
+	includes, err := compileModelSyncPatterns(append(append([]string{}, syncSetting.IncludePatterns...), channelSetting.ModelSyncInclude...))
+	if err != nil {
+		return nil, err
+	}
+	excludes, err := compileModelSyncPatterns(append(append([]string{}, syncSetting.ExcludePatterns...), channelSetting.ModelSyncExclude...))
+	if err != nil {
+		return nil, err
+	}
+	managed := func(name string) bool {
+		if strings.Contains(name, "*") || matchAnyPattern(excludes, name) {
+			return false
+		}
+		return len(includes) == 0 || matchAnyPattern(includes, name)
+	}
+
+	upstream := make(map[string]bool, len(upstreamModels))
+	for _, name := range upstreamModels {
+		upstream[name] = true
+	}
+	// 渠道模型通过模型重定向映射到上游模型时按上游模型判断
+	modelMapping := make(map[string]string)
+	if channel.ModelMapping != nil && *channel.ModelMapping != "" {
+		_ = json.Unmarshal([]byte(*channel.ModelMapping), &modelMapping)
+	}
+	current := make(map[string]bool)
+	mappedTargets := make(map[string]bool)
+	for _, name := range channel.GetModels() {
+		current[name] = true
+		if target, ok := modelMapping[name]; ok {
+			mappedTargets[target] = true
+		}
+	}
+
+	result := &ModelSyncResult{
+		Models: make([]string, 0, len(current)),
+		Misses: make(map[string]int),
+	}
+	for _, name := range channel.GetModels() {
+		if !managed(name) || upstream[name] || upstream[modelMapping[name]] {
+			result.Models = append(result.Models, name)
+			continue
+		}
+		count := misses[name] + 1
+		if syncSetting.AutoRemove && count >= max(syncSetting.RemoveAfterMisses, 1) {
+			result.Removed = append(result.Removed, name)
+			continue
+		}
+		result.Misses[name] = count
+		result.Missing = append(result.Missing, name)
+		result.Models = append(result.Models, name)
+	}
+
+	ratios := ratio_setting.GetModelRatioCopy()
+	for _, name := range upstreamModels {
+		if current[name] || mappedTargets[name] || !managed(name) {
+			continue
+		}
+		// 上游列表可能有重复
+		current[name] = true
+		if syncSetting.RequirePrice && !isModelPriced(name, ratios) {
+			result.Unpriced = append(result.Unpriced, name)
+			continue
+		}
+		if syncSetting.AutoAdd {
+			result.Added = append(result.Added, name)
+			result.Models = append(result.Models, name)
+			continue
+		}
+		result.New = append(result.New, name)
+	}
+	for _, list := range [][]string{result.Added, result.Removed, result.New, result.Unpriced, result.Missing} {
+		sort.Strings(list)
+	}
+	return result, nil
+}
+
+func isModelPriced(name string, ratios map[string]float64) bool {
+	if _, ok := ratio_setting.GetModelPrice(name, false); ok {
+		return true
+	}
+	_, ok := ratios[name]
+	return ok
+}
+
+// NewModelSyncReport 根据对比结果生成同步报告
+func NewModelSyncReport(channel *model.Channel, result *ModelSyncResult, applied bool) *model.ModelSyncReport {
+	return &model.ModelSyncReport{
+		ChannelId:   channel.Id,
+		ChannelName: channel.Name,
+		Applied:     applied,
+		Added:       strings.Join(result.Added, ","),
+		Removed:     strings.Join(result.Removed, ","),
+		New:         strings.Join(result.New, ","),
+		Unpriced:    strings.Join(result.Unpriced, ","),
+		Missing:     strings.Join(result.Missing, ","),
+	}
+}
+
+// NotifyModelSyncReport 将同步报告通知超级管理员
+func NotifyModelSyncReport(report *model.ModelSyncReport) {
+	var lines []string
+	if report.Error != "" {
+		lines = append(lines, "同步失败："+report.Error)
+	}
+	for _, item := range []struct {
+		label  string
+		models string
+	}{
+		{"已添加", report.Added},
+		{"已移除", report.Removed},
+		{"上游新增（未添加）", report.New},
+		{"上游新增（未配置价格）", report.Unpriced},
+		{"上游已不存在（未移除）", report.Missing},
+	} {
+		if item.models != "" {
+			lines = append(lines, fmt.Sprintf("%s：%s", item.label, item.models))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	subject := fmt.Sprintf("渠道「%s」（#%d）上游模型有变化", report.ChannelName, report.ChannelId)
+	NotifyRootUser(fmt.Sprintf("%s_model_sync_%d", dto.NotifyTypeChannelUpdate, report.ChannelId), subject, strings.Join(lines, "\n"))
+}
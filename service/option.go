@@ -0,0 +1,196 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/setting"
+	"one-api/setting/console_setting"
+	"one-api/setting/model_setting"
+	"one-api/setting/operation_setting"
+	"one-api/setting/ratio_setting"
+	"one-api/setting/system_setting"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ValidateOption 校验系统设置的新值，修改设置的接口与配置包共用。
+// pending 为同一批次中一起修改的其他设置，启用 OAuth 等依赖其他设置的校验优先使用其中的值
+func ValidateOption(key string, value string, pending map[string]string) error {
+	pendingValue := func(key string, current string) string {
+		if value, ok := pending[key]; ok {
+			return value
+		}
+		return current
+	}
+	switch key {
+	case "GitHubOAuthEnabled":
+		if value == "true" && pendingValue("GitHubClientId", common.GitHubClientId) == "" {
+			return errors.New("无法启用 GitHub OAuth，请先填入 GitHub Client Id 以及 GitHub Client Secret！")
+		}
+	case "oidc.enabled":
+		if value == "true" && pendingValue("oidc.client_id", system_setting.GetOIDCSettings().ClientId) == "" {
+			return errors.New("无法启用 OIDC 登录，请先填入 OIDC Client Id 以及 OIDC Client Secret！")
+		}
+	case "scim.enabled":
+		if value == "true" && system_setting.GetScimSettings().TokenHash == "" {
+			return errors.New("无法启用 SCIM，请先生成 SCIM Token！")
+		}
+	case "idp_group.mappings":
+		var mappings []system_setting.IdpGroupMapping
+		if err := json.Unmarshal([]byte(value), &mappings); err != nil {
+			return errors.New("IdP 分组映射格式错误：" + err.Error())
+		}
+		containsGroup := ratio_setting.ContainsGroupRatio
+		if groupRatio, ok := pending["GroupRatio"]; ok {
+			ratios := make(map[string]float64)
+			_ = json.Unmarshal([]byte(groupRatio), &ratios)
+			containsGroup = func(group string) bool {
+				_, ok := ratios[group]
+				return ok
+			}
+		}
+		for _, mapping := range mappings {
+			if mapping.IdpGroup == "" || !containsGroup(mapping.Group) {
+				return errors.New("IdP 分组映射无效，请检查 IdP 用户组名称与网关分组 " + mapping.Group)
+			}
+		}
+	case "ip_access.geoip_db_path":
+		if value != "" {
+			if _, err := os.Stat(value); err != nil {
+				return fmt.Errorf("IP 国家数据库文件不可用：%w", err)
+			}
+		}
+	case "ip_access.allow_ips", "ip_access.deny_ips":
+		if _, err := common.ValidateIpRules(value); err != nil {
+			return err
+		}
+	case "ip_access.allow_countries", "ip_access.deny_countries":
+		var countries []string
+		if err := json.Unmarshal([]byte(value), &countries); err != nil {
+			return errors.New("国家代码列表格式错误：" + err.Error())
+		}
+		for _, country := range countries {
+			if len(country) != 2 {
+				return errors.New("无效的国家代码：" + country)
+			}
+		}
+	case "task_poll_setting.initial_interval_seconds", "task_poll_setting.max_interval_seconds",
+		"task_poll_setting.batch_size", "task_poll_setting.lease_seconds":
+		if value, err := strconv.Atoi(value); err != nil || value <= 0 {
+			return errors.New("任务轮询配置必须为正整数")
+		}
+	case "task_poll_setting.deadline_minutes":
+		// 为 0 时不限制任务处理时间
+		if value, err := strconv.Atoi(value); err != nil || value < 0 {
+			return errors.New("任务最长处理时间不能为负数")
+		}
+	case "task_poll_setting.platform_deadline_minutes":
+		var deadlines map[string]int
+		if err := json.Unmarshal([]byte(value), &deadlines); err != nil {
+			return errors.New("平台最长处理时间格式错误：" + err.Error())
+		}
+		for platform, minutes := range deadlines {
+			if minutes < 0 {
+				return errors.New("任务最长处理时间不能为负数：" + platform)
+			}
+		}
+	case "model_test.model_test_modes":
+		var testModes map[string]string
+		if err := json.Unmarshal([]byte(value), &testModes); err != nil {
+			return errors.New("模型测试方式格式错误：" + err.Error())
+		}
+		for modelName, mode := range testModes {
+			if !model_setting.IsValidModelTestMode(mode) {
+				return fmt.Errorf("模型 %s 的测试方式 %s 无效", modelName, mode)
+			}
+		}
+	case "model_health_setting.retest_interval_minutes":
+		interval, err := strconv.Atoi(value)
+		if err != nil || interval < 0 {
+			return errors.New("重新测试间隔必须为非负整数")
+		}
+	case "model_health_setting.disable_after_failures":
+		failures, err := strconv.Atoi(value)
+		if err != nil || failures < 1 {
+			return errors.New("禁用所需的连续失败次数必须为正整数")
+		}
+	case "channel_balance_setting.forecast_warn_days":
+		days, err := strconv.ParseFloat(value, 64)
+		if err != nil || days < 0 {
+			return errors.New("预测提醒天数必须为非负数")
+		}
+	case "channel_balance_setting.forecast_window_days", "channel_balance_setting.history_retention_days":
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return errors.New("天数必须为非负整数")
+		}
+	case "model_sync_setting.include_patterns", "model_sync_setting.exclude_patterns":
+		var patterns []string
+		if err := json.Unmarshal([]byte(value), &patterns); err != nil {
+			return errors.New("模型同步规则格式错误：" + err.Error())
+		}
+		if err := ValidateModelSyncPatterns(patterns); err != nil {
+			return err
+		}
+	case "task_asset_setting.backend":
+		if value != operation_setting.TaskAssetBackendLocal && value != operation_setting.TaskAssetBackendS3 {
+			return errors.New("存储后端只能为 local 或 s3")
+		}
+	case "task_asset_setting.url_expire_seconds":
+		if value, err := strconv.Atoi(value); err != nil || value <= 0 {
+			return errors.New("访问地址有效期必须为正整数")
+		}
+	case "task_asset_setting.retention_days", "task_asset_setting.max_file_size_mb", "task_asset_setting.quota_per_mb":
+		if value, err := strconv.Atoi(value); err != nil || value < 0 {
+			return errors.New("结果转存配置不能为负数")
+		}
+	case "LinuxDOOAuthEnabled":
+		if value == "true" && pendingValue("LinuxDOClientId", common.LinuxDOClientId) == "" {
+			return errors.New("无法启用 LinuxDO OAuth，请先填入 LinuxDO Client Id 以及 LinuxDO Client Secret！")
+		}
+	case "EmailDomainRestrictionEnabled":
+		whitelist := pendingValue("EmailDomainWhitelist", strings.Join(common.EmailDomainWhitelist, ","))
+		if value == "true" && strings.TrimSpace(whitelist) == "" {
+			return errors.New("无法启用邮箱域名限制，请先填入限制的邮箱域名！")
+		}
+	case "WeChatAuthEnabled":
+		if value == "true" && pendingValue("WeChatServerAddress", common.WeChatServerAddress) == "" {
+			return errors.New("无法启用微信登录，请先填入微信登录相关配置信息！")
+		}
+	case "TurnstileCheckEnabled":
+		if value == "true" && common.TurnstileSiteKey == "" {
+			return errors.New("无法启用 Turnstile 校验，请先填入 Turnstile 校验相关配置信息！")
+		}
+	case "TelegramOAuthEnabled":
+		if value == "true" && common.TelegramBotToken == "" {
+			return errors.New("无法启用 Telegram OAuth，请先填入 Telegram Bot Token！")
+		}
+	case "GroupRatio":
+		return ratio_setting.CheckGroupRatio(value)
+	case "ModelRequestRateLimitGroup":
+		return setting.CheckModelRequestRateLimitGroup(value)
+	case "console_setting.api_info":
+		return console_setting.ValidateConsoleSettings(value, "ApiInfo")
+	case "console_setting.announcements":
+		return console_setting.ValidateConsoleSettings(value, "Announcements")
+	case "console_setting.faq":
+		return console_setting.ValidateConsoleSettings(value, "FAQ")
+	case "console_setting.uptime_kuma_groups":
+		return console_setting.ValidateConsoleSettings(value, "UptimeKumaGroups")
+	}
+	return nil
+}
+
+// PrepareOptions 写入设置前的准备工作，修改设置的接口与配置包共用：
+// IP 国家数据库路径修改时先重新加载数据库，加载失败则不保存，替换数据库文件后再次保存即可生效
+func PrepareOptions(options map[string]string) error {
+	if path, ok := options["ip_access.geoip_db_path"]; ok {
+		if err := ReloadGeoIpDb(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting"
+	"one-api/setting/system_setting"
+	"strconv"
+	"strings"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// 每个用户最多注册的通行密钥数量
+const MaxPasskeysPerUser = 10
+
+// PasskeyUser 实现 webauthn.User，用户句柄为用户 ID
+type PasskeyUser struct {
+	User        *model.User
+	Passkeys    []*model.PasskeyCredential
+	credentials []webauthn.Credential
+}
+
+func (u *PasskeyUser) WebAuthnID() []byte {
+	return []byte(strconv.Itoa(u.User.Id))
+}
+
+func (u *PasskeyUser) WebAuthnName() string {
+	return u.User.Username
+}
+
+func (u *PasskeyUser) WebAuthnDisplayName() string {
+	if u.User.DisplayName != "" {
+		return u.User.DisplayName
+	}
+	return u.User.Username
+}
+
+func (u *PasskeyUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// ExclusionDescriptors 注册时排除已注册的凭据
+func (u *PasskeyUser) ExclusionDescriptors() []protocol.CredentialDescriptor {
+	return webauthn.Credentials(u.credentials).CredentialDescriptors()
+}
+
+// FindPasskey 根据凭据 ID 查找对应的通行密钥记录
+func (u *PasskeyUser) FindPasskey(credentialId []byte) *model.PasskeyCredential {
+	id := EncodePasskeyCredentialId(credentialId)
+	for _, passkey := range u.Passkeys {
+		if passkey.CredentialId == id {
+			return passkey
+		}
+	}
+	return nil
+}
+
+func EncodePasskeyCredentialId(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+func NewPasskeyUser(user *model.User) (*PasskeyUser, error) {
+	passkeys, err := model.GetUserPasskeys(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	credentials := make([]webauthn.Credential, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		var credential webauthn.Credential
+		if err := common.UnmarshalJsonStr(passkey.Credential, &credential); err != nil {
+			common.SysError("failed to unmarshal passkey credential: " + err.Error())
+			continue
+		}
+		credentials = append(credentials, credential)
+	}
+	return &PasskeyUser{User: user, Passkeys: passkeys, credentials: credentials}, nil
+}
+
+// LoadPasskeyUserByHandle 根据用户句柄加载用户，用于无用户名登录
+func LoadPasskeyUserByHandle(userHandle []byte) (*PasskeyUser, error) {
+	userId, err := strconv.Atoi(string(userHandle))
+	if err != nil {
+		return nil, errors.New("无效的用户句柄")
+	}
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		return nil, err
+	}
+	return NewPasskeyUser(user)
+}
+
+func GetPasskeyUserVerification() protocol.UserVerificationRequirement {
+	switch system_setting.GetPasskeySettings().UserVerification {
+	case "required":
+		return protocol.VerificationRequired
+	case "discouraged":
+		return protocol.VerificationDiscouraged
+	default:
+		return protocol.VerificationPreferred
+	}
+}
+
+// GetWebAuthn 根据当前配置构造 WebAuthn 依赖方，未配置的 RP ID 与来源从服务器地址推导
+func GetWebAuthn() (*webauthn.WebAuthn, error) {
+	passkeySettings := system_setting.GetPasskeySettings()
+	if !passkeySettings.Enabled {
+		return nil, errors.New("管理员未开启通行密钥登录")
+	}
+	serverAddress := strings.TrimSuffix(setting.ServerAddress, "/")
+	rpId := passkeySettings.RPID
+	if rpId == "" {
+		parsed, err := url.Parse(serverAddress)
+		if err != nil || parsed.Hostname() == "" {
+			return nil, errors.New("无法从服务器地址推导通行密钥 RP ID，请先配置")
+		}
+		rpId = parsed.Hostname()
+	}
+	origins := make([]string, 0)
+	for _, origin := range strings.Split(passkeySettings.Origins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, strings.TrimSuffix(origin, "/"))
+		}
+	}
+	if len(origins) == 0 {
+		origins = append(origins, serverAddress)
+	}
+	displayName := passkeySettings.RPDisplayName
+	if displayName == "" {
+		displayName = common.SystemName
+	}
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpId,
+		RPDisplayName: displayName,
+		RPOrigins:     origins,
+	})
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/setting/ratio_setting"
+	"sort"
+	"strconv"
+)
+
+const (
+	priceSimulationBatchSize   = 1000
+	priceSimulationDefaultTopN = 50
+	// 单次模拟允许的最大时间跨度：93 天
+	priceSimulationMaxRange = 93 * 24 * 3600
+)
+
+// billingParams 一条日志计费时使用的倍率/价格
+type billingParams struct {
+	UsePrice        bool
+	ModelPrice      float64
+	ModelRatio      float64
+	CompletionRatio float64
+	CacheRatio      float64
+	GroupRatio      float64
+}
+
+type priceSimulationAggregator map[string]*dto.PriceSimulationItem
+
+func (a priceSimulationAggregator) add(key string, original int64, simulated int64) {
+	item, ok := a[key]
+	if !ok {
+		item = &dto.PriceSimulationItem{Key: key}
+		a[key] = item
+	}
+	item.Count++
+	item.OriginalQuota += original
+	item.SimulatedQuota += simulated
+	item.Delta += simulated - original
+}
+
+// sorted 按差额绝对值降序返回前 topN 项
+func (a priceSimulationAggregator) sorted(topN int) []*dto.PriceSimulationItem {
+	items := make([]*dto.PriceSimulationItem, 0, len(a))
+	for _, item := range a {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		di, dj := abs64(items[i].Delta), abs64(items[j].Delta)
+		if di != dj {
+			return di > dj
+		}
+		return items[i].Key < items[j].Key
+	})
+	if topN > 0 && len(items) > topN {
+		items = items[:topN]
+	}
+	return items
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func otherFloat(other map[string]interface{}, key string) (float64, bool) {
+	v, ok := other[key]
+	if !ok || v == nil {
+		return 0, false
+	}
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func otherBool(other map[string]interface{}, key string) bool {
+	v, ok := other[key].(bool)
+	return ok && v
+}
+
+// recordedBillingParams 从日志 Other 字段中还原计费时使用的倍率
+func recordedBillingParams(other map[string]interface{}) (billingParams, bool) {
+	params := billingParams{}
+	groupRatio, ok := otherFloat(other, "group_ratio")
+	if !ok {
+		return params, false
+	}
+	params.GroupRatio = groupRatio
+	if modelPrice, ok := otherFloat(other, "model_price"); ok && modelPrice >= 0 {
+		params.UsePrice = true
+		params.ModelPrice = modelPrice
+		return params, true
+	}
+	modelRatio, ok := otherFloat(other, "model_ratio")
+	if !ok {
+		return params, false
+	}
+	params.ModelRatio = modelRatio
+	params.CompletionRatio, _ = otherFloat(other, "completion_ratio")
+	params.CacheRatio, _ = otherFloat(other, "cache_ratio")
+	return params, true
+}
+
+// proposedBillingParams 在记录的倍率基础上应用拟调整的配置
+func proposedBillingParams(req *dto.PriceSimulationRequest, log *model.Log, other map[string]interface{}, recorded billingParams) billingParams {
+	params := recorded
+	modelName := log.ModelName
+
+	// 存在用户分组特殊倍率时，实际计费使用的是特殊倍率，不受分组倍率调整影响
+	if userGroupRatio, ok := otherFloat(other, "user_group_ratio"); !ok || userGroupRatio < 0 {
+		if ratio, ok := req.GroupRatio[log.Group]; ok {
+			params.GroupRatio = ratio
+		}
+	}
+
+	if price, ok := req.ModelPrice[modelName]; ok {
+		params.UsePrice = true
+		params.ModelPrice = price
+		return params
+	}
+	if ratio, ok := req.ModelRatio[modelName]; ok {
+		if params.UsePrice {
+			// 由按次计费切换为按量计费，补全/缓存倍率取当前配置
+			params.UsePrice = false
+			params.CompletionRatio = ratio_setting.GetCompletionRatio(modelName)
+			params.CacheRatio, _ = ratio_setting.GetCacheRatio(modelName)
+		}
+		params.ModelRatio = ratio
+	}
+	if params.UsePrice {
+		return params
+	}
+	if ratio, ok := req.CompletionRatio[modelName]; ok {
+		params.CompletionRatio = ratio
+	}
+	if ratio, ok := req.CacheRatio[modelName]; ok {
+		params.CacheRatio = ratio
+	}
+	return params
+}
+
+// calculateLogQuota 按给定倍率重新计算一条日志的额度，公式与实际结算保持一致
+func calculateLogQuota(log *model.Log, other map[string]interface{}, params billingParams) float64 {
+	if params.UsePrice {
+		return params.ModelPrice * common.QuotaPerUnit * params.GroupRatio
+	}
+	var tokens float64
+	if otherBool(other, "audio") || otherBool(other, "ws") {
+		textInput, _ := otherFloat(other, "text_input")
+		textOutput, _ := otherFloat(other, "text_output")
+		audioInput, _ := otherFloat(other, "audio_input")
+		audioOutput, _ := otherFloat(other, "audio_output")
+		audioRatio, _ := otherFloat(other, "audio_ratio")
+		audioCompletionRatio, _ := otherFloat(other, "audio_completion_ratio")
+		tokens = textInput + textOutput*params.CompletionRatio +
+			audioInput*audioRatio + audioOutput*audioRatio*audioCompletionRatio
+	} else if otherBool(other, "claude") {
+		cacheTokens, _ := otherFloat(other, "cache_tokens")
+		cacheCreationTokens, _ := otherFloat(other, "cache_creation_tokens")
+		cacheCreationRatio, _ := otherFloat(other, "cache_creation_ratio")
+		tokens = float64(log.PromptTokens) + cacheTokens*params.CacheRatio +
+			cacheCreationTokens*cacheCreationRatio + float64(log.CompletionTokens)*params.CompletionRatio
+	} else {
+		cacheTokens, _ := otherFloat(other, "cache_tokens")
+		imageTokens, _ := otherFloat(other, "image_output")
+		imageRatio, _ := otherFloat(other, "image_ratio")
+		tokens = float64(log.PromptTokens) - cacheTokens - imageTokens +
+			cacheTokens*params.CacheRatio + imageTokens*imageRatio +
+			float64(log.CompletionTokens)*params.CompletionRatio
+	}
+	return tokens * params.ModelRatio * params.GroupRatio
+}
+
+// SimulatePricing 使用拟调整的倍率/价格重算历史消费日志，返回按模型、分组、用户聚合的额度差异。
+// 模拟额度 = 原始额度 + (按新倍率重算 - 按原倍率重算)，因此工具调用等非倍率相关费用保持不变。
+func SimulatePricing(req *dto.PriceSimulationRequest) (*dto.PriceSimulationResult, error) {
+	if req.StartTimestamp <= 0 || req.EndTimestamp <= 0 || req.EndTimestamp < req.StartTimestamp {
+		return nil, errors.New("请指定有效的起止时间")
+	}
+	if req.EndTimestamp-req.StartTimestamp > priceSimulationMaxRange {
+		return nil, errors.New("模拟时间跨度不能超过 93 天")
+	}
+	if err := ratio_setting.CheckGroupRatio(common.GetJsonString(req.GroupRatio)); err != nil {
+		return nil, err
+	}
+	topN := req.TopN
+	if topN <= 0 {
+		topN = priceSimulationDefaultTopN
+	}
+
+	result := &dto.PriceSimulationResult{
+		StartTimestamp: req.StartTimestamp,
+		EndTimestamp:   req.EndTimestamp,
+		QuotaPerUnit:   common.QuotaPerUnit,
+		Total:          dto.PriceSimulationItem{Key: "total"},
+	}
+	byModel := priceSimulationAggregator{}
+	byGroup := priceSimulationAggregator{}
+	byUser := priceSimulationAggregator{}
+
+	err := model.ScanConsumeLogs(req.StartTimestamp, req.EndTimestamp, req.ModelName, req.Username, req.Group, req.Channel,
+		priceSimulationBatchSize, func(logs []*model.Log) error {
+			for _, log := range logs {
+				result.LogCount++
+				other, _ := common.StrToMap(log.Other)
+				recorded, ok := recordedBillingParams(other)
+				if !ok || log.Quota == 0 {
+					// 无法还原计费参数或未实际扣费的日志保持原额度
+					result.SkippedCount++
+					continue
+				}
+				proposed := proposedBillingParams(req, log, other, recorded)
+				delta := calculateLogQuota(log, other, proposed) - calculateLogQuota(log, other, recorded)
+				original := int64(log.Quota)
+				simulated := original + int64(math.Round(delta))
+				if simulated < 0 {
+					simulated = 0
+				}
+
+				result.Total.Count++
+				result.Total.OriginalQuota += original
+				result.Total.SimulatedQuota += simulated
+				result.Total.Delta += simulated - original
+				byModel.add(log.ModelName, original, simulated)
+				byGroup.add(log.Group, original, simulated)
+				byUser.add(log.Username, original, simulated)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	result.ByModel = byModel.sorted(0)
+	result.ByGroup = byGroup.sorted(0)
+	result.ByUser = byUser.sorted(topN)
+	return result, nil
+}
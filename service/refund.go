@@ -0,0 +1,74 @@
+package service
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isCompletionRelay 判断本次请求是否应当产生补全内容
+func isCompletionRelay(relayInfo *relaycommon.RelayInfo) bool {
+	switch relayInfo.RelayMode {
+	case relayconstant.RelayModeEmbeddings, relayconstant.RelayModeModerations:
+		return false
+	}
+	switch relayInfo.RelayFormat {
+	case relaycommon.RelayFormatOpenAI, relaycommon.RelayFormatClaude, relaycommon.RelayFormatGemini, relaycommon.RelayFormatOpenAIResponses:
+		return true
+	}
+	return false
+}
+
+// autoRefundReason 返回满足的自动退款条件，不满足时返回空字符串
+func autoRefundReason(relayInfo *relaycommon.RelayInfo, completionTokens int) string {
+	refundSetting := operation_setting.GetRefundSetting()
+	if !isCompletionRelay(relayInfo) {
+		return ""
+	}
+	if refundSetting.AutoRefundStreamNoFirstToken && relayInfo.IsStream && !relayInfo.HasSendResponse() {
+		return "流式响应在首个 token 返回前中断"
+	}
+	if refundSetting.AutoRefundEmptyCompletion && completionTokens == 0 {
+		return "补全内容为空"
+	}
+	return ""
+}
+
+// AutoRefundIfNeeded 在结算完成后检查自动退款条件，满足时直接退还本次扣除的额度
+func AutoRefundIfNeeded(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, consumeLog *model.Log, quota int, completionTokens int) {
+	if quota <= 0 {
+		return
+	}
+	reason := autoRefundReason(relayInfo, completionTokens)
+	if reason == "" {
+		return
+	}
+	var refund *model.Refund
+	if consumeLog != nil {
+		refund = model.NewRefundFromLog(consumeLog, reason)
+	} else {
+		refund = &model.Refund{
+			UserId:         relayInfo.UserId,
+			Username:       ctx.GetString("username"),
+			TokenId:        relayInfo.TokenId,
+			TokenName:      ctx.GetString("token_name"),
+			ModelName:      relayInfo.OriginModelName,
+			LogQuota:       quota,
+			Quota:          quota,
+			Reason:         reason,
+			CreatedTime:    common.GetTimestamp(),
+			OrganizationId: relayInfo.OrganizationId,
+		}
+	}
+	refund.Auto = true
+	if err := refund.InsertApproved(); err != nil {
+		common.LogError(ctx, "auto refund failed: "+err.Error())
+		return
+	}
+	common.LogInfo(ctx, fmt.Sprintf("auto refunded %s to user %d: %s", common.FormatQuota(quota), relayInfo.UserId, reason))
+}
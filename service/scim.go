@@ -0,0 +1,35 @@
+package service
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"one-api/common"
+	"one-api/setting/system_setting"
+	"strings"
+)
+
+const scimTokenPrefix = "scim-"
+
+// GenerateScimToken 生成 SCIM Bearer Token，返回明文与需要保存的哈希
+func GenerateScimToken() (string, string, error) {
+	key, err := common.GenerateRandomCharsKey(48)
+	if err != nil {
+		return "", "", err
+	}
+	token := scimTokenPrefix + key
+	return token, HashScimToken(token), nil
+}
+
+func HashScimToken(token string) string {
+	return hex.EncodeToString(common.Sha256Raw([]byte(token)))
+}
+
+// ValidateScimToken 校验 Authorization 头中的 SCIM Bearer Token
+func ValidateScimToken(authorization string) bool {
+	tokenHash := system_setting.GetScimSettings().TokenHash
+	if tokenHash == "" || !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))
+	return subtle.ConstantTimeCompare([]byte(HashScimToken(token)), []byte(tokenHash)) == 1
+}
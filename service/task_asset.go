@@ -0,0 +1,304 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const taskAssetBytesPerMB = 1 << 20
+
+var taskAssetExtRegexp = regexp.MustCompile(`^\.[a-zA-Z0-9]{1,8}$`)
+
+// ArchiveTaskAssets 下载任务成功后生成的文件并转存到网关存储，已转存的地址会跳过。下载较慢，调用方通常在协程中执行
+func ArchiveTaskAssets(task *model.Task, urls []string) {
+	if !operation_setting.GetTaskAssetSetting().Enabled || len(urls) == 0 {
+		return
+	}
+	ctx := context.Background()
+	seen := make(map[string]bool, len(urls))
+	for _, sourceUrl := range urls {
+		if seen[sourceUrl] || !(strings.HasPrefix(sourceUrl, "http://") || strings.HasPrefix(sourceUrl, "https://")) {
+			continue
+		}
+		seen[sourceUrl] = true
+		if model.TaskAssetExists(task.ID, sourceUrl) {
+			continue
+		}
+		if err := archiveTaskAsset(ctx, task, sourceUrl); err != nil {
+			common.LogError(ctx, fmt.Sprintf("failed to archive asset of task %s: %s", task.TaskID, err.Error()))
+		}
+	}
+}
+
+// ArchiveMidjourneyTaskAssets 在后台转存成功的 Midjourney 任务的图片与视频
+func ArchiveMidjourneyTaskAssets(midjourneyTask *model.Midjourney) {
+	if !operation_setting.GetTaskAssetSetting().Enabled || midjourneyTask.Status != model.TaskStatusSuccess {
+		return
+	}
+	urls := []string{midjourneyTask.ImageUrl, midjourneyTask.VideoUrl}
+	var videoUrls []dto.ImgUrls
+	if json.Unmarshal([]byte(midjourneyTask.VideoUrls), &videoUrls) == nil {
+		for _, videoUrl := range videoUrls {
+			urls = append(urls, videoUrl.Url)
+		}
+	}
+	task := &model.Task{
+		ID:             int64(midjourneyTask.Id),
+		TaskID:         midjourneyTask.MjId,
+		Platform:       constant.TaskPlatformMidjourney,
+		UserId:         midjourneyTask.UserId,
+		ChannelId:      midjourneyTask.ChannelId,
+		OrganizationId: midjourneyTask.OrganizationId,
+	}
+	gopool.Go(func() {
+		ArchiveTaskAssets(task, urls)
+	})
+}
+
+func archiveTaskAsset(ctx context.Context, task *model.Task, sourceUrl string) error {
+	assetSetting := operation_setting.GetTaskAssetSetting()
+	store, err := GetTaskAssetStore(assetSetting.Backend)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceUrl, nil)
+	if err != nil {
+		return err
+	}
+	// 与转发请求一样使用渠道配置的代理下载
+	httpClient := GetHttpClient()
+	if channel, err := model.CacheGetChannel(task.ChannelId); err == nil {
+		if proxy := channel.GetSetting().Proxy; proxy != "" {
+			if httpClient, err = NewProxyHttpClient(proxy); err != nil {
+				return err
+			}
+		}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download status code: %d", resp.StatusCode)
+	}
+	maxSize := int64(assetSetting.MaxFileSizeMB) * taskAssetBytesPerMB
+	if maxSize > 0 && resp.ContentLength > maxSize {
+		return fmt.Errorf("file too large: %d bytes", resp.ContentLength)
+	}
+
+	tmpFile, err := os.CreateTemp("", "task-asset-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+	}()
+	reader := io.Reader(resp.Body)
+	if maxSize > 0 {
+		reader = io.LimitReader(resp.Body, maxSize+1)
+	}
+	size, err := io.Copy(tmpFile, reader)
+	if err != nil {
+		return err
+	}
+	if maxSize > 0 && size > maxSize {
+		return fmt.Errorf("file too large: more than %d MB", assetSetting.MaxFileSizeMB)
+	}
+	if _, err = tmpFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" || strings.HasPrefix(contentType, "application/octet-stream") {
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(tmpFile, head)
+		contentType = http.DetectContentType(head[:n])
+		if _, err = tmpFile.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	// 按 MB 向上取整计费，额度不足时保留上游地址
+	quota := 0
+	if assetSetting.QuotaPerMB > 0 {
+		quota = int((size+taskAssetBytesPerMB-1)/taskAssetBytesPerMB) * assetSetting.QuotaPerMB
+		userQuota, err := model.GetBillingQuota(task.UserId, task.OrganizationId)
+		if err != nil {
+			return err
+		}
+		if userQuota < quota {
+			return errors.New("额度不足，跳过转存")
+		}
+	}
+
+	key := fmt.Sprintf("%d/%s/%s%s", task.UserId, time.Now().Format("20060102"), common.GetRandomString(24), taskAssetExt(sourceUrl, contentType))
+	if err = store.Put(ctx, key, tmpFile, size, contentType); err != nil {
+		return err
+	}
+	asset := &model.TaskAsset{
+		UserId:      task.UserId,
+		TaskRowId:   task.ID,
+		TaskId:      task.TaskID,
+		Platform:    task.Platform,
+		SourceUrl:   sourceUrl,
+		Backend:     assetSetting.Backend,
+		StorageKey:  key,
+		ContentType: contentType,
+		Size:        size,
+		Quota:       quota,
+	}
+	if retentionDays := getTaskAssetRetentionDays(task.UserId); retentionDays > 0 {
+		asset.ExpiresAt = common.GetTimestamp() + int64(retentionDays)*86400
+	}
+	if err = asset.Insert(); err != nil {
+		_ = store.Delete(ctx, key)
+		// 轮询与回调并发转存同一文件时唯一索引冲突，另一方已转存并扣费
+		if model.TaskAssetExists(task.ID, sourceUrl) {
+			return nil
+		}
+		return err
+	}
+	if quota > 0 {
+		if err = model.DecreaseBillingQuota(task.UserId, task.OrganizationId, quota); err != nil {
+			common.LogError(ctx, "failed to charge asset storage quota: "+err.Error())
+		}
+		model.RecordTaskAssetLog(asset, task.OrganizationId)
+	}
+	return nil
+}
+
+// getTaskAssetRetentionDays 用户单独设置的保留天数优先于全局配置
+func getTaskAssetRetentionDays(userId int) int {
+	userSetting, err := model.GetUserSetting(userId, false)
+	if err == nil && userSetting.AssetRetentionDays != 0 {
+		// -1 表示永久保留
+		return max(userSetting.AssetRetentionDays, 0)
+	}
+	return operation_setting.GetTaskAssetSetting().RetentionDays
+}
+
+func taskAssetExt(sourceUrl string, contentType string) string {
+	if u, err := url.Parse(sourceUrl); err == nil {
+		if ext := path.Ext(u.Path); taskAssetExtRegexp.MatchString(ext) {
+			return strings.ToLower(ext)
+		}
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			return exts[0]
+		}
+	}
+	return ""
+}
+
+func taskAssetSignature(id int, expires int64) string {
+	return common.GenerateHMAC(fmt.Sprintf("task_asset:%d:%d", id, expires))
+}
+
+// GetTaskAssetUrl 返回转存文件的签名访问地址，有效期不超过文件的保留期
+func GetTaskAssetUrl(asset *model.TaskAsset) string {
+	expires := time.Now().Unix() + int64(operation_setting.GetTaskAssetSetting().UrlExpireSeconds)
+	if asset.ExpiresAt > 0 {
+		expires = min(expires, asset.ExpiresAt)
+	}
+	return fmt.Sprintf("%s/task_assets/%d?expires=%d&signature=%s",
+		strings.TrimSuffix(setting.ServerAddress, "/"), asset.Id, expires, taskAssetSignature(asset.Id, expires))
+}
+
+func VerifyTaskAssetSignature(id int, expires int64, signature string) bool {
+	if expires < time.Now().Unix() {
+		return false
+	}
+	return hmac.Equal([]byte(taskAssetSignature(id, expires)), []byte(signature))
+}
+
+// GetTaskAssetUrlMap 返回任务中已转存文件的上游地址到签名地址的映射
+func GetTaskAssetUrlMap(taskRowIds ...int64) map[string]string {
+	urlMap := make(map[string]string)
+	assets, err := model.GetTaskAssetsByTaskRowIds(taskRowIds)
+	if err != nil {
+		return urlMap
+	}
+	for _, asset := range assets {
+		urlMap[asset.SourceUrl] = GetTaskAssetUrl(asset)
+	}
+	return urlMap
+}
+
+// ReplaceTaskAssetUrls 将任务数据中的上游地址替换为网关签名地址，同时处理 JSON 转义后的地址
+func ReplaceTaskAssetUrls(data []byte, urlMap map[string]string) []byte {
+	for sourceUrl, assetUrl := range urlMap {
+		data = bytes.ReplaceAll(data, []byte(sourceUrl), []byte(assetUrl))
+		escapedSource, _ := json.Marshal(sourceUrl)
+		escapedAsset, _ := json.Marshal(assetUrl)
+		data = bytes.ReplaceAll(data, bytes.Trim(escapedSource, `"`), bytes.Trim(escapedAsset, `"`))
+	}
+	return data
+}
+
+// CleanupExpiredTaskAssets 删除超过保留期的转存文件，返回删除数量
+func CleanupExpiredTaskAssets(ctx context.Context) (int, error) {
+	deleted := 0
+	for {
+		assets, err := model.GetExpiredTaskAssets(common.GetTimestamp(), 100)
+		if err != nil {
+			return deleted, err
+		}
+		for _, asset := range assets {
+			store, err := GetTaskAssetStore(asset.Backend)
+			if err == nil {
+				err = store.Delete(ctx, asset.StorageKey)
+			}
+			if err != nil {
+				// 存储不可用时保留记录，下次再试
+				common.LogError(ctx, fmt.Sprintf("failed to delete task asset #%d: %s", asset.Id, err.Error()))
+				return deleted, err
+			}
+			if err = asset.Delete(); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+		if len(assets) < 100 {
+			return deleted, nil
+		}
+	}
+}
+
+// AutomaticallyCleanupTaskAssets 每小时清理超过保留期的转存文件
+func AutomaticallyCleanupTaskAssets() {
+	for {
+		count, err := CleanupExpiredTaskAssets(context.Background())
+		if err != nil {
+			common.SysError("failed to clean task assets: " + err.Error())
+		} else if count > 0 {
+			common.SysLog(fmt.Sprintf("cleaned %d expired task assets", count))
+		}
+		time.Sleep(time.Hour)
+	}
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/setting/operation_setting"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// TaskAssetStore 任务结果文件的存储后端
+type TaskAssetStore interface {
+	Put(ctx context.Context, key string, body io.ReadSeeker, size int64, contentType string) error
+	// Get 返回文件内容，本地文件同时实现 io.ReadSeeker，可支持断点续传
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// GetTaskAssetStore 按当前配置返回存储后端
+func GetTaskAssetStore(backend string) (TaskAssetStore, error) {
+	assetSetting := operation_setting.GetTaskAssetSetting()
+	switch backend {
+	case operation_setting.TaskAssetBackendLocal:
+		if assetSetting.LocalPath == "" {
+			return nil, errors.New("未配置本地存储目录")
+		}
+		return &localTaskAssetStore{root: assetSetting.LocalPath}, nil
+	case operation_setting.TaskAssetBackendS3:
+		if assetSetting.S3Endpoint == "" || assetSetting.S3Bucket == "" {
+			return nil, errors.New("未配置 S3 服务地址或存储桶")
+		}
+		return &s3TaskAssetStore{
+			endpoint:        strings.TrimSuffix(assetSetting.S3Endpoint, "/"),
+			region:          assetSetting.S3Region,
+			bucket:          assetSetting.S3Bucket,
+			accessKeyId:     os.Getenv("ASSET_S3_ACCESS_KEY_ID"),
+			secretAccessKey: os.Getenv("ASSET_S3_SECRET_ACCESS_KEY"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("未知的存储后端: %s", backend)
+	}
+}
+
+type localTaskAssetStore struct {
+	root string
+}
+
+func (s *localTaskAssetStore) path(key string) (string, error) {
+	// key 由网关生成，这里仍然防止路径穿越
+	p := filepath.Join(s.root, filepath.FromSlash(key))
+	if !strings.HasPrefix(p, filepath.Clean(s.root)+string(filepath.Separator)) {
+		return "", errors.New("invalid asset key")
+	}
+	return p, nil
+}
+
+func (s *localTaskAssetStore) Put(ctx context.Context, key string, body io.ReadSeeker, size int64, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(p)
+	}
+	return err
+}
+
+func (s *localTaskAssetStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (s *localTaskAssetStore) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// s3TaskAssetStore 兼容 S3 协议的对象存储（AWS S3、MinIO 等），使用路径风格地址与 SigV4 签名
+type s3TaskAssetStore struct {
+	endpoint        string
+	region          string
+	bucket          string
+	accessKeyId     string
+	secretAccessKey string
+}
+
+func (s *s3TaskAssetStore) do(ctx context.Context, method string, key string, body io.ReadSeeker, size int64, contentType string) (*http.Response, error) {
+	payloadHash := sha256.New()
+	if body != nil {
+		if _, err := io.Copy(payloadHash, body); err != nil {
+			return nil, err
+		}
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	payloadHashHex := hex.EncodeToString(payloadHash.Sum(nil))
+	requestUrl := fmt.Sprintf("%s/%s/%s", s.endpoint, s.bucket, key)
+	var reader io.Reader
+	if body != nil {
+		reader = body
+	}
+	req, err := http.NewRequestWithContext(ctx, method, requestUrl, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+		req.Header.Set("Content-Length", strconv.FormatInt(size, 10))
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHashHex)
+	credentials := aws.Credentials{AccessKeyID: s.accessKeyId, SecretAccessKey: s.secretAccessKey}
+	err = v4.NewSigner().SignHTTP(ctx, credentials, req, payloadHashHex, "s3", s.region, time.Now(), func(options *v4.SignerOptions) {
+		options.DisableURIPathEscaping = true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetHttpClient().Do(req)
+}
+
+func checkS3Response(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 status code: %d, body: %s", resp.StatusCode, string(body))
+}
+
+func (s *s3TaskAssetStore) Put(ctx context.Context, key string, body io.ReadSeeker, size int64, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, body, size, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkS3Response(resp)
+}
+
+func (s *s3TaskAssetStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkS3Response(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *s3TaskAssetStore) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// 对象不存在时也视为删除成功
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return checkS3Response(resp)
+}
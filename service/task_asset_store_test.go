@@ -0,0 +1,180 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+const (
+	testS3AccessKeyId     = "test-access-key"
+	testS3SecretAccessKey = "test-secret-key"
+	testS3Region          = "us-east-1"
+	testS3Bucket          = "assets"
+)
+
+// fakeS3Server 本地的 S3 替身：按 SigV4 重新计算签名校验请求，并在内存中保存对象
+type fakeS3Server struct {
+	mu           sync.Mutex
+	objects      map[string][]byte
+	contentTypes map[string]string
+}
+
+// verify 使用相同的密钥重新签名请求，签名一致才视为合法请求
+func (s *fakeS3Server) verify(r *http.Request, body []byte) error {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential="+testS3AccessKeyId+"/") {
+		return fmt.Errorf("unexpected authorization header: %s", authorization)
+	}
+	payloadHash := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(payloadHash[:]) {
+		return errors.New("payload hash mismatch")
+	}
+	signTime, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	// 只复制客户端签名的请求头，传输层追加的请求头（如 Accept-Encoding）不参与签名
+	_, signedHeaders, _ := strings.Cut(authorization, "SignedHeaders=")
+	signedHeaders, _, _ = strings.Cut(signedHeaders, ",")
+	for _, name := range strings.Split(signedHeaders, ";") {
+		if name == "host" || name == "x-amz-date" {
+			continue
+		}
+		req.Header[http.CanonicalHeaderKey(name)] = r.Header.Values(name)
+	}
+	req.ContentLength = int64(len(body))
+	credentials := aws.Credentials{AccessKeyID: testS3AccessKeyId, SecretAccessKey: testS3SecretAccessKey}
+	err = v4.NewSigner().SignHTTP(context.Background(), credentials, req, r.Header.Get("X-Amz-Content-Sha256"), "s3", testS3Region, signTime, func(options *v4.SignerOptions) {
+		options.DisableURIPathEscaping = true
+	})
+	if err != nil {
+		return err
+	}
+	if req.Header.Get("Authorization") != authorization {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func (s *fakeS3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if err := s.verify(r, body); err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+testS3Bucket+"/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[key] = body
+		s.contentTypes[key] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		data, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	case http.MethodDelete:
+		if _, ok := s.objects[key]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newFakeS3Server() *fakeS3Server {
+	return &fakeS3Server{objects: make(map[string][]byte), contentTypes: make(map[string]string)}
+}
+
+func TestS3TaskAssetStore(t *testing.T) {
+	InitHttpClient()
+	fake := newFakeS3Server()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	store := &s3TaskAssetStore{
+		endpoint:        server.URL,
+		region:          testS3Region,
+		bucket:          testS3Bucket,
+		accessKeyId:     testS3AccessKeyId,
+		secretAccessKey: testS3SecretAccessKey,
+	}
+	ctx := context.Background()
+	key := "1/20260101/abc.png"
+	content := []byte("fake png content")
+
+	if err := store.Put(ctx, key, bytes.NewReader(content), int64(len(content)), "image/png"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if contentType := fake.contentTypes[key]; contentType != "image/png" {
+		t.Fatalf("stored content type %q, want image/png", contentType)
+	}
+	reader, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	got, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("get returned %q, want %q", got, content)
+	}
+	if err = store.Delete(ctx, key); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	// 对象不存在时删除也视为成功，读取返回错误
+	if err = store.Delete(ctx, key); err != nil {
+		t.Fatalf("delete missing object: %v", err)
+	}
+	if _, err = store.Get(ctx, key); err == nil {
+		t.Fatal("get deleted object: expected error")
+	}
+}
+
+func TestS3TaskAssetStoreRejectsWrongSecret(t *testing.T) {
+	InitHttpClient()
+	server := httptest.NewServer(newFakeS3Server())
+	defer server.Close()
+	store := &s3TaskAssetStore{
+		endpoint:        server.URL,
+		region:          testS3Region,
+		bucket:          testS3Bucket,
+		accessKeyId:     testS3AccessKeyId,
+		secretAccessKey: "wrong-secret",
+	}
+	content := []byte("fake png content")
+	err := store.Put(context.Background(), "1/20260101/abc.png", bytes.NewReader(content), int64(len(content)), "image/png")
+	if err == nil || !strings.Contains(err.Error(), "signature mismatch") {
+		t.Fatalf("expected signature mismatch, got %v", err)
+	}
+}
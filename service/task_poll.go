@@ -0,0 +1,72 @@
+package service
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/setting/operation_setting"
+	"os"
+	"strings"
+	"time"
+)
+
+// nodeName 当前节点名称，用于 Redis 租约与节点统计
+var nodeName = func() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "node"
+	}
+	return hostname
+}()
+
+// ShouldRunTaskPoller 启用 Redis 时所有节点通过租约共同轮询任务，否则只由主节点轮询
+func ShouldRunTaskPoller() bool {
+	return constant.UpdateTask && (common.IsMasterNode || common.RedisEnabled)
+}
+
+// taskPollLeaseOwner 租约持有者，同一主机上的多个进程也互不相同
+var taskPollLeaseOwner = nodeName + ":" + common.GetRandomString(8)
+
+func taskPollLeaseKey(taskId int64) string {
+	return fmt.Sprintf("task_poll_lease:%d", taskId)
+}
+
+// AcquireTaskPollLease 领取任务的查询租约，同一任务同一时间只由一个节点查询上游
+func AcquireTaskPollLease(taskId int64) bool {
+	if !common.RedisEnabled {
+		return true
+	}
+	ttl := time.Duration(max(operation_setting.GetTaskPollSetting().LeaseSeconds, 1)) * time.Second
+	ok, err := common.RedisSetNX(taskPollLeaseKey(taskId), taskPollLeaseOwner, ttl)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to acquire task poll lease #%d: %s", taskId, err.Error()))
+		return false
+	}
+	return ok
+}
+
+// ReleaseTaskPollLeases 查询结束后释放本节点持有的租约，下一次查询时间由 tasks.next_poll_time 控制。
+// 查询耗时超过租约时租约可能已被其他节点领取，此时不会删除
+func ReleaseTaskPollLeases(taskIds []int64) {
+	if !common.RedisEnabled {
+		return
+	}
+	for _, taskId := range taskIds {
+		if _, err := common.RedisCompareAndDel(taskPollLeaseKey(taskId), taskPollLeaseOwner); err != nil {
+			common.SysError(fmt.Sprintf("failed to release task poll lease #%d: %s", taskId, err.Error()))
+		}
+	}
+}
+
+// GenerateTaskNotifyUrl 生成上游推送任务状态的地址，未启用或未配置回调地址时返回空
+func GenerateTaskNotifyUrl(platform constant.TaskPlatform) (token string, notifyUrl string) {
+	if !operation_setting.GetTaskPollSetting().UpstreamCallbackEnabled {
+		return "", ""
+	}
+	address := strings.TrimSuffix(GetCallbackAddress(), "/")
+	if address == "" {
+		return "", ""
+	}
+	token = common.GetRandomString(32)
+	return token, fmt.Sprintf("%s/api/task/notify/%s/%s", address, platform, token)
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+)
+
+// SettleTaskRefund 将任务的累计退款额度结算到 refundQuota（不超过任务额度），返回本次实际退还的额度。
+// 已退额度记录在 tasks.refunded_quota 中，重复结算（轮询与上游推送先后到达、多节点同时处理）不会重复退款
+func SettleTaskRefund(ctx context.Context, taskId int64, refundQuota int, reason string) (int, error) {
+	task, err := model.GetTaskById(taskId)
+	if err != nil {
+		return 0, err
+	}
+	refundQuota = min(refundQuota, task.Quota)
+	delta := refundQuota - task.RefundedQuota
+	if delta <= 0 {
+		return 0, nil
+	}
+	ok, err := model.UpdateTaskRefundedQuota(task.ID, task.RefundedQuota, refundQuota)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		// 其他节点已完成结算
+		return 0, nil
+	}
+	if err := model.IncreaseBillingQuota(task.UserId, task.OrganizationId, delta); err != nil {
+		common.LogError(ctx, fmt.Sprintf("fail to refund task %s: %s", task.TaskID, err.Error()))
+		return 0, err
+	}
+	if task.TokenId != 0 {
+		token, err := model.GetTokenById(task.TokenId)
+		if err == nil && !token.UnlimitedQuota {
+			if err := model.IncreaseTokenQuota(token.Id, token.GetKeyHash(), delta); err != nil {
+				common.LogError(ctx, "fail to increase token quota: "+err.Error())
+			}
+		}
+	}
+	model.RecordTaskRefundLog(task, delta, reason)
+	common.LogInfo(ctx, fmt.Sprintf("task %s refunded %d, total refunded %d/%d", task.TaskID, delta, refundQuota, task.Quota))
+	return delta, nil
+}
@@ -0,0 +1,306 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// 投递中的记录在租约到期前不会被重试任务再次领取
+const taskWebhookLeaseSeconds = 60
+
+type taskCallbackRequest struct {
+	CallbackUrl string `json:"callback_url"`
+}
+
+// ValidateCallbackUrl 校验回调地址，仅支持 http 与 https
+func ValidateCallbackUrl(callbackUrl string) error {
+	if callbackUrl == "" {
+		return nil
+	}
+	if len(callbackUrl) > 512 {
+		return errors.New("回调地址过长")
+	}
+	u, err := url.Parse(callbackUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("无效的回调地址：" + callbackUrl)
+	}
+	return nil
+}
+
+// GetTaskCallbackUrl 返回请求体中的 callback_url，未提供时使用令牌配置的默认回调地址
+func GetTaskCallbackUrl(c *gin.Context) (string, error) {
+	var req taskCallbackRequest
+	if c.Request.Body != nil {
+		// 非 JSON 请求体（如表单上传）不读取 callback_url
+		_ = common.UnmarshalBodyReusable(c, &req)
+	}
+	callbackUrl := req.CallbackUrl
+	if callbackUrl == "" {
+		callbackUrl = common.GetContextKeyString(c, constant.ContextKeyTokenCallbackUrl)
+	}
+	if err := ValidateCallbackUrl(callbackUrl); err != nil {
+		return "", err
+	}
+	return callbackUrl, nil
+}
+
+// NotifyTaskStatusChange 任务状态变化后推送回调
+func NotifyTaskStatusChange(task *model.Task) {
+	if task.CallbackUrl == "" {
+		return
+	}
+	payload := dto.TaskWebhookPayload{
+		Event:      dto.TaskWebhookEventStatusChanged,
+		TaskId:     task.TaskID,
+		Platform:   string(task.Platform),
+		Action:     task.Action,
+		Status:     string(task.Status),
+		Progress:   task.Progress,
+		FailReason: task.FailReason,
+		SubmitTime: task.SubmitTime,
+		StartTime:  task.StartTime,
+		FinishTime: task.FinishTime,
+	}
+	if json.Valid(task.Data) {
+		payload.Data = task.Data
+	}
+	enqueueTaskWebhook(task.UserId, model.TaskWebhookTypeTask, task.CallbackUrl, payload)
+}
+
+// NotifyMidjourneyStatusChange Midjourney 任务状态变化后推送回调
+func NotifyMidjourneyStatusChange(task *model.Midjourney) {
+	if task.CallbackUrl == "" {
+		return
+	}
+	payload := dto.TaskWebhookPayload{
+		Event:      dto.TaskWebhookEventStatusChanged,
+		TaskId:     task.MjId,
+		Platform:   model.TaskWebhookTypeMidjourney,
+		Action:     task.Action,
+		Status:     task.Status,
+		Progress:   task.Progress,
+		FailReason: task.FailReason,
+		SubmitTime: task.SubmitTime,
+		StartTime:  task.StartTime,
+		FinishTime: task.FinishTime,
+		ImageUrl:   task.ImageUrl,
+		VideoUrl:   task.VideoUrl,
+	}
+	enqueueTaskWebhook(task.UserId, model.TaskWebhookTypeMidjourney, task.CallbackUrl, payload)
+}
+
+func enqueueTaskWebhook(userId int, taskType string, callbackUrl string, payload dto.TaskWebhookPayload) {
+	if !operation_setting.GetTaskWebhookSetting().Enabled {
+		return
+	}
+	payload.Timestamp = time.Now().Unix()
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		common.SysError("failed to marshal task webhook payload: " + err.Error())
+		return
+	}
+	delivery := &model.TaskWebhookDelivery{
+		UserId:        userId,
+		TaskType:      taskType,
+		TaskId:        payload.TaskId,
+		TaskStatus:    payload.Status,
+		Url:           callbackUrl,
+		Payload:       string(payloadBytes),
+		State:         model.TaskWebhookStatePending,
+		NextRetryTime: common.GetTimestamp() + taskWebhookLeaseSeconds,
+	}
+	if err = delivery.Insert(); err != nil {
+		common.SysError("failed to insert task webhook delivery: " + err.Error())
+		return
+	}
+	gopool.Go(func() {
+		deliverTaskWebhook(delivery)
+	})
+}
+
+// checkCallbackHost 禁止回调解析到内网、本机等地址，防止通过回调访问内部服务。
+// 仅用于提前拒绝，实际连接时由 getTaskWebhookClient 再次检查，防止 DNS 重绑定
+func checkCallbackHost(callbackUrl string) error {
+	if operation_setting.GetTaskWebhookSetting().AllowPrivateAddress {
+		return nil
+	}
+	u, err := url.Parse(callbackUrl)
+	if err != nil {
+		return err
+	}
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if isInternalIP(ip) {
+			return fmt.Errorf("回调地址解析到内网地址 %s", ip.String())
+		}
+	}
+	return nil
+}
+
+func isInternalIP(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+var (
+	taskWebhookClient     *http.Client
+	taskWebhookClientOnce sync.Once
+)
+
+// getTaskWebhookClient 回调专用客户端：在建立连接时检查实际连接的地址，不使用代理，也不跟随重定向
+func getTaskWebhookClient() *http.Client {
+	taskWebhookClientOnce.Do(func() {
+		dialer := &net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: func(network string, address string, _ syscall.RawConn) error {
+				if operation_setting.GetTaskWebhookSetting().AllowPrivateAddress {
+					return nil
+				}
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || isInternalIP(ip) {
+					return fmt.Errorf("回调地址解析到内网地址 %s", host)
+				}
+				return nil
+			},
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		transport.DialContext = dialer.DialContext
+		taskWebhookClient = &http.Client{
+			Transport: transport,
+			Timeout:   30 * time.Second,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	})
+	return taskWebhookClient
+}
+
+func taskWebhookBackoff(attempts int) int64 {
+	backoff := int64(operation_setting.GetTaskWebhookSetting().RetryBaseSeconds)
+	if backoff <= 0 {
+		backoff = 30
+	}
+	for i := 1; i < attempts && backoff < 3600; i++ {
+		backoff *= 2
+	}
+	return min(backoff, 3600)
+}
+
+// deliverTaskWebhook 投递一次回调并记录结果，签名使用用户通知设置中的 Webhook 密钥
+func deliverTaskWebhook(delivery *model.TaskWebhookDelivery) {
+	err := checkCallbackHost(delivery.Url)
+	if err == nil {
+		var userSetting dto.UserSetting
+		userSetting, err = model.GetUserSetting(delivery.UserId, false)
+		if err == nil {
+			err = sendSignedWebhook(getTaskWebhookClient(), delivery.Url, userSetting.WebhookSecret, []byte(delivery.Payload), map[string]string{
+				"X-Webhook-Event":    dto.TaskWebhookEventStatusChanged,
+				"X-Webhook-Delivery": strconv.Itoa(delivery.Id),
+			})
+		}
+	}
+	delivery.Attempts++
+	if err == nil {
+		delivery.State = model.TaskWebhookStateSuccess
+		delivery.LastError = ""
+	} else {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= operation_setting.GetTaskWebhookSetting().MaxAttempts {
+			delivery.State = model.TaskWebhookStateFailed
+		} else {
+			delivery.NextRetryTime = common.GetTimestamp() + taskWebhookBackoff(delivery.Attempts)
+		}
+	}
+	if err = delivery.UpdateResult(); err != nil {
+		common.SysError("failed to update task webhook delivery: " + err.Error())
+	}
+}
+
+// RetryTaskWebhookDelivery 手动重新投递，已失败的记录会重新计算投递次数
+func RetryTaskWebhookDelivery(delivery *model.TaskWebhookDelivery) error {
+	if delivery.State == model.TaskWebhookStatePending {
+		return errors.New("该回调正在等待投递")
+	}
+	delivery.State = model.TaskWebhookStatePending
+	delivery.Attempts = 0
+	delivery.NextRetryTime = common.GetTimestamp() + taskWebhookLeaseSeconds
+	if err := delivery.UpdateResult(); err != nil {
+		return err
+	}
+	gopool.Go(func() {
+		deliverTaskWebhook(delivery)
+	})
+	return nil
+}
+
+// AutomaticallyRetryTaskWebhooks 定期重试到期的回调，并按保留天数清理投递记录
+func AutomaticallyRetryTaskWebhooks() {
+	lastCleanTime := time.Time{}
+	for {
+		retryDueTaskWebhooks()
+		if time.Since(lastCleanTime) > time.Hour {
+			lastCleanTime = time.Now()
+			if days := operation_setting.GetTaskWebhookSetting().RetentionDays; days > 0 {
+				target := time.Now().AddDate(0, 0, -days).Unix()
+				count, err := model.DeleteOldTaskWebhookDeliveries(context.Background(), target, 1000)
+				if err != nil {
+					common.SysError("failed to clean task webhook deliveries: " + err.Error())
+				} else if count > 0 {
+					common.SysLog(fmt.Sprintf("cleaned %d task webhook deliveries", count))
+				}
+			}
+		}
+		time.Sleep(10 * time.Second)
+	}
+}
+
+func retryDueTaskWebhooks() {
+	deliveries, err := model.GetDueTaskWebhookDeliveries(200)
+	if err != nil {
+		common.SysError("failed to get task webhook deliveries: " + err.Error())
+		return
+	}
+	var wg sync.WaitGroup
+	// 限制并发，避免大量回调同时失败时占满连接
+	sem := make(chan struct{}, 16)
+	for _, delivery := range deliveries {
+		claimed, err := model.ClaimTaskWebhookDelivery(delivery, common.GetTimestamp()+taskWebhookLeaseSeconds)
+		if err != nil || !claimed {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		gopool.Go(func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			deliverTaskWebhook(delivery)
+		})
+	}
+	wg.Wait()
+}
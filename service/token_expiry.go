@@ -0,0 +1,81 @@
+package service
+
+import (
+	"fmt"
+	"html"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"strings"
+	"time"
+)
+
+// AutomaticallyCheckTokenExpiry 定期提醒用户即将过期的令牌，并清理宽限期已过的轮换旧令牌
+func AutomaticallyCheckTokenExpiry() {
+	for {
+		notifyExpiringTokens()
+		count, err := model.DeleteExpiredRotatedTokens()
+		if err != nil {
+			common.SysError("failed to delete rotated tokens: " + err.Error())
+		} else if count > 0 {
+			common.SysLog(fmt.Sprintf("deleted %d rotated tokens", count))
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+func notifyExpiringTokens() {
+	days := operation_setting.GetTokenSetting().ExpiryReminderDays
+	if days <= 0 {
+		return
+	}
+	deadline := time.Now().AddDate(0, 0, days).Unix()
+	tokens, err := model.GetTokensExpiringBefore(deadline)
+	if err != nil {
+		common.SysError("failed to get expiring tokens: " + err.Error())
+		return
+	}
+	userTokens := make(map[int][]*model.Token)
+	userIds := make([]int, 0)
+	for _, token := range tokens {
+		if _, ok := userTokens[token.UserId]; !ok {
+			userIds = append(userIds, token.UserId)
+		}
+		userTokens[token.UserId] = append(userTokens[token.UserId], token)
+	}
+	for _, userId := range userIds {
+		if err := notifyUserExpiringTokens(userId, userTokens[userId]); err != nil {
+			common.SysError(fmt.Sprintf("failed to send token expiry notify to user %d: %s", userId, err.Error()))
+		}
+	}
+}
+
+// notifyUserExpiringTokens 每个用户汇总发送一条提醒，发送成功后标记令牌避免重复提醒
+func notifyUserExpiringTokens(userId int, tokens []*model.Token) error {
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		return err
+	}
+	baseUser := user.ToBaseUser()
+	var items strings.Builder
+	ids := make([]int, 0, len(tokens))
+	for _, token := range tokens {
+		name := html.EscapeString(token.Name)
+		if token.KeyPrefix != "" {
+			name = fmt.Sprintf("%s（%s***）", name, token.KeyPrefix)
+		}
+		items.WriteString(fmt.Sprintf("<br/>%s：%s", name, time.Unix(token.ExpiredTime, 0).Format("2006-01-02 15:04:05")))
+		ids = append(ids, token.Id)
+	}
+	prompt := "您的令牌即将过期"
+	tokenLink := fmt.Sprintf("%s/token", setting.ServerAddress)
+	content := "{{value}}，以下 {{value}} 个令牌将在过期时间后失效，请及时轮换或延长有效期：{{value}}<br/>令牌管理：<a href='{{value}}'>{{value}}</a>"
+	err = NotifyUser(baseUser.Id, baseUser.Email, baseUser.GetSetting(), dto.NewNotify(dto.NotifyTypeTokenExpiry, prompt, content,
+		[]interface{}{prompt, len(tokens), items.String(), tokenLink, tokenLink}))
+	if err != nil {
+		return err
+	}
+	return model.MarkTokensExpiryNotified(ids)
+}
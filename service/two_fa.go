@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/system_setting"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	totpPeriod        = 30
+	totpSkew          = 1
+	recoveryCodeCount = 10
+)
+
+// IsTwoFactorEnforced 当前角色是否被要求启用两步验证
+func IsTwoFactorEnforced(role int) bool {
+	return system_setting.GetTwoFactorSettings().EnforceForAdmin && role >= common.RoleAdminUser
+}
+
+// GenerateTwoFASecret 生成 TOTP 密钥与用于生成二维码的 otpauth 链接
+func GenerateTwoFASecret(username string) (*otp.Key, error) {
+	issuer := system_setting.GetTwoFactorSettings().Issuer
+	if issuer == "" {
+		issuer = common.SystemName
+	}
+	return totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: username,
+		Period:      totpPeriod,
+	})
+}
+
+// ValidateTOTPCode 校验验证码，返回匹配的时间步；早于等于 lastUsedStep 的时间步视为重放
+func ValidateTOTPCode(secret string, code string, lastUsedStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	now := time.Now().Unix()
+	for i := -totpSkew; i <= totpSkew; i++ {
+		step := now/totpPeriod + int64(i)
+		if step <= lastUsedStep {
+			continue
+		}
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0), totp.ValidateOpts{
+			Period:    totpPeriod,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err == nil && expected == code {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateRecoveryCodes 生成一组 xxxx-xxxx 格式的一次性恢复码
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := common.GenerateRandomCharsKey(8)
+		if err != nil {
+			return nil, err
+		}
+		code = strings.ToLower(code)
+		codes = append(codes, code[:4]+"-"+code[4:])
+	}
+	return codes, nil
+}
+
+// VerifyTwoFACode 校验 6 位 TOTP 验证码或恢复码，连续失败会锁定
+func VerifyTwoFACode(userId int, code string) error {
+	twoFA, err := model.GetTwoFAByUserId(userId)
+	if err != nil || !twoFA.Enabled {
+		return errors.New("未启用两步验证")
+	}
+	if err = twoFA.CheckTwoFALock(); err != nil {
+		return err
+	}
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return errors.New("验证码不能为空")
+	}
+	var step int64
+	success := false
+	if len(code) == 6 {
+		secret, err := twoFA.GetSecret()
+		if err != nil {
+			return err
+		}
+		step, success = ValidateTOTPCode(secret, code, twoFA.LastUsedStep)
+	} else {
+		success = model.UseRecoveryCode(userId, code)
+	}
+	if err = twoFA.RecordTwoFAResult(success, step); err != nil {
+		// 验证通过但已被锁定或验证码已被并发请求使用时拒绝
+		if errors.Is(err, model.ErrTwoFALocked) || errors.Is(err, model.ErrTwoFACodeUsed) {
+			return err
+		}
+		common.SysError("failed to record 2fa result: " + err.Error())
+		if success {
+			return errors.New("验证失败，请稍后再试")
+		}
+	}
+	if !success {
+		return errors.New("验证码错误")
+	}
+	return nil
+}
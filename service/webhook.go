@@ -52,6 +52,12 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 		return fmt.Errorf("failed to marshal webhook payload: %v", err)
 	}
 
+	return sendSignedWebhook(GetHttpClient(), webhookURL, secret, payloadBytes, nil)
+}
+
+// sendSignedWebhook 使用 client 发送 webhook 请求，secret 非空时在 X-Webhook-Signature 中附带 HMAC-SHA256 签名
+func sendSignedWebhook(client *http.Client, webhookURL string, secret string, payloadBytes []byte, headers map[string]string) error {
+	var err error
 	// 创建 HTTP 请求
 	var req *http.Request
 	var resp *http.Response
@@ -67,6 +73,9 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 			},
 			Body: payloadBytes,
 		}
+		for k, v := range headers {
+			workerReq.Headers[k] = v
+		}
 
 		// 如果有secret，添加签名到headers
 		if secret != "" {
@@ -93,6 +102,9 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 
 		// 设置请求头
 		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
 
 		// 如果有 secret，生成签名
 		if secret != "" {
@@ -101,7 +113,6 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 		}
 
 		// 发送请求
-		client := GetHttpClient()
 		resp, err = client.Do(req)
 		if err != nil {
 			return fmt.Errorf("failed to send webhook request: %v", err)
@@ -0,0 +1,70 @@
+package model_setting
+
+import (
+	"one-api/setting/config"
+)
+
+// 渠道测试方式
+const (
+	ModelTestModeChat      = "chat"
+	ModelTestModeEmbedding = "embedding"
+	ModelTestModeRerank    = "rerank"
+	ModelTestModeImage     = "image"
+	ModelTestModeTTS       = "tts"
+	ModelTestModeSTT       = "stt"
+)
+
+var modelTestModes = []string{
+	ModelTestModeChat,
+	ModelTestModeEmbedding,
+	ModelTestModeRerank,
+	ModelTestModeImage,
+	ModelTestModeTTS,
+	ModelTestModeSTT,
+}
+
+type ModelTestSettings struct {
+	// 模型的测试方式，键为模型名；未配置的模型按支持的端点类型与模型名称确定，默认为 chat
+	ModelTestModes map[string]string `json:"model_test_modes"`
+}
+
+// 默认配置
+var modelTestSettings = ModelTestSettings{
+	ModelTestModes: map[string]string{
+		"text-embedding-3-small": ModelTestModeEmbedding,
+		"text-embedding-3-large": ModelTestModeEmbedding,
+		"text-embedding-ada-002": ModelTestModeEmbedding,
+		"text-embedding-v3":      ModelTestModeEmbedding,
+		"jina-embeddings-v3":     ModelTestModeEmbedding,
+		"bge-m3":                 ModelTestModeEmbedding,
+		"tts-1":                  ModelTestModeTTS,
+		"tts-1-hd":               ModelTestModeTTS,
+		"gpt-4o-mini-tts":        ModelTestModeTTS,
+		"whisper-1":              ModelTestModeSTT,
+		"gpt-4o-transcribe":      ModelTestModeSTT,
+		"gpt-4o-mini-transcribe": ModelTestModeSTT,
+	},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_test", &modelTestSettings)
+}
+
+func GetModelTestSettings() *ModelTestSettings {
+	return &modelTestSettings
+}
+
+// GetModelTestMode 返回模型配置的测试方式，未配置时返回空字符串
+func GetModelTestMode(modelName string) string {
+	return modelTestSettings.ModelTestModes[modelName]
+}
+
+func IsValidModelTestMode(mode string) bool {
+	for _, m := range modelTestModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
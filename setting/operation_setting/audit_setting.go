@@ -0,0 +1,22 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type AuditSetting struct {
+	// 审计日志保留天数，0 表示永久保留
+	RetentionDays int `json:"retention_days"`
+}
+
+// 默认配置
+var auditSetting = AuditSetting{
+	RetentionDays: 0,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("audit_setting", &auditSetting)
+}
+
+func GetAuditSetting() *AuditSetting {
+	return &auditSetting
+}
@@ -0,0 +1,37 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type ChannelBalanceSetting struct {
+	// 余额低于该值时通知超级管理员，0 表示不提醒；渠道设置中的阈值优先
+	WarnThreshold float64 `json:"warn_threshold"`
+	// 余额低于或等于该值时自动禁用渠道；渠道设置中的阈值优先
+	DisableThreshold float64 `json:"disable_threshold"`
+	// 余额不足时自动禁用渠道
+	AutoDisable bool `json:"auto_disable"`
+	// 按最近消耗速度预计余额在该天数内用尽时提醒，0 表示不按预测提醒
+	ForecastWarnDays float64 `json:"forecast_warn_days"`
+	// 计算消耗速度使用的历史天数
+	ForecastWindowDays int `json:"forecast_window_days"`
+	// 余额历史保留天数，0 表示不清理
+	HistoryRetentionDays int `json:"history_retention_days"`
+}
+
+// 默认配置
+var channelBalanceSetting = ChannelBalanceSetting{
+	WarnThreshold:        0,
+	DisableThreshold:     0,
+	AutoDisable:          true,
+	ForecastWarnDays:     3,
+	ForecastWindowDays:   7,
+	HistoryRetentionDays: 90,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_balance_setting", &channelBalanceSetting)
+}
+
+func GetChannelBalanceSetting() *ChannelBalanceSetting {
+	return &channelBalanceSetting
+}
@@ -0,0 +1,141 @@
+package operation_setting
+
+import (
+	"one-api/setting"
+	"one-api/setting/config"
+	"strings"
+)
+
+const CurrencyUSD = "USD"
+
+type CurrencySetting struct {
+	// 默认展示币种
+	DefaultCurrency string `json:"default_currency"`
+	// 汇率表：1 USD 可兑换的目标币种数量
+	ExchangeRates map[string]float64 `json:"exchange_rates"`
+	// 汇率数据源，支持 http(s) 地址或本地文件路径（file:// 前缀可选），为空时仅使用手动配置的汇率
+	RateSourceUrl string `json:"rate_source_url"`
+	// 汇率自动同步间隔（分钟），0 表示不自动同步
+	RateSyncIntervalMinutes int `json:"rate_sync_interval_minutes"`
+	// 易支付结算币种
+	EpayCurrency string `json:"epay_currency"`
+	// Stripe 结算币种
+	StripeCurrency string `json:"stripe_currency"`
+}
+
+// 默认配置
+var currencySetting = CurrencySetting{
+	DefaultCurrency:         CurrencyUSD,
+	ExchangeRates:           map[string]float64{},
+	RateSourceUrl:           "",
+	RateSyncIntervalMinutes: 0,
+	EpayCurrency:            "CNY",
+	StripeCurrency:          CurrencyUSD,
+}
+
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"CNY": "¥",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"HKD": "HK$",
+	"TWD": "NT$",
+	"KRW": "₩",
+	"SGD": "S$",
+	"AUD": "A$",
+	"CAD": "C$",
+	"RUB": "₽",
+	"INR": "₹",
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("currency_setting", &currencySetting)
+}
+
+func GetCurrencySetting() *CurrencySetting {
+	return &currencySetting
+}
+
+// GetExchangeRate 返回 1 USD 可兑换的目标币种数量，未配置时返回 false
+// CNY 未在汇率表中配置时沿用 USDExchangeRate
+func GetExchangeRate(currency string) (float64, bool) {
+	currency = strings.ToUpper(currency)
+	if currency == "" || currency == CurrencyUSD {
+		return 1, true
+	}
+	if rate, ok := currencySetting.ExchangeRates[currency]; ok && rate > 0 {
+		return rate, true
+	}
+	if currency == "CNY" && setting.USDExchangeRate > 0 {
+		return setting.USDExchangeRate, true
+	}
+	return 0, false
+}
+
+func IsSupportedCurrency(currency string) bool {
+	_, ok := GetExchangeRate(currency)
+	return ok
+}
+
+// GetSupportedCurrencies 返回所有可用币种及其汇率
+func GetSupportedCurrencies() map[string]float64 {
+	currencies := map[string]float64{CurrencyUSD: 1}
+	if rate, ok := GetExchangeRate("CNY"); ok {
+		currencies["CNY"] = rate
+	}
+	for code, rate := range currencySetting.ExchangeRates {
+		if rate > 0 {
+			currencies[strings.ToUpper(code)] = rate
+		}
+	}
+	return currencies
+}
+
+func GetCurrencySymbol(currency string) string {
+	currency = strings.ToUpper(currency)
+	if symbol, ok := currencySymbols[currency]; ok {
+		return symbol
+	}
+	return currency + " "
+}
+
+// ResolveCurrency 返回可用的展示币种，优先使用用户偏好
+func ResolveCurrency(preferred string) string {
+	preferred = strings.ToUpper(strings.TrimSpace(preferred))
+	if preferred != "" && IsSupportedCurrency(preferred) {
+		return preferred
+	}
+	defaultCurrency := strings.ToUpper(currencySetting.DefaultCurrency)
+	if IsSupportedCurrency(defaultCurrency) {
+		return defaultCurrency
+	}
+	return CurrencyUSD
+}
+
+type CurrencyInfo struct {
+	Code   string  `json:"code"`
+	Symbol string  `json:"symbol"`
+	Rate   float64 `json:"rate"`
+}
+
+// GetCurrencyInfo 返回展示币种信息，币种不可用时回退到默认币种
+func GetCurrencyInfo(preferred string) CurrencyInfo {
+	code := ResolveCurrency(preferred)
+	rate, _ := GetExchangeRate(code)
+	return CurrencyInfo{
+		Code:   code,
+		Symbol: GetCurrencySymbol(code),
+		Rate:   rate,
+	}
+}
+
+// ConvertUSD 将美元金额换算为目标币种金额
+func ConvertUSD(amount float64, currency string) float64 {
+	rate, ok := GetExchangeRate(currency)
+	if !ok {
+		return amount
+	}
+	return amount * rate
+}
@@ -0,0 +1,50 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type ModelHealthSetting struct {
+	// 错误只与请求的模型有关（模型不存在、已下线等）时只禁用渠道的该模型，而不是整个渠道
+	Enabled bool `json:"enabled"`
+	// 定时测试时测试渠道的全部模型，关闭时只测试渠道的测试模型
+	TestAllModels bool `json:"test_all_models"`
+	// 自动禁用的模型重新测试的间隔（分钟），测试通过后自动启用，0 表示不重新测试
+	RetestIntervalMinutes int `json:"retest_interval_minutes"`
+	// 模型连续失败达到该次数才禁用，避免偶发错误误禁用；各节点的成功请求只清空本节点记录过的失败
+	DisableAfterFailures int `json:"disable_after_failures"`
+	// 判定为模型相关错误的关键词（不区分大小写）
+	ModelErrorKeywords []string `json:"model_error_keywords"`
+}
+
+// 默认配置
+var modelHealthSetting = ModelHealthSetting{
+	Enabled:               true,
+	TestAllModels:         false,
+	RetestIntervalMinutes: 30,
+	DisableAfterFailures:  3,
+	ModelErrorKeywords: []string{
+		"model_not_found",
+		"model not found",
+		"model does not exist",
+		"the model `",
+		"no such model",
+		"unknown model",
+		"invalid model",
+		"unsupported model",
+		"model is not supported",
+		"model not supported",
+		"deprecated",
+		"decommissioned",
+		"has been discontinued",
+		"模型不存在",
+		"模型已下线",
+	},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_health_setting", &modelHealthSetting)
+}
+
+func GetModelHealthSetting() *ModelHealthSetting {
+	return &modelHealthSetting
+}
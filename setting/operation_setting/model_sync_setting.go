@@ -0,0 +1,45 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type ModelSyncSetting struct {
+	// 定时同步开启了模型同步的渠道的上游模型列表
+	Enabled bool `json:"enabled"`
+	// 同一渠道两次同步的间隔（分钟）
+	IntervalMinutes int `json:"interval_minutes"`
+	// 自动添加上游新增的模型
+	AutoAdd bool `json:"auto_add"`
+	// 自动移除上游已不存在的模型
+	AutoRemove bool `json:"auto_remove"`
+	// 同步管理的模型范围（正则），包含规则为空时匹配全部，不在范围内的模型不会被添加或移除
+	IncludePatterns []string `json:"include_patterns"`
+	ExcludePatterns []string `json:"exclude_patterns"`
+	// 自动添加时要求模型已配置价格或倍率
+	RequirePrice bool `json:"require_price"`
+	// 连续多少次同步未在上游找到模型后才移除，避免上游接口偶发缺失
+	RemoveAfterMisses int `json:"remove_after_misses"`
+	// 同步报告保留天数，0 表示不清理
+	ReportRetentionDays int `json:"report_retention_days"`
+}
+
+// 默认配置
+var modelSyncSetting = ModelSyncSetting{
+	Enabled:             false,
+	IntervalMinutes:     1440,
+	AutoAdd:             true,
+	AutoRemove:          false,
+	IncludePatterns:     []string{},
+	ExcludePatterns:     []string{"^ft:"},
+	RequirePrice:        true,
+	RemoveAfterMisses:   2,
+	ReportRetentionDays: 30,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_sync_setting", &modelSyncSetting)
+}
+
+func GetModelSyncSetting() *ModelSyncSetting {
+	return &modelSyncSetting
+}
@@ -0,0 +1,31 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type RefundSetting struct {
+	// 是否允许用户对消费日志发起退款申请
+	UserRequestEnabled bool `json:"user_request_enabled"`
+	// 可发起退款申请的时间窗口（小时），0 表示不限制
+	RequestWindowHours int `json:"request_window_hours"`
+	// 补全内容为空时自动退款
+	AutoRefundEmptyCompletion bool `json:"auto_refund_empty_completion"`
+	// 流式请求在首个 token 返回前中断时自动退款
+	AutoRefundStreamNoFirstToken bool `json:"auto_refund_stream_no_first_token"`
+}
+
+// 默认配置
+var refundSetting = RefundSetting{
+	UserRequestEnabled:           true,
+	RequestWindowHours:           72,
+	AutoRefundEmptyCompletion:    false,
+	AutoRefundStreamNoFirstToken: false,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("refund_setting", &refundSetting)
+}
+
+func GetRefundSetting() *RefundSetting {
+	return &refundSetting
+}
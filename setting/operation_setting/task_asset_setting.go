@@ -0,0 +1,50 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	TaskAssetBackendLocal = "local"
+	TaskAssetBackendS3    = "s3"
+)
+
+type TaskAssetSetting struct {
+	// 是否在任务成功后下载生成的图片、音频与视频，并由网关提供访问
+	Enabled bool `json:"enabled"`
+	// 存储后端：local 或 s3（兼容 S3 协议的对象存储，访问密钥通过环境变量 ASSET_S3_ACCESS_KEY_ID / ASSET_S3_SECRET_ACCESS_KEY 配置）
+	Backend string `json:"backend"`
+	// local 后端的存储目录
+	LocalPath string `json:"local_path"`
+	// s3 后端的服务地址，如 https://s3.us-east-1.amazonaws.com 或 http://127.0.0.1:9000
+	S3Endpoint string `json:"s3_endpoint"`
+	S3Region   string `json:"s3_region"`
+	S3Bucket   string `json:"s3_bucket"`
+	// 签名访问地址的有效期（秒）
+	UrlExpireSeconds int `json:"url_expire_seconds"`
+	// 默认保留天数，0 表示永久保留，可按用户单独设置
+	RetentionDays int `json:"retention_days"`
+	// 单个文件大小上限（MB），超过时保留上游地址
+	MaxFileSizeMB int `json:"max_file_size_mb"`
+	// 每 MB 存储扣除的额度，0 表示不计费
+	QuotaPerMB int `json:"quota_per_mb"`
+}
+
+// 默认配置
+var taskAssetSetting = TaskAssetSetting{
+	Enabled:          false,
+	Backend:          TaskAssetBackendLocal,
+	LocalPath:        "./data/assets",
+	S3Region:         "us-east-1",
+	UrlExpireSeconds: 3600,
+	RetentionDays:    7,
+	MaxFileSizeMB:    200,
+	QuotaPerMB:       0,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("task_asset_setting", &taskAssetSetting)
+}
+
+func GetTaskAssetSetting() *TaskAssetSetting {
+	return &taskAssetSetting
+}
@@ -0,0 +1,58 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type TaskPollSetting struct {
+	// 提交后首次查询的间隔（秒），之后每次翻倍
+	InitialIntervalSeconds int `json:"initial_interval_seconds"`
+	// 查询间隔上限（秒）
+	MaxIntervalSeconds int `json:"max_interval_seconds"`
+	// 任务从提交起的最长处理时间（分钟），超时自动标记失败并退还额度，0 为不限制
+	DeadlineMinutes int `json:"deadline_minutes"`
+	// 按平台覆盖最长处理时间（分钟），如 {"suno": 20, "kling": 90}
+	PlatformDeadlineMinutes map[string]int `json:"platform_deadline_minutes"`
+	// 每轮最多领取的任务数
+	BatchSize int `json:"batch_size"`
+	// 节点领取任务后的租约时间（秒），仅在启用 Redis 时多节点共同轮询
+	LeaseSeconds int `json:"lease_seconds"`
+	// 是否请求上游主动推送任务状态（Kling callback_url、Midjourney notifyHook），推送的任务只按最大间隔兜底查询
+	UpstreamCallbackEnabled bool `json:"upstream_callback_enabled"`
+}
+
+// 默认配置
+var taskPollSetting = TaskPollSetting{
+	InitialIntervalSeconds:  5,
+	MaxIntervalSeconds:      120,
+	DeadlineMinutes:         60,
+	PlatformDeadlineMinutes: map[string]int{},
+	BatchSize:               200,
+	LeaseSeconds:            60,
+	UpstreamCallbackEnabled: true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("task_poll_setting", &taskPollSetting)
+}
+
+func GetTaskPollSetting() *TaskPollSetting {
+	return &taskPollSetting
+}
+
+// GetTaskPollInterval 返回第 pollCount 次查询后的等待时间（秒）
+func (s *TaskPollSetting) GetTaskPollInterval(pollCount int) int64 {
+	interval := int64(max(s.InitialIntervalSeconds, 1))
+	maxInterval := int64(max(s.MaxIntervalSeconds, s.InitialIntervalSeconds, 1))
+	for i := 0; i < pollCount && interval < maxInterval; i++ {
+		interval *= 2
+	}
+	return min(interval, maxInterval)
+}
+
+// GetDeadlineMinutes 返回平台的最长处理时间（分钟），未单独配置时使用 DeadlineMinutes
+func (s *TaskPollSetting) GetDeadlineMinutes(platform string) int {
+	if minutes, ok := s.PlatformDeadlineMinutes[platform]; ok {
+		return minutes
+	}
+	return s.DeadlineMinutes
+}
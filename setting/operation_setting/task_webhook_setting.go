@@ -0,0 +1,34 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type TaskWebhookSetting struct {
+	// 是否推送异步任务状态变化
+	Enabled bool `json:"enabled"`
+	// 单条回调的最大投递次数（含首次），超过后标记为失败
+	MaxAttempts int `json:"max_attempts"`
+	// 首次重试的等待时间（秒），之后每次翻倍，最长 1 小时
+	RetryBaseSeconds int `json:"retry_base_seconds"`
+	// 是否允许回调内网地址
+	AllowPrivateAddress bool `json:"allow_private_address"`
+	// 投递记录保留天数，0 表示永久保留
+	RetentionDays int `json:"retention_days"`
+}
+
+// 默认配置
+var taskWebhookSetting = TaskWebhookSetting{
+	Enabled:             true,
+	MaxAttempts:         6,
+	RetryBaseSeconds:    30,
+	AllowPrivateAddress: false,
+	RetentionDays:       7,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("task_webhook_setting", &taskWebhookSetting)
+}
+
+func GetTaskWebhookSetting() *TaskWebhookSetting {
+	return &taskWebhookSetting
+}
@@ -0,0 +1,25 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type TokenSetting struct {
+	// 令牌轮换后旧令牌继续可用的宽限期（小时）
+	RotationGraceHours int `json:"rotation_grace_hours"`
+	// 令牌过期前多少天通知所有者，0 表示不提醒
+	ExpiryReminderDays int `json:"expiry_reminder_days"`
+}
+
+// 默认配置
+var tokenSetting = TokenSetting{
+	RotationGraceHours: 24,
+	ExpiryReminderDays: 7,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("token_setting", &tokenSetting)
+}
+
+func GetTokenSetting() *TokenSetting {
+	return &tokenSetting
+}
@@ -0,0 +1,34 @@
+package payment_setting
+
+import "one-api/setting/config"
+
+type AlipaySetting struct {
+	Enabled bool   `json:"enabled"`
+	AppId   string `json:"app_id"`
+	// 应用私钥（PKCS1 或 PKCS8，支持 PEM 或去掉头尾的 base64）
+	PrivateKey string `json:"private_key"`
+	// 支付宝公钥，用于验证异步通知签名
+	AlipayPublicKey string `json:"alipay_public_key"`
+	Gateway         string `json:"gateway"`
+	// 下单方式：precreate 当面付扫码，page 电脑网站支付跳转
+	Mode string `json:"mode"`
+	// 1 USD 额度的售价（CNY），为 0 时按汇率表换算
+	UnitPrice float64 `json:"unit_price"`
+	MinTopUp  int     `json:"min_top_up"`
+}
+
+// 默认配置
+var alipaySetting = AlipaySetting{
+	Gateway:  "https://openapi.alipay.com/gateway.do",
+	Mode:     "precreate",
+	MinTopUp: 1,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("alipay_setting", &alipaySetting)
+}
+
+func GetAlipaySetting() *AlipaySetting {
+	return &alipaySetting
+}
@@ -0,0 +1,31 @@
+package payment_setting
+
+import "one-api/setting/config"
+
+type BankTransferSetting struct {
+	Enabled       bool   `json:"enabled"`
+	BankName      string `json:"bank_name"`
+	AccountName   string `json:"account_name"`
+	AccountNumber string `json:"account_number"`
+	// 展示给用户的补充说明
+	Instructions string `json:"instructions"`
+	Currency     string `json:"currency"`
+	// 1 USD 额度的售价（支付币种），为 0 时按汇率表换算
+	UnitPrice float64 `json:"unit_price"`
+	MinTopUp  int     `json:"min_top_up"`
+}
+
+// 默认配置
+var bankTransferSetting = BankTransferSetting{
+	Currency: "CNY",
+	MinTopUp: 1,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("bank_transfer_setting", &bankTransferSetting)
+}
+
+func GetBankTransferSetting() *BankTransferSetting {
+	return &bankTransferSetting
+}
@@ -0,0 +1,32 @@
+package payment_setting
+
+import "one-api/setting/config"
+
+type PayPalSetting struct {
+	Enabled      bool   `json:"enabled"`
+	Sandbox      bool   `json:"sandbox"`
+	ClientId     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	// Webhook ID，用于校验回调签名
+	WebhookId string `json:"webhook_id"`
+	Currency  string `json:"currency"`
+	// 1 USD 额度的售价（支付币种），为 0 时按汇率表换算
+	UnitPrice float64 `json:"unit_price"`
+	MinTopUp  int     `json:"min_top_up"`
+}
+
+// 默认配置
+var payPalSetting = PayPalSetting{
+	Currency:  "USD",
+	UnitPrice: 1,
+	MinTopUp:  1,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("paypal_setting", &payPalSetting)
+}
+
+func GetPayPalSetting() *PayPalSetting {
+	return &payPalSetting
+}
@@ -0,0 +1,33 @@
+package payment_setting
+
+import "one-api/setting/config"
+
+type WeChatPaySetting struct {
+	Enabled bool   `json:"enabled"`
+	AppId   string `json:"app_id"`
+	MchId   string `json:"mch_id"`
+	// 商户 API 证书序列号与私钥
+	MchCertSerialNo string `json:"mch_cert_serial_no"`
+	MchPrivateKey   string `json:"mch_private_key"`
+	// APIv3 密钥，用于解密回调通知
+	ApiV3Key string `json:"api_v3_key"`
+	// 微信支付公钥，用于验证回调签名
+	PlatformPublicKey string `json:"platform_public_key"`
+	// 1 USD 额度的售价（CNY），为 0 时按汇率表换算
+	UnitPrice float64 `json:"unit_price"`
+	MinTopUp  int     `json:"min_top_up"`
+}
+
+// 默认配置
+var weChatPaySetting = WeChatPaySetting{
+	MinTopUp: 1,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("wechat_pay_setting", &weChatPaySetting)
+}
+
+func GetWeChatPaySetting() *WeChatPaySetting {
+	return &weChatPaySetting
+}
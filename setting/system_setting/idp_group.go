@@ -0,0 +1,31 @@
+package system_setting
+
+import "one-api/setting/config"
+
+// IdpGroupMapping 将身份提供商的用户组映射到网关分组，Quota 大于 0 时分配到该分组会将剩余额度补足至该值
+type IdpGroupMapping struct {
+	IdpGroup string `json:"idp_group"`
+	Group    string `json:"group"`
+	Quota    int    `json:"quota"`
+}
+
+type IdpGroupSettings struct {
+	// 按顺序匹配，用户所属的第一个命中的 IdP 组决定网关分组
+	Mappings []IdpGroupMapping `json:"mappings"`
+	// 用户不属于任何已映射的 IdP 组时使用的分组，为空时保持不变
+	DefaultGroup string `json:"default_group"`
+}
+
+// 默认配置
+var defaultIdpGroupSettings = IdpGroupSettings{
+	Mappings: []IdpGroupMapping{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("idp_group", &defaultIdpGroupSettings)
+}
+
+func GetIdpGroupSettings() *IdpGroupSettings {
+	return &defaultIdpGroupSettings
+}
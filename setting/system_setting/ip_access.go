@@ -0,0 +1,31 @@
+package system_setting
+
+import "one-api/setting/config"
+
+// IpAccessSettings 全局 IP 访问规则，作用于所有使用令牌的 API 请求
+type IpAccessSettings struct {
+	// 允许访问的 IP 或 CIDR，以逗号或换行分隔，为空表示不限制
+	AllowIps string `json:"allow_ips"`
+	// 禁止访问的 IP 或 CIDR，优先于所有白名单
+	DenyIps string `json:"deny_ips"`
+	// 本地 IP 国家数据库文件（CSV：起始 IP,结束 IP,国家代码），为空时不启用国家规则
+	GeoIpDbPath string `json:"geoip_db_path"`
+	// 允许访问的国家代码（ISO 3166-1 alpha-2），为空表示不限制
+	AllowCountries []string `json:"allow_countries"`
+	DenyCountries  []string `json:"deny_countries"`
+}
+
+// 默认配置
+var defaultIpAccessSettings = IpAccessSettings{
+	AllowCountries: []string{},
+	DenyCountries:  []string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("ip_access", &defaultIpAccessSettings)
+}
+
+func GetIpAccessSettings() *IpAccessSettings {
+	return &defaultIpAccessSettings
+}
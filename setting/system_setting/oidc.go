@@ -10,6 +10,8 @@ type OIDCSettings struct {
 	AuthorizationEndpoint string `json:"authorization_endpoint"`
 	TokenEndpoint         string `json:"token_endpoint"`
 	UserInfoEndpoint      string `json:"user_info_endpoint"`
+	// 用户信息中表示用户组的字段，支持以 . 分隔的嵌套字段（如 realm_access.roles），为空时不同步分组
+	GroupClaim string `json:"group_claim"`
 }
 
 // 默认配置
@@ -0,0 +1,28 @@
+package system_setting
+
+import "one-api/setting/config"
+
+type PasskeySettings struct {
+	Enabled bool `json:"enabled"`
+	// 依赖方 ID，一般为站点域名，为空时取服务器地址的域名
+	RPID          string `json:"rp_id"`
+	RPDisplayName string `json:"rp_display_name"`
+	// 允许的来源，以逗号分隔，为空时使用服务器地址
+	Origins string `json:"origins"`
+	// 用户验证要求：required / preferred / discouraged
+	UserVerification string `json:"user_verification"`
+}
+
+// 默认配置
+var defaultPasskeySettings = PasskeySettings{
+	UserVerification: "preferred",
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("passkey", &defaultPasskeySettings)
+}
+
+func GetPasskeySettings() *PasskeySettings {
+	return &defaultPasskeySettings
+}
@@ -0,0 +1,21 @@
+package system_setting
+
+import "one-api/setting/config"
+
+type ScimSettings struct {
+	Enabled bool `json:"enabled"`
+	// 身份提供商调用 SCIM 接口使用的 Bearer Token 的 SHA-256 哈希，通过 /api/option/scim_token 生成
+	TokenHash string `json:"token_hash"`
+}
+
+// 默认配置
+var defaultScimSettings = ScimSettings{}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("scim", &defaultScimSettings)
+}
+
+func GetScimSettings() *ScimSettings {
+	return &defaultScimSettings
+}
@@ -0,0 +1,22 @@
+package system_setting
+
+import "one-api/setting/config"
+
+type TwoFactorSettings struct {
+	// 要求管理员与超级管理员启用两步验证（TOTP 或通行密钥），未启用时无法访问管理接口
+	EnforceForAdmin bool `json:"enforce_for_admin"`
+	// 认证器应用中显示的发行方名称，为空时使用系统名称
+	Issuer string `json:"issuer"`
+}
+
+// 默认配置
+var defaultTwoFactorSettings = TwoFactorSettings{}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("two_factor", &defaultTwoFactorSettings)
+}
+
+func GetTwoFactorSettings() *TwoFactorSettings {
+	return &defaultTwoFactorSettings
+}
@@ -106,6 +106,12 @@ const LogsTable = () => {
             {t('错误')}
           </Tag>
         );
+      case 6:
+        return (
+          <Tag color='teal' shape='circle'>
+            {t('退款')}
+          </Tag>
+        );
       default:
         return (
           <Tag color='grey' shape='circle'>
@@ -1414,6 +1420,9 @@ const LogsTable = () => {
                       <Form.Select.Option value='5'>
                         {t('错误')}
                       </Form.Select.Option>
+                      <Form.Select.Option value='6'>
+                        {t('退款')}
+                      </Form.Select.Option>
                     </Form.Select>
                   </div>
 